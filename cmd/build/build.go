@@ -15,6 +15,7 @@ import (
 	"github.com/spf13/cobra"
 
 	root "github.com/PRASSamin/prasmoid/cmd"
+	"github.com/PRASSamin/prasmoid/internal/runtime"
 )
 
 var buildOutputDir string
@@ -22,6 +23,8 @@ var buildOutputDir string
 func init() {
 	BuildCmd.Flags().StringVarP(&buildOutputDir, "output", "o", "./build", "Output folder")
 	root.RootCmd.AddCommand(BuildCmd)
+
+	runtime.Project.Build = buildPlasmoidTo
 }
 
 // buildCmd represents the build command
@@ -37,8 +40,14 @@ var BuildCmd = &cobra.Command{
 }
 
 func BuildPlasmoid() error {
+	_, err := buildPlasmoidTo(buildOutputDir)
+	return err
+}
+
+// buildPlasmoidTo builds the project into outputDir and returns the path of the archive.
+func buildPlasmoidTo(outputDir string) (string, error) {
 	if !utilsIsValidPlasmoid() {
-		return fmt.Errorf("current directory is not a valid plasmoid")
+		return "", fmt.Errorf("current directory is not a valid plasmoid")
 	}
 
 	// compile translations
@@ -55,20 +64,20 @@ func BuildPlasmoid() error {
 	plasmoidID, ierr := utilsGetDataFromMetadata("Id")
 	version, verr := utilsGetDataFromMetadata("Version")
 	if ierr != nil || verr != nil {
-		return fmt.Errorf("invalid metadata: %v", fmt.Sprintf("%v or %v", ierr, verr))
+		return "", fmt.Errorf("invalid metadata: %v", fmt.Sprintf("%v or %v", ierr, verr))
 	}
 	zipFileName := plasmoidID.(string) + "-" + version.(string) + ".plasmoid"
 
-	if err := osRemoveAll(buildOutputDir); err != nil {
-		return fmt.Errorf("failed to clean build dir: %v", err)
+	if err := osRemoveAll(outputDir); err != nil {
+		return "", fmt.Errorf("failed to clean build dir: %v", err)
 	}
-	if err := osMkdirAll(buildOutputDir, 0755); err != nil {
-		return fmt.Errorf("failed to create build dir: %v", err)
+	if err := osMkdirAll(outputDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create build dir: %v", err)
 	}
 
-	outFile, err := osCreate(filepath.Join(buildOutputDir, zipFileName))
+	outFile, err := osCreate(filepath.Join(outputDir, zipFileName))
 	if err != nil {
-		return fmt.Errorf("failed to create zip file: %v", err)
+		return "", fmt.Errorf("failed to create zip file: %v", err)
 	}
 	defer func() {
 		if err := outFile.Close(); err != nil {
@@ -85,16 +94,17 @@ func BuildPlasmoid() error {
 
 	// Copy metadata.json
 	if err := AddFileToZip(zipWriter, "metadata.json"); err != nil {
-		return fmt.Errorf("error adding metadata.json: %v", err)
+		return "", fmt.Errorf("error adding metadata.json: %v", err)
 	}
 
 	// Copy contents directory recursively
 	if err := AddDirToZip(zipWriter, "contents"); err != nil {
-		return fmt.Errorf("error adding contents/: %v", err)
+		return "", fmt.Errorf("error adding contents/: %v", err)
 	}
 
-	color.Green("Build complete: %s", color.YellowString(filepath.Join(buildOutputDir, zipFileName)))
-	return nil
+	archive := filepath.Join(outputDir, zipFileName)
+	color.Green("Build complete: %s", color.YellowString(archive))
+	return archive, nil
 }
 
 var AddFileToZip = func(zipWriter *zip.Writer, filename string) error {
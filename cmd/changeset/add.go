@@ -15,6 +15,8 @@ import (
 	"github.com/AlecAivazis/survey/v2"
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
+
+	"github.com/PRASSamin/prasmoid/internal/runtime"
 )
 
 var ChangesFolder string = ".changes"
@@ -30,6 +32,8 @@ func init() {
 	changesetAddCmd.Flags().StringP("summary", "s", "", "Changelog summary (optional)")
 	changesetAddCmd.Flags().BoolP("apply", "a", false, "Apply changeset after creation")
 	changesetCmd.AddCommand(changesetAddCmd)
+
+	runtime.Project.ChangesetAdd = addChangeset
 }
 
 var changesetAddCmd = &cobra.Command{
@@ -115,10 +119,21 @@ var AddChangeset = func(bump string, summary string, apply bool) {
 		}
 	}
 
+	if _, err := WriteChangeset(fmt.Sprint(id), bump, next, summary); err != nil {
+		fmt.Println(color.RedString(err.Error()))
+		return
+	}
+
+	if apply {
+		ApplyChanges()
+	}
+}
+
+// WriteChangeset writes a changeset file to ChangesFolder and returns its path.
+var WriteChangeset = func(id, bump, next, summary string) (string, error) {
 	// Create changes dir if not exist
 	if err := osMkdirAll(ChangesFolder, 0755); err != nil {
-		fmt.Println(color.RedString("Failed to create changes directory: %v", err))
-		return
+		return "", fmt.Errorf("failed to create changes directory: %v", err)
 	}
 
 	// Generate filename & content
@@ -139,13 +154,40 @@ date: %s
 
 	// Write file
 	if err := osWriteFile(filename, []byte(content), 0644); err != nil {
-		fmt.Println(color.RedString("Failed to write changeset: %v", err))
-		return
+		return "", fmt.Errorf("failed to write changeset: %v", err)
+	}
+	return filename, nil
+}
+
+// addChangeset creates a changeset without prompting, for scripts.
+func addChangeset(bump, summary string, apply bool) (string, error) {
+	if !utilsIsValidPlasmoid() {
+		return "", fmt.Errorf("current directory is not a valid plasmoid")
+	}
+	if !validBumps[bump] {
+		return "", fmt.Errorf("bump must be one of patch, minor or major, got %q", bump)
+	}
+	if strings.TrimSpace(summary) == "" {
+		return "", fmt.Errorf("summary is required")
+	}
+	version, verr := utilsGetDataFromMetadata("Version")
+	id, ierr := utilsGetDataFromMetadata("Id")
+	if verr != nil || ierr != nil {
+		return "", fmt.Errorf("invalid metadata: %v or %v", ierr, verr)
+	}
+	next, err := GetNextVersion(fmt.Sprint(version), bump)
+	if err != nil {
+		return "", fmt.Errorf("failed to compute next version for %s: %v", bump, err)
 	}
 
+	filename, err := WriteChangeset(fmt.Sprint(id), bump, next, summary)
+	if err != nil {
+		return "", err
+	}
 	if apply {
-		ApplyChanges()
+		return filename, ApplyChangesets()
 	}
+	return filename, nil
 }
 
 var GetNextVersion = func(version string, bump string) (string, error) {
@@ -263,3 +263,41 @@ func TestAddChangeset(t *testing.T) {
 		assert.Contains(t, output, "Current directory is not a valid plasmoid")
 	})
 }
+
+func TestAddChangesetFromScript(t *testing.T) {
+	t.Cleanup(func() {
+		utilsIsValidPlasmoid = utils.IsValidPlasmoid
+		utilsGetDataFromMetadata = utils.GetDataFromMetadata
+		osMkdirAll = os.MkdirAll
+		osWriteFile = os.WriteFile
+	})
+	utilsIsValidPlasmoid = func() bool { return true }
+	utilsGetDataFromMetadata = func(key string) (interface{}, error) {
+		if key == "Id" {
+			return "org.kde.test", nil
+		}
+		return "1.2.3", nil
+	}
+	osMkdirAll = func(path string, perm os.FileMode) error { return nil }
+	var writtenFile string
+	osWriteFile = func(name string, data []byte, perm os.FileMode) error {
+		writtenFile = string(data)
+		return nil
+	}
+
+	t.Run("writes the changeset without prompting", func(t *testing.T) {
+		file, err := addChangeset("minor", "New feature", false)
+		require.NoError(t, err)
+		assert.True(t, strings.HasSuffix(file, "-minor.mdx"))
+		assert.Contains(t, writtenFile, "next: 1.3.0")
+		assert.Contains(t, writtenFile, "New feature")
+	})
+
+	t.Run("requires a valid bump and a summary", func(t *testing.T) {
+		_, err := addChangeset("huge", "New feature", false)
+		assert.ErrorContains(t, err, "bump must be one of patch, minor or major")
+
+		_, err = addChangeset("patch", "  ", false)
+		assert.ErrorContains(t, err, "summary is required")
+	})
+}
@@ -13,10 +13,16 @@ import (
 	"github.com/adrg/frontmatter"
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
+
+	"github.com/PRASSamin/prasmoid/internal/runtime"
 )
 
 func init() {
 	changesetCmd.AddCommand(changesetApplyCmd)
+
+	runtime.Project.ChangesetApply = func() error {
+		return ApplyChangesets()
+	}
 }
 
 // ChangesetMeta represents the metadata for a changeset
@@ -37,6 +43,12 @@ var changesetApplyCmd = &cobra.Command{
 }
 
 var ApplyChanges = func() {
+	_ = ApplyChangesets()
+}
+
+// ApplyChangesets applies every changeset, reporting progress as it goes. It
+// keeps going past changesets that fail, and returns an error if any did.
+var ApplyChangesets = func() error {
 	changesetFiles := []string{}
 	err := filepathWalk(".changes", func(path string, info os.FileInfo, err error) error {
 		if err != nil {
@@ -52,45 +64,55 @@ var ApplyChanges = func() {
 	})
 	if err != nil {
 		fmt.Println(color.RedString("Failed to walk changes directory: %v", err))
-		return
+		return fmt.Errorf("failed to walk changes directory: %v", err)
 	}
 
 	if len(changesetFiles) == 0 {
 		fmt.Println(color.YellowString("No changeset files found."))
 		fmt.Println(color.CyanString("run `prasmoid changeset add` to create a changeset."))
-		return
+		return nil
 	}
 
+	failed := 0
 	for _, file := range changesetFiles {
 		data, err := osReadFile(file)
 		if err != nil {
 			fmt.Println(color.RedString("Failed to read changeset file %s: %v", file, err))
+			failed++
 			continue
 		}
 
 		meta, body, err := matterParse(data)
 		if err != nil {
 			fmt.Println(color.RedString("Failed to parse %s: %v", file, err))
+			failed++
 			continue
 		}
 
 		if err := utilsUpdateMetadata("Version", meta.Next); err != nil {
 			fmt.Println(color.RedString("Metadata update failed in %s: %v", file, err))
+			failed++
 			continue
 		}
 
 		if err := UpdateChangelog(meta.Next, meta.Date, body); err != nil {
 			fmt.Println(color.RedString("Changelog update failed in %s: %v", file, err))
+			failed++
 			continue
 		}
 
 		if err := osRemove(file); err != nil {
 			fmt.Println(color.RedString("Failed to remove changeset file %s: %v", file, err))
+			failed++
 			continue
 		}
 	}
 
+	if failed > 0 {
+		return fmt.Errorf("%d of %d changesets could not be applied", failed, len(changesetFiles))
+	}
 	fmt.Println(color.GreenString("All changesets applied successfully!"))
+	return nil
 }
 
 var matterParse = func(data []byte) (ChangesetMeta, string, error) {
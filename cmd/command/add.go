@@ -17,6 +17,18 @@ import (
 
 var commandTemplates = map[string]string{
 	"js": consts.JS_COMMAND_TEMPLATE,
+	"ts": consts.TS_COMMAND_TEMPLATE,
+}
+
+// commandExists checks whether a command with the given name exists in any supported language
+func commandExists(name string) bool {
+	baseName := filepath.Join(root.ConfigRC.Commands.Dir, name)
+	for lang := range commandTemplates {
+		if _, err := osStat(baseName + "." + lang); err == nil {
+			return true
+		}
+	}
+	return false
 }
 
 func commandNameValidator(ans interface{}) error {
@@ -29,8 +41,7 @@ func commandNameValidator(ans interface{}) error {
 		return fmt.Errorf("invalid characters in command name")
 	}
 
-	baseName := filepath.Join(root.ConfigRC.Commands.Dir, name)
-	if _, err := osStat(baseName + ".js"); err == nil {
+	if commandExists(name) {
 		return fmt.Errorf("command already exists")
 	}
 	return nil
@@ -38,6 +49,7 @@ func commandNameValidator(ans interface{}) error {
 
 func init() {
 	commandsAddCmd.Flags().StringP("name", "n", "", "Command name")
+	commandsAddCmd.Flags().StringP("lang", "l", "js", "Command language (js|ts)")
 	commandsCmd.AddCommand(commandsAddCmd)
 }
 
@@ -47,11 +59,23 @@ var commandsAddCmd = &cobra.Command{
 	Long:  "Add a custom command to the project.",
 	Run: func(cmd *cobra.Command, args []string) {
 		commandName, _ := cmd.Flags().GetString("name")
-		AddCommand(commandName)
+		lang, _ := cmd.Flags().GetString("lang")
+		AddCommand(commandName, lang)
 	},
 }
 
-func AddCommand(commandName string) {
+// AddCommand scaffolds a new custom command. The optional language is "js" (default) or "ts".
+func AddCommand(commandName string, langOpt ...string) {
+	lang := "js"
+	if len(langOpt) > 0 && langOpt[0] != "" {
+		lang = langOpt[0]
+	}
+	template, ok := commandTemplates[lang]
+	if !ok {
+		fmt.Println(color.RedString("Unsupported command language: %s", lang))
+		return
+	}
+
 	// Ask for command name
 	if strings.TrimSpace(commandName) == "" || invalidChars.MatchString(commandName) {
 		namePrompt := &survey.Input{
@@ -63,12 +87,9 @@ func AddCommand(commandName string) {
 		}
 	}
 
-	if !invalidChars.MatchString(commandName) {
-		baseName := filepath.Join(root.ConfigRC.Commands.Dir, commandName)
-		if _, err := osStat(baseName + ".js"); err == nil {
-			fmt.Println(color.RedString("Command already exists"))
-			return
-		}
+	if !invalidChars.MatchString(commandName) && commandExists(commandName) {
+		fmt.Println(color.RedString("Command already exists"))
+		return
 	}
 
 	if strings.TrimSpace(root.ConfigRC.Commands.Dir) != "" {
@@ -79,7 +100,7 @@ func AddCommand(commandName string) {
 		}
 	}
 
-	commandFile := commandName + ".js"
+	commandFile := commandName + "." + lang
 	filePath := filepath.Join(root.ConfigRC.Commands.Dir, commandFile)
 
 	// Absolute path to command file
@@ -96,7 +117,7 @@ func AddCommand(commandName string) {
 		return
 	}
 
-	templateFilled := fmt.Sprintf(commandTemplates["js"], relPath, commandName)
+	templateFilled := fmt.Sprintf(template, relPath, commandName)
 
 	err = osWriteFile(filePath, []byte(templateFilled), 0644)
 	if err != nil {
@@ -167,6 +167,46 @@ func TestAddCommand(t *testing.T) {
 		assert.Contains(t, output, "Successfully created my-command command at")
 	})
 
+	t.Run("success: create new typescript command", func(t *testing.T) {
+		// Arrange
+		var writtenContent []byte
+		var writtenPath string
+
+		osStat = func(name string) (fs.FileInfo, error) { return nil, os.ErrNotExist }
+		osMkdirAll = func(path string, perm fs.FileMode) error { return nil }
+		osWriteFile = func(name string, data []byte, perm fs.FileMode) error {
+			writtenPath = name
+			writtenContent = data
+			return nil
+		}
+		osGetwd = func() (string, error) { return "/project", nil }
+		filepathAbs = func(path string) (string, error) { return path, nil }
+		filepathRel = func(basepath, targpath string) (string, error) { return "../prasmoid.d.ts", nil }
+		buf, restore := captureOutput()
+
+		// Act
+		AddCommand("typed", "ts")
+
+		// Assert
+		restore()
+		expectedContent := fmt.Sprintf(consts.TS_COMMAND_TEMPLATE, "../prasmoid.d.ts", "typed")
+		assert.Equal(t, filepath.Join("test_commands", "typed.ts"), writtenPath)
+		assert.Equal(t, expectedContent, string(writtenContent))
+		assert.Contains(t, buf.String(), "Successfully created typed command at")
+	})
+
+	t.Run("error: unsupported language", func(t *testing.T) {
+		// Arrange
+		buf, restore := captureOutput()
+
+		// Act
+		AddCommand("any-command", "py")
+
+		// Assert
+		restore()
+		assert.Contains(t, buf.String(), "Unsupported command language: py")
+	})
+
 	t.Run("error: command already exists", func(t *testing.T) {
 		// Arrange
 		commandName := "existing-command"
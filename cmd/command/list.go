@@ -0,0 +1,66 @@
+/*
+Copyright 2025 PRAS
+*/
+package command
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+
+	root "github.com/PRASSamin/prasmoid/cmd"
+	"github.com/PRASSamin/prasmoid/cmd/extendcli"
+)
+
+func init() {
+	commandsCmd.AddCommand(commandsListCmd)
+}
+
+var commandsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List custom commands and where they come from",
+	Long: `List the custom commands of the project, of commands.sources and of the user's commands directory.
+
+When several sources define a command of the same name, the project wins over commands.sources,
+which win over the user's commands. The commands that lose are listed as shadowed.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ListCommands(os.Stdout, extendcli.RegisteredCommands())
+		ListUnfetched(os.Stdout, extendcli.Sources(root.ConfigRC))
+	},
+}
+
+// ListUnfetched points out the git sources whose commands aren't available
+// because they were never cloned.
+func ListUnfetched(out io.Writer, sources []extendcli.Source) {
+	for _, source := range sources {
+		if !source.Fetched() {
+			fmt.Fprintln(out, color.YellowString("%s is not fetched yet, run `prasmoid command update` to get its commands.", source.Origin))
+		}
+	}
+}
+
+// ListCommands prints the discovered custom commands, with their source and script.
+func ListCommands(out io.Writer, commands []extendcli.Registered) {
+	if len(commands) == 0 {
+		fmt.Fprintln(out, color.YellowString("No custom commands found."))
+		return
+	}
+
+	var nameWidth, originWidth int
+	for _, c := range commands {
+		nameWidth = max(nameWidth, len(c.Name))
+		originWidth = max(originWidth, len(c.Origin))
+	}
+	for _, c := range commands {
+		name := fmt.Sprintf("%-*s", nameWidth, c.Name)
+		rest := fmt.Sprintf("  %-*s  %s", originWidth, c.Origin, c.Path)
+		if c.ShadowedBy != "" {
+			fmt.Fprintln(out, color.HiBlackString("%s%s (shadowed by %s)", name, rest, c.ShadowedBy))
+			continue
+		}
+		fmt.Fprintln(out, color.GreenString("%s", name)+rest)
+	}
+}
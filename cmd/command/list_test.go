@@ -0,0 +1,81 @@
+package command
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fatih/color"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/PRASSamin/prasmoid/cmd/extendcli"
+)
+
+func TestListCommands(t *testing.T) {
+	noColor := color.NoColor
+	t.Cleanup(func() { color.NoColor = noColor })
+	color.NoColor = true
+
+	t.Run("lists commands with their source", func(t *testing.T) {
+		var out bytes.Buffer
+		ListCommands(&out, []extendcli.Registered{
+			{Name: "deploy", Path: ".prasmoid/commands/deploy.js", Origin: extendcli.OriginProject},
+			{Name: "deploy", Path: "/home/me/.config/prasmoid/commands/deploy.js", Origin: extendcli.OriginUser, ShadowedBy: ".prasmoid/commands/deploy.js"},
+			{Name: "lint", Path: "/cache/lint.js", Origin: "https://github.com/team/commands.git"},
+		})
+		assert.Equal(t, ""+
+			"deploy  project                               .prasmoid/commands/deploy.js\n"+
+			"deploy  user                                  /home/me/.config/prasmoid/commands/deploy.js (shadowed by .prasmoid/commands/deploy.js)\n"+
+			"lint    https://github.com/team/commands.git  /cache/lint.js\n", out.String())
+	})
+
+	t.Run("no commands", func(t *testing.T) {
+		var out bytes.Buffer
+		ListCommands(&out, nil)
+		assert.Contains(t, out.String(), "No custom commands found.")
+	})
+
+	t.Run("git sources that are not fetched", func(t *testing.T) {
+		var out bytes.Buffer
+		ListUnfetched(&out, []extendcli.Source{
+			{Origin: extendcli.OriginProject, Dir: filepath.Join(t.TempDir(), "missing")},
+			{Origin: "https://github.com/team/commands.git", Git: "https://github.com/team/commands.git", Dir: filepath.Join(t.TempDir(), "commands")},
+			{Origin: "https://github.com/team/tools.git", Git: "https://github.com/team/tools.git", Dir: t.TempDir()},
+		})
+		assert.Equal(t, "https://github.com/team/commands.git is not fetched yet, run `prasmoid command update` to get its commands.\n", out.String())
+	})
+}
+
+func TestUpdateSources(t *testing.T) {
+	captureOutput := func(fn func()) string {
+		oldStdout := os.Stdout
+		r, w, _ := os.Pipe()
+		os.Stdout = w
+		fn()
+		_ = w.Close()
+		os.Stdout = oldStdout
+		var buf bytes.Buffer
+		_, _ = io.Copy(&buf, r)
+		return buf.String()
+	}
+
+	t.Run("without git sources", func(t *testing.T) {
+		var ok bool
+		out := captureOutput(func() { ok = UpdateSources([]extendcli.Source{{Origin: extendcli.OriginProject, Dir: "."}}) })
+		assert.True(t, ok)
+		assert.Contains(t, out, "No git repositories in commands.sources.")
+	})
+
+	t.Run("failing git sources", func(t *testing.T) {
+		missing := "file://" + filepath.Join(t.TempDir(), "missing")
+		source := extendcli.Source{Origin: missing, Git: missing, Dir: filepath.Join(t.TempDir(), "clone")}
+		var ok bool
+		out := captureOutput(func() { ok = UpdateSources([]extendcli.Source{source}) })
+		assert.False(t, ok)
+		assert.Contains(t, out, "Failed to update "+missing)
+		_, err := os.Stat(source.Dir)
+		assert.True(t, os.IsNotExist(err))
+	})
+}
@@ -0,0 +1,305 @@
+/*
+Copyright 2025 PRAS
+*/
+package command
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+
+	root "github.com/PRASSamin/prasmoid/cmd"
+	"github.com/PRASSamin/prasmoid/internal/runtime"
+)
+
+func init() {
+	commandsTestCmd.Flags().String("junit", "", "Also write a JUnit XML report to this file (\"-\" prints it instead of the summary)")
+	commandsCmd.AddCommand(commandsTestCmd)
+}
+
+var commandsTestCmd = &cobra.Command{
+	Use:   "test [files or directories...]",
+	Short: "Run the tests of custom commands",
+	Long: `Run the *.test.js and *.test.ts files in the commands directory, or in the given files and directories.
+
+Test files use describe/it/expect, mock the fs, child_process and prasmoid modules with mock.fs, mock.exec
+and mock.prasmoid, and run commands with runCommand(script, { args, flags }). Every test runs in a fresh runtime,
+sandboxed like the commands: a command run by a test gets only the permissions it declares.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		junit, _ := cmd.Flags().GetString("junit")
+		if !RunTests(args, junit) {
+			osExit(1)
+		}
+	},
+}
+
+// testResult is the outcome of a single test.
+type testResult struct {
+	Name     string
+	Duration time.Duration
+	Skipped  bool
+	Err      error
+}
+
+// testFileResult holds the results of the tests in a file.
+type testFileResult struct {
+	Path     string
+	Duration time.Duration
+	Results  []testResult
+}
+
+// RunTests runs the test files found in paths, or in the commands directory,
+// prints a report and reports whether every test passed. junit is the path of
+// a JUnit XML report to write, "-" for stdout.
+func RunTests(paths []string, junit string) bool {
+	files, err := findTestFiles(paths)
+	if err != nil {
+		fmt.Println(color.RedString("Failed to find test files: %v", err))
+		return false
+	}
+	if len(files) == 0 {
+		fmt.Println(color.YellowString("No test files found."))
+		return true
+	}
+
+	var results []testFileResult
+	for _, file := range files {
+		results = append(results, runTestFile(file))
+	}
+
+	out := io.Writer(os.Stdout)
+	if junit == "-" {
+		out = io.Discard
+	}
+	passed := printTestReport(out, results)
+
+	switch junit {
+	case "":
+	case "-":
+		if err := writeJUnit(os.Stdout, results); err != nil {
+			fmt.Println(color.RedString("Failed to write JUnit report: %v", err))
+			return false
+		}
+	default:
+		f, err := osCreate(junit)
+		if err != nil {
+			fmt.Println(color.RedString("Failed to write JUnit report: %v", err))
+			return false
+		}
+		defer func() { _ = f.Close() }()
+		if err := writeJUnit(f, results); err != nil {
+			fmt.Println(color.RedString("Failed to write JUnit report: %v", err))
+			return false
+		}
+	}
+	return passed
+}
+
+// findTestFiles lists the test files in paths, walking directories. Without
+// paths it looks in the commands directory.
+func findTestFiles(paths []string) ([]string, error) {
+	if len(paths) == 0 {
+		paths = []string{root.ConfigRC.Commands.Dir}
+	}
+	var files []string
+	for _, path := range paths {
+		info, err := osStat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		err = filepathWalk(path, func(file string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !info.IsDir() && runtime.IsTestFile(info.Name()) {
+				files = append(files, file)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	slices.Sort(files)
+	return slices.Compact(files), nil
+}
+
+// loadTests runs a test file in a fresh runtime, declaring its tests. The
+// runtime starts with an empty sandbox, which the commands loaded through
+// runCommand widen by their declared permissions, as in the CLI; the tests
+// themselves reach the disk and other processes through the mocks.
+func loadTests(path string, src []byte) (*runtime.Runtime, []*runtime.TestCase, error) {
+	vm := runtime.NewRuntime()
+	runtime.SetPermissions(vm, &runtime.Permissions{})
+	runtime.EnableTesting(vm, path)
+	if _, err := runtime.RunFile(vm, path, src); err != nil {
+		return nil, nil, err
+	}
+	return vm, runtime.Tests(vm), nil
+}
+
+// runTestFile runs every test of a file in a runtime of its own, so that
+// state and mocks never leak from one test into another.
+func runTestFile(path string) (result testFileResult) {
+	start := time.Now()
+	result.Path = path
+	defer func() { result.Duration = time.Since(start) }()
+
+	src, err := osReadFile(path)
+	if err != nil {
+		result.Results = []testResult{{Name: filepath.Base(path), Err: err}}
+		return result
+	}
+	_, declared, err := loadTests(path, src)
+	if err != nil {
+		result.Results = []testResult{{Name: filepath.Base(path), Err: err}}
+		return result
+	}
+
+	only := slices.ContainsFunc(declared, func(t *runtime.TestCase) bool { return t.Only })
+	for i, test := range declared {
+		res := testResult{Name: test.FullName()}
+		if test.Skip || (only && !test.Only) {
+			res.Skipped = true
+			result.Results = append(result.Results, res)
+			continue
+		}
+
+		testStart := time.Now()
+		vm, tests, err := loadTests(path, src)
+		if err == nil && len(tests) != len(declared) {
+			err = fmt.Errorf("the file declared %d tests on its first run and %d on the next", len(declared), len(tests))
+		}
+		if err == nil {
+			err = runtime.RunTest(vm, tests[i])
+		}
+		res.Err = err
+		res.Duration = time.Since(testStart)
+		result.Results = append(result.Results, res)
+	}
+	return result
+}
+
+// printTestReport prints the results of every file and a summary, and
+// reports whether no test failed.
+func printTestReport(out io.Writer, results []testFileResult) bool {
+	var passed, failed, skipped int
+	var total time.Duration
+	for _, file := range results {
+		total += file.Duration
+		fmt.Fprintln(out, color.New(color.Bold).Sprint(file.Path))
+		for _, res := range file.Results {
+			switch {
+			case res.Skipped:
+				skipped++
+				fmt.Fprintf(out, "  %s %s\n", color.YellowString("-"), color.YellowString("%s (skipped)", res.Name))
+			case res.Err != nil:
+				failed++
+				fmt.Fprintf(out, "  %s %s\n", color.RedString("✗"), res.Name)
+				for _, line := range strings.Split(res.Err.Error(), "\n") {
+					fmt.Fprintf(out, "      %s\n", color.RedString(line))
+				}
+			default:
+				passed++
+				fmt.Fprintf(out, "  %s %s %s\n", color.GreenString("✓"), res.Name, color.HiBlackString("(%s)", res.Duration.Round(time.Millisecond)))
+			}
+		}
+	}
+
+	summary := []string{color.GreenString("%d passed", passed)}
+	if failed > 0 {
+		summary = append(summary, color.RedString("%d failed", failed))
+	}
+	if skipped > 0 {
+		summary = append(summary, color.YellowString("%d skipped", skipped))
+	}
+	fmt.Fprintf(out, "\nTests: %s, %d total (%s)\n", strings.Join(summary, ", "), passed+failed+skipped, total.Round(time.Millisecond))
+	return failed == 0
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Skipped  int             `xml:"skipped,attr"`
+	Time     string          `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *struct{}     `xml:"skipped,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Body    string `xml:",chardata"`
+}
+
+func junitSeconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
+
+// writeJUnit writes the results as a JUnit XML report, one test suite per file.
+func writeJUnit(w io.Writer, results []testFileResult) error {
+	report := junitTestSuites{}
+	var total time.Duration
+	for _, file := range results {
+		suite := junitTestSuite{Name: filepath.ToSlash(file.Path), Time: junitSeconds(file.Duration)}
+		for _, res := range file.Results {
+			tc := junitTestCase{Name: res.Name, Classname: suite.Name, Time: junitSeconds(res.Duration)}
+			switch {
+			case res.Skipped:
+				tc.Skipped = &struct{}{}
+				suite.Skipped++
+			case res.Err != nil:
+				message, _, _ := strings.Cut(res.Err.Error(), "\n")
+				tc.Failure = &junitFailure{Message: message, Body: res.Err.Error()}
+				suite.Failures++
+			}
+			suite.Cases = append(suite.Cases, tc)
+		}
+		suite.Tests = len(suite.Cases)
+		report.Tests += suite.Tests
+		report.Failures += suite.Failures
+		report.Skipped += suite.Skipped
+		total += file.Duration
+		report.Suites = append(report.Suites, suite)
+	}
+	report.Time = junitSeconds(total)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,249 @@
+package command
+
+import (
+	"bytes"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/fatih/color"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/PRASSamin/prasmoid/cmd"
+	"github.com/PRASSamin/prasmoid/types"
+)
+
+const deployScript = `
+const fs = require("fs");
+const child_process = require("child_process");
+
+prasmoid.Command({
+	name: "deploy",
+	flags: [{ name: "target", type: "string", default: "staging" }],
+	run: (ctx) => {
+		const branch = child_process.execSync("git branch --show-current").trim();
+		const version = prasmoid.getMetadata("Version");
+		fs.writeFileSync("deploy.log", ctx.Flags().get("target") + " " + branch + " " + version);
+		prasmoid.setMetadata("Version", "2.0.0");
+		prasmoid.build({ output: "dist" });
+		return ctx.Args();
+	},
+	subcommands: [{ name: "status", run: async () => fs.existsSync("deploy.log") ? "deployed" : "idle" }],
+});
+`
+
+const deployTests = `
+describe("deploy", () => {
+	let exec, files, meta;
+	beforeEach(() => {
+		exec = mock.exec({ "git branch --show-current": "main\n" });
+		files = mock.fs({});
+		meta = { KPlugin: { Version: "1.0.0" } };
+		mock.prasmoid({ metadata: meta });
+	});
+
+	it("deploys the current branch", () => {
+		const args = runCommand("./deploy.js", { args: ["now"], flags: { target: "production" } });
+		expect(args).toEqual(["now"]);
+		expect(files["deploy.log"]).toBe("production main 1.0.0");
+		expect(meta.KPlugin.Version).toBe("2.0.0");
+		expect(exec).toHaveBeenCalledWith("git branch --show-current");
+		expect(prasmoid.build).toHaveBeenCalledTimes(1);
+	});
+
+	it("uses the flag defaults", () => {
+		runCommand("./deploy.js");
+		expect(files["deploy.log"]).toMatch(/^staging/);
+	});
+
+	it("runs subcommands", async () => {
+		expect(runCommand("./deploy.js", { name: "deploy status" })).toBe("idle");
+		fs.writeFileSync("deploy.log", "x");
+		expect(await Promise.resolve(runCommand("./deploy.js", { name: "deploy status" }))).toBe("deployed");
+	});
+
+	it("state does not leak between tests", () => {
+		expect(globalThis.leaked).toBeUndefined();
+		globalThis.leaked = true;
+	});
+
+	it("state does not leak between tests (again)", () => {
+		expect(globalThis.leaked).toBeUndefined();
+		globalThis.leaked = true;
+	});
+
+	it.skip("is skipped", () => {
+		throw new Error("should not run");
+	});
+});
+
+describe("expect", () => {
+	it("fails with a message", () => {
+		expect({ a: 1 }).toEqual({ a: 2 });
+	});
+
+	it("supports .not and toThrow", () => {
+		expect(1).not.toBe(2);
+		expect(() => { throw new Error("boom"); }).toThrow("boom");
+		expect(() => expect(1).toBe(2)).toThrow(/expected 1 to be 2/);
+		const fn = mock.fn().mockReturnValue(3);
+		expect(fn(1, "a")).toBe(3);
+		expect(fn.calls).toEqual([[1, "a"]]);
+		expect(() => process.exit(1)).toThrow("process.exit(1) was called");
+	});
+});
+`
+
+func writeTestProject(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "deploy.js"), []byte(deployScript), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "deploy.test.js"), []byte(deployTests), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "other.js"), []byte(`throw new Error("not a test")`), 0o644))
+	return dir
+}
+
+func TestRunTestFile(t *testing.T) {
+	dir := writeTestProject(t)
+	result := runTestFile(filepath.Join(dir, "deploy.test.js"))
+
+	outcomes := map[string]string{}
+	for _, res := range result.Results {
+		switch {
+		case res.Skipped:
+			outcomes[res.Name] = "skipped"
+		case res.Err != nil:
+			outcomes[res.Name] = "failed: " + res.Err.Error()
+		default:
+			outcomes[res.Name] = "passed"
+		}
+	}
+
+	assert.Equal(t, "passed", outcomes["deploy > deploys the current branch"])
+	assert.Equal(t, "passed", outcomes["deploy > uses the flag defaults"])
+	assert.Equal(t, "passed", outcomes["deploy > runs subcommands"])
+	assert.Equal(t, "passed", outcomes["deploy > state does not leak between tests"])
+	assert.Equal(t, "passed", outcomes["deploy > state does not leak between tests (again)"])
+	assert.Equal(t, "skipped", outcomes["deploy > is skipped"])
+	assert.Equal(t, "passed", outcomes["expect > supports .not and toThrow"])
+	assert.Contains(t, outcomes["expect > fails with a message"], `AssertionError: expected { a: 1 } to equal { a: 2 }`)
+	assert.Len(t, outcomes, 8)
+}
+
+func TestRunTestFileOnly(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "only.test.js")
+	require.NoError(t, os.WriteFile(path, []byte(`
+		it("a", () => {});
+		describe.only("focused", () => { it("b", () => {}); });
+		it.only("c", () => {});
+	`), 0o644))
+
+	var skipped []string
+	for _, res := range runTestFile(path).Results {
+		require.NoError(t, res.Err)
+		if res.Skipped {
+			skipped = append(skipped, res.Name)
+		}
+	}
+	assert.Equal(t, []string{"a"}, skipped)
+}
+
+func TestRunTestFileSandbox(t *testing.T) {
+	dir := t.TempDir()
+	secret := filepath.Join(t.TempDir(), "secret.txt")
+	require.NoError(t, os.WriteFile(secret, []byte("hunter2"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "peek.js"), []byte(`
+		prasmoid.Command({
+			name: "peek",
+			permissions: { env: ["HOME"] },
+			run: (ctx) => require("fs").readFileSync(ctx.Args()[0]),
+			subcommands: [{ name: "env", permissions: { env: ["USER"] }, run: () => [process.env.HOME !== undefined, process.env.PATH] }],
+		});
+	`), 0o644))
+	path := filepath.Join(dir, "peek.test.js")
+	require.NoError(t, os.WriteFile(path, []byte(`
+		const secret = `+strconv.Quote(secret)+`;
+		it("sandboxes the test file", () => {
+			expect(() => fs.readFileSync(secret)).toThrow("permission denied");
+		});
+		it("runs commands with their declared permissions", () => {
+			expect(() => runCommand("./peek.js", { args: [secret] })).toThrow("permission denied");
+			expect(runCommand("./peek.js", { name: "peek env" })).toEqual([true, undefined]);
+		});
+		it("reads the fake files", () => {
+			mock.fs({ [secret]: "fake" });
+			expect(runCommand("./peek.js", { args: [secret] })).toBe("fake");
+		});
+	`), 0o644))
+
+	for _, res := range runTestFile(path).Results {
+		assert.NoError(t, res.Err, res.Name)
+	}
+}
+
+func TestRunTestFileLoadError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "broken.test.js")
+	require.NoError(t, os.WriteFile(path, []byte(`describe("x", () => { syntax error`), 0o644))
+
+	result := runTestFile(path)
+	require.Len(t, result.Results, 1)
+	assert.Equal(t, "broken.test.js", result.Results[0].Name)
+	assert.Error(t, result.Results[0].Err)
+}
+
+func TestRunTests(t *testing.T) {
+	noColor := color.NoColor
+	t.Cleanup(func() { color.NoColor = noColor })
+	color.NoColor = true
+	dir := writeTestProject(t)
+	cmd.ConfigRC = types.Config{Commands: types.ConfigCommands{Dir: dir}}
+
+	t.Run("finds test files in the commands directory", func(t *testing.T) {
+		files, err := findTestFiles(nil)
+		require.NoError(t, err)
+		assert.Equal(t, []string{filepath.Join(dir, "deploy.test.js")}, files)
+	})
+
+	t.Run("prints a report", func(t *testing.T) {
+		var out bytes.Buffer
+		passed := printTestReport(&out, []testFileResult{runTestFile(filepath.Join(dir, "deploy.test.js"))})
+		assert.False(t, passed)
+		assert.Contains(t, out.String(), "✓ deploy > deploys the current branch")
+		assert.Contains(t, out.String(), "✗ expect > fails with a message")
+		assert.Contains(t, out.String(), "- deploy > is skipped (skipped)")
+		assert.Contains(t, out.String(), "Tests: 6 passed, 1 failed, 1 skipped, 8 total")
+	})
+
+	t.Run("writes a JUnit report", func(t *testing.T) {
+		report := filepath.Join(t.TempDir(), "junit.xml")
+		passed := RunTests(nil, report)
+		assert.False(t, passed)
+
+		data, err := os.ReadFile(report)
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(string(data), xml.Header))
+
+		var suites junitTestSuites
+		require.NoError(t, xml.Unmarshal(data, &suites))
+		assert.Equal(t, 8, suites.Tests)
+		assert.Equal(t, 1, suites.Failures)
+		assert.Equal(t, 1, suites.Skipped)
+		require.Len(t, suites.Suites, 1)
+		for _, tc := range suites.Suites[0].Cases {
+			if tc.Name == "expect > fails with a message" {
+				require.NotNil(t, tc.Failure)
+				assert.Contains(t, tc.Failure.Message, "expected { a: 1 } to equal { a: 2 }")
+			}
+		}
+	})
+
+	t.Run("passing files", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "ok.test.ts")
+		require.NoError(t, os.WriteFile(path, []byte(`it("adds", () => { const n: number = 1 + 1; expect(n).toBe(2); });`), 0o644))
+		assert.True(t, RunTests([]string{path}, ""))
+	})
+}
@@ -0,0 +1,50 @@
+/*
+Copyright 2025 PRAS
+*/
+package command
+
+import (
+	"fmt"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+
+	root "github.com/PRASSamin/prasmoid/cmd"
+	"github.com/PRASSamin/prasmoid/cmd/extendcli"
+)
+
+func init() {
+	commandsCmd.AddCommand(commandsUpdateCmd)
+}
+
+var commandsUpdateCmd = &cobra.Command{
+	Use:   "update",
+	Short: "Fetch the latest commands of the git repositories in commands.sources",
+	Long:  "Git repositories listed in commands.sources are only cloned by update, and then used as is until the next update.",
+	Run: func(cmd *cobra.Command, args []string) {
+		if !UpdateSources(extendcli.Sources(root.ConfigRC)) {
+			osExit(1)
+		}
+	},
+}
+
+// UpdateSources fetches the git sources again and reports whether they all updated.
+func UpdateSources(sources []extendcli.Source) bool {
+	ok, updated := true, 0
+	for _, source := range sources {
+		if source.Git == "" {
+			continue
+		}
+		updated++
+		if err := source.Update(); err != nil {
+			fmt.Println(color.RedString("Failed to update %s: %v", source.Origin, err))
+			ok = false
+			continue
+		}
+		fmt.Println(color.GreenString("Updated %s", source.Origin))
+	}
+	if updated == 0 {
+		fmt.Println(color.YellowString("No git repositories in commands.sources."))
+	}
+	return ok
+}
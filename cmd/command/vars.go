@@ -19,6 +19,9 @@ var (
 	osGetwd     = os.Getwd
 	osRemove    = os.Remove
 	osWriteFile = os.WriteFile
+	osReadFile  = os.ReadFile
+	osCreate    = os.Create
+	osExit      = os.Exit
 
 	// filepath
 	filepathAbs  = filepath.Abs
@@ -0,0 +1,118 @@
+/*
+Copyright 2025 PRAS
+*/
+package extendcli
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/PRASSamin/prasmoid/internal"
+	"github.com/PRASSamin/prasmoid/internal/runtime"
+)
+
+// commandMeta is what a script declares about a command. It's cached so
+// that the script only runs when one of its commands is invoked.
+type commandMeta struct {
+	runtime.CommandConfig
+	Subcommands []*commandMeta `json:"subcommands"`
+	// Runnable is false for commands that only group subcommands.
+	Runnable bool `json:"runnable"`
+	// DynamicFlags lists the flags completed by a JS function.
+	DynamicFlags []string `json:"dynamicFlags"`
+	// DynamicArgs is set when the arguments are completed by a JS function.
+	DynamicArgs bool `json:"dynamicArgs"`
+}
+
+// describe extracts the cacheable metadata of the commands a script registered.
+func describe(commands []*runtime.CommandConfig) []*commandMeta {
+	metas := make([]*commandMeta, len(commands))
+	for i, command := range commands {
+		meta := &commandMeta{
+			CommandConfig: *command,
+			Subcommands:   describe(command.Subcommands),
+			Runnable:      command.Run != nil,
+			DynamicArgs:   command.Args != nil && command.Args.Complete != nil,
+		}
+		meta.CommandConfig.Subcommands = nil
+		for _, flag := range command.Flags {
+			if flag.Complete != nil {
+				meta.DynamicFlags = append(meta.DynamicFlags, flag.Name)
+			}
+		}
+		metas[i] = meta
+	}
+	return metas
+}
+
+// commandCache is a cache entry: the metadata of a script's commands and the
+// modules it required, by the hash of their content.
+type commandCache struct {
+	Commands []*commandMeta    `json:"commands"`
+	Modules  map[string]string `json:"modules"`
+}
+
+// commandCachePath returns where the metadata of a script is cached. The key
+// covers the prasmoid version, so upgrades never read stale metadata, and the
+// script's path, since the modules it requires resolve against it.
+func commandCachePath(path string, src []byte) string {
+	dir, err := osUserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	hash := sha256.New()
+	hash.Write([]byte(internal.Version + "\x00" + path + "\x00"))
+	hash.Write(src)
+	return filepath.Join(dir, "prasmoid", "commands", fmt.Sprintf("%x.json", hash.Sum(nil)))
+}
+
+// fileHash is the hash of a file's content, or "" when it can't be read.
+func fileHash(path string) string {
+	data, err := osReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(data))
+}
+
+// readCommandCache returns the cached metadata of the script at path, unless
+// one of the modules it required changed since, like an edited helper.
+func readCommandCache(path string, src []byte) ([]*commandMeta, bool) {
+	data, err := osReadFile(commandCachePath(path, src))
+	if err != nil {
+		return nil, false
+	}
+	var entry commandCache
+	if err := json.Unmarshal(data, &entry); err != nil || len(entry.Commands) == 0 {
+		return nil, false
+	}
+	for module, hash := range entry.Modules {
+		if fileHash(module) != hash {
+			return nil, false
+		}
+	}
+	return entry.Commands, true
+}
+
+// writeCommandCache is best effort: without a cache the script just runs on every invocation.
+func writeCommandCache(path string, src []byte, metas []*commandMeta, modules []string) {
+	entry := commandCache{Commands: metas, Modules: map[string]string{}}
+	for _, module := range modules {
+		entry.Modules[module] = fileHash(module)
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	cachePath := commandCachePath(path, src)
+	if err := osMkdirAll(filepath.Dir(cachePath), 0o755); err != nil {
+		return
+	}
+	_ = osWriteFile(cachePath, data, 0o644)
+}
@@ -0,0 +1,141 @@
+package extendcli
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLazyCommands(t *testing.T) {
+	originalUserCacheDir := osUserCacheDir
+	t.Cleanup(func() { osUserCacheDir = originalUserCacheDir })
+	cacheDir := t.TempDir()
+	osUserCacheDir = func() (string, error) { return cacheDir, nil }
+
+	js := `console.log("script loaded");
+	prasmoid.Command({
+		name: "lazy",
+		short: "Runs lazily",
+		alias: ["lz"],
+		flags: [
+			{ name: "count", type: "int", default: 2, description: "How many" },
+			{ name: "env", type: "string", completions: (toComplete) => ["dev", "prod"], description: "Environment" },
+		],
+		args: { max: 1, names: ["target"] },
+		run: (ctx) => console.log("count", ctx.Flags().get("count"), ctx.Args().join(",")),
+		subcommands: [{ name: "child", short: "A child", run: () => console.log("child ran") }],
+	});`
+
+	register := func() (*cobra.Command, string) {
+		var rootCmd *cobra.Command
+		out := captureStdout(t, func() {
+			var err error
+			rootCmd, err = registerScript(t, "lazy", js)
+			require.NoError(t, err)
+		})
+		return rootCmd, out
+	}
+
+	// The first registration runs the script and fills the cache.
+	_, out := register()
+	assert.Contains(t, out, "script loaded")
+	entries, err := os.ReadDir(cacheDir + "/prasmoid/commands")
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	t.Run("cached metadata does not run the script", func(t *testing.T) {
+		rootCmd, out := register()
+		assert.NotContains(t, out, "script loaded")
+
+		lazy, _, err := rootCmd.Find([]string{"lazy"})
+		require.NoError(t, err)
+		assert.Equal(t, "lazy [target]", lazy.Use)
+		assert.Equal(t, "Runs lazily", lazy.Short)
+		assert.Equal(t, []string{"lz"}, lazy.Aliases)
+		assert.NotNil(t, lazy.Flags().Lookup("count"))
+		assert.NotNil(t, lazy.PersistentFlags().Lookup("allow-all"))
+	})
+
+	t.Run("invoking a cached command runs the script", func(t *testing.T) {
+		rootCmd, _ := register()
+		out, err := execute(rootCmd, "lazy", "--count", "5", "x")
+		require.NoError(t, err)
+		assert.Contains(t, out, "script loaded")
+		assert.Contains(t, out, "count 5 x")
+
+		rootCmd, _ = register()
+		out, err = execute(rootCmd, "lz", "child")
+		require.NoError(t, err)
+		assert.Contains(t, out, "child ran")
+	})
+
+	t.Run("cached commands complete from the script", func(t *testing.T) {
+		rootCmd, _ := register()
+		lazy, _, err := rootCmd.Find([]string{"lazy"})
+		require.NoError(t, err)
+		complete, ok := lazy.GetFlagCompletionFunc("env")
+		require.True(t, ok)
+		var values []string
+		captureStdout(t, func() { values, _ = complete(lazy, nil, "") })
+		assert.Equal(t, []string{"dev", "prod"}, values)
+	})
+
+	t.Run("changed scripts are not read from the cache", func(t *testing.T) {
+		var err error
+		out := captureStdout(t, func() {
+			_, err = registerScript(t, "lazy", js+"\n// edited")
+		})
+		require.NoError(t, err)
+		assert.Contains(t, out, "script loaded")
+	})
+}
+
+func TestCommandCacheCoversModules(t *testing.T) {
+	originalUserCacheDir := osUserCacheDir
+	t.Cleanup(func() { osUserCacheDir = originalUserCacheDir })
+	cacheDir := t.TempDir()
+	osUserCacheDir = func() (string, error) { return cacheDir, nil }
+
+	dir := t.TempDir()
+	script := filepath.Join(dir, "tool.js")
+	require.NoError(t, os.WriteFile(script, []byte(`const { short } = require("./lib");
+	prasmoid.Command({ name: "tool", short, run: () => {} });`), 0o644))
+	writeLib := func(short string) {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "lib.ts"), []byte(`export const short: string = "`+short+`";`), 0o644))
+	}
+	short := func() string {
+		rootCmd := &cobra.Command{Use: "root"}
+		rootCmd.AddGroup(&cobra.Group{ID: "custom", Title: "Custom Commands"})
+		require.NoError(t, registerJSCommand(rootCmd, script, OriginProject))
+		tool, _, err := rootCmd.Find([]string{"tool"})
+		require.NoError(t, err)
+		return tool.Short
+	}
+
+	writeLib("First")
+	assert.Equal(t, "First", short())
+	assert.Equal(t, "First", short())
+
+	// Editing the TypeScript helper invalidates the cache of the script
+	writeLib("Second")
+	assert.Equal(t, "Second", short())
+}
+
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+	oldStdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+	fn()
+	_ = w.Close()
+	os.Stdout = oldStdout
+	var buf strings.Builder
+	_, _ = io.Copy(&buf, r)
+	return buf.String()
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/dop251/goja"
@@ -17,9 +18,22 @@ import (
 	"github.com/PRASSamin/prasmoid/types"
 )
 
-// DiscoverAndRegisterCustomCommands scans for Js, Go files and registers them as cobra commands.
+// DiscoverAndRegisterCustomCommands scans the command sources for JS and TS files and registers them as cobra commands.
 func DiscoverAndRegisterCustomCommands(rootCmd *cobra.Command, ConfigRC types.Config) {
-	commandsDir := ConfigRC.Commands.Dir
+	registered = nil
+	for _, source := range Sources(ConfigRC) {
+		// Git sources wait for `prasmoid command update` to clone them.
+		if !source.Fetched() {
+			continue
+		}
+		discoverSource(rootCmd, source, ConfigRC.Commands.Ignore)
+	}
+}
+
+// discoverSource registers the commands of a source. Commands whose name is
+// taken by an earlier source are skipped.
+func discoverSource(rootCmd *cobra.Command, source Source, ignore []string) {
+	commandsDir := source.Dir
 
 	// Check if command directory exists
 	if _, err := osStat(commandsDir); os.IsNotExist(err) {
@@ -35,10 +49,11 @@ func DiscoverAndRegisterCustomCommands(rootCmd *cobra.Command, ConfigRC types.Co
 	// Filter out ignored files
 	var filteredFiles []os.DirEntry
 	for _, file := range files {
-		if file.IsDir() {
+		// Test files are run by `prasmoid command test`, they aren't commands.
+		if file.IsDir() || !runtime.IsScriptFile(file.Name()) || runtime.IsTestFile(file.Name()) {
 			continue
 		}
-		if isIgnored(file.Name(), ConfigRC.Commands.Ignore, commandsDir) {
+		if isIgnored(file.Name(), ignore, commandsDir) {
 			continue
 		}
 		filteredFiles = append(filteredFiles, file)
@@ -46,7 +61,7 @@ func DiscoverAndRegisterCustomCommands(rootCmd *cobra.Command, ConfigRC types.Co
 
 	// Register commands
 	for _, file := range filteredFiles {
-		_ = registerJSCommand(rootCmd, filepath.Join(commandsDir, file.Name()))
+		_ = registerJSCommand(rootCmd, filepath.Join(commandsDir, file.Name()), source.Origin)
 	}
 }
 
@@ -68,12 +83,78 @@ func isIgnored(filename string, ignoreList []string, root string) bool {
 	return false
 }
 
-type flagValues struct {
-	Strings map[string]*string
-	Bools   map[string]*bool
+// flagRequested checks the raw arguments for a boolean flag, since the top-level
+// code of a script runs before cobra parses the flags.
+func flagRequested(name string) bool {
+	for _, arg := range osArgs() {
+		if arg == "--" {
+			break
+		}
+		if arg == "--"+name || arg == "--"+name+"=true" {
+			return true
+		}
+	}
+	return false
+}
+
+// script is a custom command file. It only runs when needed: to read the
+// commands it declares when they aren't cached, and when one of them is invoked.
+type script struct {
+	path     string
+	src      []byte
+	vm       *runtime.Runtime
+	commands []*runtime.CommandConfig
+}
+
+// load runs the script once and collects the commands it registers.
+func (s *script) load() error {
+	if s.vm != nil {
+		return nil
+	}
+
+	// Create new runtime instance. The script starts without any permission
+	// and gets the ones it declares through prasmoid.Command.
+	vm := runtime.NewRuntime()
+	runtime.SetPermissions(vm, &runtime.Permissions{AllowAll: flagRequested("allow-all")})
+	if flagRequested("debug") {
+		runtime.EnableDebug(vm)
+	}
+
+	if _, err := runtime.RunFile(vm, s.path, s.src); err != nil {
+		fmt.Println(color.RedString("Error running script: %s", runtime.FormatError(err)))
+		return fmt.Errorf("error running script: %v", err)
+	}
+
+	commands := runtime.Commands(vm)
+	if len(commands) == 0 {
+		fmt.Println(color.YellowString("No command registered in %s", s.path))
+		return fmt.Errorf("no command registered in %s", s.path)
+	}
+
+	s.vm, s.commands = vm, commands
+	return nil
+}
+
+// lookup loads the script and returns the command at index, a path through
+// the registered commands and their subcommands.
+func (s *script) lookup(index []int) (*runtime.CommandConfig, error) {
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	var command *runtime.CommandConfig
+	list := s.commands
+	for _, i := range index {
+		if i >= len(list) {
+			fmt.Println(color.RedString("%s no longer registers this command", s.path))
+			return nil, fmt.Errorf("%s no longer registers this command", s.path)
+		}
+		command = list[i]
+		list = command.Subcommands
+	}
+	return command, nil
 }
 
-var registerJSCommand = func(rootCmd *cobra.Command, path string) error {
+var registerJSCommand = func(rootCmd *cobra.Command, path string, origin string) error {
 	// Read the JS file
 	src, err := osReadFile(path)
 	if err != nil {
@@ -81,66 +162,178 @@ var registerJSCommand = func(rootCmd *cobra.Command, path string) error {
 		return fmt.Errorf("failed to read JS script %s: %v", path, err)
 	}
 
-	// Create new runtime instance
-	vm := runtime.NewRuntime()
+	// Commands are built from cached metadata when neither the file nor the
+	// modules it requires changed, so the script itself only runs when one of
+	// its commands is invoked.
+	s := &script{path: path, src: src}
+	metas, cached := readCommandCache(path, src)
+	if !cached {
+		if err := s.load(); err != nil {
+			return err
+		}
+		metas = describe(s.commands)
+	}
 
-	_, err = vm.RunString(string(src))
-	if err != nil {
-		fmt.Println(color.RedString("Error running script: %v", err))
-		return fmt.Errorf("error running script: %v", err)
+	// A command without a name is named after its file.
+	filename := strings.TrimSpace(filepath.Base(path))
+	defaultName := strings.TrimSuffix(filename, filepath.Ext(filename))
+
+	var cmds []*cobra.Command
+	var found []Registered
+	seen := make(map[string]bool)
+	for i, meta := range metas {
+		name := meta.Name
+		if name == "" {
+			name = defaultName
+		}
+		if seen[name] {
+			fmt.Println(color.RedString("Duplicate command %q in %s", name, path))
+			return fmt.Errorf("duplicate command %q in %s", name, path)
+		}
+		seen[name] = true
+
+		entry := Registered{Name: name, Short: meta.Short, Path: path, Origin: origin}
+		if shadowedBy, taken := takenBy(rootCmd, name); taken {
+			entry.ShadowedBy = shadowedBy
+			found = append(found, entry)
+			continue
+		}
+		found = append(found, entry)
+
+		cmd, err := buildCommand(s, meta, []int{i}, name, nil)
+		if err != nil {
+			return err
+		}
+		cmd.PersistentFlags().Bool("allow-all", false, "Run the command without permission restrictions")
+		cmd.PersistentFlags().Bool("debug", false, "Log every call the command makes to the built-in modules")
+		cmd.PersistentFlags().StringArray("answer", nil, "Answer a prompt without asking, as name=value")
+		cmd.GroupID = "custom"
+		cmds = append(cmds, cmd)
 	}
 
-	cmd := &cobra.Command{}
-	flagVals := &flagValues{
-		Strings: make(map[string]*string),
-		Bools:   make(map[string]*bool),
+	if !cached {
+		writeCommandCache(path, src, metas, runtime.Modules(s.vm))
 	}
+	rootCmd.AddCommand(cmds...)
+	registered = append(registered, found...)
+	return nil
+}
 
-	command := runtime.CommandStorage
-	filename := strings.TrimSpace(filepath.Base(path))
-	cmd.Use = strings.ReplaceAll(strings.TrimSuffix(filename, filepath.Ext(filename)), " ", "")
+// takenBy reports whether rootCmd already has a command called name, and the
+// script that registered it, "built-in" for the CLI's own commands.
+func takenBy(rootCmd *cobra.Command, name string) (string, bool) {
+	for _, cmd := range rootCmd.Commands() {
+		if cmd.Name() != name && !cmd.HasAlias(name) {
+			continue
+		}
+		for _, r := range registered {
+			if r.ShadowedBy == "" && r.Name == cmd.Name() {
+				return r.Path, true
+			}
+		}
+		return "built-in", true
+	}
+	return "", false
+}
 
-	cmd.Short = command.Short
-	cmd.Long = command.Long
-	if len(command.Alias) > 0 {
-		cmd.Aliases = make([]string, len(command.Alias))
-		copy(cmd.Aliases, command.Alias)
+// buildCommand turns a command declared by a script into a cobra command,
+// recursing into its subcommands. Subcommands inherit the permissions of
+// their parents.
+func buildCommand(s *script, meta *commandMeta, index []int, name string, inherited *runtime.Permissions) (*cobra.Command, error) {
+	cmd := &cobra.Command{}
+	flagVals := make(flagValues)
+
+	cmd.Use = strings.ReplaceAll(name, " ", "")
+	cmd.Short = meta.Short
+	cmd.Long = meta.Long
+	if len(meta.Alias) > 0 {
+		cmd.Aliases = make([]string, len(meta.Alias))
+		copy(cmd.Aliases, meta.Alias)
 	}
 
-	for _, flag := range command.Flags {
+	for _, flag := range meta.Flags {
 		if flag.Name == "" {
 			fmt.Println(color.YellowString("Flag name is required"))
-			return fmt.Errorf("flag name is required")
+			return nil, fmt.Errorf("flag name is required")
 		}
 		if flag.Type == "" {
 			fmt.Println(color.YellowString("Flag type is required"))
-			return fmt.Errorf("flag type is required")
+			return nil, fmt.Errorf("flag type is required")
 		}
 
-		switch flag.Type {
-		case "string":
-			var val string
-			flagVals.Strings[flag.Name] = &val
-			if flag.Shorthand == "" {
-				cmd.Flags().StringVar(flagVals.Strings[flag.Name], flag.Name, fmt.Sprintf("%v", flag.Value), flag.Description)
-			} else {
-				cmd.Flags().StringVarP(flagVals.Strings[flag.Name], flag.Name, flag.Shorthand, fmt.Sprintf("%v", flag.Value), flag.Description)
-			}
-		case "bool":
-			var val bool
-			flagVals.Bools[flag.Name] = &val
-			if flag.Shorthand == "" {
-				cmd.Flags().BoolVar(flagVals.Bools[flag.Name], flag.Name, flag.Value.(bool), flag.Description)
-			} else {
-				cmd.Flags().BoolVarP(flagVals.Bools[flag.Name], flag.Name, flag.Shorthand, flag.Value.(bool), flag.Description)
+		var complete cobra.CompletionFunc
+		if slices.Contains(meta.DynamicFlags, flag.Name) {
+			flagName := flag.Name
+			complete = s.completion(index, func(command *runtime.CommandConfig) goja.Callable {
+				for _, f := range command.Flags {
+					if f.Name == flagName {
+						return f.Complete
+					}
+				}
+				return nil
+			})
+		}
+		if err := addFlag(cmd, flag, flagVals, complete); err != nil {
+			fmt.Fprintln(os.Stderr, color.RedString("Invalid flag in %s: %v", s.path, err))
+			return nil, err
+		}
+	}
+
+	var completeArgs cobra.CompletionFunc
+	if meta.DynamicArgs {
+		completeArgs = s.completion(index, func(command *runtime.CommandConfig) goja.Callable {
+			if command.Args == nil {
+				return nil
 			}
-		default:
-			fmt.Fprintln(os.Stderr, color.RedString("Unsupported flag type: %s", flag.Type))
-			return fmt.Errorf("unsupported flag type: %s", flag.Type)
+			return command.Args.Complete
+		})
+	}
+	applyArgs(cmd, meta.Args, completeArgs)
+
+	perms := inherited.Merge(meta.Permissions)
+
+	for i, sub := range meta.Subcommands {
+		subCmd, err := buildCommand(s, sub, append(slices.Clone(index), i), sub.Name, perms)
+		if err != nil {
+			return nil, err
 		}
+		cmd.AddCommand(subCmd)
+	}
+
+	// Commands that only group subcommands print their help.
+	if !meta.Runnable {
+		return cmd, nil
 	}
 
 	cmd.Run = func(cmd *cobra.Command, args []string) {
+		command, err := s.lookup(index)
+		if err != nil {
+			return
+		}
+		if command.Run == nil {
+			fmt.Fprintln(os.Stderr, color.RedString("JS command error (%s): %s has no run function", s.path, name))
+			return
+		}
+		vm := s.vm
+
+		if allowAll, _ := cmd.Flags().GetBool("allow-all"); allowAll {
+			runtime.SetPermissions(vm, &runtime.Permissions{AllowAll: true})
+		} else if current := runtime.GetPermissions(vm); current != nil && !current.AllowAll {
+			runtime.SetPermissions(vm, perms)
+		}
+
+		presets, _ := cmd.Flags().GetStringArray("answer")
+		answers := make(map[string]string, len(presets))
+		for _, preset := range presets {
+			name, value, ok := strings.Cut(preset, "=")
+			if !ok {
+				fmt.Fprintln(os.Stderr, color.RedString("Invalid --answer %q, expected name=value", preset))
+				return
+			}
+			answers[name] = value
+		}
+		runtime.SetAnswers(vm, answers)
+
 		// Create JavaScript object for context
 		ctxObj := vm.NewObject()
 
@@ -153,20 +346,14 @@ var registerJSCommand = func(rootCmd *cobra.Command, path string) error {
 		flagsObj := vm.NewObject()
 
 		// Add raw flag values as properties
-		for k, v := range flagVals.Strings {
-			_ = flagsObj.Set(k, vm.ToValue(*v))
-		}
-		for k, v := range flagVals.Bools {
-			_ = flagsObj.Set(k, vm.ToValue(*v))
+		for k, get := range flagVals {
+			_ = flagsObj.Set(k, vm.ToValue(get()))
 		}
 
 		// Add getFlag method
 		_ = flagsObj.Set("get", func(call goja.FunctionCall) goja.Value {
-			name := call.Argument(0).String()
-			if val, ok := flagVals.Strings[name]; ok {
-				return vm.ToValue(*val)
-			} else if val, ok := flagVals.Bools[name]; ok {
-				return vm.ToValue(*val)
+			if get, ok := flagVals[call.Argument(0).String()]; ok {
+				return vm.ToValue(get())
 			}
 			return goja.Undefined()
 		})
@@ -176,15 +363,22 @@ var registerJSCommand = func(rootCmd *cobra.Command, path string) error {
 			return flagsObj
 		})
 
-		// Pass the context to the JS function
-		_, err := command.Run(goja.Undefined(), ctxObj)
+		// Pass the context to the JS function, waiting for async ones
+		result, err := command.Run(goja.Undefined(), ctxObj)
+		if err == nil {
+			_, err = runtime.Await(vm, result)
+		}
 		if err != nil {
-			fmt.Fprintln(os.Stderr, color.RedString("JS command error (%s): %v", path, err))
+			fmt.Fprintln(os.Stderr, color.RedString("JS command error (%s): %s", s.path, runtime.FormatError(err)))
+		}
+		runtime.RunEventLoop(vm)
+		for _, rejection := range runtime.UnhandledRejections(vm) {
+			fmt.Fprintln(os.Stderr, color.RedString("%s", runtime.FormatError(rejection)))
+		}
+		if code := runtime.EmitExit(vm, 0); code != 0 {
+			osExit(code)
 		}
-		runtime.EventLoop.Wait()
 	}
 
-	cmd.GroupID = "custom"
-	rootCmd.AddCommand(cmd)
-	return nil
+	return cmd, nil
 }
@@ -7,6 +7,7 @@ import (
 	"io"
 	"io/fs"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -16,6 +17,25 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
+func TestMain(m *testing.M) {
+	// Keep the command metadata cache out of the user's cache dir, and
+	// fresh for every run. The user's own commands stay out of the tests too.
+	cacheDir, err := os.MkdirTemp("", "prasmoid-cache")
+	if err != nil {
+		panic(err)
+	}
+	configDir, err := os.MkdirTemp("", "prasmoid-config")
+	if err != nil {
+		panic(err)
+	}
+	osUserCacheDir = func() (string, error) { return cacheDir, nil }
+	osUserConfigDir = func() (string, error) { return configDir, nil }
+	code := m.Run()
+	_ = os.RemoveAll(cacheDir)
+	_ = os.RemoveAll(configDir)
+	os.Exit(code)
+}
+
 func TestIsIgnored(t *testing.T) {
 	root := t.TempDir()
 	tests := []struct {
@@ -61,7 +81,7 @@ func TestRegisterJSCommand(t *testing.T) {
 		rootCmd := &cobra.Command{}
 
 		// Act
-		err := registerJSCommand(rootCmd, "test.js")
+		err := registerJSCommand(rootCmd, "test.js", OriginProject)
 
 		// Assert
 		assert.NoError(t, err)
@@ -70,6 +90,32 @@ func TestRegisterJSCommand(t *testing.T) {
 		assert.Equal(t, "A brief description of your command.", rootCmd.Commands()[0].Short)
 	})
 
+	t.Run("valid ts command", func(t *testing.T) {
+		// Arrange
+		t.Cleanup(func() { osReadFile = os.ReadFile })
+		validTS := `import { Command } from "prasmoid";
+
+const short: string = await Promise.resolve("Typed command");
+
+Command({
+	run: (ctx: unknown): void => {},
+	short,
+});`
+		osReadFile = func(name string) ([]byte, error) {
+			return []byte(validTS), nil
+		}
+		rootCmd := &cobra.Command{}
+
+		// Act
+		err := registerJSCommand(rootCmd, "release.ts", OriginProject)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(rootCmd.Commands()))
+		assert.Equal(t, "release", rootCmd.Commands()[0].Use)
+		assert.Equal(t, "Typed command", rootCmd.Commands()[0].Short)
+	})
+
 	t.Run("non-existent file", func(t *testing.T) {
 		// Arrange
 		t.Cleanup(func() { osReadFile = os.ReadFile })
@@ -79,7 +125,7 @@ func TestRegisterJSCommand(t *testing.T) {
 		rootCmd := &cobra.Command{}
 
 		// Act
-		err := registerJSCommand(rootCmd, "non-existent-file.js")
+		err := registerJSCommand(rootCmd, "non-existent-file.js", OriginProject)
 
 		// Assert
 		assert.Error(t, err)
@@ -95,7 +141,7 @@ func TestRegisterJSCommand(t *testing.T) {
 		rootCmd := &cobra.Command{}
 
 		// Act
-		err := registerJSCommand(rootCmd, "invalid.js")
+		err := registerJSCommand(rootCmd, "invalid.js", OriginProject)
 
 		// Assert
 		assert.Error(t, err)
@@ -112,7 +158,7 @@ func TestRegisterJSCommand(t *testing.T) {
 		rootCmd := &cobra.Command{}
 
 		// Act
-		err := registerJSCommand(rootCmd, "test.js")
+		err := registerJSCommand(rootCmd, "test.js", OriginProject)
 
 		// Assert
 		assert.Error(t, err)
@@ -129,7 +175,7 @@ func TestRegisterJSCommand(t *testing.T) {
 		rootCmd := &cobra.Command{}
 
 		// Act
-		err := registerJSCommand(rootCmd, "test.js")
+		err := registerJSCommand(rootCmd, "test.js", OriginProject)
 
 		// Assert
 		assert.Error(t, err)
@@ -146,7 +192,7 @@ func TestRegisterJSCommand(t *testing.T) {
 		rootCmd := &cobra.Command{}
 
 		// Act
-		err := registerJSCommand(rootCmd, "test.js")
+		err := registerJSCommand(rootCmd, "test.js", OriginProject)
 
 		// Assert
 		assert.Error(t, err)
@@ -184,7 +230,7 @@ func TestRegisterJSCommand(t *testing.T) {
 		rootCmd.SetOut(io.Discard) // Prevent cobra from printing
 		rootCmd.SetErr(io.Discard)
 
-		err := registerJSCommand(rootCmd, "test.js")
+		err := registerJSCommand(rootCmd, "test.js", OriginProject)
 		require.NoError(t, err)
 
 		// Capture stdout
@@ -229,7 +275,7 @@ func TestRegisterJSCommand(t *testing.T) {
 		rootCmd.SetOut(io.Discard)
 		rootCmd.SetErr(io.Discard)
 
-		err := registerJSCommand(rootCmd, "test.js")
+		err := registerJSCommand(rootCmd, "test.js", OriginProject)
 		require.NoError(t, err)
 
 		// Capture stderr
@@ -253,6 +299,259 @@ func TestRegisterJSCommand(t *testing.T) {
 		assert.Contains(t, output, "JS runtime error")
 	})
 
+	t.Run("errors show a stack trace and unhandled rejections", func(t *testing.T) {
+		// Arrange
+		path := filepath.Join(t.TempDir(), "trace.js")
+		js := `prasmoid.Command({
+	name: "fail",
+	run: () => {
+		null.x;
+	},
+});
+prasmoid.Command({
+	name: "reject",
+	run: async () => {
+		Promise.reject(new Error("forgotten"));
+		throw new Error("async failure");
+	},
+});`
+		require.NoError(t, os.WriteFile(path, []byte(js), 0o644))
+		rootCmd := &cobra.Command{Use: "root"}
+		rootCmd.AddGroup(&cobra.Group{ID: "custom", Title: "Custom Commands"})
+		rootCmd.SetOut(io.Discard)
+		rootCmd.SetErr(io.Discard)
+		require.NoError(t, registerJSCommand(rootCmd, path, OriginProject))
+
+		run := func(args ...string) string {
+			oldStderr := os.Stderr
+			r, w, _ := os.Pipe()
+			os.Stderr = w
+			rootCmd.SetArgs(args)
+			executeErr := rootCmd.Execute()
+			_ = w.Close()
+			os.Stderr = oldStderr
+			var buf strings.Builder
+			_, _ = io.Copy(&buf, r)
+			require.NoError(t, executeErr)
+			return buf.String()
+		}
+
+		// Act
+		failed := run("fail")
+		rejected := run("reject")
+
+		// Assert
+		assert.Contains(t, failed, "TypeError: Cannot read property 'x' of")
+		assert.Contains(t, failed, "> 4 | \t\tnull.x;")
+		assert.Contains(t, failed, "at run ("+path+":4:")
+		assert.Contains(t, rejected, "Error: async failure")
+		assert.Contains(t, rejected, "Uncaught (in promise) Error: forgotten")
+		assert.Contains(t, rejected, "at run ("+path+":10:")
+		assert.Contains(t, rejected, "at run ("+path+":11:")
+	})
+
+	t.Run("exit listeners run and process.exitCode is the exit code", func(t *testing.T) {
+		// Arrange
+		exitCode := -1
+		t.Cleanup(func() { osExit = os.Exit })
+		osExit = func(code int) { exitCode = code }
+		path := filepath.Join(t.TempDir(), "exit.js")
+		js := `prasmoid.Command({
+	name: "exiting",
+	run: () => {
+		process.on("exit", (code) => console.log("exit event", code));
+		process.exitCode = 2;
+	},
+});`
+		require.NoError(t, os.WriteFile(path, []byte(js), 0o644))
+		rootCmd := &cobra.Command{Use: "root"}
+		rootCmd.AddGroup(&cobra.Group{ID: "custom", Title: "Custom Commands"})
+		require.NoError(t, registerJSCommand(rootCmd, path, OriginProject))
+
+		// Act
+		output := captureStdout(t, func() {
+			rootCmd.SetArgs([]string{"exiting"})
+			require.NoError(t, rootCmd.Execute())
+		})
+
+		// Assert
+		assert.Contains(t, output, "exit event 2")
+		assert.Equal(t, 2, exitCode)
+	})
+
+	t.Run("permissions are enforced unless --allow-all", func(t *testing.T) {
+		// Arrange
+		t.Cleanup(func() { osReadFile = os.ReadFile })
+		jsContent := `
+		const prasmoid = require("prasmoid");
+		prasmoid.Command({
+		    run: (ctx) => {
+		        console.log(child_process.execSync("echo allowed"));
+		        fs.readFileSync("/etc/hostname");
+		        console.log("read done");
+		    },
+		    permissions: { exec: ["echo"] },
+		});`
+		osReadFile = func(name string) ([]byte, error) {
+			return []byte(jsContent), nil
+		}
+
+		run := func(args ...string) string {
+			rootCmd := &cobra.Command{Use: "root"}
+			rootCmd.AddGroup(&cobra.Group{ID: "custom", Title: "Custom Commands"})
+			rootCmd.SetOut(io.Discard)
+			rootCmd.SetErr(io.Discard)
+			require.NoError(t, registerJSCommand(rootCmd, "sandboxed.js", OriginProject))
+
+			oldStdout, oldStderr := os.Stdout, os.Stderr
+			r, w, _ := os.Pipe()
+			os.Stdout, os.Stderr = w, w
+
+			rootCmd.SetArgs(append([]string{"sandboxed"}, args...))
+			executeErr := rootCmd.Execute()
+
+			_ = w.Close()
+			os.Stdout, os.Stderr = oldStdout, oldStderr
+			var buf strings.Builder
+			_, _ = io.Copy(&buf, r)
+			require.NoError(t, executeErr)
+			return buf.String()
+		}
+
+		// Act
+		sandboxed := run()
+		unrestricted := run("--allow-all")
+
+		// Assert
+		assert.Contains(t, sandboxed, "allowed")
+		assert.Contains(t, sandboxed, `permission denied: fs.readFileSync needs fs access to "/etc/hostname"`)
+		assert.NotContains(t, sandboxed, "read done")
+		assert.Contains(t, unrestricted, "read done")
+	})
+
+	t.Run("top-level code honours --allow-all from the command line", func(t *testing.T) {
+		// Arrange
+		originalUserCacheDir := osUserCacheDir
+		t.Cleanup(func() {
+			osReadFile = os.ReadFile
+			osArgs = func() []string { return os.Args }
+			osUserCacheDir = originalUserCacheDir
+		})
+		// Top-level code only runs when the commands aren't cached yet.
+		cacheDir := t.TempDir()
+		osUserCacheDir = func() (string, error) { return cacheDir, nil }
+		js := `const cwd = fs.readdirSync("/"); prasmoid.Command({ run: () => {} });`
+		osReadFile = func(name string) ([]byte, error) {
+			return []byte(js), nil
+		}
+
+		// Act
+		osArgs = func() []string { return []string{"prasmoid", "toplevel"} }
+		denied := registerJSCommand(&cobra.Command{}, "toplevel.js", OriginProject)
+		osArgs = func() []string { return []string{"prasmoid", "toplevel", "--allow-all"} }
+		allowed := registerJSCommand(&cobra.Command{}, "toplevel.js", OriginProject)
+
+		// Assert
+		assert.Error(t, denied)
+		assert.Contains(t, denied.Error(), "permission denied")
+		assert.NoError(t, allowed)
+	})
+
+	t.Run("multiple commands and nested subcommands", func(t *testing.T) {
+		// Arrange
+		t.Cleanup(func() { osReadFile = os.ReadFile })
+		js := `
+		prasmoid.Command({
+		    name: "release",
+		    short: "Release tasks",
+		    permissions: { exec: ["echo"] },
+		    subcommands: [
+		        { name: "notes", short: "Print notes", run: (ctx) => console.log("notes", ctx.Args().join(",")) },
+		        {
+		            name: "publish",
+		            flags: [{ name: "tag", type: "string", value: "latest" }],
+		            run: (ctx) => {
+		                console.log("publish", ctx.Flags().get("tag"), child_process.execSync("echo inherited").trim());
+		            },
+		        },
+		    ],
+		});
+		prasmoid.Command({ name: "bump", run: () => console.log("bump") });
+		prasmoid.Command({ run: () => console.log("default name") });`
+		osReadFile = func(name string) ([]byte, error) {
+			return []byte(js), nil
+		}
+
+		rootCmd := &cobra.Command{Use: "root"}
+		rootCmd.AddGroup(&cobra.Group{ID: "custom", Title: "Custom Commands"})
+		rootCmd.SetOut(io.Discard)
+		rootCmd.SetErr(io.Discard)
+		require.NoError(t, registerJSCommand(rootCmd, "tasks.js", OriginProject))
+
+		run := func(args ...string) string {
+			oldStdout := os.Stdout
+			r, w, _ := os.Pipe()
+			os.Stdout = w
+			rootCmd.SetArgs(args)
+			executeErr := rootCmd.Execute()
+			_ = w.Close()
+			os.Stdout = oldStdout
+			var buf strings.Builder
+			_, _ = io.Copy(&buf, r)
+			require.NoError(t, executeErr)
+			return buf.String()
+		}
+
+		// Assert
+		var names []string
+		for _, cmd := range rootCmd.Commands() {
+			names = append(names, cmd.Name())
+		}
+		assert.ElementsMatch(t, []string{"release", "bump", "tasks"}, names)
+
+		release, _, err := rootCmd.Find([]string{"release"})
+		require.NoError(t, err)
+		assert.Nil(t, release.Run)
+		assert.Len(t, release.Commands(), 2)
+
+		assert.Contains(t, run("release", "notes", "a", "b"), "notes a,b")
+		assert.Contains(t, run("release", "publish", "--tag", "v1"), "publish v1 inherited")
+		assert.Contains(t, run("bump"), "bump")
+		assert.Contains(t, run("tasks"), "default name")
+	})
+
+	t.Run("duplicate command names", func(t *testing.T) {
+		// Arrange
+		t.Cleanup(func() { osReadFile = os.ReadFile })
+		osReadFile = func(name string) ([]byte, error) {
+			return []byte(`prasmoid.Command({ run: () => {} }); prasmoid.Command({ name: "dup", run: () => {} });`), nil
+		}
+		rootCmd := &cobra.Command{}
+
+		// Act
+		err := registerJSCommand(rootCmd, "dup.js", OriginProject)
+
+		// Assert
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `duplicate command "dup"`)
+		assert.Empty(t, rootCmd.Commands())
+	})
+
+	t.Run("script without commands", func(t *testing.T) {
+		// Arrange
+		t.Cleanup(func() { osReadFile = os.ReadFile })
+		osReadFile = func(name string) ([]byte, error) {
+			return []byte(`const helper = 1;`), nil
+		}
+
+		// Act
+		err := registerJSCommand(&cobra.Command{}, "helper.js", OriginProject)
+
+		// Assert
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "no command registered")
+	})
+
 	t.Run("flag variations", func(t *testing.T) {
 		// Arrange
 		t.Cleanup(func() { osReadFile = os.ReadFile })
@@ -269,7 +568,7 @@ func TestRegisterJSCommand(t *testing.T) {
 		rootCmd := &cobra.Command{}
 
 		// Act
-		err := registerJSCommand(rootCmd, "test.js")
+		err := registerJSCommand(rootCmd, "test.js", OriginProject)
 
 		// Assert
 		assert.NoError(t, err)
@@ -309,13 +608,14 @@ func TestDiscoverAndRegisterCustomCommands(t *testing.T) {
 	t.Run("discover and register", func(t *testing.T) {
 		setup(t) // Call setup at the beginning of each test case
 		commandsDir := t.TempDir()
-		jsCmd1 := `if (typeof prasmoid !== 'undefined') { prasmoid.command = { Short: "cmd1" } }`
-		jsCmd2 := `if (typeof prasmoid !== 'undefined') { prasmoid.command = { Short: "cmd2" } }`
-		ignoredFile := `if (typeof prasmoid !== 'undefined') { prasmoid.command = { Short: "ignored" } }`
+		jsCmd1 := `prasmoid.Command({ short: "cmd1", run: () => {} })`
+		jsCmd2 := `prasmoid.Command({ short: "cmd2", run: () => {} })`
+		ignoredFile := `prasmoid.Command({ short: "ignored", run: () => {} })`
 
 		_ = osWriteFile(filepathJoin(commandsDir, "cmd1.js"), []byte(jsCmd1), 0644)
 		_ = osWriteFile(filepathJoin(commandsDir, "cmd2.js"), []byte(jsCmd2), 0644)
 		_ = osWriteFile(filepathJoin(commandsDir, "ignored.js"), []byte(ignoredFile), 0644)
+		_ = osWriteFile(filepathJoin(commandsDir, "cmd1.test.js"), []byte(`it("works", () => {})`), 0644)
 		_ = osMkdirAll(filepathJoin(commandsDir, "subdir"), 0755)
 
 		config := types.Config{
@@ -0,0 +1,170 @@
+/*
+Copyright 2025 PRAS
+*/
+package extendcli
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/spf13/cobra"
+
+	"github.com/PRASSamin/prasmoid/internal/runtime"
+)
+
+// flagValues maps each flag to a getter returning its parsed value as passed to JS.
+type flagValues map[string]func() interface{}
+
+// enumValue is a string flag restricted to a set of choices.
+type enumValue struct {
+	value   string
+	choices []string
+}
+
+func (e *enumValue) String() string { return e.value }
+func (e *enumValue) Type() string   { return "string" }
+func (e *enumValue) Set(val string) error {
+	if !slices.Contains(e.choices, val) {
+		return fmt.Errorf("must be one of %s", quoteAll(e.choices))
+	}
+	e.value = val
+	return nil
+}
+
+func quoteAll(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = fmt.Sprintf("%q", v)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// addFlag defines a flag declared by a script on cmd and records its getter.
+// complete, when set, completes the flag's values in place of its static completions.
+func addFlag(cmd *cobra.Command, flag runtime.CommandFlag, values flagValues, complete cobra.CompletionFunc) error {
+	flags := cmd.Flags()
+	def, err := flag.DefaultValue()
+	if err != nil {
+		return err
+	}
+
+	switch flag.Type {
+	case "string", "enum":
+		def := def.(string)
+		if flag.Type == "enum" && len(flag.Choices) == 0 {
+			return fmt.Errorf("flag %q: enum flags need choices", flag.Name)
+		}
+		if len(flag.Choices) == 0 {
+			val := flags.StringP(flag.Name, flag.Shorthand, def, flag.Description)
+			values[flag.Name] = func() interface{} { return *val }
+			break
+		}
+		if def != "" && !slices.Contains(flag.Choices, def) {
+			return fmt.Errorf("flag %q: default %q is not one of %s", flag.Name, def, quoteAll(flag.Choices))
+		}
+		val := &enumValue{value: def, choices: flag.Choices}
+		flags.VarP(val, flag.Name, flag.Shorthand, fmt.Sprintf("%s (one of %s)", flag.Description, strings.Join(flag.Choices, ", ")))
+		values[flag.Name] = func() interface{} { return val.value }
+	case "bool":
+		val := flags.BoolP(flag.Name, flag.Shorthand, def.(bool), flag.Description)
+		values[flag.Name] = func() interface{} { return *val }
+	case "int":
+		val := flags.Int64P(flag.Name, flag.Shorthand, def.(int64), flag.Description)
+		values[flag.Name] = func() interface{} { return *val }
+	case "float":
+		val := flags.Float64P(flag.Name, flag.Shorthand, def.(float64), flag.Description)
+		values[flag.Name] = func() interface{} { return *val }
+	case "stringArray":
+		val := flags.StringArrayP(flag.Name, flag.Shorthand, def.([]string), flag.Description)
+		values[flag.Name] = func() interface{} { return *val }
+	case "duration":
+		// Durations reach the script in milliseconds.
+		val := flags.DurationP(flag.Name, flag.Shorthand, def.(time.Duration), flag.Description)
+		values[flag.Name] = func() interface{} { return val.Milliseconds() }
+	}
+
+	if flag.Required {
+		_ = cmd.MarkFlagRequired(flag.Name)
+	}
+
+	completions := flag.Completions
+	if len(completions) == 0 {
+		completions = flag.Choices
+	}
+	if complete == nil && len(completions) > 0 {
+		complete = staticCompletion(completions)
+	}
+	if complete != nil {
+		_ = cmd.RegisterFlagCompletionFunc(flag.Name, complete)
+	}
+	return nil
+}
+
+// applyArgs validates positional arguments through cobra and documents them in the usage line.
+func applyArgs(cmd *cobra.Command, spec *runtime.CommandArgs, complete cobra.CompletionFunc) {
+	if spec == nil {
+		return
+	}
+
+	switch {
+	case spec.Max < 0:
+		cmd.Args = cobra.MinimumNArgs(spec.Min)
+	case spec.Min == spec.Max:
+		cmd.Args = cobra.ExactArgs(spec.Min)
+	default:
+		cmd.Args = cobra.RangeArgs(spec.Min, spec.Max)
+	}
+
+	for i, name := range spec.Names {
+		if i < spec.Min {
+			cmd.Use += fmt.Sprintf(" <%s>", name)
+		} else {
+			cmd.Use += fmt.Sprintf(" [%s]", name)
+		}
+	}
+	if spec.Max < 0 && len(spec.Names) > 0 {
+		cmd.Use += "..."
+	}
+
+	if complete == nil && len(spec.Completions) > 0 {
+		complete = staticCompletion(spec.Completions)
+	}
+	cmd.ValidArgsFunction = complete
+}
+
+// staticCompletion serves a fixed list of shell completions.
+func staticCompletion(values []string) cobra.CompletionFunc {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return values, cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+// completion serves shell completions from a JS function, called as
+// `complete(toComplete, args)`. pick finds the function on the command at
+// index, which loads the script if it only came from the cache.
+func (s *script) completion(index []int, pick func(command *runtime.CommandConfig) goja.Callable) cobra.CompletionFunc {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		command, err := s.lookup(index)
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveError
+		}
+		complete := pick(command)
+		if complete == nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		val, err := complete(goja.Undefined(), s.vm.ToValue(toComplete), s.vm.ToValue(args))
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveError
+		}
+		var out []string
+		if arr, ok := val.Export().([]interface{}); ok {
+			for _, item := range arr {
+				out = append(out, fmt.Sprintf("%v", item))
+			}
+		}
+		return out, cobra.ShellCompDirectiveNoFileComp
+	}
+}
@@ -0,0 +1,171 @@
+package extendcli
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// registerScript registers the script as "<name>.js" on a fresh root command.
+func registerScript(t *testing.T, name, js string) (*cobra.Command, error) {
+	t.Helper()
+	t.Cleanup(func() { osReadFile = os.ReadFile })
+	osReadFile = func(path string) ([]byte, error) {
+		if path == name+".js" {
+			return []byte(js), nil
+		}
+		return os.ReadFile(path)
+	}
+	rootCmd := &cobra.Command{Use: "root"}
+	rootCmd.AddGroup(&cobra.Group{ID: "custom", Title: "Custom Commands"})
+	rootCmd.SetOut(io.Discard)
+	rootCmd.SetErr(io.Discard)
+	return rootCmd, registerJSCommand(rootCmd, name+".js", OriginProject)
+}
+
+// execute runs the root command and returns its stdout and error.
+func execute(rootCmd *cobra.Command, args ...string) (string, error) {
+	oldStdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+	rootCmd.SetArgs(args)
+	err := rootCmd.Execute()
+	_ = w.Close()
+	os.Stdout = oldStdout
+	var buf strings.Builder
+	_, _ = io.Copy(&buf, r)
+	return buf.String(), err
+}
+
+func TestFlagTypes(t *testing.T) {
+	rootCmd, err := registerScript(t, "deploy", `prasmoid.Command({
+		run: (ctx) => {
+			const f = ctx.Flags();
+			console.log(JSON.stringify({
+				retries: f.get("retries"), ratio: f.get("ratio"), tags: f.get("tag"),
+				timeout: f.get("timeout"), target: f.get("target"), dry: f.get("dry"),
+			}));
+		},
+		flags: [
+			{ name: "retries", type: "int", default: 3 },
+			{ name: "ratio", type: "float", default: 0.5 },
+			{ name: "tag", type: "stringArray", shorthand: "t" },
+			{ name: "timeout", type: "duration", default: "30s" },
+			{ name: "target", type: "enum", choices: ["staging", "production"], default: "staging" },
+			{ name: "dry", type: "boolean", default: true },
+		],
+	})`)
+	require.NoError(t, err)
+
+	t.Run("defaults", func(t *testing.T) {
+		out, err := execute(rootCmd, "deploy")
+		require.NoError(t, err)
+		assert.Contains(t, out, `{"retries":3,"ratio":0.5,"tags":[],"timeout":30000,"target":"staging","dry":true}`)
+	})
+
+	t.Run("parsed values", func(t *testing.T) {
+		out, err := execute(rootCmd, "deploy", "--retries", "5", "--ratio", "1.25", "-t", "a", "-t", "b",
+			"--timeout", "1m", "--target", "production", "--dry=false")
+		require.NoError(t, err)
+		assert.Contains(t, out, `{"retries":5,"ratio":1.25,"tags":["a","b"],"timeout":60000,"target":"production","dry":false}`)
+	})
+
+	t.Run("invalid values are rejected by cobra", func(t *testing.T) {
+		_, err := execute(rootCmd, "deploy", "--target", "moon")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `must be one of "staging", "production"`)
+
+		_, err = execute(rootCmd, "deploy", "--retries", "many")
+		require.Error(t, err)
+	})
+
+	t.Run("enum choices are completed", func(t *testing.T) {
+		deploy, _, err := rootCmd.Find([]string{"deploy"})
+		require.NoError(t, err)
+		complete, ok := deploy.GetFlagCompletionFunc("target")
+		require.True(t, ok)
+		values, _ := complete(deploy, nil, "")
+		assert.Equal(t, []string{"staging", "production"}, values)
+	})
+}
+
+func TestFlagValidation(t *testing.T) {
+	tests := []struct {
+		name  string
+		flag  string
+		error string
+	}{
+		{"enum without choices", `{ name: "e", type: "enum" }`, "enum flags need choices"},
+		{"default outside choices", `{ name: "e", type: "string", choices: ["a"], default: "b" }`, `default "b" is not one of "a"`},
+		{"fractional int", `{ name: "n", type: "int", default: 1.5 }`, "invalid default value"},
+		{"bad duration", `{ name: "d", type: "duration", default: "soon" }`, "invalid default value"},
+		{"unknown type", `{ name: "x", type: "date" }`, "unsupported flag type: date"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := registerScript(t, "bad", `prasmoid.Command({ run: () => {}, flags: [`+tt.flag+`] })`)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.error)
+		})
+	}
+}
+
+func TestRequiredFlagsAndArgs(t *testing.T) {
+	rootCmd, err := registerScript(t, "release", `prasmoid.Command({
+		run: (ctx) => console.log("args", ctx.Args().join(",")),
+		args: { min: 1, max: 2, names: ["version", "notes"], completions: (toComplete) => ["v1.0.0", "v2.0.0"].filter((v) => v.startsWith(toComplete)) },
+		flags: [{ name: "token", type: "string", required: true, completions: ["abc", "def"] }],
+	})`)
+	require.NoError(t, err)
+	release, _, err := rootCmd.Find([]string{"release"})
+	require.NoError(t, err)
+
+	t.Run("usage names the arguments", func(t *testing.T) {
+		assert.Equal(t, "release <version> [notes]", release.Use)
+	})
+
+	t.Run("required flag", func(t *testing.T) {
+		_, err := execute(rootCmd, "release", "v1")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `required flag(s) "token" not set`)
+	})
+
+	t.Run("argument count", func(t *testing.T) {
+		_, err := execute(rootCmd, "release", "--token", "x")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "accepts between 1 and 2 arg(s)")
+
+		_, err = execute(rootCmd, "release", "--token", "x", "a", "b", "c")
+		require.Error(t, err)
+
+		out, err := execute(rootCmd, "release", "--token", "x", "v1", "notes")
+		require.NoError(t, err)
+		assert.Contains(t, out, "args v1,notes")
+	})
+
+	t.Run("completions", func(t *testing.T) {
+		values, _ := release.ValidArgsFunction(release, nil, "v2")
+		assert.Equal(t, []string{"v2.0.0"}, values)
+
+		complete, ok := release.GetFlagCompletionFunc("token")
+		require.True(t, ok)
+		values, _ = complete(release, nil, "")
+		assert.Equal(t, []string{"abc", "def"}, values)
+	})
+}
+
+func TestAnswerFlag(t *testing.T) {
+	rootCmd, err := registerScript(t, "greet", `prasmoid.Command({
+		run: () => console.log("Hello,", prompt.input("Name?", { name: "name" })),
+	})`)
+	require.NoError(t, err)
+
+	out, err := execute(rootCmd, "greet", "--answer", "name=Plasmoid")
+	require.NoError(t, err)
+	assert.Contains(t, out, "Hello, Plasmoid")
+}
@@ -0,0 +1,158 @@
+/*
+Copyright 2025 PRAS
+*/
+package extendcli
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/fatih/color"
+
+	"github.com/PRASSamin/prasmoid/types"
+)
+
+// Origins of the built-in command sources.
+const (
+	OriginProject = "project"
+	OriginUser    = "user"
+)
+
+// Source is a directory custom commands are discovered in.
+type Source struct {
+	// Origin is where the commands come from: OriginProject, OriginUser, or
+	// the entry of commands.sources.
+	Origin string
+	Dir    string
+	// Git is the repository a source is cloned from, if any.
+	Git string
+	// Ref is the branch or tag of Git to check out, from a "url#ref" entry.
+	Ref string
+}
+
+// Sources lists the directories commands are discovered in, by precedence:
+// the project's commands directory, then commands.sources in order, then the
+// user's commands directory. The first source to define a name wins.
+func Sources(config types.Config) []Source {
+	sources := []Source{{Origin: OriginProject, Dir: config.Commands.Dir}}
+	for _, entry := range config.Commands.Sources {
+		source, err := resolveSource(entry)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, color.RedString("Invalid command source %q: %v", entry, err))
+			continue
+		}
+		sources = append(sources, source)
+	}
+	if dir, err := osUserConfigDir(); err == nil {
+		sources = append(sources, Source{Origin: OriginUser, Dir: filepath.Join(dir, "prasmoid", "commands")})
+	}
+	return sources
+}
+
+// gitURL matches the remote repositories commands.sources may list.
+var gitURL = regexp.MustCompile(`^(https?|ssh|git|file)://|^[\w.-]+@[\w.-]+:|\.git(#.*)?$`)
+
+// resolveSource turns an entry of commands.sources into a Source. Git
+// repositories are cached in the cache directory, local paths are relative
+// to the project.
+func resolveSource(entry string) (Source, error) {
+	entry = strings.TrimSpace(entry)
+	if entry == "" {
+		return Source{}, fmt.Errorf("empty entry")
+	}
+	if !gitURL.MatchString(entry) {
+		dir := entry
+		if rest, ok := strings.CutPrefix(dir, "~/"); ok {
+			home, err := osUserHomeDir()
+			if err != nil {
+				return Source{}, err
+			}
+			dir = filepath.Join(home, rest)
+		}
+		return Source{Origin: entry, Dir: dir}, nil
+	}
+
+	url, ref, _ := strings.Cut(entry, "#")
+	cacheDir, err := osUserCacheDir()
+	if err != nil {
+		cacheDir = os.TempDir()
+	}
+	return Source{Origin: entry, Dir: filepath.Join(cacheDir, "prasmoid", "sources", sourceDirName(entry)), Git: url, Ref: ref}, nil
+}
+
+// sourceDirName names the cache directory of a git source after its
+// repository, with a hash telling apart sources of the same name.
+func sourceDirName(entry string) string {
+	url, _, _ := strings.Cut(entry, "#")
+	name := strings.TrimSuffix(filepath.Base(strings.TrimRight(url, "/")), ".git")
+	return fmt.Sprintf("%s-%x", name, sha256.Sum256([]byte(entry)))[:len(name)+13]
+}
+
+// Fetched reports whether the commands of a source are on disk. Git sources
+// are only cloned by Update, so that discovering commands, which happens on
+// every run, never waits on the network or retries a failing clone.
+func (s Source) Fetched() bool {
+	if s.Git == "" {
+		return true
+	}
+	_, err := osStat(s.Dir)
+	return err == nil
+}
+
+// Update fetches the latest commands of a git source, cloning it the first
+// time. Local sources are left alone.
+func (s Source) Update() error {
+	if s.Git == "" {
+		return nil
+	}
+	return s.clone()
+}
+
+// clone replaces the cached copy of a git source with a fresh shallow clone.
+func (s Source) clone() error {
+	if err := osMkdirAll(filepath.Dir(s.Dir), 0o755); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(os.Stderr, color.YellowString("Fetching commands from %s...", s.Git))
+	// Clone next to the cache and move it in place, so a failed clone never
+	// leaves a partial source behind.
+	tmp := s.Dir + ".tmp"
+	_ = osRemoveAll(tmp)
+	args := []string{"clone", "--quiet", "--depth", "1"}
+	if s.Ref != "" {
+		args = append(args, "--branch", s.Ref)
+	}
+	if out, err := execCommand("git", append(args, s.Git, tmp)...).CombinedOutput(); err != nil {
+		_ = osRemoveAll(tmp)
+		return fmt.Errorf("git clone failed: %v\n%s", err, strings.TrimSpace(string(out)))
+	}
+	if err := osRemoveAll(s.Dir); err != nil {
+		return err
+	}
+	return osRename(tmp, s.Dir)
+}
+
+// Registered describes a custom command found while discovering commands.
+type Registered struct {
+	Name   string
+	Short  string
+	Path   string
+	Origin string
+	// ShadowedBy is set when the command isn't available because one of the
+	// same name was registered first: the path of its script, or "built-in".
+	ShadowedBy string
+}
+
+// registered lists the commands found by the last discovery, in order.
+var registered []Registered
+
+// RegisteredCommands returns the custom commands found by
+// DiscoverAndRegisterCustomCommands and where each came from.
+func RegisteredCommands() []Registered {
+	return registered
+}
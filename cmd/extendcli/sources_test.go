@@ -0,0 +1,148 @@
+package extendcli
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/PRASSamin/prasmoid/types"
+)
+
+func writeCommand(t *testing.T, dir, file, name, short string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(dir, 0o755))
+	js := `prasmoid.Command({ name: "` + name + `", short: "` + short + `", run: () => {} })`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, file), []byte(js), 0o644))
+}
+
+func TestResolveSource(t *testing.T) {
+	originalHome := osUserHomeDir
+	t.Cleanup(func() { osUserHomeDir = originalHome })
+	osUserHomeDir = func() (string, error) { return "/home/me", nil }
+
+	local, err := resolveSource("../shared/commands")
+	require.NoError(t, err)
+	assert.Equal(t, Source{Origin: "../shared/commands", Dir: "../shared/commands"}, local)
+
+	home, err := resolveSource("~/team/commands")
+	require.NoError(t, err)
+	assert.Equal(t, "/home/me/team/commands", home.Dir)
+
+	for _, entry := range []string{
+		"https://github.com/team/commands.git",
+		"git@github.com:team/commands.git",
+		"https://github.com/team/commands#v1.2.0",
+		"file:///srv/commands",
+	} {
+		source, err := resolveSource(entry)
+		require.NoError(t, err)
+		assert.NotEmpty(t, source.Git, entry)
+		assert.Contains(t, source.Dir, filepath.Join("prasmoid", "sources", "commands-"), entry)
+	}
+
+	tagged, _ := resolveSource("https://github.com/team/commands#v1.2.0")
+	assert.Equal(t, "https://github.com/team/commands", tagged.Git)
+	assert.Equal(t, "v1.2.0", tagged.Ref)
+	latest, _ := resolveSource("https://github.com/team/commands")
+	assert.NotEqual(t, latest.Dir, tagged.Dir)
+
+	_, err = resolveSource(" ")
+	assert.Error(t, err)
+}
+
+func TestCommandSources(t *testing.T) {
+	project := t.TempDir()
+	shared := t.TempDir()
+	userDir, _ := osUserConfigDir()
+	user := filepath.Join(userDir, "prasmoid", "commands")
+	t.Cleanup(func() { _ = os.RemoveAll(user) })
+
+	writeCommand(t, project, "deploy.js", "deploy", "project deploy")
+	writeCommand(t, shared, "deploy.js", "deploy", "shared deploy")
+	writeCommand(t, shared, "lint.js", "lint", "shared lint")
+	writeCommand(t, user, "lint.js", "lint", "user lint")
+	writeCommand(t, user, "notes.js", "notes", "user notes")
+	writeCommand(t, user, "build.js", "build", "user build")
+
+	rootCmd := &cobra.Command{Use: "root"}
+	rootCmd.AddCommand(&cobra.Command{Use: "build"})
+	config := types.Config{Commands: types.ConfigCommands{Dir: project, Sources: []string{shared}}}
+	DiscoverAndRegisterCustomCommands(rootCmd, config)
+
+	shorts := map[string]string{}
+	for _, cmd := range rootCmd.Commands() {
+		shorts[cmd.Name()] = cmd.Short
+	}
+	assert.Equal(t, map[string]string{
+		"build":  "",
+		"deploy": "project deploy",
+		"lint":   "shared lint",
+		"notes":  "user notes",
+	}, shorts)
+
+	assert.Equal(t, []Registered{
+		{Name: "deploy", Short: "project deploy", Path: filepath.Join(project, "deploy.js"), Origin: OriginProject},
+		{Name: "deploy", Short: "shared deploy", Path: filepath.Join(shared, "deploy.js"), Origin: shared, ShadowedBy: filepath.Join(project, "deploy.js")},
+		{Name: "lint", Short: "shared lint", Path: filepath.Join(shared, "lint.js"), Origin: shared},
+		{Name: "build", Short: "user build", Path: filepath.Join(user, "build.js"), Origin: OriginUser, ShadowedBy: "built-in"},
+		{Name: "lint", Short: "user lint", Path: filepath.Join(user, "lint.js"), Origin: OriginUser, ShadowedBy: filepath.Join(shared, "lint.js")},
+		{Name: "notes", Short: "user notes", Path: filepath.Join(user, "notes.js"), Origin: OriginUser},
+	}, RegisteredCommands())
+}
+
+func TestGitSource(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	repo := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", repo, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		out, err := cmd.CombinedOutput()
+		require.NoError(t, err, string(out))
+	}
+	git("init", "--quiet")
+	writeCommand(t, repo, "release.js", "release", "v1")
+	git("add", ".")
+	git("commit", "--quiet", "-m", "v1")
+
+	config := types.Config{Commands: types.ConfigCommands{Dir: t.TempDir(), Sources: []string{"file://" + repo}}}
+	source := Sources(config)[1]
+	require.NotEmpty(t, source.Git)
+
+	// Discovering commands never clones, only updating does.
+	rootCmd := &cobra.Command{Use: "root"}
+	DiscoverAndRegisterCustomCommands(rootCmd, config)
+	assert.Empty(t, rootCmd.Commands())
+	assert.False(t, source.Fetched())
+
+	require.NoError(t, source.Update())
+	assert.True(t, source.Fetched())
+	rootCmd = &cobra.Command{Use: "root"}
+	DiscoverAndRegisterCustomCommands(rootCmd, config)
+	require.Len(t, rootCmd.Commands(), 1)
+	assert.Equal(t, "v1", rootCmd.Commands()[0].Short)
+
+	// The cached clone is used until the source is updated.
+	writeCommand(t, repo, "release.js", "release", "v2")
+	git("commit", "--quiet", "-am", "v2")
+	rootCmd = &cobra.Command{Use: "root"}
+	DiscoverAndRegisterCustomCommands(rootCmd, config)
+	assert.Equal(t, "v1", rootCmd.Commands()[0].Short)
+
+	require.NoError(t, source.Update())
+	rootCmd = &cobra.Command{Use: "root"}
+	DiscoverAndRegisterCustomCommands(rootCmd, config)
+	assert.Equal(t, "v2", rootCmd.Commands()[0].Short)
+
+	bad := Source{Git: filepath.Join(t.TempDir(), "missing"), Dir: filepath.Join(t.TempDir(), "clone")}
+	assert.ErrorContains(t, bad.Update(), "git clone failed")
+	assert.False(t, bad.Fetched())
+	_, err := os.Stat(bad.Dir + ".tmp")
+	assert.True(t, os.IsNotExist(err))
+}
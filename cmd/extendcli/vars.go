@@ -2,6 +2,7 @@ package extendcli
 
 import (
 	"os"
+	"os/exec"
 	"path/filepath"
 
 	"github.com/bmatcuk/doublestar/v4"
@@ -15,6 +16,13 @@ var (
 	osWriteFile         = os.WriteFile
 	osRemoveAll         = os.RemoveAll
 	osCreateTemp        = os.CreateTemp
+	osUserCacheDir      = os.UserCacheDir
+	osUserConfigDir     = os.UserConfigDir
+	osUserHomeDir       = os.UserHomeDir
+	osRename            = os.Rename
+	osExit              = os.Exit
+	execCommand         = exec.Command
 	filepathJoin        = filepath.Join
 	doublestarPathMatch = doublestar.PathMatch
+	osArgs              = func() []string { return os.Args }
 )
@@ -4,18 +4,22 @@ Copyright 2025 PRAS
 package format
 
 import (
+	"bytes"
 	"fmt"
 	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/PRASSamin/prasmoid/cmd"
+	"github.com/PRASSamin/prasmoid/internal/runtime"
 	"github.com/PRASSamin/prasmoid/utils"
 	"github.com/fatih/color"
 	"github.com/fsnotify/fsnotify"
+	"github.com/pmezard/go-difflib/difflib"
 	"github.com/spf13/cobra"
 )
 
@@ -39,10 +43,11 @@ func (w *watcherWrapper) Errors() chan error {
 }
 
 var (
-	utilsIsValidPlasmoid      = utils.IsValidPlasmoid
-	utilsIsQmlFile            = utils.IsQmlFile
-	utilsIsPackageInstalled   = utils.IsPackageInstalled
-	execCommand               = exec.Command
+	utilsIsValidPlasmoid    = utils.IsValidPlasmoid
+	utilsIsQmlFile          = utils.IsQmlFile
+	utilsIsPackageInstalled = utils.IsPackageInstalled
+	execCommand             = exec.Command
+	osExit                  = os.Exit
 	// for testing
 	filepathWalk  = filepath.Walk
 	timeAfterFunc = time.AfterFunc
@@ -57,11 +62,17 @@ var (
 
 var watch bool
 var dir string
+var check bool
+var showDiff bool
 
 func init() {
 	FormatCmd.Flags().BoolVarP(&watch, "watch", "w", false, "watch for changes")
 	FormatCmd.Flags().StringVarP(&dir, "dir", "d", "./contents", "directory to format")
-	
+	FormatCmd.Flags().BoolVar(&check, "check", false, "list unformatted files without changing them, and exit non-zero if there are any")
+	FormatCmd.Flags().BoolVar(&showDiff, "diff", false, "print the changes formatting would make without making them")
+	FormatCmd.MarkFlagsMutuallyExclusive("watch", "check")
+	FormatCmd.MarkFlagsMutuallyExclusive("watch", "diff")
+
 	if utilsIsPackageInstalled("qmlformat") {
 		FormatCmd.Short = "Prettify QML files"
 	} else {
@@ -69,6 +80,22 @@ func init() {
 	}
 
 	cmd.RootCmd.AddCommand(FormatCmd)
+
+	runtime.Project.Format = func(files []string) error {
+		if !utilsIsPackageInstalled("qmlformat") {
+			return fmt.Errorf("formatting needs qmlformat; use `prasmoid fix` to install it")
+		}
+		if !utilsIsValidPlasmoid() {
+			return fmt.Errorf("current directory is not a valid plasmoid")
+		}
+		if len(files) == 0 {
+			var err error
+			if files, err = qmlFiles("./contents"); err != nil {
+				return err
+			}
+		}
+		return formatFiles(files)
+	}
 }
 
 // FormatCmd represents the format command
@@ -89,7 +116,11 @@ var FormatCmd = &cobra.Command{
 
 		crrPath, _ := os.Getwd()
 		relPath := filepath.Join(crrPath, dir)
-		if watch {
+		if check || showDiff {
+			if !checkFormat(relPath, showDiff) && check {
+				osExit(1)
+			}
+		} else if watch {
 			prettifyOnWatch(relPath, make(chan bool))
 		} else {
 			prettify(relPath)
@@ -186,9 +217,20 @@ func prettifyOnWatch(path string, done chan bool) {
 }
 
 func prettify(path string) {
-	var files []string
+	files, err := qmlFiles(path)
+	if err != nil {
+		fmt.Println(color.RedString("Error walking directory for prettify: %v", err))
+		return
+	}
 
-	if err := filepathWalk(path, func(path string, info os.FileInfo, err error) error {
+	format(files)
+	fmt.Println(color.GreenString("Formatted %d files.", len(files)))
+}
+
+// qmlFiles lists the QML files under path.
+func qmlFiles(path string) ([]string, error) {
+	var files []string
+	err := filepathWalk(path, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
@@ -199,21 +241,124 @@ func prettify(path string) {
 			files = append(files, path)
 		}
 		return nil
-	}); err != nil {
-		fmt.Println(color.RedString("Error walking directory for prettify: %v", err))
-		return
-	}
-
-	format(files)
-	fmt.Println(color.GreenString("Formatted %d files.", len(files)))
+	})
+	return files, err
 }
 
 func format(files []string) {
+	if err := formatFiles(files); err != nil {
+		fmt.Println(color.RedString("Failed to format qml files: %v", err))
+	}
+}
+
+func formatFiles(files []string) error {
 	formatter := execCommand("qmlformat", "-i")
 	formatter.Args = append(formatter.Args, files...)
 	formatter.Stdout = os.Stdout
 	formatter.Stderr = os.Stderr
+	return formatter.Run()
+}
+
+// checkFormat lists the QML files under path that aren't formatted, with
+// their diffs if showDiff, and reports whether they all are.
+func checkFormat(path string, showDiff bool) bool {
+	files, err := qmlFiles(path)
+	if err != nil {
+		fmt.Println(color.RedString("Error walking directory for check: %v", err))
+		return false
+	}
+
+	crrPath, _ := os.Getwd()
+	var unformatted []string
+	failed := false
+	for _, file := range files {
+		name := file
+		if rel, err := filepath.Rel(crrPath, file); err == nil {
+			name = rel
+		}
+		diff, err := formatDiff(file, name)
+		if err != nil {
+			fmt.Println(color.RedString("Failed to format %s: %v", name, err))
+			failed = true
+			continue
+		}
+		if diff == "" {
+			continue
+		}
+		unformatted = append(unformatted, name)
+		if showDiff {
+			printDiff(diff)
+		}
+	}
+
+	if len(unformatted) == 0 {
+		if !failed {
+			fmt.Println(color.GreenString("All %d files are formatted.", len(files)))
+		}
+		return !failed
+	}
+	fmt.Println(color.YellowString("%d of %d files are not formatted:", len(unformatted), len(files)))
+	for _, name := range unformatted {
+		fmt.Printf("  - %s\n", name)
+	}
+	fmt.Println(color.BlueString("- Run `prasmoid format` to format them."))
+	return false
+}
+
+// formatDiff is the unified diff between file and what qmlformat makes of
+// it, with name in its headers, or "" if file is formatted.
+func formatDiff(file, name string) (string, error) {
+	current, err := os.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	var formatted, stderr bytes.Buffer
+	formatter := execCommand("qmlformat", file)
+	formatter.Stdout = &formatted
+	formatter.Stderr = &stderr
 	if err := formatter.Run(); err != nil {
-		fmt.Println(color.RedString("Failed to format qml files: %v", err))
+		if msg := bytes.TrimSpace(stderr.Bytes()); len(msg) > 0 {
+			return "", fmt.Errorf("%v: %s", err, msg)
+		}
+		return "", err
+	}
+	if bytes.Equal(current, formatted.Bytes()) {
+		return "", nil
+	}
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        splitLines(string(current)),
+		B:        splitLines(formatted.String()),
+		FromFile: "a/" + filepath.ToSlash(name),
+		ToFile:   "b/" + filepath.ToSlash(name),
+		Context:  3,
+	})
+}
+
+// printDiff prints a unified diff, colored when stdout is a terminal.
+func printDiff(diff string) {
+	for _, line := range splitLines(diff) {
+		switch {
+		case strings.HasPrefix(line, "---"), strings.HasPrefix(line, "+++"):
+			fmt.Print(color.New(color.Bold).Sprint(line))
+		case strings.HasPrefix(line, "@@"):
+			fmt.Print(color.CyanString(line))
+		case strings.HasPrefix(line, "-"):
+			fmt.Print(color.RedString(line))
+		case strings.HasPrefix(line, "+"):
+			fmt.Print(color.GreenString(line))
+		default:
+			fmt.Print(line)
+		}
+	}
+}
+
+// splitLines splits s after each newline, ending its last line with one if
+// it doesn't.
+func splitLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		return lines[:len(lines)-1]
 	}
+	lines[len(lines)-1] += "\n"
+	return lines
 }
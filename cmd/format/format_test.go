@@ -390,3 +390,118 @@ func TestFormatError(t *testing.T) {
 	output := buf.String()
 	assert.Contains(t, output, "Failed to format qml files")
 }
+
+// fakeQmlformat prints files with tabs indented by four spaces instead, like
+// qmlformat without -i.
+func fakeQmlformat(name string, arg ...string) *exec.Cmd {
+	if len(arg) == 1 && strings.HasSuffix(arg[0], "broken.qml") {
+		return exec.Command("sh", "-c", "echo 'broken.qml:1:1: Expected token' >&2; exit 1")
+	}
+	return exec.Command("sed", `s/\t/    /g`, arg[len(arg)-1])
+}
+
+func TestCheckFormat(t *testing.T) {
+	originalExecCommand := execCommand
+	originalUtilsIsQmlFile := utilsIsQmlFile
+	t.Cleanup(func() {
+		execCommand = originalExecCommand
+		utilsIsQmlFile = originalUtilsIsQmlFile
+	})
+	execCommand = fakeQmlformat
+	utilsIsQmlFile = func(file string) bool { return strings.HasSuffix(file, ".qml") }
+
+	project := t.TempDir()
+	oldWd, _ := os.Getwd()
+	require.NoError(t, os.Chdir(project))
+	t.Cleanup(func() { _ = os.Chdir(oldWd) })
+	contents := filepath.Join(project, "contents", "ui")
+	require.NoError(t, os.MkdirAll(contents, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(contents, "main.qml"), []byte("Item {\n    width: 10\n}\n"), 0644))
+
+	run := func(showDiff bool) (string, bool) {
+		oldStdout := os.Stdout
+		r, w, _ := os.Pipe()
+		os.Stdout = w
+		color.Output = w
+		ok := checkFormat(filepath.Join(project, "contents"), showDiff)
+		_ = w.Close()
+		os.Stdout = oldStdout
+		color.Output = os.Stdout
+		var buf bytes.Buffer
+		_, _ = io.Copy(&buf, r)
+		return buf.String(), ok
+	}
+
+	t.Run("formatted", func(t *testing.T) {
+		output, ok := run(true)
+		assert.True(t, ok)
+		assert.Contains(t, output, "All 1 files are formatted.")
+	})
+
+	unformatted := "Item {\n\theight: 10\n}\n"
+	require.NoError(t, os.WriteFile(filepath.Join(contents, "Label.qml"), []byte(unformatted), 0644))
+
+	t.Run("lists unformatted files", func(t *testing.T) {
+		output, ok := run(false)
+		assert.False(t, ok)
+		assert.Contains(t, output, "1 of 2 files are not formatted:\n  - contents/ui/Label.qml\n")
+		assert.NotContains(t, output, "@@")
+	})
+
+	t.Run("prints diffs", func(t *testing.T) {
+		output, ok := run(true)
+		assert.False(t, ok)
+		assert.Contains(t, output, "--- a/contents/ui/Label.qml\n+++ b/contents/ui/Label.qml\n@@ -1,3 +1,3 @@\n Item {\n-\theight: 10\n+    height: 10\n }\n")
+		assert.NotContains(t, output, "main.qml")
+	})
+
+	t.Run("leaves files alone", func(t *testing.T) {
+		data, err := os.ReadFile(filepath.Join(contents, "Label.qml"))
+		require.NoError(t, err)
+		assert.Equal(t, unformatted, string(data))
+	})
+
+	t.Run("qmlformat fails", func(t *testing.T) {
+		require.NoError(t, os.Remove(filepath.Join(contents, "Label.qml")))
+		require.NoError(t, os.WriteFile(filepath.Join(contents, "broken.qml"), []byte("Item {\n"), 0644))
+		output, ok := run(false)
+		assert.False(t, ok)
+		assert.Contains(t, output, "Failed to format contents/ui/broken.qml: exit status 1: broken.qml:1:1: Expected token")
+	})
+}
+
+func TestFormatCmdCheck(t *testing.T) {
+	originalIsPackageInstalled := utilsIsPackageInstalled
+	originalExecCommand := execCommand
+	originalOsExit := osExit
+	t.Cleanup(func() {
+		utilsIsPackageInstalled = originalIsPackageInstalled
+		execCommand = originalExecCommand
+		osExit = originalOsExit
+		check = false
+	})
+	utilsIsPackageInstalled = func(pkg string) bool { return true }
+	execCommand = fakeQmlformat
+	code := 0
+	osExit = func(c int) { code = c }
+
+	_, cleanup := tests.SetupTestProject(t)
+	defer cleanup()
+	require.NoError(t, os.WriteFile(filepath.Join("contents", "ui", "Label.qml"), []byte("Item {\n\theight: 10\n}\n"), 0644))
+
+	oldStdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+	color.Output = w
+
+	check = true
+	FormatCmd.Run(FormatCmd, []string{})
+	_ = w.Close()
+
+	var buf bytes.Buffer
+	_, _ = io.Copy(&buf, r)
+	os.Stdout = oldStdout
+	color.Output = os.Stdout
+	assert.Equal(t, 1, code)
+	assert.Contains(t, buf.String(), "contents/ui/Label.qml")
+}
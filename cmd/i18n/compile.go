@@ -12,6 +12,7 @@ import (
 	"strings"
 
 	root "github.com/PRASSamin/prasmoid/cmd"
+	"github.com/PRASSamin/prasmoid/internal/runtime"
 	"github.com/PRASSamin/prasmoid/types"
 	"github.com/PRASSamin/prasmoid/utils"
 	"github.com/fatih/color"
@@ -30,6 +31,16 @@ func init() {
 	}
 
 	I18nCmd.AddCommand(I18nCompileCmd)
+
+	runtime.Project.I18nCompile = func(silent bool) error {
+		if !utilsIsPackageInstalled("msgfmt") {
+			return fmt.Errorf("compiling translations needs msgfmt; use `prasmoid fix` to install it")
+		}
+		if !utilsIsValidPlasmoid() {
+			return fmt.Errorf("current directory is not a valid plasmoid")
+		}
+		return CompileI18n(root.ConfigRC, silent)
+	}
 }
 
 var I18nCompileCmd = &cobra.Command{
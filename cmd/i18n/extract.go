@@ -16,6 +16,8 @@ import (
 	"time"
 
 	root "github.com/PRASSamin/prasmoid/cmd"
+	"github.com/PRASSamin/prasmoid/internal/runtime"
+	"github.com/PRASSamin/prasmoid/types"
 	"github.com/PRASSamin/prasmoid/utils"
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
@@ -31,6 +33,16 @@ func init() {
 	}
 
 	I18nCmd.AddCommand(I18nExtractCmd)
+
+	runtime.Project.I18nExtract = func(noPo bool) error {
+		if !utilsIsPackageInstalled("msginit") || !utilsIsPackageInstalled("msgmerge") || !utilsIsPackageInstalled("xgettext") {
+			return fmt.Errorf("extracting translations needs msginit, msgmerge and xgettext; use `prasmoid fix` to install them")
+		}
+		if !utilsIsValidPlasmoid() {
+			return fmt.Errorf("current directory is not a valid plasmoid")
+		}
+		return ExtractI18n(root.ConfigRC, noPo)
+	}
 }
 
 var I18nExtractCmd = &cobra.Command{
@@ -49,27 +61,34 @@ var I18nExtractCmd = &cobra.Command{
 
 		color.Cyan("Extracting translatable strings...")
 
-		// Create translations directory if it doesn't exist
-		translationsDir := root.ConfigRC.I18n.Dir
-		_ = osMkdirAll(translationsDir, 0755)
-
-		// Run xgettext to extract strings
-		if err := runXGettext(translationsDir); err != nil {
-			fmt.Println(color.RedString("Failed to extract strings: %v", err))
-			return
+		noPo, _ := cmd.Flags().GetBool("no-po")
+		if err := ExtractI18n(root.ConfigRC, noPo); err != nil {
+			fmt.Println(color.RedString(err.Error()))
 		}
+	},
+}
 
-		fmt.Println(color.GreenString("Successfully extracted strings to %s/template.pot", translationsDir))
+// ExtractI18n extracts the translatable strings into the translations dir
+// and, unless noPo is set, updates the .po files of the configured locales.
+func ExtractI18n(config types.Config, noPo bool) error {
+	// Create translations directory if it doesn't exist
+	translationsDir := config.I18n.Dir
+	_ = osMkdirAll(translationsDir, 0755)
 
-		// Generate .po files for configured locales
-		isPoGen, _ := cmd.Flags().GetBool("no-po")
-		if !isPoGen {
-			if err := generatePoFiles(translationsDir); err != nil {
-				fmt.Println(color.RedString("Failed to generate .po files: %v", err))
-				return
-			}
+	// Run xgettext to extract strings
+	if err := runXGettext(translationsDir); err != nil {
+		return fmt.Errorf("failed to extract strings: %v", err)
+	}
+
+	fmt.Println(color.GreenString("Successfully extracted strings to %s/template.pot", translationsDir))
+
+	// Generate .po files for configured locales
+	if !noPo {
+		if err := generatePoFiles(translationsDir); err != nil {
+			return fmt.Errorf("failed to generate .po files: %v", err)
 		}
-	},
+	}
+	return nil
 }
 
 func generatePoFiles(poDir string) error {
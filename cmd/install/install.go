@@ -11,10 +11,18 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/PRASSamin/prasmoid/cmd"
+	"github.com/PRASSamin/prasmoid/internal/runtime"
 )
 
 func init() {
 	cmd.RootCmd.AddCommand(InstallCmd)
+
+	runtime.Project.Install = func() error {
+		if !utilsIsValidPlasmoid() {
+			return fmt.Errorf("current directory is not a valid plasmoid")
+		}
+		return InstallPlasmoid()
+	}
 }
 
 // InstallCmd represents the production command
@@ -10,6 +10,7 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/PRASSamin/prasmoid/cmd"
+	"github.com/PRASSamin/prasmoid/internal/runtime"
 )
 
 var linkWhere bool
@@ -17,6 +18,17 @@ var linkWhere bool
 func init() {
 	LinkCmd.Flags().BoolVarP(&linkWhere, "where", "w", false, "show where the plasmoid is linked.")
 	cmd.RootCmd.AddCommand(LinkCmd)
+
+	runtime.Project.Link = func() (string, error) {
+		if !utilsIsValidPlasmoid() {
+			return "", fmt.Errorf("current directory is not a valid plasmoid")
+		}
+		dest, err := utilsGetDevDest()
+		if err != nil {
+			return "", err
+		}
+		return dest, LinkPlasmoid(dest)
+	}
 }
 
 // devCmd represents the dev command
@@ -0,0 +1,74 @@
+package preview
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// defaultCheckTimeout is how long --check waits at most for the viewers to
+// load the plasmoid by default.
+const defaultCheckTimeout = 10 * time.Second
+
+// checkEnv runs the viewers of --check without a display.
+var checkEnv = []string{"QT_QPA_PLATFORM=offscreen"}
+
+// checkLoadedMarker is what the bootstrap of --check logs once it loaded the
+// plasmoid, which ends the check.
+const checkLoadedMarker = "prasmoid: the plasmoid loaded"
+
+// checkBootstrap is the body of the bootstrap of --check. It loads the
+// plasmoid's main.qml, formatted in as a JSON string, and then logs the
+// marker, also formatted in. The Loader loads synchronously, so any error of
+// the plasmoid is logged before the marker.
+const checkBootstrap = `
+    Component.onCompleted: {
+        loader.source = %s;
+        console.log(%s);
+    }
+`
+
+// writeCheckBootstrap installs the bootstrap of --check into the plasmoid
+// installed in env.
+func writeCheckBootstrap(env *IsolatedEnv) error {
+	main, err := filepath.Abs(filepath.Join("contents", "ui", "main.qml"))
+	if err != nil {
+		return err
+	}
+	source, _ := json.Marshal("file://" + main)
+	marker, _ := json.Marshal(checkLoadedMarker)
+	return WriteBootstrap(env, "preview --check", fmt.Sprintf(checkBootstrap, source, marker))
+}
+
+// reportCheck prints the summary of --check, the problems logs saw and err,
+// the error of the viewers, and reports whether the plasmoid passed.
+func reportCheck(logs *LogPrinter, err error) bool {
+	problems := logs.problemsSeen()
+	if err == nil && len(problems) == 0 {
+		fmt.Println(color.GreenString("Check passed: the plasmoid loaded without QML errors."))
+		return true
+	}
+
+	fmt.Println(color.RedString("\nCheck failed:"))
+	if err != nil {
+		fmt.Printf("  - %s\n", color.RedString("plasmoidviewer failed: %v", err))
+	}
+	var errors, warnings int
+	for _, problem := range problems {
+		if problem.level >= levelError {
+			errors++
+		} else {
+			warnings++
+		}
+		message := problem.message
+		if location := problem.location(); location != "" {
+			message = location + " " + message
+		}
+		fmt.Printf("  - %s %s\n", problem.level.colorize(problem.level.String()), message)
+	}
+	fmt.Printf("\nQML: %s, %s\n", color.RedString("%d errors", errors), color.YellowString("%d warnings", warnings))
+	return false
+}
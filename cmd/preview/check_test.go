@@ -0,0 +1,118 @@
+package preview
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeViewer is a plasmoidviewer that checks it runs offscreen in an
+// isolated environment with the bootstrap of --check, logs like Qt, says the
+// plasmoid loaded like the bootstrap and waits to be stopped.
+const fakeViewer = `#!/bin/sh
+[ "$QT_QPA_PLATFORM" = offscreen ] || exit 3
+case "$XDG_DATA_HOME" in *prasmoid-preview-*) ;; *) exit 4 ;; esac
+[ -f "$XDG_DATA_HOME/plasma/plasmoids/org.example.clock/metadata.json" ] || exit 5
+main="$XDG_DATA_HOME/plasma/plasmoids/org.example.clock/contents/ui/main.qml"
+grep -q 'loader.source = "file://.*/contents/ui/main.qml"' "$main" || exit 7
+[ -z "$FAKE_VIEWER_CRASH" ] || exit 6
+log() {
+	printf '%s\n' "$QT_MESSAGE_PATTERN" | sed "s|%{type}|$1|; s|%{file}||; s|%{line}|0|; s|%{message}|$2|" >&2
+}
+if [ -n "$FAKE_VIEWER_WARNING" ]; then
+	log warning "file://$PWD/contents/ui/main.qml:2:5: $FAKE_VIEWER_WARNING"
+fi
+[ -n "$FAKE_VIEWER_HANG" ] || log debug "$(sed -n 's/.*console.log("\(.*\)");/\1/p' "$main")"
+exec sleep 10
+`
+
+func TestPreviewCheck(t *testing.T) {
+	previewTestMutex.Lock()
+	defer previewTestMutex.Unlock()
+
+	project := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(project, "contents", "ui"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(project, "contents", "ui", "main.qml"), []byte("Item {\n    foo: 1\n}\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(project, "metadata.json"), []byte(`{"KPlugin": {"Id": "org.example.clock"}}`), 0644))
+	originalWd, _ := os.Getwd()
+	require.NoError(t, os.Chdir(project))
+	t.Cleanup(func() { _ = os.Chdir(originalWd) })
+
+	bin := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(bin, "plasmoidviewer"), []byte(fakeViewer), 0755))
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	originalIsPackageInstalled := utilsIsPackageInstalled
+	originalIsValidPlasmoid := utilsIsValidPlasmoid
+	originalOsExit := osExit
+	t.Cleanup(func() {
+		utilsIsPackageInstalled = originalIsPackageInstalled
+		utilsIsValidPlasmoid = originalIsValidPlasmoid
+		osExit = originalOsExit
+		_ = PreviewCmd.Flags().Set("check", "false")
+		_ = PreviewCmd.Flags().Set("timeout", defaultCheckTimeout.String())
+	})
+	utilsIsPackageInstalled = func(pkg string) bool { return true }
+	utilsIsValidPlasmoid = func() bool { return true }
+	require.NoError(t, PreviewCmd.Flags().Set("check", "true"))
+	require.NoError(t, PreviewCmd.Flags().Set("timeout", "5s"))
+
+	check := func() (string, int) {
+		code := 0
+		osExit = func(c int) { code = c }
+
+		oldStdout := os.Stdout
+		r, w, _ := os.Pipe()
+		os.Stdout = w
+		color.Output = w
+		PreviewCmd.Run(PreviewCmd, []string{})
+		_ = w.Close()
+		os.Stdout = oldStdout
+		color.Output = oldStdout
+
+		var buf bytes.Buffer
+		_, _ = io.Copy(&buf, r)
+		return buf.String(), code
+	}
+
+	t.Run("passes", func(t *testing.T) {
+		start := time.Now()
+		output, code := check()
+		assert.Equal(t, 0, code)
+		assert.Contains(t, output, "Check passed")
+		assert.NotContains(t, output, checkLoadedMarker)
+		assert.Less(t, time.Since(start), 5*time.Second, "the viewer is stopped once the plasmoid loaded")
+	})
+
+	t.Run("fails when the plasmoid does not load in time", func(t *testing.T) {
+		t.Setenv("FAKE_VIEWER_HANG", "1")
+		require.NoError(t, PreviewCmd.Flags().Set("timeout", "300ms"))
+		t.Cleanup(func() { _ = PreviewCmd.Flags().Set("timeout", "5s") })
+		output, code := check()
+		assert.Equal(t, 1, code)
+		assert.Contains(t, output, "the plasmoid didn't load within 300ms")
+	})
+
+	t.Run("fails on QML warnings of the project", func(t *testing.T) {
+		t.Setenv("FAKE_VIEWER_WARNING", `Cannot assign to non-existent property "foo"`)
+		output, code := check()
+		assert.Equal(t, 1, code)
+		assert.Contains(t, output, "> 2 |     foo: 1")
+		assert.Contains(t, output, `  - warn contents/ui/main.qml:2:5 Cannot assign to non-existent property "foo"`)
+		assert.Contains(t, output, "QML: 0 errors, 1 warnings")
+	})
+
+	t.Run("fails when the viewer does", func(t *testing.T) {
+		t.Setenv("FAKE_VIEWER_CRASH", "1")
+		output, code := check()
+		assert.Equal(t, 1, code)
+		assert.Contains(t, output, "plasmoidviewer failed: exit status 6")
+	})
+}
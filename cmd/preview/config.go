@@ -0,0 +1,272 @@
+package preview
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// appletsrcName is the file, in the config home, where plasmoidviewer keeps
+// its containments and the configuration of their applets.
+const appletsrcName = "plasmoidviewer-appletsrc"
+
+var (
+	appletGroupPattern      = regexp.MustCompile(`^\[Containments\]\[(\d+)\]\[Applets\]\[(\d+)\]$`)
+	containmentGroupPattern = regexp.MustCompile(`^\[Containments\]\[(\d+)\]$`)
+)
+
+// ConfigGroup is a [group] of a KConfig file, and its entries in order.
+type ConfigGroup struct {
+	name    string
+	entries []configEntry
+}
+
+type configEntry struct {
+	key, value string
+}
+
+func (g *ConfigGroup) get(key string) (string, bool) {
+	for _, entry := range g.entries {
+		if entry.key == key {
+			return entry.value, true
+		}
+	}
+	return "", false
+}
+
+// Set sets key to value, keeping the place of an existing entry.
+func (g *ConfigGroup) Set(key, value string) {
+	for i, entry := range g.entries {
+		if entry.key == key {
+			g.entries[i].value = value
+			return
+		}
+	}
+	g.entries = append(g.entries, configEntry{key, value})
+}
+
+// ConfigFile is a KConfig file. Its first group, named "", holds the entries
+// in front of any group header. Comments are dropped.
+type ConfigFile []*ConfigGroup
+
+// ReadConfigFile reads the KConfig file at path.
+func ReadConfigFile(path string) (ConfigFile, error) {
+	file := ConfigFile{{}}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return file, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	group := file[0]
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+		case strings.HasPrefix(line, "["):
+			group = file.Group(line)
+		default:
+			key, value, _ := strings.Cut(line, "=")
+			group.Set(strings.TrimSpace(key), strings.TrimSpace(value))
+		}
+	}
+	return file, scanner.Err()
+}
+
+// Write writes f to path, creating its directory if needed.
+func (f ConfigFile) Write(path string) error {
+	var buf bytes.Buffer
+	for _, group := range f {
+		if group.name == "" && len(group.entries) == 0 {
+			continue
+		}
+		if buf.Len() > 0 {
+			buf.WriteString("\n")
+		}
+		if group.name != "" {
+			buf.WriteString(group.name + "\n")
+		}
+		for _, entry := range group.entries {
+			buf.WriteString(entry.key + "=" + entry.value + "\n")
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0644)
+}
+
+// lookup is the group called name, or nil.
+func (f ConfigFile) lookup(name string) *ConfigGroup {
+	for _, group := range f {
+		if group.name == name {
+			return group
+		}
+	}
+	return nil
+}
+
+// Group is the group called name, which is added if it's missing.
+func (f *ConfigFile) Group(name string) *ConfigGroup {
+	if group := f.lookup(name); group != nil {
+		return group
+	}
+	group := &ConfigGroup{name: name}
+	*f = append(*f, group)
+	return group
+}
+
+// applets are the groups of the applets of the plasmoid id.
+func (f ConfigFile) applets(id string) []string {
+	var applets []string
+	for _, group := range f {
+		if plugin, _ := group.get("plugin"); plugin == id && appletGroupPattern.MatchString(group.name) {
+			applets = append(applets, group.name)
+		}
+	}
+	return applets
+}
+
+// addApplet adds an applet of the plasmoid id to the first containment, and
+// returns its group. Applets and containments share their ids.
+func (f *ConfigFile) addApplet(id string) string {
+	containment, last := "", 0
+	for _, group := range *f {
+		if m := containmentGroupPattern.FindStringSubmatch(group.name); m != nil {
+			if containment == "" {
+				containment = m[1]
+			}
+			n, _ := strconv.Atoi(m[1])
+			last = max(last, n)
+		}
+		if m := appletGroupPattern.FindStringSubmatch(group.name); m != nil {
+			n, _ := strconv.Atoi(m[2])
+			last = max(last, n)
+		}
+	}
+	if containment == "" {
+		last++
+		containment = strconv.Itoa(last)
+	}
+	name := fmt.Sprintf("[Containments][%s][Applets][%d]", containment, last+1)
+	f.Group(name).Set("plugin", id)
+	return name
+}
+
+// parseConfigValues reads the values of --config, key=value for the General
+// group or Group/key=value, into the groups below an applet's
+// [Configuration] they belong to.
+func parseConfigValues(values []string) (map[string]map[string]string, error) {
+	config := map[string]map[string]string{}
+	for _, value := range values {
+		key, val, ok := strings.Cut(value, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid --config %q, expected key=value", value)
+		}
+		group := "General"
+		if i := strings.LastIndex(key, "/"); i >= 0 {
+			group, key = key[:i], key[i+1:]
+		}
+		group = "[" + strings.ReplaceAll(group, "/", "][") + "]"
+		if config[group] == nil {
+			config[group] = map[string]string{}
+		}
+		config[group][key] = strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(val)
+	}
+	return config, nil
+}
+
+// appletConfig keeps the configuration of the previewed plasmoid across
+// restarts of its viewers: the values of --config to begin with, and what
+// was changed in its settings since.
+type appletConfig struct {
+	mu sync.Mutex
+	// path is the viewers' appletsrc, id the plasmoid's.
+	path string
+	id   string
+	// values are the entries below the applet's [Configuration], by group,
+	// e.g. "[General]".
+	values map[string]map[string]string
+}
+
+// newAppletConfig keeps the configuration of the plasmoid id in the
+// appletsrc of configHome, starting with values.
+func newAppletConfig(configHome, id string, values map[string]map[string]string) *appletConfig {
+	return &appletConfig{path: filepath.Join(configHome, appletsrcName), id: id, values: values}
+}
+
+// save takes the configuration of the plasmoid from the appletsrc, once its
+// viewers exited and wrote it. If the plasmoid isn't there, e.g. because its
+// viewer crashed, the configuration is kept.
+func (c *appletConfig) save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	file, err := ReadConfigFile(c.path)
+	if err != nil {
+		return err
+	}
+	applets := file.applets(c.id)
+	if len(applets) == 0 {
+		return nil
+	}
+	prefix := applets[0] + "[Configuration]"
+	values := map[string]map[string]string{}
+	for _, group := range file {
+		sub, ok := strings.CutPrefix(group.name, prefix)
+		if !ok || sub == "" {
+			continue
+		}
+		values[sub] = map[string]string{}
+		for _, entry := range group.entries {
+			values[sub][entry.key] = entry.value
+		}
+	}
+	c.values = values
+	return nil
+}
+
+// restore writes the configuration into every applet of the plasmoid in the
+// appletsrc, adding one if there's none, before its viewers start.
+func (c *appletConfig) restore() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.values) == 0 {
+		return nil
+	}
+	file, err := ReadConfigFile(c.path)
+	if err != nil {
+		return err
+	}
+	applets := file.applets(c.id)
+	if len(applets) == 0 {
+		applets = []string{file.addApplet(c.id)}
+	}
+	var groups []string
+	for group := range c.values {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+	for _, applet := range applets {
+		for _, name := range groups {
+			var keys []string
+			for key := range c.values[name] {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			group := file.Group(applet + "[Configuration]" + name)
+			for _, key := range keys {
+				group.Set(key, c.values[name][key])
+			}
+		}
+	}
+	return file.Write(c.path)
+}
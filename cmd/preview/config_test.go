@@ -0,0 +1,91 @@
+package preview
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseConfigValues(t *testing.T) {
+	config, err := parseConfigValues([]string{"city=Dhaka", "Appearance/showSeconds=true", "a/b/c=x=y", "lines=one\ntwo"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]map[string]string{
+		"[General]":    {"city": "Dhaka", "lines": `one\ntwo`},
+		"[Appearance]": {"showSeconds": "true"},
+		"[a][b]":       {"c": "x=y"},
+	}, config)
+
+	_, err = parseConfigValues([]string{"city"})
+	assert.EqualError(t, err, `invalid --config "city", expected key=value`)
+}
+
+func TestAppletConfig(t *testing.T) {
+	configHome := t.TempDir()
+	appletsrc := filepath.Join(configHome, appletsrcName)
+
+	t.Run("seeds a new applet", func(t *testing.T) {
+		config := newAppletConfig(configHome, "org.example.clock", map[string]map[string]string{"[General]": {"city": "Dhaka"}})
+		require.NoError(t, config.restore())
+
+		data, err := os.ReadFile(appletsrc)
+		require.NoError(t, err)
+		assert.Equal(t, "[Containments][1][Applets][2]\nplugin=org.example.clock\n\n[Containments][1][Applets][2][Configuration][General]\ncity=Dhaka\n", string(data))
+	})
+
+	t.Run("keeps what the viewer saved and the rest of the file", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(appletsrc, []byte(`[ActionPlugins][0]
+RightButton;NoModifier=org.kde.contextmenu
+
+[Containments][4]
+plugin=org.kde.panel
+
+[Containments][4][Applets][7]
+plugin=org.example.clock
+
+[Containments][4][Applets][7][Configuration]
+PreloadWeight=42
+
+[Containments][4][Applets][7][Configuration][General]
+city=Sylhet
+showSeconds=true
+`), 0644))
+		config := newAppletConfig(configHome, "org.example.clock", map[string]map[string]string{"[General]": {"city": "Dhaka"}})
+		require.NoError(t, config.save())
+		assert.Equal(t, map[string]map[string]string{"[General]": {"city": "Sylhet", "showSeconds": "true"}}, config.values)
+
+		// The next viewer starts over with a plasmoid without configuration
+		require.NoError(t, os.WriteFile(appletsrc, []byte("[Containments][4]\nplugin=org.kde.panel\n\n[Containments][4][Applets][8]\nplugin=org.example.clock\n"), 0644))
+		require.NoError(t, config.restore())
+		data, err := os.ReadFile(appletsrc)
+		require.NoError(t, err)
+		assert.Equal(t, "[Containments][4]\nplugin=org.kde.panel\n\n[Containments][4][Applets][8]\nplugin=org.example.clock\n\n[Containments][4][Applets][8][Configuration][General]\ncity=Sylhet\nshowSeconds=true\n", string(data))
+	})
+
+	t.Run("restarts keep the configuration the viewer saved when it quit", func(t *testing.T) {
+		require.NoError(t, os.Remove(appletsrc))
+		seen := filepath.Join(configHome, "seen")
+		originalExecCommand := execCommand
+		t.Cleanup(func() { execCommand = originalExecCommand })
+		execCommand = func(name string, arg ...string) *exec.Cmd {
+			// Like plasmoidviewer, save the settings changed meanwhile on SIGTERM
+			return exec.Command("sh", "-c", `grep city= "$0" >> "$1"; trap 'sed -i s/city=.*/city=Rajshahi/ "$0"; exit 0' TERM; while :; do sleep 0.01; done`, appletsrc, seen)
+		}
+		seenLines := func() string {
+			data, _ := os.ReadFile(seen)
+			return string(data)
+		}
+
+		config := newAppletConfig(configHome, "org.example.clock", map[string]map[string]string{"[General]": {"city": "Dhaka"}})
+		viewers := []viewer{{args: []string{"-a", "org.example.clock"}, config: config}}
+		require.NoError(t, startViewers(viewers))
+		assert.Eventually(t, func() bool { return seenLines() == "city=Dhaka\n" }, time.Second, 10*time.Millisecond)
+		restartViewers(viewers)
+		assert.Eventually(t, func() bool { return seenLines() == "city=Dhaka\ncity=Rajshahi\n" }, time.Second, 10*time.Millisecond)
+		stopViewers()
+	})
+}
@@ -0,0 +1,190 @@
+package preview
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// hotPollTimeout is how long a poll of the bootstrap waits for a new
+// generation before it's answered with none, and polls again.
+const hotPollTimeout = 30 * time.Second
+
+// bootstrapTemplate is a main.qml installed in place of the plasmoid's,
+// which shows the plasmoid's main.qml in a Loader. It's formatted with the
+// command that wrote it, and the rest of its body.
+const bootstrapTemplate = `import QtQuick
+import org.kde.plasma.plasmoid
+
+// Written by prasmoid %s, the plasmoid's main.qml is loaded below.
+PlasmoidItem {
+    id: bootstrap
+
+    readonly property Item plasmoidItem: loader.item
+
+    compactRepresentation: plasmoidItem && plasmoidItem.compactRepresentation ? plasmoidItem.compactRepresentation : null
+    fullRepresentation: plasmoidItem && plasmoidItem.fullRepresentation ? plasmoidItem.fullRepresentation : null
+    preferredRepresentation: plasmoidItem ? plasmoidItem.preferredRepresentation : null
+
+    Loader {
+        id: loader
+        anchors.fill: parent
+    }
+%s}
+`
+
+// hotBootstrap is the body of the bootstrap of --hot. It shows the
+// plasmoid's main.qml of the latest copy of contents/, and polls the
+// hotReloader for the next one. Each copy has its own path, so QML can't
+// reuse any component it cached.
+const hotBootstrap = `
+    property int generation: 0
+
+    Timer {
+        id: retry
+        onTriggered: bootstrap.poll()
+    }
+
+    function poll() {
+        const request = new XMLHttpRequest();
+        request.onreadystatechange = function () {
+            if (request.readyState !== XMLHttpRequest.DONE) {
+                return;
+            }
+            if (request.status === 200) {
+                const next = JSON.parse(request.responseText);
+                bootstrap.generation = next.generation;
+                loader.source = next.source;
+            }
+            // Poll again right away, or in a bit if prasmoid is gone
+            retry.interval = request.status === 0 ? 1000 : 0;
+            retry.start();
+        };
+        request.open("GET", "%s/reload?after=" + bootstrap.generation);
+        request.send();
+    }
+
+    Component.onCompleted: poll()
+`
+
+// WriteBootstrap installs a bootstrapTemplate with body into the plasmoid
+// installed in env.
+func WriteBootstrap(env *IsolatedEnv, command, body string) error {
+	return os.WriteFile(filepath.Join(env.packageDir(), "contents", "ui", "main.qml"), []byte(fmt.Sprintf(bootstrapTemplate, command, body)), 0644)
+}
+
+// hotReloader makes copies, generations, of contents/ for the bootstrap of
+// --hot to load, and tells it about each new one over HTTP on localhost.
+type hotReloader struct {
+	// dir holds a directory per generation.
+	dir    string
+	server *http.Server
+	addr   string
+
+	mu         sync.Mutex
+	generation int
+	// changed is closed, and replaced, when there's a new generation.
+	changed chan struct{}
+	done    chan struct{}
+}
+
+// newHotReloader keeps the generations in dir and starts answering the
+// polls of the bootstrap.
+func newHotReloader(dir string) (*hotReloader, error) {
+	listener, err := netListen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	h := &hotReloader{dir: dir, addr: listener.Addr().String(), changed: make(chan struct{}), done: make(chan struct{})}
+	h.server = &http.Server{Handler: h}
+	go func() { _ = h.server.Serve(listener) }()
+	return h, nil
+}
+
+func (h *hotReloader) url() string {
+	return "http://" + h.addr
+}
+
+// source is the main.qml of generation.
+func (h *hotReloader) source(generation int) string {
+	return "file://" + filepath.Join(h.dir, strconv.Itoa(generation), "contents", "ui", "main.qml")
+}
+
+// bootstrap installs the bootstrap into the plasmoid installed in env.
+func (h *hotReloader) bootstrap(env *IsolatedEnv) error {
+	return WriteBootstrap(env, "preview --hot", fmt.Sprintf(hotBootstrap, h.url()))
+}
+
+// reload copies contents/ of the current directory into a new generation,
+// which the bootstrap loads in place of the current one. The generation
+// before that, which nothing shows anymore, is removed.
+func (h *hotReloader) reload() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	next := h.generation + 1
+	dir := filepath.Join(h.dir, strconv.Itoa(next))
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	if err := os.CopyFS(filepath.Join(dir, "contents"), os.DirFS("contents")); err != nil {
+		return err
+	}
+	_ = os.RemoveAll(filepath.Join(h.dir, strconv.Itoa(next-2)))
+	h.generation = next
+	close(h.changed)
+	h.changed = make(chan struct{})
+	return nil
+}
+
+// ServeHTTP answers GET /reload?after=N with the generation and source of
+// the first generation after N, once there's one.
+func (h *hotReloader) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/reload" {
+		http.NotFound(w, r)
+		return
+	}
+	after, _ := strconv.Atoi(r.URL.Query().Get("after"))
+	timeout := time.NewTimer(hotPollTimeout)
+	defer timeout.Stop()
+	for {
+		h.mu.Lock()
+		generation, changed := h.generation, h.changed
+		h.mu.Unlock()
+		if generation > after {
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"generation": generation, "source": h.source(generation)})
+			return
+		}
+		select {
+		case <-changed:
+		case <-timeout.C:
+			w.WriteHeader(http.StatusNoContent)
+			return
+		case <-h.done:
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// close stops answering the bootstrap.
+func (h *hotReloader) close() error {
+	close(h.done)
+	return h.server.Close()
+}
+
+// isHotReloadable reports whether a change to file, relative to the project,
+// can be reloaded in place. Changes outside contents/, and to the schema
+// of the configuration, need a restart.
+func isHotReloadable(file string) bool {
+	file = filepath.ToSlash(file)
+	return strings.HasPrefix(file, "contents/") && !strings.HasPrefix(file, "contents/config/")
+}
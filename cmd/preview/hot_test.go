@@ -0,0 +1,169 @@
+package preview
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHotReloader(t *testing.T) {
+	project := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(project, "contents", "ui"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(project, "contents", "ui", "main.qml"), []byte("Item {}\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(project, "metadata.json"), []byte("{}"), 0644))
+	originalWd, _ := os.Getwd()
+	require.NoError(t, os.Chdir(project))
+	t.Cleanup(func() { _ = os.Chdir(originalWd) })
+
+	env, err := NewIsolatedEnv("org.example.clock")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = env.Remove() })
+	hot, err := newHotReloader(filepath.Join(env.dir, "hot"))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = hot.close() })
+
+	type next struct {
+		Generation int    `json:"generation"`
+		Source     string `json:"source"`
+	}
+	poll := func(after string) next {
+		res, err := http.Get(hot.url() + "/reload?after=" + after)
+		require.NoError(t, err)
+		defer func() { _ = res.Body.Close() }()
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		var n next
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&n))
+		return n
+	}
+
+	t.Run("installs the bootstrap", func(t *testing.T) {
+		require.NoError(t, env.Install())
+		require.NoError(t, hot.bootstrap(env))
+		main, err := os.ReadFile(filepath.Join(env.packageDir(), "contents", "ui", "main.qml"))
+		require.NoError(t, err)
+		assert.Contains(t, string(main), `request.open("GET", "`+hot.url()+`/reload?after=" + bootstrap.generation);`)
+	})
+
+	t.Run("polls wait for the next generation", func(t *testing.T) {
+		require.NoError(t, hot.reload())
+		assert.Equal(t, next{1, "file://" + filepath.Join(env.dir, "hot", "1", "contents", "ui", "main.qml")}, poll("0"))
+
+		require.NoError(t, os.WriteFile(filepath.Join(project, "contents", "ui", "main.qml"), []byte("Rectangle {}\n"), 0644))
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			assert.NoError(t, hot.reload())
+		}()
+		n := poll("1")
+		assert.Equal(t, 2, n.Generation)
+		main, err := os.ReadFile(filepath.Join(env.dir, "hot", "2", "contents", "ui", "main.qml"))
+		require.NoError(t, err)
+		assert.Equal(t, "Rectangle {}\n", string(main))
+
+		require.NoError(t, hot.reload())
+		assert.NoDirExists(t, filepath.Join(env.dir, "hot", "1"), "generations nothing shows are removed")
+		assert.DirExists(t, filepath.Join(env.dir, "hot", "2"))
+	})
+
+	t.Run("only reload contents", func(t *testing.T) {
+		assert.True(t, isHotReloadable("contents/ui/main.qml"))
+		assert.True(t, isHotReloadable("contents/code/helpers.js"))
+		assert.False(t, isHotReloadable("contents/config/main.xml"))
+		assert.False(t, isHotReloadable("metadata.json"))
+	})
+}
+
+func TestWatchOnChangeHotReload(t *testing.T) {
+	previewTestMutex.Lock()
+	defer previewTestMutex.Unlock()
+
+	originalFsnotifyNewWatcher := fsnotifyNewWatcher
+	originalFilepathWalk := filepathWalk
+	originalExecCommand := execCommand
+	originalTimeAfterFunc := timeAfterFunc
+	originalSignalNotify := signalNotify
+	t.Cleanup(func() {
+		fsnotifyNewWatcher = originalFsnotifyNewWatcher
+		filepathWalk = originalFilepathWalk
+		execCommand = originalExecCommand
+		timeAfterFunc = originalTimeAfterFunc
+		signalNotify = originalSignalNotify
+	})
+
+	project := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(project, "contents", "ui"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(project, "contents", "ui", "main.qml"), []byte("Item {}\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(project, "metadata.json"), []byte("{}"), 0644))
+	originalWd, _ := os.Getwd()
+	require.NoError(t, os.Chdir(project))
+	t.Cleanup(func() { _ = os.Chdir(originalWd) })
+
+	env, err := NewIsolatedEnv("org.example.clock")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = env.Remove() })
+	hot, err := newHotReloader(filepath.Join(env.dir, "hot"))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = hot.close() })
+
+	mw := newMockWatcher()
+	fsnotifyNewWatcher = func() (iWatcher, error) { return mw, nil }
+	filepathWalk = func(root string, walkFn filepath.WalkFunc) error {
+		return walkFn(root, &MockFileInfo{isDir: true}, nil)
+	}
+	var execMu sync.Mutex
+	var execCount int
+	execCommand = func(name string, arg ...string) *exec.Cmd {
+		execMu.Lock()
+		execCount++
+		execMu.Unlock()
+		return exec.Command("sleep", "10")
+	}
+	timeAfterFunc = func(d time.Duration, f func()) *time.Timer {
+		f()
+		return time.NewTimer(d)
+	}
+	quitChan := make(chan os.Signal, 1)
+	signalNotify = func(c chan<- os.Signal, sig ...os.Signal) {
+		go func() {
+			for s := range quitChan {
+				c <- s
+			}
+		}()
+	}
+	generation := func() int {
+		hot.mu.Lock()
+		defer hot.mu.Unlock()
+		return hot.generation
+	}
+
+	done := make(chan bool)
+	go func() {
+		watchOnChange(project, []viewer{{args: []string{"-a", "org.example.clock"}, isolated: env, hot: hot}}, nil)
+		close(done)
+	}()
+
+	assert.Eventually(t, func() bool { return generation() == 1 }, time.Second, 10*time.Millisecond)
+	mw.EventChan <- fsnotify.Event{Name: filepath.Join(project, "contents", "ui", "main.qml"), Op: fsnotify.Write}
+	assert.Eventually(t, func() bool { return generation() == 2 }, time.Second, 10*time.Millisecond)
+	execMu.Lock()
+	assert.Equal(t, 1, execCount, "QML changes reload in place")
+	execMu.Unlock()
+
+	mw.EventChan <- fsnotify.Event{Name: filepath.Join(project, "metadata.json"), Op: fsnotify.Write}
+	assert.Eventually(t, func() bool {
+		execMu.Lock()
+		defer execMu.Unlock()
+		return execCount == 2
+	}, 5*time.Second, 10*time.Millisecond, "metadata changes restart")
+
+	quitChan <- os.Interrupt
+	<-done
+}
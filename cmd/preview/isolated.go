@@ -0,0 +1,62 @@
+package preview
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// IsolatedEnv is a temporary data, config and cache home for the viewers of
+// --isolated, with the plasmoid installed in it. Previews in it never touch
+// the user's Plasma, and several can run side by side.
+type IsolatedEnv struct {
+	dir string
+	id  string
+}
+
+func NewIsolatedEnv(id string) (*IsolatedEnv, error) {
+	dir, err := osMkdirTemp("", "prasmoid-preview-")
+	if err != nil {
+		return nil, err
+	}
+	return &IsolatedEnv{dir: dir, id: id}, nil
+}
+
+func (e *IsolatedEnv) dataHome() string   { return filepath.Join(e.dir, "data") }
+func (e *IsolatedEnv) ConfigHome() string { return filepath.Join(e.dir, "config") }
+func (e *IsolatedEnv) cacheHome() string  { return filepath.Join(e.dir, "cache") }
+
+// packageDir is where the plasmoid is installed.
+func (e *IsolatedEnv) packageDir() string {
+	return filepath.Join(e.dataHome(), "plasma", "plasmoids", e.id)
+}
+
+// environ are the variables that make the viewers use the environment.
+func (e *IsolatedEnv) environ() []string {
+	return []string{
+		"XDG_DATA_HOME=" + e.dataHome(),
+		"XDG_CONFIG_HOME=" + e.ConfigHome(),
+		"XDG_CACHE_HOME=" + e.cacheHome(),
+	}
+}
+
+// Install copies the plasmoid in the current directory into the
+// environment, replacing the copy of an earlier install.
+func (e *IsolatedEnv) Install() error {
+	dest := e.packageDir()
+	if err := os.RemoveAll(dest); err != nil {
+		return err
+	}
+	if err := os.CopyFS(filepath.Join(dest, "contents"), os.DirFS("contents")); err != nil {
+		return err
+	}
+	metadata, err := os.ReadFile("metadata.json")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dest, "metadata.json"), metadata, 0644)
+}
+
+// Remove deletes the environment.
+func (e *IsolatedEnv) Remove() error {
+	return os.RemoveAll(e.dir)
+}
@@ -0,0 +1,43 @@
+package preview
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsolatedEnv(t *testing.T) {
+	project := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(project, "contents", "ui"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(project, "contents", "ui", "main.qml"), []byte("Item {}\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(project, "metadata.json"), []byte(`{"KPlugin": {"Id": "org.example.clock"}}`), 0644))
+	originalWd, _ := os.Getwd()
+	require.NoError(t, os.Chdir(project))
+	t.Cleanup(func() { _ = os.Chdir(originalWd) })
+
+	env, err := NewIsolatedEnv("org.example.clock")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = env.Remove() })
+	installed := filepath.Join(env.dir, "data", "plasma", "plasmoids", "org.example.clock")
+
+	require.NoError(t, env.Install())
+	assert.FileExists(t, filepath.Join(installed, "metadata.json"))
+	assert.FileExists(t, filepath.Join(installed, "contents", "ui", "main.qml"))
+	assert.Equal(t, []string{
+		"XDG_DATA_HOME=" + filepath.Join(env.dir, "data"),
+		"XDG_CONFIG_HOME=" + filepath.Join(env.dir, "config"),
+		"XDG_CACHE_HOME=" + filepath.Join(env.dir, "cache"),
+	}, env.environ())
+
+	// Installing again picks up renames
+	require.NoError(t, os.Rename(filepath.Join(project, "contents", "ui", "main.qml"), filepath.Join(project, "contents", "ui", "Main.qml")))
+	require.NoError(t, env.Install())
+	assert.NoFileExists(t, filepath.Join(installed, "contents", "ui", "main.qml"))
+	assert.FileExists(t, filepath.Join(installed, "contents", "ui", "Main.qml"))
+
+	require.NoError(t, env.Remove())
+	assert.NoDirExists(t, env.dir)
+}
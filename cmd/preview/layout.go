@@ -0,0 +1,192 @@
+package preview
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	root "github.com/PRASSamin/prasmoid/cmd"
+	"github.com/PRASSamin/prasmoid/types"
+	"github.com/spf13/cobra"
+)
+
+// formFactors are the form factors plasmoidviewer knows.
+var formFactors = []string{"planar", "horizontal", "vertical", "mediacenter", "application"}
+
+// locations maps the short location names to plasmoidviewer's, which work too.
+var locations = map[string]string{
+	"floating":   "floating",
+	"desktop":    "desktop",
+	"fullscreen": "fullscreen",
+	"top":        "topedge",
+	"bottom":     "bottomedge",
+	"left":       "leftedge",
+	"right":      "rightedge",
+}
+
+var sizePattern = regexp.MustCompile(`^[0-9]+x[0-9]+$`)
+
+// tileGap is the space between the windows of --matrix, and tileWidth the
+// width assumed for a window whose profile has no size.
+const (
+	tileGap   = 20
+	tileWidth = 480
+)
+
+// layoutFlags are the flags that override the layout of a profile.
+var layoutFlags = []string{"containment", "formfactor", "location", "size"}
+
+func init() {
+	addLayoutFlags(PreviewCmd)
+}
+
+// addLayoutFlags adds --profile and the flags of resolveLayout to cmd.
+func addLayoutFlags(cmd *cobra.Command) {
+	cmd.Flags().String("profile", "", "Layout profile from preview.profiles of prasmoid.config.js")
+	cmd.Flags().String("containment", "", "Containment plugin to show the plasmoid in, e.g. org.kde.panel")
+	cmd.Flags().String("formfactor", "", "Form factor: "+strings.Join(formFactors, ", "))
+	cmd.Flags().String("location", "", "Location: floating, desktop, fullscreen, top, bottom, left or right")
+	cmd.Flags().String("size", "", "Window size, WIDTHxHEIGHT")
+	cmd.Flags().Bool("matrix", false, "Preview every profile of preview.profiles side by side")
+	cmd.MarkFlagsMutuallyExclusive("matrix", "profile")
+
+	_ = cmd.RegisterFlagCompletionFunc("profile", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return ProfileNames(root.ConfigRC.Preview.Profiles), cobra.ShellCompDirectiveNoFileComp
+	})
+	_ = cmd.RegisterFlagCompletionFunc("formfactor", cobra.FixedCompletions(formFactors, cobra.ShellCompDirectiveNoFileComp))
+	_ = cmd.RegisterFlagCompletionFunc("location", cobra.FixedCompletions([]string{"floating", "desktop", "fullscreen", "top", "bottom", "left", "right"}, cobra.ShellCompDirectiveNoFileComp))
+}
+
+// ProfileNames are the names of profiles, sorted.
+func ProfileNames(profiles map[string]types.ConfigPreviewProfile) []string {
+	var names []string
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// resolveLayouts are the layouts to preview: every profile with --matrix,
+// or the one of resolveLayout, and the names of their profiles. The name of
+// the single layout without --matrix is empty.
+func resolveLayouts(cmd *cobra.Command, profiles map[string]types.ConfigPreviewProfile) ([]string, []types.ConfigPreviewProfile, error) {
+	if matrix, _ := cmd.Flags().GetBool("matrix"); !matrix {
+		layout, err := resolveLayout(cmd, profiles)
+		return []string{""}, []types.ConfigPreviewProfile{layout}, err
+	}
+	if len(profiles) == 0 {
+		return nil, nil, fmt.Errorf("--matrix previews the preview.profiles of prasmoid.config.js, but there are none")
+	}
+	names := ProfileNames(profiles)
+	var layouts []types.ConfigPreviewProfile
+	for _, name := range names {
+		layout := applyLayoutFlags(cmd, profiles[name])
+		if err := ValidateLayout(layout); err != nil {
+			return nil, nil, fmt.Errorf("profile %s: %w", name, err)
+		}
+		layouts = append(layouts, layout)
+	}
+	return names, layouts, nil
+}
+
+// resolveLayout is the layout of the profile picked by --profile, if any,
+// with the layout flags that were set on top.
+func resolveLayout(cmd *cobra.Command, profiles map[string]types.ConfigPreviewProfile) (types.ConfigPreviewProfile, error) {
+	var layout types.ConfigPreviewProfile
+	if name, _ := cmd.Flags().GetString("profile"); name != "" {
+		profile, ok := profiles[name]
+		if !ok && len(profiles) == 0 {
+			return layout, fmt.Errorf("unknown profile %q, prasmoid.config.js has no preview.profiles", name)
+		}
+		if !ok {
+			return layout, fmt.Errorf("unknown profile %q, expected one of %s", name, strings.Join(ProfileNames(profiles), ", "))
+		}
+		layout = profile
+	}
+	layout = applyLayoutFlags(cmd, layout)
+	return layout, ValidateLayout(layout)
+}
+
+// applyLayoutFlags overrides layout with the layout flags that were set.
+func applyLayoutFlags(cmd *cobra.Command, layout types.ConfigPreviewProfile) types.ConfigPreviewProfile {
+	for _, flag := range layoutFlags {
+		if !cmd.Flags().Changed(flag) {
+			continue
+		}
+		value, _ := cmd.Flags().GetString(flag)
+		switch flag {
+		case "containment":
+			layout.Containment = value
+		case "formfactor":
+			layout.FormFactor = value
+		case "location":
+			layout.Location = value
+		case "size":
+			layout.Size = value
+		}
+	}
+	return layout
+}
+
+// ValidateLayout reports the first value of layout plasmoidviewer wouldn't accept.
+func ValidateLayout(layout types.ConfigPreviewProfile) error {
+	if layout.FormFactor != "" && !slices.Contains(formFactors, layout.FormFactor) {
+		return fmt.Errorf("unknown form factor %q, expected one of %s", layout.FormFactor, strings.Join(formFactors, ", "))
+	}
+	if layout.Location != "" && locationArg(layout.Location) == "" {
+		return fmt.Errorf("unknown location %q, expected one of floating, desktop, fullscreen, top, bottom, left, right", layout.Location)
+	}
+	if layout.Size != "" && !sizePattern.MatchString(layout.Size) {
+		return fmt.Errorf("invalid size %q, expected WIDTHxHEIGHT like 400x60", layout.Size)
+	}
+	return nil
+}
+
+// locationArg is plasmoidviewer's name for location, or "" if it's unknown.
+func locationArg(location string) string {
+	if arg, ok := locations[location]; ok {
+		return arg
+	}
+	for _, arg := range locations {
+		if arg == location {
+			return arg
+		}
+	}
+	return ""
+}
+
+// ViewerArgs are the arguments of plasmoidviewer to show the plasmoid id with layout.
+func ViewerArgs(id string, layout types.ConfigPreviewProfile) []string {
+	args := []string{"-a", id}
+	if layout.Containment != "" {
+		args = append(args, "-c", layout.Containment)
+	}
+	if layout.FormFactor != "" {
+		args = append(args, "-f", layout.FormFactor)
+	}
+	if layout.Location != "" {
+		args = append(args, "-l", locationArg(layout.Location))
+	}
+	if layout.Size != "" {
+		args = append(args, "-s", layout.Size)
+	}
+	return args
+}
+
+// tileViewers places the windows of viewers, the arguments of the viewers
+// of layouts, side by side from the left of the screen.
+func tileViewers(viewers [][]string, layouts []types.ConfigPreviewProfile) {
+	x := 0
+	for i, layout := range layouts {
+		viewers[i] = append(viewers[i], "-x", strconv.Itoa(x), "-y", "0")
+		width := tileWidth
+		if layout.Size != "" {
+			width, _ = strconv.Atoi(strings.SplitN(layout.Size, "x", 2)[0])
+		}
+		x += width + tileGap
+	}
+}
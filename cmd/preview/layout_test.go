@@ -0,0 +1,103 @@
+package preview
+
+import (
+	"testing"
+
+	"github.com/PRASSamin/prasmoid/types"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveLayout(t *testing.T) {
+	profiles := map[string]types.ConfigPreviewProfile{
+		"panel":   {Containment: "org.kde.panel", FormFactor: "horizontal", Location: "top", Size: "400x60"},
+		"desktop": {FormFactor: "planar", Location: "desktop"},
+	}
+	resolve := func(args ...string) (types.ConfigPreviewProfile, error) {
+		cmd := &cobra.Command{}
+		addLayoutFlags(cmd)
+		require.NoError(t, cmd.ParseFlags(args))
+		return resolveLayout(cmd, profiles)
+	}
+
+	t.Run("no flags", func(t *testing.T) {
+		layout, err := resolve()
+		require.NoError(t, err)
+		assert.Equal(t, types.ConfigPreviewProfile{}, layout)
+	})
+
+	t.Run("profile with flags on top", func(t *testing.T) {
+		layout, err := resolve("--profile", "panel", "--location", "bottomedge", "--size", "600x40")
+		require.NoError(t, err)
+		assert.Equal(t, types.ConfigPreviewProfile{Containment: "org.kde.panel", FormFactor: "horizontal", Location: "bottomedge", Size: "600x40"}, layout)
+		assert.Equal(t, []string{"-a", "org.example.clock", "-c", "org.kde.panel", "-f", "horizontal", "-l", "bottomedge", "-s", "600x40"}, ViewerArgs("org.example.clock", layout))
+	})
+
+	t.Run("flags only", func(t *testing.T) {
+		layout, err := resolve("--formfactor", "vertical", "--location", "left")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"-a", "id", "-f", "vertical", "-l", "leftedge"}, ViewerArgs("id", layout))
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		_, err := resolve("--profile", "sidebar")
+		assert.EqualError(t, err, `unknown profile "sidebar", expected one of desktop, panel`)
+		_, err = resolve("--formfactor", "diagonal")
+		assert.ErrorContains(t, err, `unknown form factor "diagonal"`)
+		_, err = resolve("--location", "middle")
+		assert.ErrorContains(t, err, `unknown location "middle"`)
+		_, err = resolve("--size", "400")
+		assert.ErrorContains(t, err, `invalid size "400"`)
+
+		cmd := &cobra.Command{}
+		addLayoutFlags(cmd)
+		require.NoError(t, cmd.ParseFlags([]string{"--profile", "panel"}))
+		_, err = resolveLayout(cmd, nil)
+		assert.EqualError(t, err, `unknown profile "panel", prasmoid.config.js has no preview.profiles`)
+	})
+}
+
+func TestResolveLayoutsMatrix(t *testing.T) {
+	profiles := map[string]types.ConfigPreviewProfile{
+		"panel":    {Containment: "org.kde.panel", FormFactor: "horizontal", Size: "400x60"},
+		"desktop":  {FormFactor: "planar"},
+		"vertical": {FormFactor: "vertical", Location: "sideways"},
+	}
+	resolve := func(profiles map[string]types.ConfigPreviewProfile, args ...string) ([]types.ConfigPreviewProfile, error) {
+		cmd := &cobra.Command{}
+		addLayoutFlags(cmd)
+		require.NoError(t, cmd.ParseFlags(args))
+		names, layouts, err := resolveLayouts(cmd, profiles)
+		if err == nil {
+			assert.Len(t, names, len(layouts))
+		}
+		return layouts, err
+	}
+
+	_, err := resolve(profiles, "--matrix")
+	assert.EqualError(t, err, `profile vertical: unknown location "sideways", expected one of floating, desktop, fullscreen, top, bottom, left, right`)
+
+	layouts, err := resolve(profiles, "--matrix", "--location", "left")
+	require.NoError(t, err)
+	assert.Equal(t, []types.ConfigPreviewProfile{
+		{FormFactor: "planar", Location: "left"},
+		{Containment: "org.kde.panel", FormFactor: "horizontal", Location: "left", Size: "400x60"},
+		{FormFactor: "vertical", Location: "left"},
+	}, layouts)
+
+	viewers := [][]string{{"-a", "id"}, {"-a", "id"}, {"-a", "id"}}
+	tileViewers(viewers, layouts)
+	assert.Equal(t, [][]string{
+		{"-a", "id", "-x", "0", "-y", "0"},
+		{"-a", "id", "-x", "500", "-y", "0"},
+		{"-a", "id", "-x", "920", "-y", "0"},
+	}, viewers)
+
+	_, err = resolve(nil, "--matrix")
+	assert.ErrorContains(t, err, "there are none")
+
+	layouts, err = resolve(profiles, "--formfactor", "vertical")
+	require.NoError(t, err)
+	assert.Equal(t, []types.ConfigPreviewProfile{{FormFactor: "vertical"}}, layouts)
+}
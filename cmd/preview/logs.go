@@ -0,0 +1,338 @@
+package preview
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/PRASSamin/prasmoid/internal/stack"
+	"github.com/fatih/color"
+)
+
+// logLevel is the severity of a Qt log message.
+type logLevel int
+
+const (
+	levelDebug logLevel = iota
+	levelInfo
+	levelWarning
+	levelError
+)
+
+// logLevels are the names of --log-level, and of Qt's message types.
+var logLevels = map[string]logLevel{
+	"debug":    levelDebug,
+	"info":     levelInfo,
+	"warning":  levelWarning,
+	"error":    levelError,
+	"critical": levelError,
+	"fatal":    levelError,
+}
+
+func (l logLevel) String() string {
+	switch l {
+	case levelInfo:
+		return "info"
+	case levelWarning:
+		return "warn"
+	case levelError:
+		return "error"
+	}
+	return "debug"
+}
+
+func (l logLevel) colorize(s string) string {
+	switch l {
+	case levelInfo:
+		return color.CyanString(s)
+	case levelWarning:
+		return color.YellowString(s)
+	case levelError:
+		return color.RedString(s)
+	}
+	return color.HiBlackString(s)
+}
+
+// logSeparator separates the fields of logPattern, so that the viewer's log
+// lines can be told apart from anything else it prints.
+const logSeparator = "\x1f"
+
+// logPattern is the QT_MESSAGE_PATTERN of the viewers: the type, file, line
+// and message of each log message.
+var logPattern = strings.Join([]string{"", "prasmoid", "%{type}", "%{file}", "%{line}", "%{message}"}, logSeparator)
+
+// logEnv makes Qt log to stderr with logPattern, even when it's not a terminal.
+var logEnv = []string{"QT_MESSAGE_PATTERN=" + logPattern, "QT_FORCE_STDERR_LOGGING=1"}
+
+// messageLocation matches the location QML puts in front of its warnings,
+// e.g. "file:///…/contents/ui/main.qml:42:5: Cannot assign to non-existent property".
+var messageLocation = regexp.MustCompile(`^((?:file://|qrc:)?[^\s:]+\.(?:qml|js|mjs)):(\d+)(?::(\d+))?:?\s*(.*)$`)
+
+// logEntry is a message the viewer logged.
+type logEntry struct {
+	level logLevel
+	// file is the source of the message relative to the project when it's
+	// one of its files, with line and column when they're known.
+	file    string
+	line    int
+	column  int
+	message string
+}
+
+// location is "file:line:column", as far as it's known.
+func (e logEntry) location() string {
+	switch {
+	case e.file == "":
+		return ""
+	case e.line == 0:
+		return e.file
+	case e.column == 0:
+		return fmt.Sprintf("%s:%d", e.file, e.line)
+	}
+	return fmt.Sprintf("%s:%d:%d", e.file, e.line, e.column)
+}
+
+// inProject reports whether the entry comes from a file of the project.
+func (e logEntry) inProject() bool {
+	return strings.HasPrefix(e.file, "contents/")
+}
+
+// isProblem reports whether the entry fails --check: any error, and the
+// warnings about the project's files.
+func (e logEntry) isProblem() bool {
+	return e.level >= levelError || (e.level == levelWarning && e.inProject())
+}
+
+// String is the entry without colors, as written to the log file.
+func (e logEntry) String() string {
+	if location := e.location(); location != "" {
+		return fmt.Sprintf("%-5s %s %s", e.level, location, e.message)
+	}
+	return fmt.Sprintf("%-5s %s", e.level, e.message)
+}
+
+// parseLogLine reads a line of the viewer's output. Lines that don't follow
+// logPattern are plain info messages.
+func parseLogLine(line string) logEntry {
+	fields := strings.SplitN(line, logSeparator, 6)
+	if len(fields) != 6 || fields[0] != "" || fields[1] != "prasmoid" {
+		return logEntry{level: levelInfo, message: line}
+	}
+	entry := logEntry{level: logLevels[fields[2]], message: fields[5]}
+	if fields[3] != "" {
+		entry.file = projectFile(fields[3])
+		entry.line, _ = strconv.Atoi(fields[4])
+	}
+	if m := messageLocation.FindStringSubmatch(entry.message); m != nil {
+		entry.file = projectFile(m[1])
+		entry.line, _ = strconv.Atoi(m[2])
+		entry.column, _ = strconv.Atoi(m[3])
+		entry.message = m[4]
+	}
+	return entry
+}
+
+// projectFile turns the URL of a source of the installed plasmoid, e.g.
+// file:///home/me/.local/share/plasma/plasmoids/org.example/contents/ui/main.qml,
+// into the project's file, contents/ui/main.qml, when it exists.
+func projectFile(url string) string {
+	path := strings.TrimPrefix(url, "file://")
+	if i := strings.LastIndex(path, "/contents/"); i >= 0 {
+		if rel := path[i+1:]; fileExists(rel) {
+			return rel
+		}
+	}
+	return path
+}
+
+func fileExists(path string) bool {
+	_, err := osStat(path)
+	return err == nil
+}
+
+// LogPrinter prints what the viewers log: colorized, filtered by level and
+// pattern, with repeated messages collapsed and QML errors shown in their
+// source. Everything is written to file as well, if there's one.
+type LogPrinter struct {
+	mu    sync.Mutex
+	out   io.Writer
+	file  io.WriteCloser
+	level logLevel
+	grep  *regexp.Regexp
+
+	// last is the latest message printed, repeats how many times it came again.
+	last    string
+	repeats int
+
+	// problems are the different entries that fail --check.
+	problems []logEntry
+
+	// loaded counts the viewers that logged checkLoadedMarker, and allLoaded
+	// is closed once expected of them did (see awaitLoaded).
+	loaded    int
+	expected  int
+	allLoaded chan struct{}
+}
+
+// writer is an output of the viewer called name, which prefixes its lines
+// when it's set. Its flush prints what's left once the viewer exited.
+func (p *LogPrinter) writer(name string) *logWriter {
+	return &logWriter{printer: p, name: name}
+}
+
+func (p *LogPrinter) print(name string, entry logEntry) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	prefix := ""
+	if name != "" {
+		prefix = "[" + name + "] "
+	}
+	// The marker of --check is for prasmoid, not for the user
+	if strings.HasSuffix(entry.message, checkLoadedMarker) {
+		p.loaded++
+		if p.allLoaded != nil && p.loaded == p.expected {
+			close(p.allLoaded)
+		}
+		return
+	}
+	plain := prefix + entry.String()
+	if p.file != nil {
+		_, _ = fmt.Fprintln(p.file, plain)
+	}
+	if entry.isProblem() && !slices.Contains(p.problems, entry) {
+		p.problems = append(p.problems, entry)
+	}
+	if entry.level < p.level || (p.grep != nil && !p.grep.MatchString(plain)) {
+		return
+	}
+	if plain == p.last {
+		p.repeats++
+		return
+	}
+	p.flushRepeats()
+	p.last = plain
+
+	line := prefix + entry.level.colorize(fmt.Sprintf("%-5s", entry.level))
+	if location := entry.location(); location != "" {
+		line += " " + color.HiBlackString(location)
+	}
+	if entry.level >= levelError {
+		line += " " + color.RedString(entry.message)
+	} else {
+		line += " " + entry.message
+	}
+	_, _ = fmt.Fprintln(p.out, line)
+	if entry.level >= levelWarning && entry.line > 0 && entry.inProject() {
+		if frame := stack.CodeFrame(stack.Frame{File: entry.file, Line: entry.line, Column: entry.column}); frame != "" {
+			_, _ = fmt.Fprintln(p.out, frame)
+		}
+	}
+}
+
+// flushRepeats says how many times the last message was repeated.
+func (p *LogPrinter) flushRepeats() {
+	if p.repeats > 0 {
+		_, _ = fmt.Fprintln(p.out, color.HiBlackString("      (repeated %d more times)", p.repeats))
+	}
+	p.repeats = 0
+}
+
+// logWriter splits the output of a viewer into lines for its LogPrinter.
+type logWriter struct {
+	printer *LogPrinter
+	name    string
+	buf     []byte
+}
+
+func (w *logWriter) Write(data []byte) (int, error) {
+	w.buf = append(w.buf, data...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			return len(data), nil
+		}
+		w.printer.print(w.name, parseLogLine(strings.TrimSuffix(string(w.buf[:i]), "\r")))
+		w.buf = w.buf[i+1:]
+	}
+}
+
+func (w *logWriter) flush() {
+	if len(w.buf) > 0 {
+		w.printer.print(w.name, parseLogLine(string(w.buf)))
+		w.buf = nil
+	}
+	w.printer.mu.Lock()
+	w.printer.flushRepeats()
+	w.printer.last = ""
+	w.printer.mu.Unlock()
+}
+
+// NewLogPrinter prints to stdout the messages of at least level that match
+// grep, if it's set, and writes all of them to logFile, if it's set.
+func NewLogPrinter(level, grep, logFile string) (*LogPrinter, error) {
+	p := &LogPrinter{out: os.Stdout}
+	if level != "" {
+		l, ok := logLevels[level]
+		if !ok {
+			return nil, fmt.Errorf("unknown log level %q, expected one of debug, info, warning, error", level)
+		}
+		p.level = l
+	}
+	if grep != "" {
+		re, err := regexp.Compile(grep)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --grep: %v", err)
+		}
+		p.grep = re
+	}
+	if logFile != "" {
+		file, err := osCreate(logFile)
+		if err != nil {
+			return nil, err
+		}
+		p.file = file
+	}
+	return p, nil
+}
+
+// problemsSeen are the entries printed so far that fail --check.
+func (p *LogPrinter) problemsSeen() []logEntry {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return slices.Clone(p.problems)
+}
+
+// awaitLoaded returns a channel that's closed once viewers viewers logged
+// checkLoadedMarker.
+func (p *LogPrinter) awaitLoaded(viewers int) <-chan struct{} {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.loaded, p.expected, p.allLoaded = 0, viewers, make(chan struct{})
+	return p.allLoaded
+}
+
+// loadedViewers is how many viewers logged checkLoadedMarker so far.
+func (p *LogPrinter) loadedViewers() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.loaded
+}
+
+// close closes the log file, once.
+func (p *LogPrinter) close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.file == nil {
+		return nil
+	}
+	err := p.file.Close()
+	p.file = nil
+	return err
+}
@@ -0,0 +1,126 @@
+package preview
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// qtLog is a line as the viewer logs it with logPattern.
+func qtLog(level, file, line, message string) string {
+	return strings.Join([]string{"", "prasmoid", level, file, line, message}, logSeparator)
+}
+
+func TestParseLogLine(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "contents", "ui"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "contents", "ui", "main.qml"), []byte("Item {}\n"), 0644))
+	originalWd, _ := os.Getwd()
+	require.NoError(t, os.Chdir(dir))
+	t.Cleanup(func() { _ = os.Chdir(originalWd) })
+
+	installed := "file:///home/me/.local/share/plasma/plasmoids/org.example/contents/ui/"
+	tests := []struct {
+		name string
+		line string
+		want logEntry
+	}{
+		{
+			name: "console.log from QML",
+			line: qtLog("debug", installed+"main.qml", "12", "hello"),
+			want: logEntry{level: levelDebug, file: "contents/ui/main.qml", line: 12, message: "hello"},
+		},
+		{
+			name: "QML warning with its location in the message",
+			line: qtLog("warning", "", "0", installed+"main.qml:42:5: Cannot assign to non-existent property \"foo\""),
+			want: logEntry{level: levelWarning, file: "contents/ui/main.qml", line: 42, column: 5, message: "Cannot assign to non-existent property \"foo\""},
+		},
+		{
+			name: "critical is an error",
+			line: qtLog("critical", "", "0", "Plasmoid failed to load"),
+			want: logEntry{level: levelError, message: "Plasmoid failed to load"},
+		},
+		{
+			name: "files outside the project keep their path",
+			line: qtLog("warning", "", "0", "file:///usr/lib/qml/org/kde/plasma/Item.qml:3: oops"),
+			want: logEntry{level: levelWarning, file: "/usr/lib/qml/org/kde/plasma/Item.qml", line: 3, message: "oops"},
+		},
+		{
+			name: "other output",
+			line: "plasmoidviewer: starting",
+			want: logEntry{level: levelInfo, message: "plasmoidviewer: starting"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, parseLogLine(tt.line))
+		})
+	}
+}
+
+func TestLogPrinter(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "contents", "ui"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "contents", "ui", "main.qml"), []byte("import QtQuick\n\nItem {\n    foo: 1\n}\n"), 0644))
+	originalWd, _ := os.Getwd()
+	require.NoError(t, os.Chdir(dir))
+	t.Cleanup(func() { _ = os.Chdir(originalWd) })
+
+	installed := "file:///tmp/plasmoids/org.example/contents/ui/main.qml"
+
+	t.Run("collapses repeats and shows errors in the source", func(t *testing.T) {
+		var out bytes.Buffer
+		p := &LogPrinter{out: &out}
+		w := p.writer("")
+		_, _ = w.Write([]byte(qtLog("debug", installed, "2", "tick") + "\n" + qtLog("debug", installed, "2", "tick") + "\n"))
+		_, _ = w.Write([]byte(qtLog("debug", installed, "2", "tick") + "\n" + qtLog("warning", "", "0", installed+":4:5: Cannot assign to non-existent property \"foo\"")))
+		w.flush()
+
+		assert.Equal(t, strings.Join([]string{
+			"debug contents/ui/main.qml:2 tick",
+			"      (repeated 2 more times)",
+			`warn  contents/ui/main.qml:4:5 Cannot assign to non-existent property "foo"`,
+			"  2 |",
+			"  3 | Item {",
+			"> 4 |     foo: 1",
+			"    |     ^",
+			"  5 | }",
+			"  6 |",
+			"",
+		}, "\n"), out.String())
+	})
+
+	t.Run("filters by level and pattern, logs everything to the file", func(t *testing.T) {
+		logFile := filepath.Join(dir, "preview.log")
+		p, err := NewLogPrinter("warning", "prop", logFile)
+		require.NoError(t, err)
+		var out bytes.Buffer
+		p.out = &out
+
+		w := p.writer("panel")
+		_, _ = w.Write([]byte(strings.Join([]string{
+			qtLog("debug", "", "0", "property changed"),
+			qtLog("warning", "", "0", "unrelated"),
+			qtLog("critical", "", "0", "property binding loop"),
+		}, "\n")))
+		w.flush()
+		require.NoError(t, p.close())
+
+		assert.Equal(t, "[panel] error property binding loop\n", out.String())
+		written, err := os.ReadFile(logFile)
+		require.NoError(t, err)
+		assert.Equal(t, "[panel] debug property changed\n[panel] warn  unrelated\n[panel] error property binding loop\n", string(written))
+	})
+
+	t.Run("invalid flags", func(t *testing.T) {
+		_, err := NewLogPrinter("loud", "", "")
+		assert.EqualError(t, err, `unknown log level "loud", expected one of debug, info, warning, error`)
+		_, err = NewLogPrinter("", "(", "")
+		assert.ErrorContains(t, err, "invalid --grep")
+	})
+}
@@ -6,18 +6,24 @@ package preview
 import (
 	"fmt"
 	"log"
+	"net"
 	"os"
 	"os/exec"
 	"os/signal"
 	"path/filepath"
+	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/AlecAivazis/survey/v2"
-	"github.com/PRASSamin/prasmoid/cmd"
+	root "github.com/PRASSamin/prasmoid/cmd"
 	"github.com/PRASSamin/prasmoid/cmd/link"
+	"github.com/PRASSamin/prasmoid/types"
 	"github.com/PRASSamin/prasmoid/utils"
+	"github.com/bmatcuk/doublestar/v4"
 	"github.com/fatih/color"
 	"github.com/fsnotify/fsnotify"
 	"github.com/spf13/cobra"
@@ -47,13 +53,15 @@ var (
 	// os/exec
 	execCommand = exec.Command
 
+	// os
+	osExit = os.Exit
+
 	// utils
-	utilsIsValidPlasmoid      = utils.IsValidPlasmoid
-	utilsIsLinked             = utils.IsLinked
-	utilsGetDevDest           = utils.GetDevDest
-	utilsGetDataFromMetadata  = utils.GetDataFromMetadata
-	utilsIsQmlFile            = utils.IsQmlFile
-	utilsIsPackageInstalled   = utils.IsPackageInstalled
+	utilsIsValidPlasmoid     = utils.IsValidPlasmoid
+	utilsIsLinked            = utils.IsLinked
+	utilsGetDevDest          = utils.GetDevDest
+	utilsGetDataFromMetadata = utils.GetDataFromMetadata
+	utilsIsPackageInstalled  = utils.IsPackageInstalled
 
 	// link
 	linkLinkPlasmoid = link.LinkPlasmoid
@@ -69,12 +77,28 @@ var (
 		}
 		return &watcherWrapper{w}, nil
 	}
-	currentViewer *exec.Cmd
-	viewerMutex   sync.Mutex
+	// currentViewers are the running plasmoidviewers, one per layout
+	currentViewers []*runningViewer
+	// viewersStopping is set once the preview quits, so that a pending
+	// restart doesn't start viewers again
+	viewersStopping bool
+	viewerMutex     sync.Mutex
 
 	// filepath
 	filepathWalk = filepath.Walk
 
+	// net
+	netListen = net.Listen
+
+	// os
+	osStat          = os.Stat
+	osCreate        = os.Create
+	osUserConfigDir = os.UserConfigDir
+	osMkdirTemp     = os.MkdirTemp
+
+	// doublestar
+	doublestarPathMatch = doublestar.PathMatch
+
 	// os/signal
 	signalNotify = signal.Notify
 
@@ -82,11 +106,23 @@ var (
 	timeAfterFunc = time.AfterFunc
 
 	// confirmation
-	confirmLink         bool
+	confirmLink bool
 )
 
 func init() {
-	PreviewCmd.Flags().BoolP("watch", "w", false, "Watch for changes and automatically restart the preview. Note: This uses hot restart instead of hot reload, which may be slower.")
+	PreviewCmd.Flags().BoolP("watch", "w", false, "Watch for changes and automatically restart the preview. Use --hot to reload QML changes in place instead.")
+	PreviewCmd.Flags().Bool("hot", false, "Watch for changes and reload QML in place without restarting the viewer (implies --watch and --isolated)")
+	PreviewCmd.Flags().StringSlice("include", nil, "Globs of the files whose changes restart the preview in watch mode (default: preview.watch of prasmoid.config.js, or contents/** and metadata.json)")
+	PreviewCmd.Flags().String("log-level", "", "Only show log messages of at least this level: debug, info, warning or error")
+	PreviewCmd.Flags().String("grep", "", "Only show log messages matching this regular expression")
+	PreviewCmd.Flags().String("log-file", "", "Also write every log message, unfiltered, to this file")
+	PreviewCmd.Flags().Bool("check", false, "Load the plasmoid without a display, fail on QML errors and exit, e.g. in CI (implies --isolated)")
+	PreviewCmd.Flags().Duration("timeout", defaultCheckTimeout, "How long --check waits at most for the plasmoid to load")
+	PreviewCmd.MarkFlagsMutuallyExclusive("check", "watch")
+	PreviewCmd.MarkFlagsMutuallyExclusive("check", "hot")
+	PreviewCmd.Flags().Bool("isolated", false, "Preview in a temporary data and config home instead of linking into your Plasma")
+	PreviewCmd.Flags().StringArray("config", nil, "Configuration value of the plasmoid, key=value for the General group or Group/key=value (can be repeated)")
+	_ = PreviewCmd.RegisterFlagCompletionFunc("log-level", cobra.FixedCompletions([]string{"debug", "info", "warning", "error"}, cobra.ShellCompDirectiveNoFileComp))
 
 	if utilsIsPackageInstalled("plasmoidviewer") {
 		PreviewCmd.Short = "Enter plasmoid preview mode"
@@ -94,13 +130,13 @@ func init() {
 		PreviewCmd.Short = fmt.Sprintf("Enter plasmoid preview mode %s", color.RedString("(disabled)"))
 	}
 
-	cmd.RootCmd.AddCommand(PreviewCmd)
+	root.RootCmd.AddCommand(PreviewCmd)
 }
 
 // PreviewCmd represents the preview command
 var PreviewCmd = &cobra.Command{
-	Use:   "preview",
-	Long:  "Launch the plasmoid in preview mode for testing and development.",
+	Use:  "preview",
+	Long: "Launch the plasmoid in preview mode for testing and development.",
 	Run: func(cmd *cobra.Command, args []string) {
 		if !utilsIsPackageInstalled("plasmoidviewer") {
 			fmt.Println(color.RedString("preview command is disabled due to missing dependencies."))
@@ -112,8 +148,47 @@ var PreviewCmd = &cobra.Command{
 			return
 		}
 		watch, _ := cmd.Flags().GetBool("watch")
+		isolated, _ := cmd.Flags().GetBool("isolated")
+		hot, _ := cmd.Flags().GetBool("hot")
+		if hot {
+			watch, isolated = true, true
+		}
+		check, _ := cmd.Flags().GetBool("check")
+		var timeout time.Duration
+		if check {
+			isolated = true
+			timeout, _ = cmd.Flags().GetDuration("timeout")
+		}
+		include, _ := cmd.Flags().GetStringSlice("include")
+		if len(include) == 0 {
+			include = root.ConfigRC.Preview.Watch
+		}
+		names, layouts, err := resolveLayouts(cmd, root.ConfigRC.Preview.Profiles)
+		if err != nil {
+			fmt.Println(color.RedString(err.Error()))
+			return
+		}
+		configValues, _ := cmd.Flags().GetStringArray("config")
+		config, err := parseConfigValues(configValues)
+		if err != nil {
+			fmt.Println(color.RedString(err.Error()))
+			return
+		}
+		logLevel, _ := cmd.Flags().GetString("log-level")
+		grep, _ := cmd.Flags().GetString("grep")
+		logFile, _ := cmd.Flags().GetString("log-file")
+		logs, err := NewLogPrinter(logLevel, grep, logFile)
+		if err != nil {
+			fmt.Println(color.RedString(err.Error()))
+			return
+		}
+		defer func() {
+			if err := logs.close(); err != nil {
+				fmt.Println(color.RedString("Failed to write log file: %v", err))
+			}
+		}()
 
-		if !utilsIsLinked() {
+		if !isolated && !utilsIsLinked() {
 			confirmPrompt := &survey.Confirm{
 				Message: "Plasmoid is not linked. Do you want to link it first?",
 				Default: true,
@@ -138,33 +213,433 @@ var PreviewCmd = &cobra.Command{
 			}
 		}
 
-		
-
-		if err := previewPlasmoid(watch); err != nil {
+		opts := previewOptions{watch: watch, isolated: isolated, hot: hot, check: timeout, include: include, names: names, layouts: layouts, logs: logs, config: config}
+		if check {
+			passed := reportCheck(logs, previewPlasmoid(opts))
+			if err := logs.close(); err != nil {
+				fmt.Println(color.RedString("Failed to write log file: %v", err))
+			}
+			if !passed {
+				osExit(1)
+			}
+			return
+		}
+		if err := previewPlasmoid(opts); err != nil {
 			fmt.Println(color.RedString("Failed to preview plasmoid: %v", err))
 			return
 		}
 	},
 }
 
-var previewPlasmoid = func(watch bool) error {
+// previewOptions are the flags of the preview command.
+type previewOptions struct {
+	watch bool
+	// isolated previews in an IsolatedEnv instead of the linked plasmoid.
+	isolated bool
+	// hot reloads changes of contents/ in place, in watch mode and isolated.
+	hot bool
+	// check is how long --check waits at most for the viewers, offscreen, to
+	// load the plasmoid, or zero without --check.
+	check time.Duration
+	// include are the globs, relative to the project, of the files whose
+	// changes restart the preview in watch mode.
+	include []string
+	// layouts are the containments, form factors, locations and sizes to show
+	// the plasmoid with, each in its own viewer.
+	layouts []types.ConfigPreviewProfile
+	// names are the names of the profiles of layouts, which prefix the log
+	// lines of their viewers.
+	names []string
+	// logs prints the output of the viewers. Without it, the output is
+	// passed through as is.
+	logs *LogPrinter
+	// config are the values of --config, by group, to seed the plasmoid's
+	// configuration with.
+	config map[string]map[string]string
+}
+
+// viewer is a plasmoidviewer to run.
+type viewer struct {
+	name string
+	args []string
+	logs *LogPrinter
+	// config is the configuration of the plasmoid, shared by all viewers,
+	// which is kept when they restart.
+	config *appletConfig
+	// isolated is the environment of the viewers with --isolated.
+	isolated *IsolatedEnv
+	// hot reloads the plasmoid in the viewers with --hot.
+	hot *hotReloader
+	// env are more environment variables of the viewer.
+	env []string
+}
+
+// runningViewer is a started viewer.
+type runningViewer struct {
+	*exec.Cmd
+	// exited is closed once the viewer exited.
+	exited chan struct{}
+}
+
+// ViewerStopTimeout is how long the viewers get to save their configuration
+// and quit before they're killed.
+const ViewerStopTimeout = 3 * time.Second
+
+// ViewerCommand is a plasmoidviewer with args, in isolated if it's set, with
+// the more environment variables env, whose output logs prints with its
+// lines prefixed by name. The function to call once it exited prints the rest
+// of its output.
+func ViewerCommand(name string, args []string, logs *LogPrinter, isolated *IsolatedEnv, env []string) (*exec.Cmd, func()) {
+	return viewer{name: name, args: args, logs: logs, isolated: isolated, env: env}.command()
+}
+
+// command is the plasmoidviewer of v, and the function to call once it
+// exited, which prints the rest of its output.
+func (v viewer) command() (*exec.Cmd, func()) {
+	plasmoidViewer := execCommand("plasmoidviewer", v.args...)
+	var env []string
+	if v.isolated != nil {
+		env = v.isolated.environ()
+	}
+	env = append(env, v.env...)
+	if v.logs == nil {
+		if env != nil {
+			plasmoidViewer.Env = append(os.Environ(), env...)
+		}
+		plasmoidViewer.Stdout = os.Stdout
+		plasmoidViewer.Stderr = os.Stderr
+		return plasmoidViewer, func() {}
+	}
+	stdout, stderr := v.logs.writer(v.name), v.logs.writer(v.name)
+	plasmoidViewer.Stdout = stdout
+	plasmoidViewer.Stderr = stderr
+	plasmoidViewer.Env = append(append(os.Environ(), env...), logEnv...)
+	return plasmoidViewer, func() {
+		stdout.flush()
+		stderr.flush()
+	}
+}
+
+// defaultWatchInclude is what watch mode watches when neither --include nor
+// preview.watch in prasmoid.config.js say otherwise.
+var defaultWatchInclude = []string{"contents/**", "metadata.json"}
+
+var previewPlasmoid = func(opts previewOptions) error {
 	id, err := utilsGetDataFromMetadata("Id")
 	if err != nil {
 		return err
 	}
 
-	if watch {
-		watchOnChange("./contents", id.(string))
+	layouts := opts.layouts
+	if len(layouts) == 0 {
+		layouts = []types.ConfigPreviewProfile{{}}
+	}
+	args := make([][]string, len(layouts))
+	for i, layout := range layouts {
+		args[i] = ViewerArgs(id.(string), layout)
+	}
+	if len(layouts) > 1 {
+		tileViewers(args, layouts)
+	}
+	var isolated *IsolatedEnv
+	configHome, err := osUserConfigDir()
+	if opts.isolated {
+		isolated, err = NewIsolatedEnv(id.(string))
+		if err != nil {
+			return fmt.Errorf("failed to create the isolated environment: %w", err)
+		}
+		defer func() {
+			if err := isolated.Remove(); err != nil {
+				fmt.Println(color.RedString("Failed to remove the isolated environment: %v", err))
+			}
+		}()
+		configHome = isolated.ConfigHome()
+	}
+	if err != nil {
+		return err
+	}
+	var hot *hotReloader
+	if opts.hot && isolated != nil {
+		hot, err = newHotReloader(filepath.Join(isolated.dir, "hot"))
+		if err != nil {
+			return fmt.Errorf("failed to start hot reload: %w", err)
+		}
+		defer func() { _ = hot.close() }()
+	}
+	config := newAppletConfig(configHome, id.(string), opts.config)
+	viewers := make([]viewer, len(layouts))
+	for i := range layouts {
+		viewers[i] = viewer{args: args[i], logs: opts.logs, config: config, isolated: isolated, hot: hot}
+		if opts.check > 0 {
+			viewers[i].env = checkEnv
+		}
+		if i < len(opts.names) {
+			viewers[i].name = opts.names[i]
+		}
+	}
+	if opts.watch {
+		watchOnChange(".", viewers, opts.include)
 		return nil
 	}
 
-	plasmoidViewer := execCommand("plasmoidviewer", "-a", id.(string))
-	plasmoidViewer.Stdout = os.Stdout
-	plasmoidViewer.Stderr = os.Stderr
-	return plasmoidViewer.Run()
+	if err := prepareViewers(viewers); err != nil {
+		return err
+	}
+	var loaded <-chan struct{}
+	if opts.check > 0 && isolated != nil {
+		if err := writeCheckBootstrap(isolated); err != nil {
+			return fmt.Errorf("failed to install the check bootstrap: %w", err)
+		}
+		loaded = opts.logs.awaitLoaded(len(viewers))
+	}
+
+	var running []*exec.Cmd
+	var flushes []func()
+	defer func() {
+		for _, flush := range flushes {
+			flush()
+		}
+	}()
+	for _, v := range viewers {
+		plasmoidViewer, flush := v.command()
+		if err := plasmoidViewer.Start(); err != nil {
+			for _, viewer := range running {
+				_ = viewer.Process.Kill()
+			}
+			return err
+		}
+		running = append(running, plasmoidViewer)
+		flushes = append(flushes, flush)
+	}
+	// With --check, the viewers are stopped once they all loaded the
+	// plasmoid, or when they don't within the timeout
+	var stopped atomic.Bool
+	exited := make(chan struct{})
+	if loaded != nil {
+		stop := func() {
+			stopped.Store(true)
+			for _, viewer := range running {
+				_ = viewer.Process.Signal(syscall.SIGTERM)
+			}
+		}
+		timer := timeAfterFunc(opts.check, stop)
+		defer timer.Stop()
+		go func() {
+			select {
+			case <-loaded:
+				stop()
+			case <-exited:
+			}
+		}()
+	}
+	var firstErr error
+	for _, viewer := range running {
+		if err := viewer.Wait(); err != nil && firstErr == nil && !stopped.Load() {
+			firstErr = err
+		}
+	}
+	close(exited)
+	if loaded != nil && firstErr == nil && opts.logs.loadedViewers() < len(running) {
+		if stopped.Load() {
+			return fmt.Errorf("the plasmoid didn't load within %s", opts.check)
+		}
+		return fmt.Errorf("exited before the plasmoid loaded")
+	}
+	return firstErr
+}
+
+// prepareViewers installs the plasmoid into the isolated environment of
+// viewers, if they have one, and writes the configuration it had before.
+// The viewers share both.
+func prepareViewers(viewers []viewer) error {
+	if len(viewers) == 0 {
+		return nil
+	}
+	if isolated := viewers[0].isolated; isolated != nil {
+		if err := isolated.Install(); err != nil {
+			return fmt.Errorf("failed to install the plasmoid into the isolated environment: %w", err)
+		}
+		if hot := viewers[0].hot; hot != nil {
+			if err := hot.bootstrap(isolated); err != nil {
+				return fmt.Errorf("failed to install the hot reload bootstrap: %w", err)
+			}
+			if err := hot.reload(); err != nil {
+				return fmt.Errorf("failed to copy the plasmoid for hot reload: %w", err)
+			}
+		}
+	}
+	if config := viewers[0].config; config != nil {
+		if err := config.restore(); err != nil {
+			return fmt.Errorf("failed to write the plasmoid's configuration: %w", err)
+		}
+	}
+	return nil
 }
 
-var watchOnChange = func(path string, id string) {
+// startViewers starts each of viewers and makes them the current viewers.
+// If one fails to start, the ones already started are stopped.
+func startViewers(viewers []viewer) error {
+	if err := prepareViewers(viewers); err != nil {
+		return err
+	}
+	for _, v := range viewers {
+		if err := startViewer(v); err != nil {
+			stopViewers()
+			return err
+		}
+	}
+	return nil
+}
+
+// startViewer starts v and adds it to the current viewers. It doesn't start
+// anything once the preview is quitting.
+func startViewer(v viewer) error {
+	plasmoidViewer, flush := v.command()
+
+	viewerMutex.Lock()
+	defer viewerMutex.Unlock()
+	if viewersStopping {
+		return nil
+	}
+	if err := plasmoidViewer.Start(); err != nil {
+		return err
+	}
+	running := &runningViewer{Cmd: plasmoidViewer, exited: make(chan struct{})}
+	currentViewers = append(currentViewers, running)
+	go func() {
+		if err := plasmoidViewer.Wait(); err != nil {
+			log.Printf("Error waiting for plasmoid viewer process: %v", err)
+		}
+		flush()
+		close(running.exited)
+		viewerMutex.Lock()
+		currentViewers = slices.DeleteFunc(currentViewers, func(viewer *runningViewer) bool { return viewer == running })
+		viewerMutex.Unlock()
+	}()
+	return nil
+}
+
+// stopViewers asks the current viewers to quit, so that they save the
+// plasmoid's configuration, and kills those still running after
+// ViewerStopTimeout.
+func stopViewers() {
+	viewerMutex.Lock()
+	defer viewerMutex.Unlock()
+	for _, viewer := range currentViewers {
+		if err := viewer.Process.Signal(syscall.SIGTERM); err != nil {
+			log.Printf("Error stopping current viewer process: %v", err)
+		}
+	}
+	timeout := time.After(ViewerStopTimeout)
+	timedOut := false
+	for _, viewer := range currentViewers {
+		if !timedOut {
+			select {
+			case <-viewer.exited:
+				continue
+			case <-timeout:
+				timedOut = true
+			}
+		}
+		if err := viewer.Process.Kill(); err != nil {
+			log.Printf("Error killing current viewer process: %v", err)
+		}
+	}
+	currentViewers = nil
+}
+
+// quitViewers stops the current viewers for good: restarts and reloads
+// pending when the preview quits start nothing afterwards.
+func quitViewers() {
+	viewerMutex.Lock()
+	viewersStopping = true
+	viewerMutex.Unlock()
+	stopViewers()
+}
+
+// quitting reports whether the preview is quitting.
+func quitting() bool {
+	viewerMutex.Lock()
+	defer viewerMutex.Unlock()
+	return viewersStopping
+}
+
+// reloadViewers reloads the plasmoid in place in viewers, or restarts them if
+// that fails.
+func reloadViewers(viewers []viewer) {
+	if quitting() {
+		return
+	}
+	if err := viewers[0].hot.reload(); err != nil {
+		fmt.Println(color.RedString("Failed to hot reload, restarting: %v", err))
+		restartViewers(viewers)
+		return
+	}
+	fmt.Println(color.GreenString("Reloaded"))
+}
+
+// restartViewers stops viewers, keeps the configuration they saved, and
+// starts them again.
+func restartViewers(viewers []viewer) {
+	if quitting() {
+		return
+	}
+	stopViewers()
+	if len(viewers) > 0 && viewers[0].config != nil {
+		if err := viewers[0].config.save(); err != nil {
+			fmt.Println(color.RedString("Failed to keep the plasmoid's configuration: %v", err))
+		}
+	}
+	if err := startViewers(viewers); err != nil {
+		fmt.Println(color.RedString("Error starting plasmoidviewer: %v", err))
+	}
+}
+
+// isWatched reports whether a change to file, relative to dir, should
+// restart the preview. Hidden files and editor backups never do.
+func isWatched(dir, file string, include []string) bool {
+	name := filepath.Base(file)
+	if strings.HasPrefix(name, ".") || strings.HasSuffix(name, "~") {
+		return false
+	}
+	rel, err := filepath.Rel(dir, file)
+	if err != nil {
+		return false
+	}
+	for _, pattern := range include {
+		if match, _ := doublestarPathMatch(pattern, rel); match {
+			return true
+		}
+	}
+	return false
+}
+
+// watchDirs adds dir and the directories below it to the watcher, skipping
+// hidden ones like .git and node_modules.
+func watchDirs(watcher iWatcher, dir string) error {
+	return filepathWalk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if p != dir && (strings.HasPrefix(info.Name(), ".") || info.Name() == "node_modules") {
+			return filepath.SkipDir
+		}
+		return watcher.Add(p)
+	})
+}
+
+// watchOnChange runs viewers and restarts them all whenever a file under dir
+// matching include is written, created, renamed or removed. Editors that save
+// by renaming a temporary file over the original are covered, and new
+// directories are watched as they appear. With hot reload, changes that allow
+// it are reloaded in place instead.
+var watchOnChange = func(dir string, viewers []viewer, include []string) {
+	if len(include) == 0 {
+		include = defaultWatchInclude
+	}
 	watcher, err := fsnotifyNewWatcher()
 	if err != nil {
 		fmt.Println(color.RedString("Failed to start watcher: %v", err))
@@ -177,95 +652,70 @@ var watchOnChange = func(path string, id string) {
 	}()
 
 	done := make(chan bool)
-	debounceTimers := make(map[string]*time.Timer)
-	cooldownInProgress := make(map[string]bool)
 	debounceDuration := 300 * time.Millisecond
 
 	// Set up signal handling
 	quit := make(chan os.Signal, 1)
 	signalNotify(quit, os.Interrupt, syscall.SIGTERM)
 
+	viewerMutex.Lock()
+	viewersStopping = false
+	viewerMutex.Unlock()
 	go func() {
 		<-quit
-		viewerMutex.Lock()
-		if currentViewer != nil && currentViewer.Process != nil {
-			if err := currentViewer.Process.Kill(); err != nil {
-				log.Printf("Error killing current viewer process: %v", err)
-			}
-		}
-		viewerMutex.Unlock()
+		quitViewers()
 		close(done)
 	}()
 
-	plasmoidViewer := execCommand("plasmoidviewer", "-a", id)
-	plasmoidViewer.Stdout = os.Stdout
-	plasmoidViewer.Stderr = os.Stderr
-	viewerMutex.Lock()
-	currentViewer = plasmoidViewer
-	if err := currentViewer.Start(); err != nil {
-		viewerMutex.Unlock()
+	if err := startViewers(viewers); err != nil {
 		fmt.Println(color.RedString("Error starting plasmoidviewer: %v", err))
 		return
 	}
-	viewerMutex.Unlock()
 
 	go func() {
+		// A burst of changes, like an editor's atomic save, restarts once
+		var debounce *time.Timer
+		// restart is set when a change of the burst can't be hot reloaded.
+		var restartMu sync.Mutex
+		restart := false
 		for {
 			select {
 			case event, ok := <-watcher.Events():
 				if !ok {
 					return
 				}
-
-				file := event.Name
-				if !utilsIsQmlFile(file) || event.Op&fsnotify.Write != fsnotify.Write {
+				if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename|fsnotify.Remove) == 0 {
 					continue
 				}
-
-				if cooldownInProgress[file] {
+				if event.Op&fsnotify.Create != 0 {
+					if info, err := osStat(event.Name); err == nil && info.IsDir() {
+						if err := watchDirs(watcher, event.Name); err != nil {
+							fmt.Println(color.RedString("Failed to watch directory: %v", err))
+						}
+					}
+				}
+				if !isWatched(dir, event.Name, include) {
 					continue
 				}
-
-				cooldownInProgress[file] = true
-
-				if timer, exists := debounceTimers[file]; exists {
-					timer.Stop()
+				if rel, _ := filepath.Rel(dir, event.Name); viewers[0].hot == nil || !isHotReloadable(rel) {
+					restartMu.Lock()
+					restart = true
+					restartMu.Unlock()
 				}
 
-				debounceTimers[file] = timeAfterFunc(debounceDuration, func() {
-					viewerMutex.Lock()
-					if currentViewer != nil {
-						if err := currentViewer.Process.Kill(); err != nil {
-							log.Printf("Error killing current viewer process: %v", err)
-						}
-					}
-					viewerMutex.Unlock()
-
-					plasmoidViewer := execCommand("plasmoidviewer", "-a", id)
-					plasmoidViewer.Stdout = os.Stdout
-					plasmoidViewer.Stderr = os.Stderr
-
-					viewerMutex.Lock()
-					currentViewer = plasmoidViewer
-					if err := currentViewer.Start(); err != nil {
-						viewerMutex.Unlock()
-						fmt.Println(color.RedString("Error starting plasmoidviewer: %v", err))
-						return
+				if debounce != nil {
+					debounce.Stop()
+				}
+				debounce = timeAfterFunc(debounceDuration, func() {
+					restartMu.Lock()
+					full := restart
+					restart = false
+					restartMu.Unlock()
+					if full {
+						restartViewers(viewers)
+					} else {
+						reloadViewers(viewers)
 					}
-					viewerMutex.Unlock()
-
-					go func() {
-						if err := plasmoidViewer.Wait(); err != nil {
-							log.Printf("Error waiting for plasmoid viewer process: %v", err)
-						}
-						viewerMutex.Lock()
-						if currentViewer == plasmoidViewer {
-							currentViewer = nil
-						}
-						viewerMutex.Unlock()
-					}()
-
-					cooldownInProgress[file] = false
 				})
 			case err, ok := <-watcher.Errors():
 				if !ok {
@@ -277,19 +727,11 @@ var watchOnChange = func(path string, id string) {
 		}
 	}()
 
-	if err := filepathWalk(path, func(p string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-		if info.IsDir() {
-			return watcher.Add(p)
-		}
-		return nil
-	}); err != nil {
+	if err := watchDirs(watcher, dir); err != nil {
 		fmt.Println(color.RedString("Failed to watch directory: %v", err))
 		return
 	}
 
 	fmt.Printf("Previewer running in watch mode ... Press Ctrl+C to exit\n")
 	<-done
-}
\ No newline at end of file
+}
@@ -7,13 +7,16 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
+	"syscall"
 	"testing"
 	"time"
 
 	"github.com/AlecAivazis/survey/v2"
 	"github.com/PRASSamin/prasmoid/tests"
+	"github.com/PRASSamin/prasmoid/types"
 	"github.com/fatih/color"
 	"github.com/fsnotify/fsnotify"
 	"github.com/stretchr/testify/assert"
@@ -51,7 +54,6 @@ func TestPreviewCmdRun(t *testing.T) {
 		assert.Contains(t, buf.String(), "preview command is disabled due to missing dependencies.")
 	})
 
-
 	originalPreviewPlasmoid := previewPlasmoid
 	defer func() {
 		previewPlasmoid = originalPreviewPlasmoid
@@ -61,7 +63,7 @@ func TestPreviewCmdRun(t *testing.T) {
 	setupMocks := func() {
 		utilsIsValidPlasmoid = func() bool { return true }
 		utilsIsLinked = func() bool { return true }
-		previewPlasmoid = func(watch bool) error { return nil }
+		previewPlasmoid = func(opts previewOptions) error { return nil }
 		utilsIsPackageInstalled = func(pkg string) bool { return true }
 	}
 
@@ -137,7 +139,7 @@ func TestPreviewCmdRun(t *testing.T) {
 			linkCalled = true
 			return nil
 		}
-		previewPlasmoid = func(watch bool) error { return nil }
+		previewPlasmoid = func(opts previewOptions) error { return nil }
 
 		// Act
 		PreviewCmd.Run(PreviewCmd, []string{})
@@ -161,7 +163,7 @@ func TestPreviewCmdRun(t *testing.T) {
 			linkCalled = true
 			return nil
 		}
-		previewPlasmoid = func(watch bool) error { return nil }
+		previewPlasmoid = func(opts previewOptions) error { return nil }
 
 		// Act
 		PreviewCmd.Run(PreviewCmd, []string{})
@@ -170,12 +172,39 @@ func TestPreviewCmdRun(t *testing.T) {
 		assert.False(t, linkCalled, "LinkPlasmoid should not have been called")
 	})
 
+	t.Run("isolated doesn't link", func(t *testing.T) {
+		// Arrange
+		_, _, cleanup := tests.SetupTestEnvironment(t)
+		defer cleanup()
+		setupMocks()
+		utilsIsLinked = func() bool { return false }
+		var asked bool
+		surveyAskOne = func(p survey.Prompt, response interface{}, opts ...survey.AskOpt) error {
+			asked = true
+			return nil
+		}
+		var got previewOptions
+		previewPlasmoid = func(opts previewOptions) error {
+			got = opts
+			return nil
+		}
+		_ = PreviewCmd.Flags().Set("isolated", "true")
+		defer func() { _ = PreviewCmd.Flags().Set("isolated", "false") }()
+
+		// Act
+		PreviewCmd.Run(PreviewCmd, []string{})
+
+		// Assert
+		assert.False(t, asked, "the user isn't asked to link")
+		assert.True(t, got.isolated)
+	})
+
 	t.Run("previewPlasmoid fails", func(t *testing.T) {
 		// Arrange
 		_, _, cleanup := tests.SetupTestEnvironment(t)
 		defer cleanup()
 		setupMocks()
-		previewPlasmoid = func(watch bool) error { return errors.New("preview error") }
+		previewPlasmoid = func(opts previewOptions) error { return errors.New("preview error") }
 
 		// Capture output
 		oldStdout := os.Stdout
@@ -256,14 +285,14 @@ func TestWatchOnChange(t *testing.T) {
 	originalFilepathWalk := filepathWalk
 	originalExecCommand := execCommand
 	originalTimeAfterFunc := timeAfterFunc
-	originalUtilsIsQmlFile := utilsIsQmlFile
+	originalOsStat := osStat
 	originalSignalNotify := signalNotify
 	defer func() {
 		fsnotifyNewWatcher = originalFsnotifyNewWatcher
 		filepathWalk = originalFilepathWalk
 		execCommand = originalExecCommand
 		timeAfterFunc = originalTimeAfterFunc
-		utilsIsQmlFile = originalUtilsIsQmlFile
+		osStat = originalOsStat
 		signalNotify = originalSignalNotify
 	}()
 
@@ -291,9 +320,6 @@ func TestWatchOnChange(t *testing.T) {
 		filepathWalk = func(root string, walkFn filepath.WalkFunc) error {
 			return walkFn(root, &MockFileInfo{isDir: true}, nil)
 		}
-		utilsIsQmlFile = func(file string) bool {
-			return strings.HasSuffix(file, ".qml")
-		}
 
 		var execCount int
 		execCommand = func(name string, arg ...string) *exec.Cmd {
@@ -320,12 +346,12 @@ func TestWatchOnChange(t *testing.T) {
 		// Act
 		done := make(chan bool)
 		go func() {
-			watchOnChange("/fake/path", "my-plasmoid")
+			watchOnChange("/fake/path", []viewer{{args: []string{"-a", "my-plasmoid"}}}, nil)
 			close(done)
 		}()
 
 		time.Sleep(100 * time.Millisecond) // allow initial start
-		mw.EventChan <- fsnotify.Event{Name: "test.qml", Op: fsnotify.Write}
+		mw.EventChan <- fsnotify.Event{Name: "/fake/path/contents/ui/main.qml", Op: fsnotify.Write}
 		time.Sleep(100 * time.Millisecond) // allow restart
 
 		quitChan <- os.Interrupt
@@ -335,11 +361,199 @@ func TestWatchOnChange(t *testing.T) {
 		assert.Equal(t, 2, execCount, "execCommand should be called twice")
 	})
 
+	t.Run("success: watches new directories and restarts on renames", func(t *testing.T) {
+		// Arrange
+		mw := newMockWatcher()
+		fsnotifyNewWatcher = func() (iWatcher, error) {
+			return mw, nil
+		}
+		filepathWalk = func(root string, walkFn filepath.WalkFunc) error {
+			return walkFn(root, &MockFileInfo{isDir: true}, nil)
+		}
+		osStat = func(name string) (os.FileInfo, error) {
+			return &MockFileInfo{isDir: filepath.Ext(name) == ""}, nil
+		}
+
+		var execMu sync.Mutex
+		var execCount int
+		execCommand = func(name string, arg ...string) *exec.Cmd {
+			execMu.Lock()
+			execCount++
+			execMu.Unlock()
+			return exec.Command("sleep", "0.1")
+		}
+		timeAfterFunc = func(d time.Duration, f func()) *time.Timer {
+			f()
+			return time.NewTimer(d)
+		}
+
+		quitChan := make(chan os.Signal, 1)
+		signalNotify = func(c chan<- os.Signal, sig ...os.Signal) {
+			go func() {
+				for s := range quitChan {
+					c <- s
+				}
+			}()
+		}
+
+		// Act
+		done := make(chan bool)
+		go func() {
+			watchOnChange("/fake/path", []viewer{{args: []string{"-a", "my-plasmoid"}}}, []string{"contents/**"})
+			close(done)
+		}()
+
+		time.Sleep(100 * time.Millisecond)
+		mw.EventChan <- fsnotify.Event{Name: "/fake/path/contents/icons", Op: fsnotify.Create}
+		mw.EventChan <- fsnotify.Event{Name: "/fake/path/contents/ui/.main.qml.swp", Op: fsnotify.Write}
+		mw.EventChan <- fsnotify.Event{Name: "/fake/path/contents/ui/main.qml", Op: fsnotify.Chmod}
+		mw.EventChan <- fsnotify.Event{Name: "/fake/path/metadata.json", Op: fsnotify.Write}
+		mw.EventChan <- fsnotify.Event{Name: "/fake/path/contents/code/helpers.js", Op: fsnotify.Rename}
+		time.Sleep(100 * time.Millisecond)
+
+		quitChan <- os.Interrupt
+		<-done
+
+		// Assert
+		mw.mu.Lock()
+		defer mw.mu.Unlock()
+		assert.Equal(t, []string{"/fake/path", "/fake/path/contents/icons"}, mw.addPaths)
+		execMu.Lock()
+		defer execMu.Unlock()
+		assert.Equal(t, 3, execCount, "the new directory and the rename restart, the rest doesn't")
+	})
+
+	t.Run("success: restarts and stops every viewer of a matrix", func(t *testing.T) {
+		// Arrange
+		mw := newMockWatcher()
+		fsnotifyNewWatcher = func() (iWatcher, error) {
+			return mw, nil
+		}
+		filepathWalk = func(root string, walkFn filepath.WalkFunc) error {
+			return walkFn(root, &MockFileInfo{isDir: true}, nil)
+		}
+
+		var execMu sync.Mutex
+		var started [][]string
+		execCommand = func(name string, arg ...string) *exec.Cmd {
+			execMu.Lock()
+			started = append(started, arg)
+			execMu.Unlock()
+			return exec.Command("sleep", "10")
+		}
+		timeAfterFunc = func(d time.Duration, f func()) *time.Timer {
+			f()
+			return time.NewTimer(d)
+		}
+
+		quitChan := make(chan os.Signal, 1)
+		signalNotify = func(c chan<- os.Signal, sig ...os.Signal) {
+			go func() {
+				for s := range quitChan {
+					c <- s
+				}
+			}()
+		}
+
+		// Act
+		done := make(chan bool)
+		go func() {
+			watchOnChange("/fake/path", []viewer{{args: []string{"-a", "id", "-f", "planar"}}, {args: []string{"-a", "id", "-f", "vertical"}}}, nil)
+			close(done)
+		}()
+
+		time.Sleep(100 * time.Millisecond)
+		viewerMutex.Lock()
+		assert.Len(t, currentViewers, 2)
+		viewerMutex.Unlock()
+		mw.EventChan <- fsnotify.Event{Name: "/fake/path/contents/ui/main.qml", Op: fsnotify.Write}
+		time.Sleep(100 * time.Millisecond)
+		viewerMutex.Lock()
+		running := slices.Clone(currentViewers)
+		viewerMutex.Unlock()
+
+		quitChan <- os.Interrupt
+		<-done
+
+		// Assert
+		execMu.Lock()
+		defer execMu.Unlock()
+		assert.Equal(t, [][]string{
+			{"-a", "id", "-f", "planar"}, {"-a", "id", "-f", "vertical"},
+			{"-a", "id", "-f", "planar"}, {"-a", "id", "-f", "vertical"},
+		}, started)
+		assert.Len(t, running, 2)
+		for _, viewer := range running {
+			pid := viewer.Process.Pid
+			assert.Eventually(t, func() bool { return syscall.Kill(pid, 0) != nil }, time.Second, 10*time.Millisecond, "the viewers are killed")
+		}
+		viewerMutex.Lock()
+		assert.Empty(t, currentViewers)
+		viewerMutex.Unlock()
+	})
+
+	t.Run("success: a restart pending when quitting starts nothing", func(t *testing.T) {
+		// Arrange
+		mw := newMockWatcher()
+		fsnotifyNewWatcher = func() (iWatcher, error) {
+			return mw, nil
+		}
+		filepathWalk = func(root string, walkFn filepath.WalkFunc) error {
+			return walkFn(root, &MockFileInfo{isDir: true}, nil)
+		}
+
+		var execMu sync.Mutex
+		var execCount int
+		execCommand = func(name string, arg ...string) *exec.Cmd {
+			execMu.Lock()
+			execCount++
+			execMu.Unlock()
+			return exec.Command("sleep", "10")
+		}
+		pending := make(chan func(), 1)
+		timeAfterFunc = func(d time.Duration, f func()) *time.Timer {
+			pending <- f // fired only after quitting
+			return time.NewTimer(d)
+		}
+
+		quitChan := make(chan os.Signal, 1)
+		signalNotify = func(c chan<- os.Signal, sig ...os.Signal) {
+			go func() {
+				for s := range quitChan {
+					c <- s
+				}
+			}()
+		}
+
+		// Act
+		done := make(chan bool)
+		go func() {
+			watchOnChange("/fake/path", []viewer{{args: []string{"-a", "my-plasmoid"}}}, nil)
+			close(done)
+		}()
+
+		time.Sleep(100 * time.Millisecond)
+		mw.EventChan <- fsnotify.Event{Name: "/fake/path/contents/ui/main.qml", Op: fsnotify.Write}
+		restart := <-pending
+
+		quitChan <- os.Interrupt
+		<-done
+		restart()
+
+		// Assert
+		execMu.Lock()
+		defer execMu.Unlock()
+		assert.Equal(t, 1, execCount, "the pending restart doesn't start a viewer")
+		viewerMutex.Lock()
+		assert.Empty(t, currentViewers)
+		viewerMutex.Unlock()
+	})
+
 	t.Run("error: newWatcher fails", func(t *testing.T) {
 		fsnotifyNewWatcher = func() (iWatcher, error) {
 			return nil, errors.New("watcher failed")
 		}
-		watchOnChange("/fake/path", "id")
+		watchOnChange("/fake/path", []viewer{{args: []string{"-a", "id"}}}, nil)
 	})
 
 	t.Run("error: filepathWalk fails", func(t *testing.T) {
@@ -364,7 +578,7 @@ func TestWatchOnChange(t *testing.T) {
 		}
 		done := make(chan bool)
 		go func() {
-			watchOnChange("/fake/path", "id")
+			watchOnChange("/fake/path", []viewer{{args: []string{"-a", "id"}}}, nil)
 			close(done)
 		}()
 		quitChan <- os.Interrupt
@@ -396,7 +610,7 @@ func TestWatchOnChange(t *testing.T) {
 		// Act
 		done := make(chan bool)
 		go func() {
-			watchOnChange("/fake/path", "id")
+			watchOnChange("/fake/path", []viewer{{args: []string{"-a", "id"}}}, nil)
 			close(done)
 		}()
 
@@ -450,7 +664,7 @@ func TestPreviewPlasmoid(t *testing.T) {
 		}
 
 		// Act
-		err := previewPlasmoid(false)
+		err := previewPlasmoid(previewOptions{})
 
 		// Assert
 		assert.NoError(t, err)
@@ -463,20 +677,100 @@ func TestPreviewPlasmoid(t *testing.T) {
 			return "my-plasmoid", nil
 		}
 		var watchCalled bool
-		watchOnChange = func(path string, id string) {
+		watchOnChange = func(dir string, viewers []viewer, include []string) {
 			watchCalled = true
-			assert.Equal(t, "./contents", path)
-			assert.Equal(t, "my-plasmoid", id)
+			assert.Equal(t, ".", dir)
+			if assert.Len(t, viewers, 1) {
+				assert.Equal(t, []string{"-a", "my-plasmoid", "-f", "vertical"}, viewers[0].args)
+				assert.Equal(t, "my-plasmoid", viewers[0].config.id)
+			}
+			assert.Equal(t, []string{"contents/ui/*.qml"}, include)
 		}
 
 		// Act
-		err := previewPlasmoid(true)
+		err := previewPlasmoid(previewOptions{
+			watch:   true,
+			include: []string{"contents/ui/*.qml"},
+			layouts: []types.ConfigPreviewProfile{{FormFactor: "vertical"}},
+		})
 
 		// Assert
 		assert.NoError(t, err)
 		assert.True(t, watchCalled)
 	})
 
+	t.Run("success: runs a viewer per layout side by side", func(t *testing.T) {
+		// Arrange
+		utilsGetDataFromMetadata = func(key string) (interface{}, error) {
+			return "my-plasmoid", nil
+		}
+		var started [][]string
+		execCommand = func(name string, arg ...string) *exec.Cmd {
+			started = append(started, arg)
+			return exec.Command("true")
+		}
+
+		// Act
+		err := previewPlasmoid(previewOptions{layouts: []types.ConfigPreviewProfile{{FormFactor: "planar", Size: "300x300"}, {FormFactor: "vertical"}}})
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, [][]string{
+			{"-a", "my-plasmoid", "-f", "planar", "-s", "300x300", "-x", "0", "-y", "0"},
+			{"-a", "my-plasmoid", "-f", "vertical", "-x", "320", "-y", "0"},
+		}, started)
+	})
+
+	t.Run("success: prints the logs of each viewer with its profile", func(t *testing.T) {
+		// Arrange
+		utilsGetDataFromMetadata = func(key string) (interface{}, error) {
+			return "my-plasmoid", nil
+		}
+		execCommand = func(name string, arg ...string) *exec.Cmd {
+			// Log like Qt does with the QT_MESSAGE_PATTERN it's given
+			return exec.Command("sh", "-c", `echo "$QT_MESSAGE_PATTERN" | sed 's/%{type}/warning/; s/%{file}//; s/%{line}/0/; s/%{message}/hi/' >&2`)
+		}
+		var out bytes.Buffer
+		logs := &LogPrinter{out: &out}
+
+		// Act
+		err := previewPlasmoid(previewOptions{
+			names:   []string{"desktop", "panel"},
+			layouts: []types.ConfigPreviewProfile{{FormFactor: "planar"}, {FormFactor: "horizontal"}},
+			logs:    logs,
+		})
+
+		// Assert
+		assert.NoError(t, err)
+		assert.ElementsMatch(t, []string{"[desktop] warn  hi", "[panel] warn  hi"}, strings.Split(strings.TrimSpace(out.String()), "\n"))
+	})
+
+	t.Run("success: runs the viewer in an isolated environment", func(t *testing.T) {
+		// Arrange
+		project := t.TempDir()
+		_ = os.MkdirAll(filepath.Join(project, "contents"), 0755)
+		_ = os.WriteFile(filepath.Join(project, "metadata.json"), []byte("{}"), 0644)
+		originalWd, _ := os.Getwd()
+		_ = os.Chdir(project)
+		defer func() { _ = os.Chdir(originalWd) }()
+		utilsGetDataFromMetadata = func(key string) (interface{}, error) {
+			return "my-plasmoid", nil
+		}
+		seen := filepath.Join(t.TempDir(), "seen")
+		execCommand = func(name string, arg ...string) *exec.Cmd {
+			return exec.Command("sh", "-c", `test -f "$XDG_DATA_HOME/plasma/plasmoids/my-plasmoid/metadata.json" && echo "$XDG_CONFIG_HOME" > "$0"`, seen)
+		}
+
+		// Act
+		err := previewPlasmoid(previewOptions{isolated: true, config: map[string]map[string]string{"[General]": {"city": "Dhaka"}}})
+
+		// Assert
+		assert.NoError(t, err)
+		configHome, _ := os.ReadFile(seen)
+		assert.Contains(t, string(configHome), "prasmoid-preview-")
+		assert.NoDirExists(t, filepath.Dir(strings.TrimSpace(string(configHome))), "the environment is removed")
+	})
+
 	t.Run("error: GetDataFromMetadata fails", func(t *testing.T) {
 		// Arrange
 		expectedErr := errors.New("metadata error")
@@ -485,7 +779,7 @@ func TestPreviewPlasmoid(t *testing.T) {
 		}
 
 		// Act
-		err := previewPlasmoid(false)
+		err := previewPlasmoid(previewOptions{})
 
 		// Assert
 		assert.Equal(t, expectedErr, err)
@@ -501,9 +795,31 @@ func TestPreviewPlasmoid(t *testing.T) {
 		}
 
 		// Act
-		err := previewPlasmoid(false)
+		err := previewPlasmoid(previewOptions{})
 
 		// Assert
 		assert.Error(t, err)
 	})
 }
+
+func TestIsWatched(t *testing.T) {
+	include := []string{"contents/**", "metadata.json"}
+	tests := []struct {
+		file string
+		want bool
+	}{
+		{"project/contents/ui/main.qml", true},
+		{"project/contents/code/helpers.js", true},
+		{"project/contents/config/main.xml", true},
+		{"project/contents/icons/logo.svg", true},
+		{"project/metadata.json", true},
+		{"project/README.md", false},
+		{"project/contents/ui/.main.qml.swp", false},
+		{"project/contents/ui/main.qml~", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			assert.Equal(t, tt.want, isWatched("project", tt.file, include))
+		})
+	}
+}
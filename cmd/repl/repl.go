@@ -0,0 +1,274 @@
+/*
+Copyright 2025 PRAS
+*/
+package repl
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/dop251/goja"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+
+	"github.com/PRASSamin/prasmoid/cmd"
+	"github.com/PRASSamin/prasmoid/internal/runtime"
+)
+
+func init() {
+	cmd.RootCmd.AddCommand(ReplCmd)
+}
+
+var ReplCmd = &cobra.Command{
+	Use:   "repl",
+	Short: "Start an interactive JavaScript shell",
+	Long:  "Start an interactive shell on the runtime custom commands use, with its modules loaded as globals. Type .help for the commands it understands.",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := Start(); err != nil {
+			fmt.Println(color.RedString("REPL error: %v", err))
+		}
+	},
+}
+
+const (
+	primaryPrompt      = "> "
+	continuationPrompt = "... "
+	helpText           = `.clear   Discard the expression being typed
+.exit    Exit the REPL (or press Ctrl+D)
+.help    Show this help
+Tab      Complete globals and module members, e.g. fs.rea<Tab>
+`
+)
+
+// session evaluates the lines typed into the REPL. It buffers lines until they
+// make a complete program, so functions and objects can span several lines.
+type session struct {
+	vm      *runtime.Runtime
+	out     io.Writer
+	pending []string
+}
+
+func newSession(out io.Writer) *session {
+	vm := runtime.NewRuntime()
+	runtime.SetArgv(vm, []string{"prasmoid"})
+	return &session{vm: vm, out: out}
+}
+
+func (s *session) prompt() string {
+	if len(s.pending) > 0 {
+		return continuationPrompt
+	}
+	return primaryPrompt
+}
+
+// feed handles one line of input and reports whether the REPL should exit.
+func (s *session) feed(line string) bool {
+	if len(s.pending) == 0 {
+		switch strings.TrimSpace(line) {
+		case "":
+			return false
+		case ".exit":
+			return true
+		case ".help":
+			fmt.Fprint(s.out, helpText)
+			return false
+		case ".clear":
+			return false
+		}
+	} else if strings.TrimSpace(line) == ".clear" {
+		s.pending = nil
+		return false
+	}
+
+	s.pending = append(s.pending, line)
+	src := strings.Join(s.pending, "\n")
+	program, err := goja.Compile("repl", src, false)
+	if err != nil && incomplete(err) {
+		return false
+	}
+	s.pending = nil
+	if err != nil {
+		s.report(err)
+		return false
+	}
+	s.eval(program)
+	return false
+}
+
+// incomplete reports whether a syntax error only means the input stops too early.
+func incomplete(err error) bool {
+	return strings.Contains(err.Error(), "Unexpected end of input")
+}
+
+func (s *session) eval(program *goja.Program) {
+	val, err := s.vm.RunProgram(program)
+	if err != nil {
+		s.report(err)
+		return
+	}
+	// Echo what a promise settles to, like an await at the prompt would.
+	if _, ok := val.Export().(*goja.Promise); ok {
+		val, err = runtime.Await(s.vm, val)
+		if err != nil {
+			fmt.Fprintln(s.out, color.RedString("Uncaught (in promise) %s", runtime.FormatError(err)))
+		}
+	}
+	runtime.RunEventLoop(s.vm)
+	if err == nil {
+		fmt.Fprintln(s.out, runtime.Inspect(val))
+	}
+	for _, rejection := range runtime.UnhandledRejections(s.vm) {
+		fmt.Fprintln(s.out, color.RedString("%s", runtime.FormatError(rejection)))
+	}
+}
+
+func (s *session) report(err error) {
+	if _, ok := runtime.AsScriptError(err); ok {
+		fmt.Fprintln(s.out, color.RedString("Uncaught %s", runtime.FormatError(err)))
+		return
+	}
+	fmt.Fprintln(s.out, color.RedString("%v", err))
+}
+
+var memberChain = regexp.MustCompile(`[A-Za-z_$][\w$]*(?:\.[A-Za-z_$][\w$]*)*\.?$`)
+
+// complete completes the identifier or member chain before pos, e.g. "fs.rea".
+// It returns the new line and cursor position, and the candidates when there's
+// more than one.
+func (s *session) complete(line string, pos int) (string, int, []string) {
+	before := line[:pos]
+	chain := memberChain.FindString(before)
+	var (
+		target  *goja.Object
+		partial = chain
+	)
+	if dot := strings.LastIndex(chain, "."); dot >= 0 {
+		partial = chain[dot+1:]
+		target = s.resolve(chain[:dot])
+		if target == nil {
+			return line, pos, nil
+		}
+	} else {
+		target = s.vm.GlobalObject()
+	}
+
+	var candidates []string
+	for _, name := range propertyNames(target) {
+		if strings.HasPrefix(name, partial) {
+			candidates = append(candidates, name)
+		}
+	}
+	if len(candidates) == 0 {
+		return line, pos, nil
+	}
+
+	completion := commonPrefix(candidates)
+	newLine := before + completion[len(partial):] + line[pos:]
+	newPos := pos + len(completion) - len(partial)
+	if len(candidates) == 1 {
+		return newLine, newPos, nil
+	}
+	return newLine, newPos, candidates
+}
+
+// resolve looks up a member chain such as "prasmoid.i18n" without evaluating code.
+func (s *session) resolve(chain string) *goja.Object {
+	var val goja.Value
+	for i, name := range strings.Split(chain, ".") {
+		if i == 0 {
+			val = s.vm.Get(name)
+		} else {
+			val = val.ToObject(s.vm.Runtime).Get(name)
+		}
+		if val == nil || goja.IsUndefined(val) || goja.IsNull(val) {
+			return nil
+		}
+	}
+	return val.ToObject(s.vm.Runtime)
+}
+
+// propertyNames lists the properties of obj and its prototypes, sorted.
+func propertyNames(obj *goja.Object) []string {
+	seen := map[string]bool{}
+	var names []string
+	for ; obj != nil; obj = obj.Prototype() {
+		for _, name := range obj.GetOwnPropertyNames() {
+			if !seen[name] && name != "constructor" && !strings.HasPrefix(name, "__") {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func commonPrefix(values []string) string {
+	prefix := values[0]
+	for _, v := range values[1:] {
+		for !strings.HasPrefix(v, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return prefix
+}
+
+// Start runs the REPL on stdin. On a terminal it offers line editing, history
+// and tab completion; otherwise it evaluates the lines it reads.
+func Start() error {
+	fd := int(osStdin.Fd())
+	if !termIsTerminal(fd) {
+		s := newSession(osStdout)
+		scanner := bufio.NewScanner(osStdin)
+		for scanner.Scan() {
+			if s.feed(scanner.Text()) {
+				break
+			}
+		}
+		return scanner.Err()
+	}
+
+	t := term.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{osStdin, osStdout}, primaryPrompt)
+	s := newSession(osStdout)
+	t.AutoCompleteCallback = func(line string, pos int, key rune) (string, int, bool) {
+		if key != '\t' {
+			return "", 0, false
+		}
+		newLine, newPos, candidates := s.complete(line, pos)
+		if len(candidates) > 0 {
+			fmt.Fprintln(t, strings.Join(candidates, "  "))
+		}
+		return newLine, newPos, true
+	}
+
+	fmt.Fprintln(osStdout, "Welcome to the prasmoid REPL. Type .help for help.")
+	for {
+		state, err := termMakeRaw(fd)
+		if err != nil {
+			return err
+		}
+		t.SetPrompt(s.prompt())
+		line, err := t.ReadLine()
+		// Scripts print with plain newlines, so they run in cooked mode.
+		_ = termRestore(fd, state)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil && !errors.Is(err, term.ErrPasteIndicator) {
+			return err
+		}
+		if s.feed(line) {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package repl
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/fatih/color"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestSession(t *testing.T) (*session, *strings.Builder) {
+	t.Helper()
+	color.NoColor = true
+	out := &strings.Builder{}
+	return newSession(out), out
+}
+
+func TestFeed(t *testing.T) {
+	t.Run("echoes results", func(t *testing.T) {
+		s, out := newTestSession(t)
+		s.feed(`1 + 2`)
+		s.feed(`"hi"`)
+		s.feed(`({ a: [1, "b"] })`)
+		assert.Equal(t, "3\n\"hi\"\n{ a: [1, \"b\"] }\n", out.String())
+	})
+
+	t.Run("keeps state between lines", func(t *testing.T) {
+		s, out := newTestSession(t)
+		s.feed(`const x = 20`)
+		s.feed(`x * 2`)
+		assert.Equal(t, "undefined\n40\n", out.String())
+	})
+
+	t.Run("multiline input", func(t *testing.T) {
+		s, out := newTestSession(t)
+		s.feed(`function add(a, b) {`)
+		assert.Equal(t, continuationPrompt, s.prompt())
+		s.feed(`  return a + b`)
+		s.feed(`}`)
+		assert.Equal(t, primaryPrompt, s.prompt())
+		s.feed(`add(2, 3)`)
+		assert.Equal(t, "undefined\n5\n", out.String())
+	})
+
+	t.Run(".clear discards the pending input", func(t *testing.T) {
+		s, _ := newTestSession(t)
+		s.feed(`[1, 2,`)
+		s.feed(`.clear`)
+		assert.Equal(t, primaryPrompt, s.prompt())
+	})
+
+	t.Run("errors are reported", func(t *testing.T) {
+		s, out := newTestSession(t)
+		s.feed(`throw new Error("boom")`)
+		s.feed(`let = =`)
+		assert.Contains(t, out.String(), "Uncaught Error: boom")
+		assert.Equal(t, primaryPrompt, s.prompt())
+		assert.Contains(t, out.String(), "    at repl:1:7")
+		assert.Contains(t, out.String(), "Uncaught SyntaxError")
+	})
+
+	t.Run("unhandled rejections are reported", func(t *testing.T) {
+		s, out := newTestSession(t)
+		s.feed(`Promise.reject(new Error("lost")); 1`)
+		assert.Contains(t, out.String(), "1\n")
+		assert.Contains(t, out.String(), "Uncaught (in promise) Error: lost")
+	})
+
+	t.Run("promises are awaited", func(t *testing.T) {
+		s, out := newTestSession(t)
+		s.feed(`Promise.resolve(7)`)
+		s.feed(`Promise.reject("no")`)
+		assert.Equal(t, "7\nUncaught (in promise) \"no\"\n", out.String())
+	})
+
+	t.Run("modules are preloaded", func(t *testing.T) {
+		s, out := newTestSession(t)
+		s.feed(`typeof fs.readFileSync + " " + typeof prasmoid.Command`)
+		assert.Equal(t, "\"function function\"\n", out.String())
+	})
+
+	t.Run("dot commands", func(t *testing.T) {
+		s, out := newTestSession(t)
+		assert.False(t, s.feed(`.help`))
+		assert.Contains(t, out.String(), ".exit")
+		assert.True(t, s.feed(`.exit`))
+	})
+}
+
+func TestComplete(t *testing.T) {
+	s, _ := newTestSession(t)
+
+	t.Run("module member", func(t *testing.T) {
+		line, pos, candidates := s.complete("fs.readFileS", 12)
+		assert.Equal(t, "fs.readFileSync", line)
+		assert.Equal(t, 15, pos)
+		assert.Empty(t, candidates)
+	})
+
+	t.Run("ambiguous completes the common prefix", func(t *testing.T) {
+		line, _, candidates := s.complete("crypto.random", 13)
+		assert.Equal(t, "crypto.random", line)
+		assert.Contains(t, candidates, "randomUUID")
+		assert.Contains(t, candidates, "randomBytes")
+	})
+
+	t.Run("nested members and globals", func(t *testing.T) {
+		line, _, _ := s.complete("prasmoid.i18n.ext", 17)
+		assert.Equal(t, "prasmoid.i18n.extract", line)
+
+		line, _, _ = s.complete("x = consol", 10)
+		assert.Equal(t, "x = console", line)
+	})
+
+	t.Run("keeps the text after the cursor", func(t *testing.T) {
+		line, pos, _ := s.complete("path.joi(a)", 8)
+		assert.Equal(t, "path.join(a)", line)
+		assert.Equal(t, 9, pos)
+	})
+
+	t.Run("unknown objects", func(t *testing.T) {
+		line, pos, candidates := s.complete("nope.a", 6)
+		assert.Equal(t, "nope.a", line)
+		assert.Equal(t, 6, pos)
+		assert.Empty(t, candidates)
+	})
+}
+
+func TestStartWithoutTerminal(t *testing.T) {
+	t.Cleanup(func() { osStdin, osStdout = os.Stdin, os.Stdout })
+	in, err := os.CreateTemp(t.TempDir(), "input")
+	require.NoError(t, err)
+	_, _ = in.WriteString("const a = [1,\n2]\na.length\n.exit\na.length\n")
+	_, _ = in.Seek(0, 0)
+	r, w, _ := os.Pipe()
+	osStdin, osStdout = in, w
+
+	require.NoError(t, Start())
+	_ = w.Close()
+	var buf strings.Builder
+	data := make([]byte, 1024)
+	n, _ := r.Read(data)
+	buf.Write(data[:n])
+	assert.Equal(t, "undefined\n2\n", buf.String())
+}
@@ -0,0 +1,15 @@
+package repl
+
+import (
+	"os"
+
+	"golang.org/x/term"
+)
+
+var (
+	termIsTerminal = term.IsTerminal
+	termMakeRaw    = term.MakeRaw
+	termRestore    = term.Restore
+	osStdin        = os.Stdin
+	osStdout       = os.Stdout
+)
@@ -19,6 +19,7 @@ import (
 	"github.com/PRASSamin/prasmoid/cmd/extendcli"
 	"github.com/PRASSamin/prasmoid/consts"
 	"github.com/PRASSamin/prasmoid/internal"
+	"github.com/PRASSamin/prasmoid/internal/runtime"
 	"github.com/PRASSamin/prasmoid/types"
 	"github.com/PRASSamin/prasmoid/utils"
 	"github.com/fatih/color"
@@ -70,6 +71,8 @@ var ConfigRC types.Config
 
 func init() {
 	ConfigRC = utilsLoadConfigRC()
+	runtime.Project.Config = func() types.Config { return ConfigRC }
+	runtime.Project.SetMetadata = utils.UpdateMetadata
 	RootCmd.Flags().BoolP("version", "v", false, "show Prasmoid version")
 	RootCmd.AddGroup(&cobra.Group{
 		ID:    "custom",
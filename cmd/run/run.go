@@ -0,0 +1,89 @@
+/*
+Copyright 2025 PRAS
+*/
+package run
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+
+	"github.com/PRASSamin/prasmoid/cmd"
+	"github.com/PRASSamin/prasmoid/internal/runtime"
+)
+
+var debug bool
+
+func init() {
+	RunCmd.Flags().BoolVar(&debug, "debug", false, "Log every call the script makes to the built-in modules")
+	// Everything after the script belongs to the script, flags included.
+	RunCmd.Flags().SetInterspersed(false)
+	cmd.RootCmd.AddCommand(RunCmd)
+}
+
+var RunCmd = &cobra.Command{
+	Use:   "run <script> [args...]",
+	Short: "Run a JavaScript or TypeScript file",
+	Long:  "Run a script with the runtime custom commands use. Its arguments are in process.argv, after the executable and the script.",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := RunScript(args[0], args[1:])
+		var code exitCode
+		if errors.As(err, &code) {
+			osExit(int(code))
+			return
+		}
+		if err != nil {
+			fmt.Println(color.RedString("Error running script: %s", runtime.FormatError(err)))
+			osExit(1)
+		}
+	},
+}
+
+// exitCode is the error of a script that set a nonzero process.exitCode.
+type exitCode int
+
+func (c exitCode) Error() string {
+	return fmt.Sprintf("script exited with code %d", int(c))
+}
+
+// RunScript runs the script at path with the given arguments and waits for its
+// event loop, then emits process's "exit" event. Like Node, a promise rejected
+// without a handler fails the script.
+func RunScript(path string, args []string) error {
+	src, err := osReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	exe, err := osExecutable()
+	if err != nil {
+		exe = "prasmoid"
+	}
+	script := path
+	if abs, err := filepath.Abs(path); err == nil {
+		script = abs
+	}
+
+	vm := runtime.NewRuntime()
+	runtime.SetArgv(vm, append([]string{exe, script}, args...))
+	if debug {
+		runtime.EnableDebug(vm)
+	}
+	if _, err := runtime.RunFile(vm, path, src); err != nil {
+		runtime.EmitExit(vm, 1)
+		return err
+	}
+	runtime.RunEventLoop(vm)
+	if rejections := runtime.UnhandledRejections(vm); len(rejections) > 0 {
+		runtime.EmitExit(vm, 1)
+		return rejections[0]
+	}
+	if code := runtime.EmitExit(vm, 0); code != 0 {
+		return exitCode(code)
+	}
+	return nil
+}
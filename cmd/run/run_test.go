@@ -0,0 +1,96 @@
+package run
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+	oldStdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+	fn()
+	_ = w.Close()
+	os.Stdout = oldStdout
+	var buf strings.Builder
+	_, _ = io.Copy(&buf, r)
+	return buf.String()
+}
+
+func writeScript(t *testing.T, name, src string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(src), 0o644))
+	return path
+}
+
+func TestRunScript(t *testing.T) {
+	t.Run("passes arguments in process.argv", func(t *testing.T) {
+		path := writeScript(t, "args.js", `console.log(JSON.stringify(process.argv.slice(1)))`)
+		var err error
+		out := captureStdout(t, func() { err = RunScript(path, []string{"--name", "x"}) })
+		require.NoError(t, err)
+		assert.Contains(t, out, `["`+path+`","--name","x"]`)
+	})
+
+	t.Run("runs typescript and waits for the event loop", func(t *testing.T) {
+		path := writeScript(t, "wait.ts", `const n: number = 2; Promise.resolve(n).then((v) => console.log("done", v))`)
+		var err error
+		out := captureStdout(t, func() { err = RunScript(path, nil) })
+		require.NoError(t, err)
+		assert.Contains(t, out, "done 2")
+	})
+
+	t.Run("reports script errors", func(t *testing.T) {
+		path := writeScript(t, "throw.js", `throw new Error("boom")`)
+		err := RunScript(path, nil)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "boom")
+	})
+
+	t.Run("fails on unhandled rejections", func(t *testing.T) {
+		path := writeScript(t, "reject.js", `Promise.reject(new Error("late")); console.log("after")`)
+		err := RunScript(path, nil)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "Uncaught (in promise) Error: late")
+	})
+
+	t.Run("emits exit with process.exitCode", func(t *testing.T) {
+		path := writeScript(t, "exit.js", `process.on("exit", (code) => console.log("exiting", code)); process.exitCode = 3;`)
+		var err error
+		out := captureStdout(t, func() { err = RunScript(path, nil) })
+		assert.Equal(t, exitCode(3), err)
+		assert.Contains(t, out, "exiting 3")
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		err := RunScript(filepath.Join(t.TempDir(), "missing.js"), nil)
+		assert.Error(t, err)
+	})
+}
+
+func TestRunCmd(t *testing.T) {
+	exitCode := -1
+	t.Cleanup(func() { osExit = os.Exit })
+	osExit = func(code int) { exitCode = code }
+
+	path := writeScript(t, "fail.js", `throw new Error("nope")`)
+	out := captureStdout(t, func() { RunCmd.Run(RunCmd, []string{path}) })
+	assert.Equal(t, 1, exitCode)
+	assert.Contains(t, out, "Error running script")
+	assert.Contains(t, out, "> 1 | throw new Error(\"nope\")")
+
+	t.Run("exits with process.exitCode", func(t *testing.T) {
+		path := writeScript(t, "code.js", `process.exitCode = 4`)
+		out := captureStdout(t, func() { RunCmd.Run(RunCmd, []string{path}) })
+		assert.Equal(t, 4, exitCode)
+		assert.NotContains(t, out, "Error running script")
+	})
+}
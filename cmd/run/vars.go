@@ -0,0 +1,11 @@
+package run
+
+import (
+	"os"
+)
+
+var (
+	osReadFile   = os.ReadFile
+	osExecutable = os.Executable
+	osExit       = os.Exit
+)
@@ -0,0 +1,418 @@
+/*
+Copyright 2025 PRAS
+*/
+package screenshot
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	root "github.com/PRASSamin/prasmoid/cmd"
+	"github.com/PRASSamin/prasmoid/cmd/preview"
+	"github.com/PRASSamin/prasmoid/types"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// defaultScreenshotDelay is how long the plasmoid gets to load before
+	// its screenshot is taken.
+	defaultScreenshotDelay = 2 * time.Second
+	// screenshotTimeout is how long a screenshot may take after the delay.
+	screenshotTimeout = 10 * time.Second
+)
+
+// screenshotBootstrap is the body of the bootstrap of `prasmoid screenshot`.
+// It loads the plasmoid's main.qml, formatted in as a JSON string, and saves
+// a picture of it to the path after the delay in milliseconds.
+const screenshotBootstrap = `
+    Component.onCompleted: loader.source = %s
+
+    Timer {
+        interval: %d
+        running: true
+        onTriggered: bootstrap.grabToImage(function (result) {
+            if (!result.saveToFile(%s)) {
+                console.error("Failed to save the screenshot");
+            }
+        })
+    }
+`
+
+// colorScheme is a color scheme and the matching Plasma theme.
+type colorScheme struct {
+	name  string
+	theme string
+}
+
+// colorSchemes are the color schemes of --light-dark.
+var colorSchemes = map[string]colorScheme{
+	"light": {name: "BreezeLight", theme: "breeze-light"},
+	"dark":  {name: "BreezeDark", theme: "breeze-dark"},
+}
+
+func init() {
+	ScreenshotCmd.Flags().StringSlice("profile", nil, "Preview profiles to take screenshots with (default: screenshot.profiles of prasmoid.config.js, or every profile)")
+	ScreenshotCmd.Flags().StringSlice("locale", nil, "Languages to take screenshots in, e.g. de,fr (default: screenshot.locales of prasmoid.config.js, or the current one)")
+	ScreenshotCmd.Flags().Bool("light-dark", false, "Take every screenshot with a light and a dark color scheme")
+	ScreenshotCmd.Flags().StringP("output", "o", "screenshots", "Directory to save the screenshots in")
+	ScreenshotCmd.Flags().String("platform", "auto", "Where the viewer runs: xvfb, offscreen, or auto for Xvfb when it's installed")
+	ScreenshotCmd.Flags().Duration("delay", defaultScreenshotDelay, "How long the plasmoid gets to load before its screenshot")
+
+	_ = ScreenshotCmd.RegisterFlagCompletionFunc("profile", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return preview.ProfileNames(root.ConfigRC.Preview.Profiles), cobra.ShellCompDirectiveNoFileComp
+	})
+	_ = ScreenshotCmd.RegisterFlagCompletionFunc("platform", cobra.FixedCompletions([]string{"auto", "xvfb", "offscreen"}, cobra.ShellCompDirectiveNoFileComp))
+
+	if utilsIsPackageInstalled("plasmoidviewer") {
+		ScreenshotCmd.Short = "Take screenshots of the plasmoid"
+	} else {
+		ScreenshotCmd.Short = fmt.Sprintf("Take screenshots of the plasmoid %s", color.RedString("(disabled)"))
+	}
+
+	root.RootCmd.AddCommand(ScreenshotCmd)
+}
+
+// ScreenshotCmd represents the screenshot command
+var ScreenshotCmd = &cobra.Command{
+	Use:  "screenshot",
+	Long: "Take screenshots of the plasmoid with the preview profiles, in each locale and optionally with a light and a dark color scheme, as PNGs for docs and store listings.",
+	Run: func(cmd *cobra.Command, args []string) {
+		if !utilsIsPackageInstalled("plasmoidviewer") {
+			fmt.Println(color.RedString("screenshot command is disabled due to missing dependencies."))
+			fmt.Println(color.BlueString("- Use `prasmoid fix` to install them."))
+			return
+		}
+		if !utilsIsValidPlasmoid() {
+			fmt.Println(color.RedString("Current directory is not a valid plasmoid."))
+			return
+		}
+
+		config := root.ConfigRC.Screenshot
+		profiles, _ := cmd.Flags().GetStringSlice("profile")
+		if len(profiles) == 0 {
+			profiles = config.Profiles
+		}
+		locales, _ := cmd.Flags().GetStringSlice("locale")
+		if len(locales) == 0 {
+			locales = config.Locales
+		}
+		lightDark, _ := cmd.Flags().GetBool("light-dark")
+		shots, err := screenshotShots(profiles, root.ConfigRC.Preview.Profiles, locales, lightDark || config.LightDark)
+		if err != nil {
+			fmt.Println(color.RedString(err.Error()))
+			return
+		}
+
+		output, _ := cmd.Flags().GetString("output")
+		platform, _ := cmd.Flags().GetString("platform")
+		delay, _ := cmd.Flags().GetDuration("delay")
+		if err := takeScreenshots(screenshotOptions{shots: shots, output: output, platform: platform, delay: delay}); err != nil {
+			fmt.Println(color.RedString("Failed to take screenshots: %v", err))
+			return
+		}
+		color.Green("Saved %d screenshots in %s", len(shots), output)
+	},
+}
+
+// shot is a screenshot to take.
+type shot struct {
+	profile string
+	layout  types.ConfigPreviewProfile
+	// locale is the language, and scheme the key of colorSchemes, if any.
+	locale string
+	scheme string
+}
+
+// fileName is e.g. panel-de-dark.png.
+func (s shot) fileName() string {
+	parts := []string{s.profile}
+	for _, part := range []string{s.locale, s.scheme} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, "-") + ".png"
+}
+
+// screenshotShots are the screenshots of each of profiles, every profile of
+// layouts if there are none, in each of locales and, with lightDark, in both
+// colorSchemes. Without any profile, the plasmoid is shown as plasmoidviewer
+// does by default.
+func screenshotShots(profiles []string, layouts map[string]types.ConfigPreviewProfile, locales []string, lightDark bool) ([]shot, error) {
+	if len(profiles) == 0 {
+		profiles = preview.ProfileNames(layouts)
+	}
+	var base []shot
+	for _, name := range profiles {
+		layout, ok := layouts[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown profile %q, expected one of %s", name, strings.Join(preview.ProfileNames(layouts), ", "))
+		}
+		if err := preview.ValidateLayout(layout); err != nil {
+			return nil, fmt.Errorf("profile %s: %w", name, err)
+		}
+		base = append(base, shot{profile: name, layout: layout})
+	}
+	if len(base) == 0 {
+		base = []shot{{profile: "default"}}
+	}
+	if len(locales) == 0 {
+		locales = []string{""}
+	}
+	schemes := []string{""}
+	if lightDark {
+		schemes = []string{"light", "dark"}
+	}
+
+	var shots []shot
+	for _, s := range base {
+		for _, locale := range locales {
+			for _, scheme := range schemes {
+				s.locale, s.scheme = locale, scheme
+				shots = append(shots, s)
+			}
+		}
+	}
+	return shots, nil
+}
+
+// screenshotOptions are the flags of the screenshot command.
+type screenshotOptions struct {
+	shots  []shot
+	output string
+	// platform is auto, xvfb or offscreen.
+	platform string
+	delay    time.Duration
+}
+
+var takeScreenshots = func(opts screenshotOptions) error {
+	id, err := utilsGetDataFromMetadata("Id")
+	if err != nil {
+		return err
+	}
+	output, err := filepath.Abs(opts.output)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(output, 0755); err != nil {
+		return err
+	}
+	env, stop, err := startDisplay(opts.platform)
+	if err != nil {
+		return err
+	}
+	defer stop()
+
+	logs, err := preview.NewLogPrinter("warning", "", "")
+	if err != nil {
+		return err
+	}
+	for _, s := range opts.shots {
+		path := filepath.Join(output, s.fileName())
+		if err := takeScreenshot(id.(string), s, path, env, opts.delay, logs); err != nil {
+			return fmt.Errorf("%s: %w", s.fileName(), err)
+		}
+		fmt.Printf("%s %s\n", color.GreenString("✓"), filepath.Join(opts.output, s.fileName()))
+	}
+	return nil
+}
+
+// startDisplay starts the display of the viewers for platform, and is the
+// environment they need to use it. stop stops it.
+func startDisplay(platform string) (env []string, stop func(), err error) {
+	switch platform {
+	case "auto":
+		if utilsIsPackageInstalled("Xvfb") {
+			return startXvfb()
+		}
+		fallthrough
+	case "offscreen":
+		// The software renderer draws without a GPU, which grabToImage needs offscreen
+		return []string{"QT_QPA_PLATFORM=offscreen", "QT_QUICK_BACKEND=software"}, func() {}, nil
+	case "xvfb":
+		return startXvfb()
+	}
+	return nil, nil, fmt.Errorf("unknown platform %q, expected one of auto, xvfb, offscreen", platform)
+}
+
+// startXvfb starts a virtual X server on a free display.
+func startXvfb() ([]string, func(), error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer func() { _ = r.Close() }()
+	xvfb := execCommand("Xvfb", "-displayfd", "3", "-screen", "0", "1920x1080x24", "-nolisten", "tcp")
+	xvfb.ExtraFiles = []*os.File{w}
+	err = xvfb.Start()
+	_ = w.Close()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to start Xvfb: %w", err)
+	}
+	stop := func() {
+		_ = xvfb.Process.Kill()
+		_ = xvfb.Wait()
+	}
+
+	// Xvfb writes the display it picked to fd 3 once it's ready
+	display := make(chan string, 1)
+	go func() {
+		line, _ := bufio.NewReader(r).ReadString('\n')
+		display <- strings.TrimSpace(line)
+	}()
+	select {
+	case n := <-display:
+		if n == "" {
+			stop()
+			return nil, nil, fmt.Errorf("xvfb exited without a display")
+		}
+		return []string{"DISPLAY=:" + n, "QT_QPA_PLATFORM=xcb"}, stop, nil
+	case <-time.After(screenshotTimeout):
+		stop()
+		return nil, nil, fmt.Errorf("xvfb didn't start in %s", screenshotTimeout)
+	}
+}
+
+// takeScreenshot saves the screenshot s of the plasmoid id to path, with a
+// viewer in its own isolated environment on the display of env.
+func takeScreenshot(id string, s shot, path string, env []string, delay time.Duration, logs *preview.LogPrinter) error {
+	isolated, err := preview.NewIsolatedEnv(id)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = isolated.Remove() }()
+	if err := isolated.Install(); err != nil {
+		return err
+	}
+	main, err := filepath.Abs(filepath.Join("contents", "ui", "main.qml"))
+	if err != nil {
+		return err
+	}
+	source, _ := json.Marshal("file://" + main)
+	target, _ := json.Marshal(path)
+	if err := preview.WriteBootstrap(isolated, "screenshot", fmt.Sprintf(screenshotBootstrap, source, delay.Milliseconds(), target)); err != nil {
+		return err
+	}
+	if scheme, ok := colorSchemes[s.scheme]; ok {
+		if err := applyColorScheme(isolated.ConfigHome(), scheme); err != nil {
+			return err
+		}
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	plasmoidViewer, flush := preview.ViewerCommand(strings.TrimSuffix(s.fileName(), ".png"), preview.ViewerArgs(id, s.layout), logs, isolated, slices.Concat(env, localeEnv(s.locale)))
+	if err := plasmoidViewer.Start(); err != nil {
+		return err
+	}
+	exited := make(chan error, 1)
+	go func() { exited <- plasmoidViewer.Wait() }()
+	defer flush()
+
+	err = waitForScreenshot(path, delay+screenshotTimeout, exited)
+	select {
+	case <-exited:
+	default:
+		_ = plasmoidViewer.Process.Signal(syscall.SIGTERM)
+		select {
+		case <-exited:
+		case <-time.After(preview.ViewerStopTimeout):
+			_ = plasmoidViewer.Process.Kill()
+			<-exited
+		}
+	}
+	return err
+}
+
+// waitForScreenshot waits until path is written, for at most timeout, or
+// until the viewer exited.
+func waitForScreenshot(path string, timeout time.Duration, exited chan error) error {
+	deadline := time.After(timeout)
+	size := int64(-1)
+	for {
+		select {
+		case err := <-exited:
+			if err == nil {
+				err = fmt.Errorf("exited")
+			}
+			// Put it back for the caller, which waits for the viewer too
+			exited <- err
+			return fmt.Errorf("plasmoidviewer failed before the screenshot: %w", err)
+		case <-deadline:
+			return fmt.Errorf("no screenshot after %s", timeout)
+		case <-time.After(100 * time.Millisecond):
+		}
+		// The screenshot is complete once its size stopped changing
+		if info, err := osStat(path); err == nil && info.Size() > 0 {
+			if info.Size() == size {
+				return nil
+			}
+			size = info.Size()
+		}
+	}
+}
+
+// localeEnv makes the viewer use locale, if it's set. A language like "de"
+// only sets LANGUAGE, which picks the translations. A full locale like
+// "de_DE" or "pt_BR.UTF-8" sets LANG and LC_ALL too, for the formats of
+// numbers and dates.
+func localeEnv(locale string) []string {
+	if locale == "" {
+		return nil
+	}
+	language, _, _ := strings.Cut(locale, ".")
+	env := []string{"LANGUAGE=" + language}
+	if strings.Contains(language, "_") {
+		lang := locale
+		if !strings.Contains(lang, ".") {
+			lang += ".UTF-8"
+		}
+		env = append(env, "LANG="+lang, "LC_ALL="+lang)
+	}
+	return env
+}
+
+// applyColorScheme makes scheme the color scheme and Plasma theme of
+// configHome, with the colors of the scheme when it's installed.
+func applyColorScheme(configHome string, scheme colorScheme) error {
+	kdeglobals := preview.ConfigFile{{}}
+	if path := colorSchemeFile(scheme.name); path != "" {
+		colors, err := preview.ReadConfigFile(path)
+		if err != nil {
+			return err
+		}
+		kdeglobals = colors
+	}
+	kdeglobals.Group("[General]").Set("ColorScheme", scheme.name)
+	if err := kdeglobals.Write(filepath.Join(configHome, "kdeglobals")); err != nil {
+		return err
+	}
+	plasmarc := preview.ConfigFile{{}}
+	plasmarc.Group("[Theme]").Set("name", scheme.theme)
+	return plasmarc.Write(filepath.Join(configHome, "plasmarc"))
+}
+
+// colorSchemeFile is the file of the color scheme called name in the data
+// directories, or "" if it isn't installed.
+func colorSchemeFile(name string) string {
+	dirs := os.Getenv("XDG_DATA_DIRS")
+	if dirs == "" {
+		dirs = "/usr/local/share:/usr/share"
+	}
+	for _, dir := range filepath.SplitList(dirs) {
+		path := filepath.Join(dir, "color-schemes", name+".colors")
+		if _, err := osStat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
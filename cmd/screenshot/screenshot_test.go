@@ -0,0 +1,142 @@
+package screenshot
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/PRASSamin/prasmoid/types"
+	"github.com/fatih/color"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScreenshotShots(t *testing.T) {
+	profiles := map[string]types.ConfigPreviewProfile{
+		"panel":   {FormFactor: "horizontal", Size: "400x60"},
+		"desktop": {FormFactor: "planar"},
+	}
+
+	shots, err := screenshotShots(nil, profiles, []string{"de"}, true)
+	require.NoError(t, err)
+	var names []string
+	for _, s := range shots {
+		names = append(names, s.fileName())
+	}
+	assert.Equal(t, []string{"desktop-de-light.png", "desktop-de-dark.png", "panel-de-light.png", "panel-de-dark.png"}, names)
+	assert.Equal(t, profiles["panel"], shots[2].layout)
+
+	shots, err = screenshotShots(nil, nil, nil, false)
+	require.NoError(t, err)
+	assert.Equal(t, []shot{{profile: "default"}}, shots)
+
+	_, err = screenshotShots([]string{"tray"}, profiles, nil, false)
+	assert.EqualError(t, err, `unknown profile "tray", expected one of desktop, panel`)
+}
+
+func TestLocaleEnv(t *testing.T) {
+	assert.Nil(t, localeEnv(""))
+	assert.Equal(t, []string{"LANGUAGE=de"}, localeEnv("de"))
+	assert.Equal(t, []string{"LANGUAGE=de_DE", "LANG=de_DE.UTF-8", "LC_ALL=de_DE.UTF-8"}, localeEnv("de_DE"))
+	assert.Equal(t, []string{"LANGUAGE=pt_BR", "LANG=pt_BR.ISO-8859-1", "LC_ALL=pt_BR.ISO-8859-1"}, localeEnv("pt_BR.ISO-8859-1"))
+}
+
+func TestApplyColorScheme(t *testing.T) {
+	dataDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dataDir, "color-schemes"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dataDir, "color-schemes", "BreezeDark.colors"), []byte("[Colors:Window]\nBackgroundNormal=32,35,38\n\n[General]\nName=Breeze Dark\n"), 0644))
+	t.Setenv("XDG_DATA_DIRS", dataDir)
+	configHome := t.TempDir()
+
+	require.NoError(t, applyColorScheme(configHome, colorSchemes["dark"]))
+	kdeglobals, err := os.ReadFile(filepath.Join(configHome, "kdeglobals"))
+	require.NoError(t, err)
+	assert.Equal(t, "[Colors:Window]\nBackgroundNormal=32,35,38\n\n[General]\nName=Breeze Dark\nColorScheme=BreezeDark\n", string(kdeglobals))
+	plasmarc, err := os.ReadFile(filepath.Join(configHome, "plasmarc"))
+	require.NoError(t, err)
+	assert.Equal(t, "[Theme]\nname=breeze-dark\n", string(plasmarc))
+
+	// Schemes that aren't installed are still named
+	require.NoError(t, applyColorScheme(configHome, colorSchemes["light"]))
+	kdeglobals, err = os.ReadFile(filepath.Join(configHome, "kdeglobals"))
+	require.NoError(t, err)
+	assert.Equal(t, "[General]\nColorScheme=BreezeLight\n", string(kdeglobals))
+}
+
+// fakeScreenshotViewer is a plasmoidviewer that saves where the bootstrap
+// would, and what it was run with as the picture.
+const fakeScreenshotViewer = `#!/bin/sh
+main="$XDG_DATA_HOME/plasma/plasmoids/org.example.clock/contents/ui/main.qml"
+target=$(sed -n 's/.*saveToFile("\(.*\)").*/\1/p' "$main")
+grep -q 'loader.source = "file://.*/contents/ui/main.qml"' "$main" || exit 3
+scheme=$(sed -n 's/^ColorScheme=//p' "$XDG_CONFIG_HOME/kdeglobals" 2>/dev/null)
+echo "$QT_QPA_PLATFORM $LANGUAGE $scheme $*" > "$target"
+exec sleep 10
+`
+
+func TestScreenshotCmd(t *testing.T) {
+	project := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(project, "contents", "ui"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(project, "contents", "ui", "main.qml"), []byte("Item {}\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(project, "metadata.json"), []byte(`{"KPlugin": {"Id": "org.example.clock"}}`), 0644))
+	originalWd, _ := os.Getwd()
+	require.NoError(t, os.Chdir(project))
+	t.Cleanup(func() { _ = os.Chdir(originalWd) })
+
+	bin := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(bin, "plasmoidviewer"), []byte(fakeScreenshotViewer), 0755))
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("XDG_DATA_DIRS", t.TempDir())
+
+	originalIsPackageInstalled := utilsIsPackageInstalled
+	originalIsValidPlasmoid := utilsIsValidPlasmoid
+	originalGetDataFromMetadata := utilsGetDataFromMetadata
+	t.Cleanup(func() {
+		utilsIsPackageInstalled = originalIsPackageInstalled
+		utilsIsValidPlasmoid = originalIsValidPlasmoid
+		utilsGetDataFromMetadata = originalGetDataFromMetadata
+	})
+	utilsIsPackageInstalled = func(pkg string) bool { return pkg == "plasmoidviewer" }
+	utilsIsValidPlasmoid = func() bool { return true }
+	utilsGetDataFromMetadata = func(key string) (interface{}, error) { return "org.example.clock", nil }
+
+	cmd := ScreenshotCmd
+	require.NoError(t, cmd.Flags().Set("locale", "de,fr"))
+	require.NoError(t, cmd.Flags().Set("light-dark", "true"))
+	require.NoError(t, cmd.Flags().Set("delay", "10ms"))
+	t.Cleanup(func() {
+		_ = cmd.Flags().Set("light-dark", "false")
+		_ = cmd.Flags().Set("delay", defaultScreenshotDelay.String())
+		cmd.Flags().Lookup("locale").Changed = false
+		_ = cmd.Flags().Lookup("locale").Value.(interface{ Replace([]string) error }).Replace(nil)
+	})
+
+	oldStdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+	color.Output = w
+	start := time.Now()
+	cmd.Run(cmd, []string{})
+	_ = w.Close()
+	os.Stdout = oldStdout
+	color.Output = oldStdout
+	var buf bytes.Buffer
+	_, _ = io.Copy(&buf, r)
+
+	assert.Contains(t, buf.String(), "Saved 4 screenshots in screenshots")
+	assert.Less(t, time.Since(start), 10*time.Second, "the viewers are stopped once they saved")
+	for name, want := range map[string]string{
+		"default-de-light.png": "offscreen de BreezeLight -a org.example.clock\n",
+		"default-de-dark.png":  "offscreen de BreezeDark -a org.example.clock\n",
+		"default-fr-light.png": "offscreen fr BreezeLight -a org.example.clock\n",
+		"default-fr-dark.png":  "offscreen fr BreezeDark -a org.example.clock\n",
+	} {
+		got, err := os.ReadFile(filepath.Join(project, "screenshots", name))
+		if assert.NoError(t, err) {
+			assert.Equal(t, want, string(got), name)
+		}
+	}
+}
@@ -0,0 +1,18 @@
+package screenshot
+
+import (
+	"os"
+	"os/exec"
+
+	"github.com/PRASSamin/prasmoid/utils"
+)
+
+// To enable mocking
+var (
+	execCommand = exec.Command
+	osStat      = os.Stat
+
+	utilsIsValidPlasmoid     = utils.IsValidPlasmoid
+	utilsGetDataFromMetadata = utils.GetDataFromMetadata
+	utilsIsPackageInstalled  = utils.IsPackageInstalled
+)
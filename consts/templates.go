@@ -125,37 +125,431 @@ declare module "prasmoid" {
   /**
    * Retrieves a value from the project's metadata.json file.
    * @param key The key from the "KPlugin" section of metadata.json (e.g., "Id", "Version").
-   * @returns {string | undefined} The value from the metadata, or undefined if not found.
+   * @returns The value from the metadata, keeping its JSON type (e.g. Authors is an array).
    */
-  export function getMetadata(key: string): string | undefined;
+  export function getMetadata(key: string): any;
   /**
-   * Registers a custom command.
+   * Registers a custom command. A file may register several commands.
    * @param config The configuration for the command.
    */
   export function Command(config: Config): void;
+
+  /**
+   * The project's prasmoid.config.js.
+   */
+  export const config: {
+    commands: { dir: string; ignore: string[]; sources?: string[] };
+    i18n: { dir: string; locales: string[] };
+    preview: { watch?: string[]; profiles?: Record<string, PreviewProfile> };
+    screenshot: { profiles?: string[]; locales?: string[]; lightDark?: boolean };
+  };
+  /**
+   * Sets a value in metadata.json. Needs the "metadata" project permission.
+   * @param section Defaults to "KPlugin"; "." sets a key at the root.
+   */
+  export function setMetadata(key: string, value: any, section?: string): void;
+  /**
+   * Builds the .plasmoid archive, like ` + "`prasmoid build`" + `, and returns its path.
+   * The output directory, "./build" by default, is replaced and needs fs access.
+   */
+  export function build(options?: { output?: string }): string;
+  /** Links the project for development and returns the link's location. */
+  export function link(): string;
+  /** Installs the project system-wide. */
+  export function install(): void;
+  export const i18n: {
+    /** Extracts translatable strings and, unless noPo is set, updates the .po files. */
+    extract(options?: { noPo?: boolean }): void;
+    /** Compiles the .po files into .mo files. */
+    compile(options?: { silent?: boolean }): void;
+  };
+  export const changeset: {
+    /** Writes a changeset and returns its path. */
+    add(options: { bump: "patch" | "minor" | "major"; summary: string; apply?: boolean }): string;
+    /** Applies the pending changesets. */
+    apply(): void;
+  };
+  /**
+   * Formats QML files with qmlformat, by default every file under contents/.
+   * The files are rewritten in place and need fs access.
+   */
+  export function format(files?: string[]): void;
 }
 
 /**
  * Configuration for the custom command.
  */
 interface Config {
-  run: (ctx: CommandContext) => void;
+  /**
+   * The command name. Defaults to the file name, so it's only required when a file
+   * registers several commands, and for subcommands.
+   */
+  name?: string;
+  /** Runs the command. Optional for commands that only group subcommands. */
+  run?: (ctx: CommandContext) => void | Promise<void>;
+  /**
+   * Nested commands, e.g. ` + "`prasmoid release notes`" + `. They inherit the permissions of their parent.
+   * @example
+   * subcommands: [{ name: "notes", short: "Print release notes", run: (ctx) => {} }]
+   */
+  subcommands?: (Config & { name: string })[];
   /** A brief description of your command. */
   short: string;
   /** A longer description that spans multiple lines. */
-  long: string;
+  long?: string;
   /** Optional aliases for the command. */
   alias?: string[];
   /** Flag definitions for the command. */
-  flags?: {
-    name: string;
-    shorthand?: string;
-    type: "string" | "boolean";
-    default?: string | boolean;
-    description: string;
-  }[];
+  flags?: Flag[];
+  /**
+   * Positional arguments, validated before ` + "`run`" + ` is called. ` + "`max: -1`" + ` allows any number.
+   * @example
+   * args: { min: 1, max: 2, names: ["version", "notes"] }
+   */
+  args?: {
+    min?: number;
+    max?: number;
+    names?: string[];
+    completions?: Completions;
+  };
+  /**
+   * Capabilities the command needs. Custom commands run sandboxed: anything not
+   * declared here is denied, unless the command is run with --allow-all.
+   * @example
+   * permissions: { fs: ["./contents"], exec: ["git"], env: ["PRASMOID_*"] }
+   */
+  permissions?: Permissions;
+}
+
+/**
+ * Shell completion values: a fixed list, or a function returning candidates for the word being completed.
+ */
+type Completions = string[] | ((toComplete: string, args: string[]) => string[]);
+
+/**
+ * A command-line flag. Durations accept Go duration strings ("1m30s") or
+ * milliseconds, and are read back in milliseconds.
+ */
+interface Flag {
+  name: string;
+  shorthand?: string;
+  type: "string" | "bool" | "boolean" | "int" | "float" | "stringArray" | "duration" | "enum";
+  default?: string | boolean | number | string[];
+  description: string;
+  /** Allowed values, for "enum" and "string" flags. */
+  choices?: string[];
+  /** Fail when the flag is not given. */
+  required?: boolean;
+  /** Completion values. Defaults to ` + "`choices`" + `. */
+  completions?: Completions;
+}
+
+/**
+ * Capability declaration for a custom command. A "*" entry allows everything in its category.
+ */
+interface Permissions {
+  /** Files and directories the fs module may access, and require() may load modules from besides the command's directory, relative to the project root. */
+  fs?: string[];
+  /** Executables child_process may run. */
+  exec?: string[];
+  /** Environment variables (glob patterns allowed) visible through process.env. */
+  env?: string[];
+  /** Process operations the command may perform. */
+  process?: ("exit" | "kill" | "chdir" | "umask" | "setPriority" | "*")[];
+  /** Hosts (or "host:port") fetch may connect to, redirects included, and http servers may listen on. */
+  net?: string[];
+  /** Project operations of the prasmoid module, e.g. build() needs "build". */
+  project?: ("metadata" | "build" | "link" | "install" | "i18n" | "changeset" | "format" | "*")[];
+}
+
+type HeadersInit = Headers | Record<string, string> | [string, string][];
+type BodyInit = string | ArrayBuffer | ArrayBufferView;
+
+declare class Headers {
+  constructor(init?: HeadersInit);
+  append(name: string, value: string): void;
+  set(name: string, value: string): void;
+  get(name: string): string | null;
+  has(name: string): boolean;
+  delete(name: string): void;
+  forEach(callback: (value: string, name: string, headers: Headers) => void): void;
+  keys(): IterableIterator<string>;
+  values(): IterableIterator<string>;
+  entries(): IterableIterator<[string, string]>;
+  [Symbol.iterator](): IterableIterator<[string, string]>;
+}
+
+declare class Response {
+  constructor(
+    body?: BodyInit | null,
+    init?: { status?: number; statusText?: string; headers?: HeadersInit }
+  );
+  readonly status: number;
+  readonly statusText: string;
+  readonly ok: boolean;
+  readonly url: string;
+  readonly redirected: boolean;
+  readonly headers: Headers;
+  readonly bodyUsed: boolean;
+  text(): Promise<string>;
+  json<T = any>(): Promise<T>;
+  arrayBuffer(): Promise<ArrayBuffer>;
+  clone(): Response;
+}
+
+declare class AbortSignal {
+  static abort(reason?: any): AbortSignal;
+  readonly aborted: boolean;
+  readonly reason: any;
+  onabort: ((event: { type: "abort" }) => void) | null;
+  addEventListener(type: "abort", listener: (event: { type: "abort" }) => void): void;
+  removeEventListener(type: "abort", listener: (event: { type: "abort" }) => void): void;
+  throwIfAborted(): void;
+}
+
+declare class AbortController {
+  readonly signal: AbortSignal;
+  abort(reason?: any): void;
+}
+
+interface RequestInit {
+  method?: string;
+  headers?: HeadersInit;
+  body?: BodyInit | null;
+  signal?: AbortSignal;
+  /** Rejects with a TimeoutError when the response takes longer than this many milliseconds. */
+  timeout?: number;
+}
+
+/**
+ * Performs an HTTP request. Needs the host in ` + "`permissions.net`" + `.
+ * @example
+ * const res = await fetch("https://api.github.com/repos/PRASSamin/prasmoid");
+ * const repo = await res.json();
+ */
+declare function fetch(url: string | { toString(): string }, init?: RequestInit): Promise<Response>;
+
+declare module "http" {
+  interface IncomingMessage {
+    method: string;
+    url: string;
+    httpVersion: string;
+    headers: Record<string, string>;
+    on(event: "data", listener: (chunk: string) => void): this;
+    on(event: "end", listener: () => void): this;
+  }
+
+  interface ServerResponse {
+    statusCode: number;
+    readonly headersSent: boolean;
+    setHeader(name: string, value: string | number | string[]): this;
+    getHeader(name: string): string | undefined;
+    removeHeader(name: string): void;
+    writeHead(statusCode: number, statusMessage?: string | Record<string, string>, headers?: Record<string, string>): this;
+    write(chunk: string | ArrayBuffer | ArrayBufferView): boolean;
+    end(chunk?: string | ArrayBuffer | ArrayBufferView): this;
+  }
+
+  type RequestListener = (req: IncomingMessage, res: ServerResponse) => void;
+
+  interface Server {
+    readonly listening: boolean;
+    /** Listens on localhost unless a host is given; port 0 picks a free port. */
+    listen(port?: number, host?: string, callback?: () => void): this;
+    listen(port?: number, callback?: () => void): this;
+    close(callback?: () => void): this;
+    address(): { address: string; family: string; port: number } | null;
+    on(event: "request", listener: RequestListener): this;
+    on(event: "listening" | "close", listener: () => void): this;
+  }
+
+  export const STATUS_CODES: Record<number, string>;
+  export function createServer(listener?: RequestListener): Server;
 }
 
+type BufferEncoding = "utf8" | "utf-8" | "hex" | "base64" | "base64url";
+type BinaryLike = string | ArrayBuffer | ArrayBufferView;
+
+declare class Buffer extends Uint8Array {
+  static from(data: string, encoding?: BufferEncoding): Buffer;
+  static from(data: ArrayBuffer | ArrayBufferView | number[]): Buffer;
+  static alloc(size: number, fill?: string | number, encoding?: BufferEncoding): Buffer;
+  static concat(list: ArrayBufferView[], totalLength?: number): Buffer;
+  static isBuffer(value: any): value is Buffer;
+  static byteLength(value: BinaryLike, encoding?: BufferEncoding): number;
+  static compare(a: ArrayBufferView, b: ArrayBufferView): -1 | 0 | 1;
+  static isEncoding(encoding: string): boolean;
+  toString(encoding?: BufferEncoding): string;
+  equals(other: ArrayBufferView): boolean;
+  write(data: string, offset?: number, length?: number, encoding?: BufferEncoding): number;
+}
+
+declare module "buffer" {
+  export { Buffer };
+}
+
+declare module "crypto" {
+  type HashAlgorithm = "md5" | "sha1" | "sha224" | "sha256" | "sha384" | "sha512";
+
+  interface Hash {
+    update(data: BinaryLike, inputEncoding?: BufferEncoding): Hash;
+    digest(): Buffer;
+    digest(encoding: BufferEncoding): string;
+  }
+
+  /**
+   * @example
+   * const key = crypto.createHash("sha256").update(fs.readFileSync("metadata.json")).digest("hex");
+   */
+  export function createHash(algorithm: HashAlgorithm): Hash;
+  export function createHmac(algorithm: HashAlgorithm, key: BinaryLike): Hash;
+  /** One-shot digest, hex encoded unless another encoding is given. */
+  export function hash(algorithm: HashAlgorithm, data: BinaryLike, encoding?: BufferEncoding): string;
+  export function getHashes(): HashAlgorithm[];
+  export function randomBytes(size: number): Buffer;
+  export function randomUUID(): string;
+  export function randomInt(max: number): number;
+  export function randomInt(min: number, max: number): number;
+  export function timingSafeEqual(a: ArrayBufferView, b: ArrayBufferView): boolean;
+}
+
+declare module "zlib" {
+  interface ZlibOptions {
+    /** Compression level from 0 (none) to 9 (best), -1 for the default. */
+    level?: number;
+  }
+  type Callback = (err: Error | null, result: Buffer) => void;
+
+  export const constants: {
+    Z_NO_COMPRESSION: number;
+    Z_BEST_SPEED: number;
+    Z_BEST_COMPRESSION: number;
+    Z_DEFAULT_COMPRESSION: number;
+  };
+
+  export function gzipSync(data: BinaryLike, options?: ZlibOptions): Buffer;
+  export function gunzipSync(data: BinaryLike): Buffer;
+  export function deflateSync(data: BinaryLike, options?: ZlibOptions): Buffer;
+  export function inflateSync(data: BinaryLike): Buffer;
+  export function deflateRawSync(data: BinaryLike, options?: ZlibOptions): Buffer;
+  export function inflateRawSync(data: BinaryLike): Buffer;
+  /** Decompresses gzip or deflate data, detected from the header. */
+  export function unzipSync(data: BinaryLike): Buffer;
+
+  export function gzip(data: BinaryLike, callback: Callback): void;
+  export function gzip(data: BinaryLike, options: ZlibOptions, callback: Callback): void;
+  export function gunzip(data: BinaryLike, callback: Callback): void;
+  export function deflate(data: BinaryLike, callback: Callback): void;
+  export function deflate(data: BinaryLike, options: ZlibOptions, callback: Callback): void;
+  export function inflate(data: BinaryLike, callback: Callback): void;
+  export function deflateRaw(data: BinaryLike, callback: Callback): void;
+  export function deflateRaw(data: BinaryLike, options: ZlibOptions, callback: Callback): void;
+  export function inflateRaw(data: BinaryLike, callback: Callback): void;
+  export function unzip(data: BinaryLike, callback: Callback): void;
+}
+
+/**
+ * Interactive prompts. A prompt with a ` + "`name`" + ` can be answered up front with
+ * ` + "`--answer name=value`" + ` or the PRASMOID_ANSWER_<NAME> environment variable. When
+ * stdin isn't a terminal, unanswered prompts fall back to their default, or throw.
+ */
+declare module "prompt" {
+  interface PromptOptions<T> {
+    /** Identifies the prompt for --answer and PRASMOID_ANSWER_<NAME>. */
+    name?: string;
+    default?: T;
+    help?: string;
+    required?: boolean;
+    /** Returns true when the answer is valid, or an error message. */
+    validate?: (value: T) => boolean | string;
+  }
+
+  export function input(message: string, options?: PromptOptions<string>): string;
+  export function password(message: string, options?: PromptOptions<string>): string;
+  /** Opens $VISUAL or $EDITOR. */
+  export function editor(message: string, options?: PromptOptions<string>): string;
+  /** Preset answers accept true/false and yes/no. */
+  export function confirm(message: string, options?: PromptOptions<boolean>): boolean;
+  export function select(message: string, choices: string[], options?: PromptOptions<string>): string;
+  /** Preset answers are comma separated. */
+  export function multiselect(message: string, choices: string[], options?: PromptOptions<string[]>): string[];
+}
+
+/**
+ * Test API of ` + "`prasmoid command test`" + `, available in *.test.js and *.test.ts files.
+ * Every test runs in a fresh runtime, so mocks and globals never leak between tests.
+ */
+declare function describe(name: string, fn: () => void): void;
+declare namespace describe {
+  function skip(name: string, fn: () => void): void;
+  function only(name: string, fn: () => void): void;
+}
+declare function it(name: string, fn: () => void | Promise<void>): void;
+declare namespace it {
+  function skip(name: string, fn: () => void | Promise<void>): void;
+  function only(name: string, fn: () => void | Promise<void>): void;
+}
+declare const test: typeof it;
+declare function beforeEach(fn: () => void | Promise<void>): void;
+declare function afterEach(fn: () => void | Promise<void>): void;
+
+interface Matchers {
+  /** Negates the matcher that follows. */
+  not: Matchers;
+  toBe(expected: any): void;
+  /** Compares arrays and objects recursively. */
+  toEqual(expected: any): void;
+  toBeTruthy(): void;
+  toBeFalsy(): void;
+  toBeNull(): void;
+  toBeUndefined(): void;
+  toBeDefined(): void;
+  toContain(item: any): void;
+  toMatch(pattern: string | RegExp): void;
+  toHaveLength(length: number): void;
+  toBeGreaterThan(n: number): void;
+  toBeGreaterThanOrEqual(n: number): void;
+  toBeLessThan(n: number): void;
+  toBeLessThanOrEqual(n: number): void;
+  /** Calls the function and checks that it throws, optionally a matching message. */
+  toThrow(message?: string | RegExp): void;
+  toHaveBeenCalled(): void;
+  toHaveBeenCalledTimes(times: number): void;
+  toHaveBeenCalledWith(...args: any[]): void;
+}
+declare function expect(actual: any): Matchers;
+
+interface MockFunction<T extends (...args: any[]) => any = (...args: any[]) => any> {
+  (...args: Parameters<T>): ReturnType<T>;
+  /** The arguments of every call. */
+  readonly calls: Parameters<T>[];
+  mockReturnValue(value: ReturnType<T>): MockFunction<T>;
+  mockImplementation(impl: T): MockFunction<T>;
+  mockClear(): MockFunction<T>;
+}
+
+declare const mock: {
+  fn<T extends (...args: any[]) => any = (...args: any[]) => any>(impl?: T): MockFunction<T>;
+  /** Replaces members of a built-in module, e.g. mock.module("os", { hostname: () => "ci" }). */
+  module<T = any>(name: string, overrides: Record<string, any>): T;
+  /** Backs fs with the given { path: content } files; returns them, including the files written. The streams and watchers throw. */
+  fs(files?: Record<string, string>): Record<string, string>;
+  /** Answers child_process.execSync from { command: output } or a function; returns the mocked execSync. */
+  exec(outputs: Record<string, string> | ((command: string) => string)): MockFunction<(command: string) => string>;
+  /** Fakes metadata.json and prasmoid.config, and replaces the project operations with mock functions. */
+  prasmoid(options?: { metadata?: Record<string, any>; config?: Record<string, any> }): any;
+};
+
+/**
+ * Loads a command script, relative to the test file, and runs one of its commands.
+ * name is a path like "release publish" and defaults to the script's first command.
+ * Returns what the command's run function returned, awaited.
+ */
+declare function runCommand(
+  script: string,
+  options?: { name?: string; args?: string[]; flags?: Record<string, any>; answers?: Record<string, string> }
+): any;
+
 interface Console {
   /**
    * Logs a red-colored message.
@@ -281,11 +675,36 @@ type PrasmoidConfig = {
   commands: {
     dir: string;
     ignore: string[];
+    /** More directories or git repositories ("url#ref" checks out a branch or tag) to load commands from. */
+    sources?: string[];
   };
   i18n: {
     dir: string;
     locales: LocaleCode[];
   };
+  preview?: {
+    /** Globs of the files whose changes restart ` + "`prasmoid preview --watch`" + `, by default ["contents/**", "metadata.json"]. */
+    watch?: string[];
+    /** Layouts picked with ` + "`prasmoid preview --profile <name>`" + `. */
+    profiles?: Record<string, PreviewProfile>;
+  };
+  screenshot?: {
+    /** Names of preview.profiles to take screenshots with, by default all of them. */
+    profiles?: string[];
+    /** Languages to take screenshots in, by default the current one. */
+    locales?: LocaleCode[];
+    /** Take every screenshot with a light and a dark color scheme. */
+    lightDark?: boolean;
+  };
+};
+
+type PreviewProfile = {
+  /** Containment plugin, e.g. "org.kde.panel" or "org.kde.desktopcontainment". */
+  containment?: string;
+  formfactor?: "planar" | "horizontal" | "vertical" | "mediacenter" | "application";
+  location?: "floating" | "desktop" | "fullscreen" | "top" | "bottom" | "left" | "right";
+  /** Window size, "WIDTHxHEIGHT". */
+  size?: string;
 };
 `
 
@@ -310,3 +729,23 @@ prasmoid.Command({
 	long: "A longer description that spans multiple lines and likely contains examples\nand usage of using your command. For example:\n\nPlasmoid CLI is a CLI tool for KDE Plasmoid development.\nIt's a all-in-one tool for plasmoid development.",
 	flags: [],
 });`
+
+var TS_COMMAND_TEMPLATE = `/// <reference path="%s" />
+import { Command, getMetadata } from "prasmoid";
+
+Command({
+	run: (ctx: CommandContext): void => {
+		const plasmoidId = getMetadata("Id");
+		if (!plasmoidId) {
+			console.red(
+				"Could not get Plasmoid ID. Are you in a valid project directory?"
+			);
+			return;
+		}
+
+		console.color("%s Called", "blue");
+	},
+	short: "A brief description of your command.",
+	long: "A longer description that spans multiple lines and likely contains examples\nand usage of using your command. For example:\n\nPlasmoid CLI is a CLI tool for KDE Plasmoid development.\nIt's a all-in-one tool for plasmoid development.",
+	flags: [],
+});`
@@ -0,0 +1,63 @@
+package runtime
+
+import (
+	"bytes"
+
+	"github.com/dop251/goja"
+	"github.com/dop251/goja_nodejs/buffer"
+)
+
+// Buffer is goja_nodejs' buffer module, completed with the static helpers
+// scripts commonly rely on (concat, isBuffer, byteLength, compare).
+func Buffer(vm *Runtime, module *goja.Object) {
+	buffer.Require(vm.Runtime, module)
+	_buffer := module.Get("exports").(*goja.Object)
+	ctor := _buffer.Get("Buffer").ToObject(vm.Runtime)
+
+	toBytes := func(val goja.Value) []byte {
+		return buffer.DecodeBytes(vm.Runtime, val, goja.Undefined())
+	}
+
+	// Buffer.isBuffer(value) => boolean
+	_ = ctor.Set("isBuffer", func(call goja.FunctionCall) goja.Value {
+		obj, ok := call.Argument(0).(*goja.Object)
+		return vm.ToValue(ok && vm.InstanceOf(obj, ctor))
+	})
+
+	// Buffer.byteLength(string | buffer, [encoding]) => number
+	_ = ctor.Set("byteLength", func(call goja.FunctionCall) goja.Value {
+		return vm.ToValue(len(buffer.DecodeBytes(vm.Runtime, call.Argument(0), call.Argument(1))))
+	})
+
+	// Buffer.concat(list, [totalLength]) => Buffer
+	_ = ctor.Set("concat", func(call goja.FunctionCall) goja.Value {
+		var list []goja.Value
+		if err := vm.ExportTo(call.Argument(0), &list); err != nil {
+			panic(vm.NewTypeError("Buffer.concat: list argument must be an Array of Buffers"))
+		}
+		var out []byte
+		for _, item := range list {
+			out = append(out, toBytes(item)...)
+		}
+		if total := call.Argument(1); !goja.IsUndefined(total) {
+			length := int(total.ToInteger())
+			if length < len(out) {
+				out = out[:length]
+			} else {
+				out = append(out, make([]byte, length-len(out))...)
+			}
+		}
+		return buffer.WrapBytes(vm.Runtime, out)
+	})
+
+	// Buffer.compare(a, b) => -1 | 0 | 1
+	_ = ctor.Set("compare", func(call goja.FunctionCall) goja.Value {
+		return vm.ToValue(bytes.Compare(toBytes(call.Argument(0)), toBytes(call.Argument(1))))
+	})
+
+	_ = ctor.Set("isEncoding", func(call goja.FunctionCall) goja.Value {
+		return vm.ToValue(buffer.StringCodecByName(call.Argument(0).String()) != nil)
+	})
+
+	_ = vm.Set("Buffer", ctor)
+}
@@ -0,0 +1,22 @@
+package runtime
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBufferModule(t *testing.T) {
+	vm := NewRuntime()
+	val, err := vm.RunString(`[
+		Buffer.from("aGVsbG8=", "base64").toString(),
+		Buffer.concat([Buffer.from("ab"), Buffer.from("cd")]).toString("hex"),
+		Buffer.byteLength("héllo"),
+		Buffer.compare(Buffer.from("a"), Buffer.from("b")),
+		Buffer.isBuffer("nope"),
+		require("buffer").Buffer === Buffer,
+	]`)
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{"hello", "61626364", int64(6), int64(-1), false, true}, val.Export())
+}
@@ -1,14 +1,19 @@
 package runtime
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"os"
 	"os/exec"
 	"strings"
+	"syscall"
 
 	"github.com/dop251/goja"
+	"golang.org/x/sys/unix"
 )
 
-func ChildProcess(vm *goja.Runtime, module *goja.Object) {
+func ChildProcess(vm *Runtime, module *goja.Object) {
 	_cp := module.Get("exports").(*goja.Object)
 
 	// === execSync remains unchanged ===
@@ -18,6 +23,7 @@ func ChildProcess(vm *goja.Runtime, module *goja.Object) {
 		if len(parts) == 0 {
 			return vm.ToValue("No command given")
 		}
+		checkExec(vm, "child_process.execSync", parts[0])
 		cmd := exec.Command(parts[0], parts[1:]...)
 		out, err := cmd.CombinedOutput()
 		if err != nil {
@@ -33,6 +39,35 @@ func ChildProcess(vm *goja.Runtime, module *goja.Object) {
 	_ = _cp.Set("execSync", execSync)
 	_ = _cp.Set("exec", execAsync)
 
+	// spawn(command[, args][, options]) => ChildProcess, where options is
+	// { cwd, env, stdio, shell }. stdio is "pipe", "inherit", "ignore" or an
+	// array of those for stdin, stdout and stderr.
+	_ = _cp.Set("spawn", func(call goja.FunctionCall) goja.Value {
+		command := stringArg(vm, call, 0, "command")
+		var args []string
+		optsArg := call.Argument(1)
+		if list, ok := optsArg.Export().([]interface{}); ok {
+			for _, arg := range list {
+				args = append(args, fmt.Sprint(arg))
+			}
+			optsArg = call.Argument(2)
+		}
+		opts := spawnOptionsOf(vm, optsArg)
+
+		name := command
+		if opts.shell != "" {
+			name, args = opts.shell, []string{"-c", strings.Join(append([]string{command}, args...), " ")}
+		}
+		checkExec(vm, "child_process.spawn", name)
+		if opts.cwd != "" {
+			checkFS(vm, "child_process.spawn", opts.cwd)
+		}
+		cmd := exec.Command(name, args...)
+		cmd.Dir = opts.cwd
+		cmd.Env = opts.env
+		return spawn(vm, cmd, opts.stdio)
+	})
+
 	// === NOT IMPLEMENTED FUNCTIONS ===
 	notImplemented := func(name string) func(goja.FunctionCall) goja.Value {
 		return func(call goja.FunctionCall) goja.Value {
@@ -41,8 +76,200 @@ func ChildProcess(vm *goja.Runtime, module *goja.Object) {
 	}
 
 	for _, name := range []string{
-		"execFileSync", "execFile", "spawn", "spawnSync",
+		"execFileSync", "execFile", "spawnSync",
 	} {
 		_ = _cp.Set(name, notImplemented(name))
 	}
 }
+
+// spawnOptions are the options of spawn.
+type spawnOptions struct {
+	cwd   string
+	env   []string
+	stdio [3]string
+	shell string
+}
+
+func spawnOptionsOf(vm *Runtime, val goja.Value) spawnOptions {
+	opts := spawnOptions{stdio: [3]string{"pipe", "pipe", "pipe"}}
+	obj, _ := val.(*goja.Object)
+	get := func(name string) goja.Value {
+		if obj == nil {
+			return nil
+		}
+		if v := obj.Get(name); v != nil && !goja.IsUndefined(v) && !goja.IsNull(v) {
+			return v
+		}
+		return nil
+	}
+	if v := get("cwd"); v != nil {
+		opts.cwd = v.String()
+	}
+	// Like node, the child gets process.env by default, which only has the
+	// variables the sandbox lets the command read.
+	env := vm.Get("process").ToObject(vm.Runtime).Get("env")
+	if v := get("env"); v != nil {
+		env = v
+	}
+	envObj := env.ToObject(vm.Runtime)
+	opts.env = []string{}
+	for _, key := range envObj.Keys() {
+		opts.env = append(opts.env, key+"="+envObj.Get(key).String())
+	}
+	if v := get("stdio"); v != nil {
+		if list, ok := v.Export().([]interface{}); ok {
+			for i := 0; i < len(list) && i < 3; i++ {
+				if list[i] != nil {
+					opts.stdio[i] = fmt.Sprint(list[i])
+				}
+			}
+		} else {
+			opts.stdio = [3]string{v.String(), v.String(), v.String()}
+		}
+	}
+	if v := get("shell"); v != nil {
+		if shell, ok := v.Export().(string); ok {
+			opts.shell = shell
+		} else if v.ToBoolean() {
+			opts.shell = "/bin/sh"
+		}
+	}
+	return opts
+}
+
+// spawn starts cmd and returns its ChildProcess. The pipes of stdio are
+// streams. "exit" is emitted when the process exits, and "close" once its
+// streams are closed too.
+func spawn(vm *Runtime, cmd *exec.Cmd, stdio [3]string) goja.Value {
+	child := vm.NewObject()
+	events := newEmitter(vm, child)
+	_ = child.Set("spawnfile", cmd.Path)
+	_ = child.Set("spawnargs", cmd.Args)
+	_ = child.Set("exitCode", goja.Null())
+	_ = child.Set("signalCode", goja.Null())
+	_ = child.Set("killed", false)
+
+	// childEnds are the ends of the pipes that the child gets, closed here once it started
+	var childEnds []io.Closer
+	var readers []*readStream
+	names := []string{"stdin", "stdout", "stderr"}
+	inherit := []*os.File{os.Stdin, os.Stdout, os.Stderr}
+	files := make([]*os.File, 3)
+	var failed error
+	for fd, mode := range stdio {
+		_ = child.Set(names[fd], goja.Null())
+		switch mode {
+		case "inherit":
+			files[fd] = inherit[fd]
+		case "pipe":
+			r, w, err := os.Pipe()
+			if err != nil {
+				failed = err
+				continue
+			}
+			if fd == 0 {
+				files[fd] = r
+				childEnds = append(childEnds, r)
+				_ = child.Set(names[fd], newWriteStream(vm, w, w).obj)
+			} else {
+				files[fd] = w
+				childEnds = append(childEnds, w)
+				stream := newReadStream(vm, r, r, 0)
+				readers = append(readers, stream)
+				_ = child.Set(names[fd], stream.obj)
+			}
+		}
+	}
+	// Unset files are /dev/null, like "ignore"
+	if files[0] != nil {
+		cmd.Stdin = files[0]
+	}
+	if files[1] != nil {
+		cmd.Stdout = files[1]
+	}
+	if files[2] != nil {
+		cmd.Stderr = files[2]
+	}
+
+	if failed == nil {
+		failed = cmd.Start()
+	}
+	for _, end := range childEnds {
+		_ = end.Close()
+	}
+	if failed != nil {
+		if errors.Is(failed, exec.ErrNotFound) {
+			failed = fmt.Errorf("spawn %s %w", cmd.Args[0], syscall.ENOENT)
+		}
+		for _, stream := range readers {
+			stream.destroy(goja.Undefined())
+		}
+		done := Schedule(vm)
+		go done(func() {
+			events.emitFromLoop("error", systemError(vm, failed))
+			events.emitFromLoop("close", vm.ToValue(-int(syscall.ENOENT)), goja.Null())
+		})
+		return child
+	}
+
+	_ = child.Set("pid", cmd.Process.Pid)
+	// kill([signal]) sends signal, SIGTERM by default, and reports whether it could
+	_ = child.Set("kill", func(call goja.FunctionCall) goja.Value {
+		sig := syscall.SIGTERM
+		switch arg := call.Argument(0); {
+		case goja.IsUndefined(arg):
+		case typeOf(arg) == "number":
+			sig = syscall.Signal(arg.ToInteger())
+		default:
+			if sig = unix.SignalNum(arg.String()); sig == 0 {
+				panic(vm.NewTypeError("Unknown signal: " + arg.String()))
+			}
+		}
+		if err := cmd.Process.Signal(sig); err != nil {
+			return vm.ToValue(false)
+		}
+		_ = child.Set("killed", true)
+		return vm.ToValue(true)
+	})
+
+	done := Schedule(vm)
+	go done(func() { events.emitFromLoop("spawn") })
+
+	exited := Schedule(vm)
+	go func() {
+		_ = cmd.Wait()
+		exited(func() {
+			code, signal := goja.Null(), goja.Null()
+			if status, ok := cmd.ProcessState.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+				signal = vm.ToValue(unix.SignalName(status.Signal()))
+			} else {
+				code = vm.ToValue(cmd.ProcessState.ExitCode())
+			}
+			_ = child.Set("exitCode", code)
+			_ = child.Set("signalCode", signal)
+			events.emitFromLoop("exit", code, signal)
+
+			// Streams nobody reads are closed, "close" waits for the others
+			open := 0
+			closed := func(...goja.Value) {
+				if open--; open == 0 {
+					events.emitFromLoop("close", code, signal)
+				}
+			}
+			for _, stream := range readers {
+				if stream.flowing == nil && !stream.destroyed {
+					stream.destroy(goja.Undefined())
+				}
+				if !stream.closed {
+					open++
+					stream.on("close", closed)
+				}
+			}
+			if open == 0 {
+				open = 1
+				closed()
+			}
+		})
+	}()
+	return child
+}
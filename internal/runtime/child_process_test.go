@@ -61,7 +61,6 @@ func TestChildProcessModule(t *testing.T) {
 		notImplementedFuncs := []string{
 			"execFileSync",
 			"execFile",
-			"spawn",
 			"spawnSync",
 		}
 
@@ -0,0 +1,59 @@
+package runtime
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// compatCase is an expression of testdata/node-compat.json and what node
+// returned for it, rendered by compatShow.
+type compatCase struct {
+	Expr   string `json:"expr"`
+	Value  string `json:"value,omitempty"`
+	Throws *struct {
+		Name    string `json:"name"`
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"throws,omitempty"`
+}
+
+// compatTable is resolved before any test changes the working directory.
+var compatTable, _ = filepath.Abs("testdata/node-compat.json")
+
+// compatShow renders the result of an expression the way the table was
+// recorded with node: JSON for values, or the name, code and message of an error.
+const compatShow = `(f) => {
+	let v;
+	try { v = f(); } catch (e) { return { throws: { name: e.name, code: e.code, message: e.message } }; }
+	return { value: v === undefined ? "undefined" : typeof v === "bigint" ? "bigint" : JSON.stringify(v) };
+}`
+
+// TestNodeCompat checks the path, os and process modules against output
+// recorded from node 20 on Linux.
+func TestNodeCompat(t *testing.T) {
+	data, err := os.ReadFile(compatTable)
+	require.NoError(t, err)
+	var cases []compatCase
+	require.NoError(t, json.Unmarshal(data, &cases))
+
+	vm := NewRuntime()
+	show, err := vm.RunString(compatShow)
+	require.NoError(t, err)
+	require.NoError(t, vm.Set("show", show))
+
+	for _, c := range cases {
+		t.Run(c.Expr, func(t *testing.T) {
+			val, err := vm.RunString("JSON.stringify(show(() => " + c.Expr + "))")
+			require.NoError(t, err)
+			var got compatCase
+			require.NoError(t, json.Unmarshal([]byte(val.String()), &got))
+			assert.Equal(t, c.Value, got.Value)
+			assert.Equal(t, c.Throws, got.Throws)
+		})
+	}
+}
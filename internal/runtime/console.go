@@ -23,7 +23,7 @@ import (
 //
 // Example:
 // console.color("This is a", "red", " warning!")
-func Console(vm *goja.Runtime, module *goja.Object) {
+func Console(vm *Runtime, module *goja.Object) {
 	_console := module.Get("exports").(*goja.Object)
 
 	createPlainLogger := func(prefix string) func(goja.FunctionCall) goja.Value {
@@ -117,6 +117,52 @@ func Console(vm *goja.Runtime, module *goja.Object) {
 	})
 }
 
+// Inspect formats a value the way the REPL echoes it: like console.log, with strings quoted.
+// Objects keep the order of their properties.
+func Inspect(val goja.Value) string {
+	return inspect(val, map[*goja.Object]bool{})
+}
+
+func inspect(val goja.Value, seen map[*goja.Object]bool) string {
+	if val == nil || goja.IsUndefined(val) {
+		return "undefined"
+	}
+	obj, ok := val.(*goja.Object)
+	if !ok {
+		return stringifyJS(val.Export(), true)
+	}
+	if _, ok := goja.AssertFunction(val); ok {
+		return "[Function]"
+	}
+	if seen[obj] {
+		return "[Circular]"
+	}
+	seen[obj] = true
+	defer delete(seen, obj)
+
+	switch obj.ClassName() {
+	case "Array":
+		parts := []string{}
+		for _, key := range obj.Keys() {
+			parts = append(parts, inspect(obj.Get(key), seen))
+		}
+		return "[" + strings.Join(parts, ", ") + "]"
+	case "Object":
+		keys := obj.Keys()
+		if len(keys) == 0 {
+			return "{}"
+		}
+		parts := make([]string, len(keys))
+		for i, key := range keys {
+			parts[i] = key + ": " + inspect(obj.Get(key), seen)
+		}
+		return "{ " + strings.Join(parts, ", ") + " }"
+	case "RegExp", "Date", "Error":
+		return obj.String()
+	}
+	return stringifyJS(val.Export(), true)
+}
+
 func stringifyJS(v interface{}, inContainer bool) string {
 	switch val := v.(type) {
 	case nil:
@@ -0,0 +1,148 @@
+package runtime
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"fmt"
+	"hash"
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/dop251/goja"
+	"github.com/dop251/goja_nodejs/buffer"
+)
+
+var hashAlgorithms = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha224": sha256.New224,
+	"sha256": sha256.New,
+	"sha384": sha512.New384,
+	"sha512": sha512.New,
+}
+
+func Crypto(vm *Runtime, module *goja.Object) {
+	_crypto := module.Get("exports").(*goja.Object)
+
+	algorithm := func(name string) func() hash.Hash {
+		newHash, ok := hashAlgorithms[strings.ToLower(strings.ReplaceAll(name, "-", ""))]
+		if !ok {
+			panic(vm.NewTypeError("Digest method not supported: %s", name))
+		}
+		return newHash
+	}
+
+	// createHash(algorithm) => Hash
+	_ = _crypto.Set("createHash", func(call goja.FunctionCall) goja.Value {
+		return newHashObject(vm, "Hash", algorithm(call.Argument(0).String())())
+	})
+
+	// createHmac(algorithm, key) => Hmac
+	_ = _crypto.Set("createHmac", func(call goja.FunctionCall) goja.Value {
+		newHash := algorithm(call.Argument(0).String())
+		key := buffer.DecodeBytes(vm.Runtime, call.Argument(1), goja.Undefined())
+		return newHashObject(vm, "Hmac", hmac.New(newHash, key))
+	})
+
+	// hash(algorithm, data, [encoding = "hex"]) => string
+	_ = _crypto.Set("hash", func(call goja.FunctionCall) goja.Value {
+		h := algorithm(call.Argument(0).String())()
+		h.Write(buffer.DecodeBytes(vm.Runtime, call.Argument(1), goja.Undefined()))
+		enc := call.Argument(2)
+		if goja.IsUndefined(enc) {
+			enc = vm.ToValue("hex")
+		}
+		return buffer.EncodeBytes(vm.Runtime, h.Sum(nil), enc)
+	})
+
+	_ = _crypto.Set("getHashes", func(call goja.FunctionCall) goja.Value {
+		names := make([]string, 0, len(hashAlgorithms))
+		for name := range hashAlgorithms {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return vm.ToValue(names)
+	})
+
+	// randomBytes(size) => Buffer
+	_ = _crypto.Set("randomBytes", func(call goja.FunctionCall) goja.Value {
+		size := call.Argument(0).ToInteger()
+		if size < 0 {
+			panic(vm.NewTypeError("crypto.randomBytes: size must be a non-negative number"))
+		}
+		data := make([]byte, size)
+		if _, err := rand.Read(data); err != nil {
+			panic(vm.NewGoError(err))
+		}
+		return buffer.WrapBytes(vm.Runtime, data)
+	})
+
+	// randomUUID() => RFC 4122 version 4 UUID
+	_ = _crypto.Set("randomUUID", func(call goja.FunctionCall) goja.Value {
+		var u [16]byte
+		if _, err := rand.Read(u[:]); err != nil {
+			panic(vm.NewGoError(err))
+		}
+		u[6] = (u[6] & 0x0f) | 0x40
+		u[8] = (u[8] & 0x3f) | 0x80
+		return vm.ToValue(fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:16]))
+	})
+
+	// randomInt([min = 0], max) => integer in [min, max)
+	_ = _crypto.Set("randomInt", func(call goja.FunctionCall) goja.Value {
+		min, max := int64(0), call.Argument(0).ToInteger()
+		if len(call.Arguments) >= 2 {
+			min, max = call.Argument(0).ToInteger(), call.Argument(1).ToInteger()
+		}
+		if max <= min {
+			panic(vm.NewTypeError("crypto.randomInt: max must be greater than min"))
+		}
+		n, err := rand.Int(rand.Reader, big.NewInt(max-min))
+		if err != nil {
+			panic(vm.NewGoError(err))
+		}
+		return vm.ToValue(min + n.Int64())
+	})
+
+	// timingSafeEqual(a, b) => boolean
+	_ = _crypto.Set("timingSafeEqual", func(call goja.FunctionCall) goja.Value {
+		a := buffer.DecodeBytes(vm.Runtime, call.Argument(0), goja.Undefined())
+		b := buffer.DecodeBytes(vm.Runtime, call.Argument(1), goja.Undefined())
+		if len(a) != len(b) {
+			panic(vm.NewTypeError("Input buffers must have the same byte length"))
+		}
+		return vm.ToValue(subtle.ConstantTimeCompare(a, b) == 1)
+	})
+}
+
+// newHashObject wraps a hash.Hash in a node-style Hash/Hmac object.
+func newHashObject(vm *Runtime, kind string, h hash.Hash) *goja.Object {
+	obj := vm.NewObject()
+	finalized := false
+
+	// update(data, [inputEncoding]) => this
+	_ = obj.Set("update", func(call goja.FunctionCall) goja.Value {
+		if finalized {
+			panic(vm.NewGoError(fmt.Errorf("%s: digest already called", kind)))
+		}
+		h.Write(buffer.DecodeBytes(vm.Runtime, call.Argument(0), call.Argument(1)))
+		return obj
+	})
+
+	// digest([encoding]) => Buffer, or a string when an encoding is given
+	_ = obj.Set("digest", func(call goja.FunctionCall) goja.Value {
+		if finalized {
+			panic(vm.NewGoError(fmt.Errorf("%s: digest already called", kind)))
+		}
+		finalized = true
+		return buffer.EncodeBytes(vm.Runtime, h.Sum(nil), call.Argument(0))
+	})
+
+	return obj
+}
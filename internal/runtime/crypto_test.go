@@ -0,0 +1,67 @@
+package runtime
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCryptoModule(t *testing.T) {
+	vm := NewRuntime()
+
+	t.Run("createHash", func(t *testing.T) {
+		val, err := vm.RunString(`crypto.createHash("sha256").update("hello ").update("world").digest("hex")`)
+		require.NoError(t, err)
+		assert.Equal(t, "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9", val.String())
+
+		val, err = vm.RunString(`crypto.createHash("md5").update("aGk=", "base64").digest("base64")`)
+		require.NoError(t, err)
+		assert.Equal(t, "SfaKXIST7CwL9ImCHCH8Ow==", val.String())
+
+		val, err = vm.RunString(`Buffer.isBuffer(require("crypto").createHash("sha1").digest())`)
+		require.NoError(t, err)
+		assert.True(t, val.ToBoolean())
+	})
+
+	t.Run("one-shot hash", func(t *testing.T) {
+		val, err := vm.RunString(`crypto.hash("sha1", "abc")`)
+		require.NoError(t, err)
+		assert.Equal(t, "a9993e364706816aba3e25717850c26c9cd0d89d", val.String())
+	})
+
+	t.Run("createHmac", func(t *testing.T) {
+		val, err := vm.RunString(`crypto.createHmac("sha256", "key").update("The quick brown fox jumps over the lazy dog").digest("hex")`)
+		require.NoError(t, err)
+		assert.Equal(t, "f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8", val.String())
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		_, err := vm.RunString(`crypto.createHash("whirlpool")`)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "Digest method not supported")
+
+		_, err = vm.RunString(`const h = crypto.createHash("sha256"); h.digest(); h.digest()`)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "digest already called")
+	})
+
+	t.Run("random values", func(t *testing.T) {
+		val, err := vm.RunString(`crypto.randomUUID()`)
+		require.NoError(t, err)
+		assert.Regexp(t, regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`), val.String())
+
+		val, err = vm.RunString(`crypto.randomBytes(16).toString("hex").length`)
+		require.NoError(t, err)
+		assert.Equal(t, int64(32), val.ToInteger())
+
+		val, err = vm.RunString(`const n = crypto.randomInt(5, 7); n === 5 || n === 6`)
+		require.NoError(t, err)
+		assert.True(t, val.ToBoolean())
+
+		val, err = vm.RunString(`crypto.timingSafeEqual(Buffer.from("abc"), Buffer.from("abc"))`)
+		require.NoError(t, err)
+		assert.True(t, val.ToBoolean())
+	})
+}
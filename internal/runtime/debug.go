@@ -0,0 +1,93 @@
+package runtime
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/dop251/goja"
+	"github.com/fatih/color"
+)
+
+var (
+	moduleNamesMu sync.Mutex
+	moduleNames   []string
+
+	debugOutput io.Writer = os.Stderr
+)
+
+func registerModuleName(name string) {
+	moduleNamesMu.Lock()
+	defer moduleNamesMu.Unlock()
+	if !slices.Contains(moduleNames, name) {
+		moduleNames = append(moduleNames, name)
+	}
+}
+
+// EnableDebug logs every call scripts in vm make to the built-in modules and
+// fetch, with its arguments, to stderr. console is left out, its calls show
+// anyway.
+func EnableDebug(vm *Runtime) {
+	moduleNamesMu.Lock()
+	names := slices.Clone(moduleNames)
+	moduleNamesMu.Unlock()
+
+	for _, name := range names {
+		if name == "console" {
+			continue
+		}
+		if exports, ok := vm.Get(name).(*goja.Object); ok {
+			traceCalls(vm, name, exports, true)
+		}
+	}
+	if fetch, ok := goja.AssertFunction(vm.Get("fetch")); ok {
+		_ = vm.Set("fetch", traceFunc(vm, "fetch", fetch))
+	}
+}
+
+// traceCalls replaces the functions of obj with ones logging their calls.
+// Classes are left alone, as wrapping them would break new. nested also
+// traces the functions of plain objects in obj, like prasmoid.i18n.
+func traceCalls(vm *Runtime, prefix string, obj *goja.Object, nested bool) {
+	for _, key := range obj.Keys() {
+		member, ok := obj.Get(key).(*goja.Object)
+		if !ok {
+			continue
+		}
+		name := prefix + "." + key
+		if fn, ok := goja.AssertFunction(member); ok {
+			if r := []rune(key); unicode.IsUpper(r[0]) {
+				continue
+			}
+			_ = obj.Set(key, traceFunc(vm, name, fn))
+		} else if nested && member.ClassName() == "Object" {
+			traceCalls(vm, name, member, false)
+		}
+	}
+}
+
+// maxDebugArg is how much of an argument the debug log shows.
+const maxDebugArg = 80
+
+func traceFunc(vm *Runtime, name string, fn goja.Callable) goja.Value {
+	return vm.ToValue(func(call goja.FunctionCall) goja.Value {
+		args := make([]string, len(call.Arguments))
+		for i, arg := range call.Arguments {
+			args[i] = Inspect(arg)
+			if len(args[i]) > maxDebugArg {
+				args[i] = args[i][:maxDebugArg] + "…"
+			}
+		}
+		fmt.Fprintln(debugOutput, color.HiBlackString("[debug] %s(%s)", name, strings.Join(args, ", ")))
+		val, err := fn(call.This, call.Arguments...)
+		if err != nil {
+			fmt.Fprintln(debugOutput, color.HiBlackString("[debug] %s threw %s", name, strings.SplitN(err.Error(), "\n", 2)[0]))
+			throw(vm, err)
+		}
+		return val
+	})
+}
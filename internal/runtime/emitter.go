@@ -0,0 +1,130 @@
+package runtime
+
+import (
+	"github.com/dop251/goja"
+)
+
+// emitter is a minimal node EventEmitter, mixed into obj. process, streams
+// and readline interfaces are emitters.
+type emitter struct {
+	vm        *Runtime
+	obj       *goja.Object
+	listeners map[string][]emitterListener
+	// onListen is called when a listener is added, e.g. for streams to start
+	// flowing once someone listens to "data".
+	onListen func(event string)
+}
+
+type emitterListener struct {
+	fn   goja.Value
+	once bool
+}
+
+func newEmitter(vm *Runtime, obj *goja.Object) *emitter {
+	e := &emitter{vm: vm, obj: obj, listeners: map[string][]emitterListener{}}
+	add := func(once, prepend bool) func(goja.FunctionCall) goja.Value {
+		return func(call goja.FunctionCall) goja.Value {
+			event := call.Argument(0).String()
+			if _, ok := goja.AssertFunction(call.Argument(1)); !ok {
+				panic(invalidArgType(vm, "listener", "function", call.Argument(1)))
+			}
+			e.add(event, call.Argument(1), once, prepend)
+			return obj
+		}
+	}
+	remove := func(call goja.FunctionCall) goja.Value {
+		event := call.Argument(0).String()
+		list := e.listeners[event]
+		for i := len(list) - 1; i >= 0; i-- {
+			if list[i].fn.StrictEquals(call.Argument(1)) {
+				e.listeners[event] = append(list[:i:i], list[i+1:]...)
+				break
+			}
+		}
+		return obj
+	}
+
+	_ = obj.Set("on", add(false, false))
+	_ = obj.Set("addListener", add(false, false))
+	_ = obj.Set("prependListener", add(false, true))
+	_ = obj.Set("once", add(true, false))
+	_ = obj.Set("off", remove)
+	_ = obj.Set("removeListener", remove)
+	_ = obj.Set("removeAllListeners", func(call goja.FunctionCall) goja.Value {
+		if goja.IsUndefined(call.Argument(0)) {
+			e.listeners = map[string][]emitterListener{}
+		} else {
+			delete(e.listeners, call.Argument(0).String())
+		}
+		return obj
+	})
+	_ = obj.Set("listeners", func(call goja.FunctionCall) goja.Value {
+		fns := []goja.Value{}
+		for _, l := range e.listeners[call.Argument(0).String()] {
+			fns = append(fns, l.fn)
+		}
+		return vm.ToValue(fns)
+	})
+	_ = obj.Set("listenerCount", func(call goja.FunctionCall) goja.Value {
+		return vm.ToValue(len(e.listeners[call.Argument(0).String()]))
+	})
+	_ = obj.Set("emit", func(call goja.FunctionCall) goja.Value {
+		return vm.ToValue(e.emit(call.Argument(0).String(), call.Arguments[1:]...))
+	})
+	return e
+}
+
+func (e *emitter) add(event string, fn goja.Value, once, prepend bool) {
+	listener := emitterListener{fn: fn, once: once}
+	if prepend {
+		e.listeners[event] = append([]emitterListener{listener}, e.listeners[event]...)
+	} else {
+		e.listeners[event] = append(e.listeners[event], listener)
+	}
+	if e.onListen != nil {
+		e.onListen(event)
+	}
+}
+
+// on adds a listener implemented in Go.
+func (e *emitter) on(event string, fn func(args ...goja.Value)) {
+	e.add(event, e.vm.ToValue(func(call goja.FunctionCall) goja.Value {
+		fn(call.Arguments...)
+		return goja.Undefined()
+	}), false, false)
+}
+
+// emit calls the listeners of event in order and reports whether there were any.
+// A listener that throws aborts the emit. Like node, an "error" nobody listens
+// to is thrown.
+func (e *emitter) emit(event string, args ...goja.Value) bool {
+	list := e.listeners[event]
+	if len(list) == 0 {
+		if event == "error" && len(args) > 0 {
+			panic(args[0])
+		}
+		return false
+	}
+	var kept []emitterListener
+	for _, l := range list {
+		if !l.once {
+			kept = append(kept, l)
+		}
+	}
+	e.listeners[event] = kept
+	for _, l := range list {
+		fn, _ := goja.AssertFunction(l.fn)
+		if _, err := fn(e.obj, args...); err != nil {
+			panic(err)
+		}
+	}
+	return true
+}
+
+// emitFromLoop emits event from an event loop callback, where no script can
+// catch what a listener throws. It's reported as an uncaught error instead.
+func (e *emitter) emitFromLoop(event string, args ...goja.Value) {
+	if exception := e.vm.Try(func() { e.emit(event, args...) }); exception != nil {
+		reportUncaught(e.vm, exception)
+	}
+}
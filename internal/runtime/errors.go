@@ -0,0 +1,198 @@
+package runtime
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/PRASSamin/prasmoid/internal/stack"
+	"github.com/dop251/goja"
+)
+
+// ScriptError is an error thrown by a script, with its stack trace.
+type ScriptError struct {
+	// Message is what was thrown, e.g. "TypeError: x is not a function".
+	Message string
+	Frames  []stack.Frame
+}
+
+func (e *ScriptError) Error() string {
+	var b strings.Builder
+	b.WriteString(e.Message)
+	for _, frame := range e.Frames {
+		b.WriteString("\n\tat " + frame.String())
+	}
+	return b.String()
+}
+
+// stackLine matches a frame of goja's stack traces: "\tat fn (file:1:2(3))" or "\tat file:1:2(3)".
+var stackLine = regexp.MustCompile(`^\s*at (?:(.+) \()?(.+):(\d+):(\d+)\(\d+\)\)?$`)
+
+// parseStack reads the frames of a stack trace, leaving out native functions.
+func parseStack(trace string) []stack.Frame {
+	var frames []stack.Frame
+	for _, line := range strings.Split(trace, "\n") {
+		m := stackLine.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		lineNo, _ := strconv.Atoi(m[3])
+		column, _ := strconv.Atoi(m[4])
+		frames = append(frames, stack.Frame{Func: m[1], File: m[2], Line: lineNo, Column: column})
+	}
+	return frames
+}
+
+// errorOf describes a thrown value. Errors keep the stack of where they were
+// created, other values get the stack they were thrown from.
+func errorOf(val goja.Value, stack []goja.StackFrame) *ScriptError {
+	if obj, ok := val.(*goja.Object); ok {
+		if s := obj.Get("stack"); s != nil && !goja.IsUndefined(s) {
+			return &ScriptError{Message: obj.String(), Frames: parseStack(s.String())}
+		}
+	}
+	var trace bytes.Buffer
+	for _, frame := range stack {
+		trace.WriteString("\tat ")
+		frame.Write(&trace)
+		trace.WriteByte('\n')
+	}
+	return &ScriptError{Message: Inspect(val), Frames: parseStack(trace.String())}
+}
+
+// AsScriptError returns the script error err stands for: exceptions a script
+// threw, rejected promises and syntax errors.
+func AsScriptError(err error) (*ScriptError, bool) {
+	var scriptErr *ScriptError
+	if errors.As(err, &scriptErr) {
+		return scriptErr, true
+	}
+	var exception *goja.Exception
+	if errors.As(err, &exception) {
+		return errorOf(exception.Value(), exception.Stack()), true
+	}
+	var syntaxErr *goja.CompilerSyntaxError
+	if errors.As(err, &syntaxErr) {
+		e := &ScriptError{Message: "SyntaxError: " + syntaxErr.Message}
+		if syntaxErr.File != nil {
+			pos := syntaxErr.File.Position(syntaxErr.Offset)
+			e.Frames = []stack.Frame{{File: pos.Filename, Line: pos.Line, Column: pos.Column}}
+		}
+		return e, true
+	}
+	return nil, false
+}
+
+// FormatError describes an error for the terminal. Script errors get their
+// stack trace and a frame of the code that threw.
+func FormatError(err error) string {
+	scriptErr, ok := AsScriptError(err)
+	if !ok {
+		return err.Error()
+	}
+	var b strings.Builder
+	b.WriteString(scriptErr.Message)
+	for _, frame := range scriptErr.Frames {
+		if code := stack.CodeFrame(frame); code != "" {
+			b.WriteString("\n\n" + code + "\n")
+			break
+		}
+	}
+	for _, frame := range scriptErr.Frames {
+		b.WriteString("\n    at " + frame.String())
+	}
+	return b.String()
+}
+
+// rejectionTracker holds the promises of a runtime rejected without a handler.
+type rejectionTracker struct {
+	mu       sync.Mutex
+	promises []*goja.Promise
+	// uncaught are the errors thrown by callbacks of the event loop.
+	uncaught []error
+}
+
+// trackRejections records the promises of vm that are rejected without a
+// handler, until a handler is attached.
+func trackRejections(vm *Runtime) {
+	tracker := &rejectionTracker{}
+	vm.rejections = tracker
+	vm.SetPromiseRejectionTracker(func(p *goja.Promise, operation goja.PromiseRejectionOperation) {
+		tracker.mu.Lock()
+		defer tracker.mu.Unlock()
+		switch operation {
+		case goja.PromiseRejectionReject:
+			tracker.promises = append(tracker.promises, p)
+		case goja.PromiseRejectionHandle:
+			tracker.promises = slices.DeleteFunc(tracker.promises, func(q *goja.Promise) bool { return q == p })
+		}
+	})
+}
+
+// markHandled stops tracking a rejected promise whose error is reported elsewhere.
+func markHandled(vm *Runtime, p *goja.Promise) {
+	t := vm.rejections
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.promises = slices.DeleteFunc(t.promises, func(q *goja.Promise) bool { return q == p })
+}
+
+// reportUncaught records an error thrown by an event loop callback, like a
+// stream's listener, which no script could catch.
+func reportUncaught(vm *Runtime, err error) {
+	if scriptErr, ok := AsScriptError(err); ok {
+		err = &ScriptError{Message: "Uncaught " + scriptErr.Message, Frames: scriptErr.Frames}
+	}
+	t := vm.rejections
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.uncaught = append(t.uncaught, err)
+}
+
+// UnhandledRejections returns the errors of the promises rejected without a
+// handler since the last call, marked "Uncaught (in promise)", after the
+// errors thrown by event loop callbacks. Call it once the event loop is done.
+func UnhandledRejections(vm *Runtime) []error {
+	t := vm.rejections
+	t.mu.Lock()
+	promises, errs := t.promises, t.uncaught
+	t.promises, t.uncaught = nil, nil
+	t.mu.Unlock()
+
+	for _, p := range promises {
+		errs = append(errs, unhandledError(rejectionError(p.Result())))
+	}
+	return errs
+}
+
+// unhandledError marks the error of an unhandled rejection as such.
+func unhandledError(err error) error {
+	if scriptErr, ok := err.(*ScriptError); ok {
+		return &ScriptError{Message: "Uncaught (in promise) " + scriptErr.Message, Frames: scriptErr.Frames}
+	}
+	return fmt.Errorf("Uncaught (in promise) %w", err)
+}
+
+// Await waits on the event loop for val to settle when it is a Promise, and
+// returns what it resolved to. A rejection is returned as the error, so it
+// doesn't count as unhandled.
+func Await(vm *Runtime, val goja.Value) (goja.Value, error) {
+	promise, ok := val.Export().(*goja.Promise)
+	if !ok {
+		return val, nil
+	}
+	runUntil(vm, func() bool { return promise.State() != goja.PromiseStatePending })
+	switch promise.State() {
+	case goja.PromiseStateRejected:
+		markHandled(vm, promise)
+		return nil, rejectionError(promise.Result())
+	case goja.PromiseStatePending:
+		return nil, errors.New("the promise never settled")
+	}
+	return promise.Result(), nil
+}
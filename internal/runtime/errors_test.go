@@ -0,0 +1,137 @@
+package runtime
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/PRASSamin/prasmoid/internal/stack"
+	"github.com/fatih/color"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeScript(t *testing.T, name, src string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(src), 0o644))
+	return path
+}
+
+func TestFormatError(t *testing.T) {
+	t.Run("stack trace and code frame", func(t *testing.T) {
+		src := "function check(v) {\n\tif (!v) {\n\t\tthrow new TypeError(\"missing\");\n\t}\n}\n\ncheck();\n"
+		path := writeScript(t, "check.js", src)
+		_, err := RunFile(NewRuntime(), path, []byte(src))
+		require.Error(t, err)
+
+		scriptErr, ok := AsScriptError(err)
+		require.True(t, ok)
+		assert.Equal(t, "TypeError: missing", scriptErr.Message)
+		require.Len(t, scriptErr.Frames, 2)
+		assert.Equal(t, stack.Frame{Func: "check", File: path, Line: 3, Column: 9}, scriptErr.Frames[0])
+		assert.Equal(t, 7, scriptErr.Frames[1].Line)
+
+		assert.Equal(t, "TypeError: missing\n\n"+
+			"  1 | function check(v) {\n"+
+			"  2 | \tif (!v) {\n"+
+			"> 3 | \t\tthrow new TypeError(\"missing\");\n"+
+			"    | \t\t      ^\n"+
+			"  4 | \t}\n"+
+			"  5 | }\n\n"+
+			"    at check ("+path+":3:9)\n"+
+			"    at "+path+":7:6", FormatError(err))
+	})
+
+	t.Run("typescript points into the original file", func(t *testing.T) {
+		src := "type Flags = { name: string };\n\nconst flags: Flags = { name: \"x\" };\nthrow new Error(flags.name);\n"
+		path := writeScript(t, "typed.ts", src)
+		_, err := RunFile(NewRuntime(), path, []byte(src))
+		require.Error(t, err)
+		assert.Contains(t, FormatError(err), "> 4 | throw new Error(flags.name);")
+	})
+
+	t.Run("syntax errors", func(t *testing.T) {
+		src := "const a = 1;\nconst b = ;\n"
+		path := writeScript(t, "syntax.ts", src)
+		_, err := RunFile(NewRuntime(), path, []byte(src))
+		require.Error(t, err)
+		formatted := FormatError(err)
+		assert.Contains(t, formatted, "SyntaxError: Unexpected \";\"")
+		assert.Contains(t, formatted, "> 2 | const b = ;\n    |           ^")
+	})
+
+	t.Run("thrown values that aren't errors", func(t *testing.T) {
+		_, err := NewRuntime().RunScript("inline.js", `throw { code: 2 }`)
+		require.Error(t, err)
+		assert.Equal(t, "{ code: 2 }\n    at inline.js:1:1", FormatError(err))
+	})
+
+	t.Run("other errors", func(t *testing.T) {
+		assert.Equal(t, "plain", FormatError(errors.New("plain")))
+	})
+}
+
+func TestUnhandledRejections(t *testing.T) {
+	vm := NewRuntime()
+	_, err := vm.RunString(`
+		Promise.reject(new Error("lost"));
+		Promise.reject(new Error("caught")).catch(() => {});
+		const later = Promise.reject(new Error("caught later"));
+		later.catch(() => {});
+	`)
+	require.NoError(t, err)
+	RunEventLoop(vm)
+
+	rejections := UnhandledRejections(vm)
+	require.Len(t, rejections, 1)
+	assert.Contains(t, rejections[0].Error(), "Uncaught (in promise) Error: lost")
+	assert.Empty(t, UnhandledRejections(vm))
+
+	t.Run("awaited promises are handled", func(t *testing.T) {
+		val, err := vm.RunString(`Promise.reject(new Error("awaited"))`)
+		require.NoError(t, err)
+		_, err = Await(vm, val)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "Error: awaited")
+		assert.Empty(t, UnhandledRejections(vm))
+	})
+
+	t.Run("tests fail on unhandled rejections", func(t *testing.T) {
+		vm := newTestingRuntime(t)
+		_, err := vm.RunString(`it("forgets to await", () => { Promise.reject(new Error("dropped")); })`)
+		require.NoError(t, err)
+		err = RunTest(vm, Tests(vm)[0])
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "Uncaught (in promise) Error: dropped")
+	})
+}
+
+func TestEnableDebug(t *testing.T) {
+	noColor := color.NoColor
+	t.Cleanup(func() {
+		color.NoColor = noColor
+		debugOutput = os.Stderr
+	})
+	color.NoColor = true
+	var out bytes.Buffer
+	debugOutput = &out
+
+	vm := NewRuntime()
+	EnableDebug(vm)
+	_, err := vm.RunString(`
+		path.join("a", "b");
+		require("os").platform();
+		console.log("not traced");
+		path.basename("x".repeat(100));
+	`)
+	require.NoError(t, err)
+
+	assert.Contains(t, out.String(), `[debug] path.join("a", "b")`)
+	assert.Contains(t, out.String(), "[debug] os.platform()")
+	assert.NotContains(t, out.String(), "console.log")
+	assert.Contains(t, out.String(), `[debug] path.basename("`+strings.Repeat("x", 79)+"…)")
+}
@@ -1,6 +1,65 @@
 package runtime
 
-import "sync"
+import (
+	"sync"
+	"sync/atomic"
+)
 
-// Global wait group to manage the event loop
-var EventLoop = &sync.WaitGroup{}
+// loop is the job queue of a single runtime. Goja is not goroutine safe, so
+// asynchronous operations hand their results back through the queue and the
+// goroutine driving the runtime (see RunEventLoop) executes them.
+type loop struct {
+	jobs chan func()
+	// pending counts the operations the loop waits for. Jobs only complete on
+	// the loop's goroutine, so it's never decremented behind the loop's back.
+	pending atomic.Int64
+}
+
+func newLoop() *loop {
+	return &loop{jobs: make(chan func(), 64)}
+}
+
+// Schedule reserves a slot on the event loop of vm for an asynchronous operation
+// and returns the function that completes it. The callback passed to the returned
+// function runs on the runtime's goroutine, where it may resolve promises or call
+// into JS. The returned function must be called exactly once.
+func Schedule(vm *Runtime) func(callback func()) {
+	l := vm.loop
+	l.pending.Add(1)
+	return func(callback func()) {
+		l.jobs <- func() {
+			defer l.pending.Add(-1)
+			callback()
+		}
+	}
+}
+
+// hold keeps the event loop of vm running, like a persistent fs.watch, until
+// the returned function is called. It may be called more than once.
+func hold(vm *Runtime) func() {
+	release := Schedule(vm)
+	var once sync.Once
+	return func() { once.Do(func() { release(func() {}) }) }
+}
+
+// post runs callback on the runtime's goroutine without keeping the event loop
+// running for it. If the loop is done, it runs when the loop runs again.
+func post(vm *Runtime, callback func()) {
+	Schedule(vm)(callback)
+}
+
+// RunEventLoop executes scheduled callbacks for vm until no asynchronous
+// operation is pending anymore.
+func RunEventLoop(vm *Runtime) {
+	runUntil(vm, func() bool { return false })
+}
+
+// runUntil executes scheduled callbacks for vm until stop reports true or no
+// asynchronous operation is pending anymore.
+func runUntil(vm *Runtime, stop func() bool) {
+	l := vm.loop
+	for !stop() && l.pending.Load() > 0 {
+		job := <-l.jobs
+		job()
+	}
+}
@@ -0,0 +1,221 @@
+package runtime
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/dop251/goja"
+)
+
+// mockState records the calls of a function created by mock.fn.
+type mockState struct {
+	calls   [][]goja.Value
+	impl    goja.Callable
+	returns goja.Value
+}
+
+// matcher checks actual against the matcher's arguments. It returns whether
+// it passed and what was expected, e.g. "to be 2", for the failure message.
+type matcher func(actual goja.Value, args []goja.Value) (bool, string)
+
+// registerExpect adds expect(actual) with jest-like matchers, each of which
+// can be negated with .not. It returns the registry of mock functions, which
+// the toHaveBeenCalled matchers inspect.
+func registerExpect(vm *Runtime, global *goja.Object) map[*goja.Object]*mockState {
+	mocks := map[*goja.Object]*mockState{}
+
+	mockOf := func(name string, actual goja.Value) *mockState {
+		if obj, ok := actual.(*goja.Object); ok {
+			if state, ok := mocks[obj]; ok {
+				return state
+			}
+		}
+		panic(vm.NewTypeError("expect(...).%s: %s is not a mock function", name, Inspect(actual)))
+	}
+	number := func(name string, val goja.Value) float64 {
+		if _, ok := val.Export().(int64); !ok {
+			if _, ok := val.Export().(float64); !ok {
+				panic(vm.NewTypeError("expect(...).%s: %s is not a number", name, Inspect(val)))
+			}
+		}
+		return val.ToFloat()
+	}
+	compare := func(name, phrase string, cmp func(a, b float64) bool) matcher {
+		return func(actual goja.Value, args []goja.Value) (bool, string) {
+			return cmp(number(name, actual), number(name, args[0])), phrase + " " + Inspect(args[0])
+		}
+	}
+
+	// toThrow reuses toMatch, hence the separate declaration.
+	var matchers map[string]matcher
+	matchers = map[string]matcher{
+		"toBe": func(actual goja.Value, args []goja.Value) (bool, string) {
+			return actual.SameAs(args[0]), "to be " + Inspect(args[0])
+		},
+		"toEqual": func(actual goja.Value, args []goja.Value) (bool, string) {
+			return deepEqual(actual, args[0]), "to equal " + Inspect(args[0])
+		},
+		"toBeTruthy": func(actual goja.Value, _ []goja.Value) (bool, string) {
+			return actual.ToBoolean(), "to be truthy"
+		},
+		"toBeFalsy": func(actual goja.Value, _ []goja.Value) (bool, string) {
+			return !actual.ToBoolean(), "to be falsy"
+		},
+		"toBeNull": func(actual goja.Value, _ []goja.Value) (bool, string) {
+			return goja.IsNull(actual), "to be null"
+		},
+		"toBeUndefined": func(actual goja.Value, _ []goja.Value) (bool, string) {
+			return goja.IsUndefined(actual), "to be undefined"
+		},
+		"toBeDefined": func(actual goja.Value, _ []goja.Value) (bool, string) {
+			return !goja.IsUndefined(actual), "to be defined"
+		},
+		"toContain": func(actual goja.Value, args []goja.Value) (bool, string) {
+			phrase := "to contain " + Inspect(args[0])
+			if s, ok := actual.Export().(string); ok {
+				return strings.Contains(s, args[0].String()), phrase
+			}
+			var items []goja.Value
+			if err := vm.ExportTo(actual, &items); err != nil {
+				panic(vm.NewTypeError("expect(...).toContain: %s is not a string or an array", Inspect(actual)))
+			}
+			return slices.ContainsFunc(items, args[0].SameAs), phrase
+		},
+		"toMatch": func(actual goja.Value, args []goja.Value) (bool, string) {
+			pattern, ok := args[0].(*goja.Object)
+			if !ok || pattern.ClassName() != "RegExp" {
+				return strings.Contains(actual.String(), args[0].String()), "to match " + Inspect(args[0])
+			}
+			test, _ := goja.AssertFunction(pattern.Get("test"))
+			res, err := test(pattern, actual)
+			if err != nil {
+				throw(vm, err)
+			}
+			return res.ToBoolean(), "to match " + pattern.String()
+		},
+		"toHaveLength": func(actual goja.Value, args []goja.Value) (bool, string) {
+			length := actual.ToObject(vm.Runtime).Get("length")
+			return length != nil && length.SameAs(args[0]), "to have length " + Inspect(args[0])
+		},
+		"toBeGreaterThan":        compare("toBeGreaterThan", "to be greater than", func(a, b float64) bool { return a > b }),
+		"toBeGreaterThanOrEqual": compare("toBeGreaterThanOrEqual", "to be greater than or equal to", func(a, b float64) bool { return a >= b }),
+		"toBeLessThan":           compare("toBeLessThan", "to be less than", func(a, b float64) bool { return a < b }),
+		"toBeLessThanOrEqual":    compare("toBeLessThanOrEqual", "to be less than or equal to", func(a, b float64) bool { return a <= b }),
+		// toThrow([message | RegExp]) calls actual and checks what it throws.
+		"toThrow": func(actual goja.Value, args []goja.Value) (bool, string) {
+			fn, ok := goja.AssertFunction(actual)
+			if !ok {
+				panic(vm.NewTypeError("expect(...).toThrow: %s is not a function", Inspect(actual)))
+			}
+			_, err := fn(goja.Undefined())
+			if len(args) == 0 || goja.IsUndefined(args[0]) {
+				return err != nil, "to throw"
+			}
+			if err == nil {
+				return false, "to throw " + Inspect(args[0])
+			}
+			message := err.Error()
+			if exception, ok := err.(*goja.Exception); ok {
+				message = exception.Value().String()
+				if obj, ok := exception.Value().(*goja.Object); ok {
+					if msg := obj.Get("message"); msg != nil && !goja.IsUndefined(msg) {
+						message = msg.String()
+					}
+				}
+			}
+			pass, _ := matchers["toMatch"](vm.ToValue(message), args)
+			return pass, fmt.Sprintf("to throw %s, got %q", Inspect(args[0]), message)
+		},
+		"toHaveBeenCalled": func(actual goja.Value, _ []goja.Value) (bool, string) {
+			return len(mockOf("toHaveBeenCalled", actual).calls) > 0, "to have been called"
+		},
+		"toHaveBeenCalledTimes": func(actual goja.Value, args []goja.Value) (bool, string) {
+			calls := len(mockOf("toHaveBeenCalledTimes", actual).calls)
+			return int64(calls) == args[0].ToInteger(), fmt.Sprintf("to have been called %d times, got %d", args[0].ToInteger(), calls)
+		},
+		"toHaveBeenCalledWith": func(actual goja.Value, args []goja.Value) (bool, string) {
+			state := mockOf("toHaveBeenCalledWith", actual)
+			want := Inspect(vm.ToValue(args))
+			for _, call := range state.calls {
+				if len(call) == len(args) && slices.EqualFunc(call, args, deepEqual) {
+					return true, "to have been called with " + want
+				}
+			}
+			return false, fmt.Sprintf("to have been called with %s (%d calls)", want, len(state.calls))
+		},
+	}
+	var build func(actual goja.Value, negate bool) *goja.Object
+	build = func(actual goja.Value, negate bool) *goja.Object {
+		obj := vm.NewObject()
+		for name, match := range matchers {
+			_ = obj.Set(name, func(call goja.FunctionCall) goja.Value {
+				args := call.Arguments
+				if len(args) == 0 {
+					args = []goja.Value{goja.Undefined()}
+				}
+				pass, phrase := match(actual, args)
+				if pass == negate {
+					subject := Inspect(actual)
+					if _, ok := mocks[objectOf(actual)]; ok {
+						subject = "mock function"
+					} else if _, ok := goja.AssertFunction(actual); ok {
+						subject = "function"
+					}
+					not := ""
+					if negate {
+						not = "not "
+					}
+					panic(assertionError(vm, fmt.Sprintf("expected %s %s%s", subject, not, phrase)))
+				}
+				return goja.Undefined()
+			})
+		}
+		if !negate {
+			_ = obj.Set("not", build(actual, true))
+		}
+		return obj
+	}
+
+	_ = global.Set("expect", func(call goja.FunctionCall) goja.Value {
+		return build(call.Argument(0), false)
+	})
+	return mocks
+}
+
+func objectOf(val goja.Value) *goja.Object {
+	obj, _ := val.(*goja.Object)
+	return obj
+}
+
+// deepEqual compares values recursively: objects and arrays are equal when
+// they have the same class and equal enumerable properties.
+func deepEqual(a, b goja.Value) bool {
+	ao, aok := a.(*goja.Object)
+	bo, bok := b.(*goja.Object)
+	if !aok || !bok {
+		return a.SameAs(b)
+	}
+	if ao.SameAs(bo) {
+		return true
+	}
+	if _, ok := goja.AssertFunction(a); ok {
+		return false
+	}
+	if ao.ClassName() != bo.ClassName() {
+		return false
+	}
+	if ao.ClassName() == "Date" || ao.ClassName() == "RegExp" {
+		return ao.String() == bo.String()
+	}
+	keys := ao.Keys()
+	if len(keys) != len(bo.Keys()) {
+		return false
+	}
+	for _, key := range keys {
+		if !slices.Contains(bo.Keys(), key) || !deepEqual(ao.Get(key), bo.Get(key)) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,313 @@
+package runtime
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/dop251/goja"
+)
+
+// fetchClasses implements the parts of the Fetch API that don't touch the network.
+// It is called with Go helpers converting between strings and ArrayBuffers.
+const fetchClasses = `(function (decode, encode) {
+    function makeError(name, message) {
+        const err = new Error(message);
+        err.name = name;
+        return err;
+    }
+
+    class Headers {
+        constructor(init) {
+            this._map = {};
+            if (init instanceof Headers) {
+                init.forEach((value, name) => this.append(name, value));
+            } else if (Array.isArray(init)) {
+                init.forEach(([name, value]) => this.append(name, value));
+            } else if (init) {
+                Object.keys(init).forEach((name) => this.append(name, init[name]));
+            }
+        }
+        append(name, value) {
+            name = String(name).toLowerCase();
+            value = String(value);
+            this._map[name] = name in this._map ? this._map[name] + ", " + value : value;
+        }
+        set(name, value) { this._map[String(name).toLowerCase()] = String(value); }
+        get(name) {
+            const value = this._map[String(name).toLowerCase()];
+            return value === undefined ? null : value;
+        }
+        has(name) { return String(name).toLowerCase() in this._map; }
+        delete(name) { delete this._map[String(name).toLowerCase()]; }
+        forEach(callback, thisArg) {
+            Object.keys(this._map).sort().forEach((name) => callback.call(thisArg, this._map[name], name, this));
+        }
+        keys() { return Object.keys(this._map).sort()[Symbol.iterator](); }
+        values() { return Object.keys(this._map).sort().map((name) => this._map[name])[Symbol.iterator](); }
+        entries() { return Object.keys(this._map).sort().map((name) => [name, this._map[name]])[Symbol.iterator](); }
+        [Symbol.iterator]() { return this.entries(); }
+    }
+
+    class Response {
+        constructor(body, init = {}) {
+            if (ArrayBuffer.isView(body)) {
+                body = body.buffer.slice(body.byteOffset, body.byteOffset + body.byteLength);
+            } else if (body !== undefined && body !== null && !(body instanceof ArrayBuffer)) {
+                body = String(body);
+            }
+            this._body = body === undefined || body === null ? "" : body;
+            this.status = init.status === undefined ? 200 : init.status;
+            this.statusText = init.statusText || "";
+            this.headers = new Headers(init.headers);
+            this.url = init.url || "";
+            this.redirected = !!init.redirected;
+            this.bodyUsed = false;
+        }
+        get ok() { return this.status >= 200 && this.status < 300; }
+        _consume() {
+            if (this.bodyUsed) {
+                return Promise.reject(new TypeError("Body has already been consumed"));
+            }
+            this.bodyUsed = true;
+            return Promise.resolve(this._body);
+        }
+        text() { return this._consume().then((body) => typeof body === "string" ? body : decode(body)); }
+        json() { return this.text().then((text) => JSON.parse(text)); }
+        arrayBuffer() { return this._consume().then((body) => typeof body === "string" ? encode(body) : body); }
+        clone() {
+            if (this.bodyUsed) {
+                throw new TypeError("Response body has already been consumed");
+            }
+            return new Response(this._body, this);
+        }
+    }
+
+    class AbortSignal {
+        constructor() {
+            this.aborted = false;
+            this.reason = undefined;
+            this.onabort = null;
+            this._listeners = [];
+        }
+        addEventListener(type, listener) {
+            if (type === "abort") this._listeners.push(listener);
+        }
+        removeEventListener(type, listener) {
+            this._listeners = this._listeners.filter((l) => l !== listener);
+        }
+        throwIfAborted() {
+            if (this.aborted) throw this.reason;
+        }
+        _abort(reason) {
+            if (this.aborted) return;
+            this.aborted = true;
+            this.reason = reason === undefined ? makeError("AbortError", "This operation was aborted") : reason;
+            const event = { type: "abort", target: this };
+            if (typeof this.onabort === "function") this.onabort(event);
+            this._listeners.forEach((listener) => listener.call(this, event));
+        }
+        static abort(reason) {
+            const controller = new AbortController();
+            controller.abort(reason);
+            return controller.signal;
+        }
+    }
+
+    class AbortController {
+        constructor() { this.signal = new AbortSignal(); }
+        abort(reason) { this.signal._abort(reason); }
+    }
+
+    return { Headers, Response, AbortSignal, AbortController, makeError };
+})`
+
+// EnableFetch installs fetch() together with Headers, Response, AbortController
+// and AbortSignal as globals. Requests run on their own goroutine and settle
+// their promise on the event loop.
+//
+// Besides the standard options, fetch accepts `timeout` in milliseconds.
+func EnableFetch(vm *Runtime) {
+	factory, err := vm.RunString(fetchClasses)
+	if err != nil {
+		panic(err)
+	}
+	fn, _ := goja.AssertFunction(factory)
+	decode := func(call goja.FunctionCall) goja.Value {
+		return vm.ToValue(string(toBytes(call.Argument(0))))
+	}
+	encode := func(call goja.FunctionCall) goja.Value {
+		return vm.ToValue(vm.NewArrayBuffer([]byte(call.Argument(0).String())))
+	}
+	classesVal, err := fn(goja.Undefined(), vm.ToValue(decode), vm.ToValue(encode))
+	if err != nil {
+		panic(err)
+	}
+	classes := classesVal.ToObject(vm.Runtime)
+	for _, name := range []string{"Headers", "Response", "AbortSignal", "AbortController"} {
+		_ = vm.Set(name, classes.Get(name))
+	}
+
+	headersCtor := classes.Get("Headers").ToObject(vm.Runtime)
+	responseCtor := classes.Get("Response").ToObject(vm.Runtime)
+	makeError, _ := goja.AssertFunction(classes.Get("makeError"))
+	newError := func(name, message string) goja.Value {
+		err, _ := makeError(goja.Undefined(), vm.ToValue(name), vm.ToValue(message))
+		return err
+	}
+
+	_ = vm.Set("fetch", func(call goja.FunctionCall) goja.Value {
+		promise, resolve, reject := vm.NewPromise()
+
+		target, err := url.Parse(call.Argument(0).String())
+		if err != nil || (target.Scheme != "http" && target.Scheme != "https") {
+			panic(vm.NewTypeError("fetch: invalid URL %q", call.Argument(0).String()))
+		}
+		checkNet(vm, "fetch", target.Hostname(), urlPort(target))
+		// Redirects are followed on another goroutine, so they are checked
+		// against the permissions fetch was called with.
+		perms := GetPermissions(vm)
+		client := &http.Client{CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return errors.New("stopped after 10 redirects")
+			}
+			if !perms.AllowsNet(req.URL.Hostname(), urlPort(req.URL)) {
+				return &PermissionError{Op: "fetch", Kind: "net", Resource: req.URL.Hostname()}
+			}
+			return nil
+		}}
+
+		method := "GET"
+		var body io.Reader
+		var timeout time.Duration
+		var signal *goja.Object
+		header := http.Header{}
+
+		if opts, ok := call.Argument(1).(*goja.Object); ok {
+			if m := opts.Get("method"); m != nil && !goja.IsUndefined(m) {
+				method = strings.ToUpper(m.String())
+			}
+			if h := opts.Get("headers"); h != nil && !goja.IsUndefined(h) && !goja.IsNull(h) {
+				headers, err := vm.New(headersCtor, h)
+				if err != nil {
+					panic(err)
+				}
+				for name, value := range headers.Get("_map").Export().(map[string]interface{}) {
+					header.Set(name, fmt.Sprint(value))
+				}
+			}
+			if b := opts.Get("body"); b != nil && !goja.IsUndefined(b) && !goja.IsNull(b) {
+				body = bytes.NewReader(toBytes(b))
+			}
+			if t := opts.Get("timeout"); t != nil && !goja.IsUndefined(t) {
+				timeout = time.Duration(t.ToInteger()) * time.Millisecond
+			}
+			if s, ok := opts.Get("signal").(*goja.Object); ok {
+				signal = s
+			}
+		}
+
+		if signal != nil && signal.Get("aborted").ToBoolean() {
+			_ = reject(signal.Get("reason"))
+			return vm.ToValue(promise)
+		}
+
+		var ctx context.Context
+		var cancel context.CancelFunc
+		if timeout > 0 {
+			ctx, cancel = context.WithTimeout(context.Background(), timeout)
+		} else {
+			ctx, cancel = context.WithCancel(context.Background())
+		}
+		if signal != nil {
+			addListener, _ := goja.AssertFunction(signal.Get("addEventListener"))
+			_, _ = addListener(signal, vm.ToValue("abort"), vm.ToValue(func(goja.FunctionCall) goja.Value {
+				cancel()
+				return goja.Undefined()
+			}))
+		}
+
+		req, err := http.NewRequestWithContext(ctx, method, target.String(), body)
+		if err != nil {
+			cancel()
+			panic(vm.NewTypeError("fetch: %v", err))
+		}
+		req.Header = header
+
+		done := Schedule(vm)
+		go func() {
+			var data []byte
+			resp, err := client.Do(req)
+			if err == nil {
+				data, err = io.ReadAll(resp.Body)
+				_ = resp.Body.Close()
+			}
+
+			done(func() {
+				defer cancel()
+				if err != nil {
+					var permErr *PermissionError
+					switch {
+					case errors.As(err, &permErr):
+						_ = reject(vm.NewGoError(permErr))
+					case signal != nil && signal.Get("aborted").ToBoolean():
+						_ = reject(signal.Get("reason"))
+					case errors.Is(ctx.Err(), context.DeadlineExceeded):
+						_ = reject(newError("TimeoutError", fmt.Sprintf("fetch: %s timed out after %v", target, timeout)))
+					default:
+						_ = reject(vm.NewTypeError("fetch failed: %v", err))
+					}
+					return
+				}
+
+				var headers []interface{}
+				for name, values := range resp.Header {
+					for _, value := range values {
+						headers = append(headers, vm.NewArray(name, value))
+					}
+				}
+				init := vm.NewObject()
+				_ = init.Set("status", resp.StatusCode)
+				_ = init.Set("statusText", http.StatusText(resp.StatusCode))
+				_ = init.Set("headers", vm.NewArray(headers...))
+				_ = init.Set("url", resp.Request.URL.String())
+				_ = init.Set("redirected", resp.Request.URL.String() != target.String())
+
+				response, err := vm.New(responseCtor, vm.ToValue(vm.NewArrayBuffer(data)), init)
+				if err != nil {
+					_ = reject(err)
+					return
+				}
+				_ = resolve(response)
+			})
+		}()
+
+		return vm.ToValue(promise)
+	})
+}
+
+// toBytes converts strings, ArrayBuffers and typed arrays into bytes.
+func toBytes(val goja.Value) []byte {
+	switch v := val.Export().(type) {
+	case goja.ArrayBuffer:
+		return v.Bytes()
+	case []byte:
+		return v
+	default:
+		return []byte(val.String())
+	}
+}
+
+// urlPort returns the port of u, or the default port of its scheme.
+func urlPort(u *url.URL) string {
+	if port := u.Port(); port != "" {
+		return port
+	}
+	return map[string]string{"http": "80", "https": "443"}[u.Scheme]
+}
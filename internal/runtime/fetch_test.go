@@ -0,0 +1,173 @@
+package runtime
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// await runs the script, drives the event loop and returns what its promise settled with.
+func await(t *testing.T, vm *Runtime, script string) (goja.Value, error) {
+	t.Helper()
+	val, err := vm.RunString(script)
+	require.NoError(t, err)
+	promise, ok := val.Export().(*goja.Promise)
+	require.True(t, ok, "script must return a promise")
+	RunEventLoop(vm)
+	if promise.State() == goja.PromiseStateRejected {
+		return nil, rejectionError(promise.Result())
+	}
+	require.Equal(t, goja.PromiseStateFulfilled, promise.State())
+	return promise.Result(), nil
+}
+
+func TestFetch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/json":
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("X-Request-Token", r.Header.Get("Authorization"))
+			_, _ = fmt.Fprint(w, `{"name":"prasmoid","version":"1.0.0"}`)
+		case "/echo":
+			body, _ := io.ReadAll(r.Body)
+			w.WriteHeader(http.StatusCreated)
+			_, _ = fmt.Fprintf(w, "%s %s", r.Method, body)
+		case "/slow":
+			select {
+			case <-time.After(2 * time.Second):
+			case <-r.Context().Done():
+			}
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	vm := NewRuntime()
+	require.NoError(t, vm.Set("base", server.URL))
+
+	t.Run("json and headers", func(t *testing.T) {
+		val, err := await(t, vm, `(async () => {
+			const res = await fetch(base + "/json", { headers: { Authorization: "token abc" } });
+			const data = await res.json();
+			return [res.status, res.ok, res.headers.get("content-type"), res.headers.get("x-request-token"), data.name];
+		})()`)
+		require.NoError(t, err)
+		assert.Equal(t, []interface{}{int64(200), true, "application/json", "token abc", "prasmoid"}, val.Export())
+	})
+
+	t.Run("post body as text and arrayBuffer", func(t *testing.T) {
+		val, err := await(t, vm, `(async () => {
+			const res = await fetch(base + "/echo", { method: "post", body: "hello" });
+			const copy = res.clone();
+			const buf = await copy.arrayBuffer();
+			return [res.status, await res.text(), buf.byteLength];
+		})()`)
+		require.NoError(t, err)
+		assert.Equal(t, []interface{}{int64(201), "POST hello", int64(10)}, val.Export())
+	})
+
+	t.Run("non-2xx responses resolve", func(t *testing.T) {
+		val, err := await(t, vm, `fetch(base + "/missing").then((res) => [res.status, res.ok, res.statusText])`)
+		require.NoError(t, err)
+		assert.Equal(t, []interface{}{int64(404), false, "Not Found"}, val.Export())
+	})
+
+	t.Run("body can only be read once", func(t *testing.T) {
+		_, err := await(t, vm, `fetch(base + "/json").then(async (res) => { await res.text(); return res.text(); })`)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "Body has already been consumed")
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		_, err := await(t, vm, `fetch(base + "/slow", { timeout: 50 })`)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "TimeoutError")
+	})
+
+	t.Run("abort controller", func(t *testing.T) {
+		val, err := await(t, vm, `(async () => {
+			const controller = new AbortController();
+			const pending = fetch(base + "/slow", { signal: controller.signal });
+			controller.abort();
+			try {
+				await pending;
+				return "resolved";
+			} catch (err) {
+				return err.name;
+			}
+		})()`)
+		require.NoError(t, err)
+		assert.Equal(t, "AbortError", val.Export())
+
+		_, err = await(t, vm, `fetch(base + "/json", { signal: AbortSignal.abort(new Error("cancelled")) })`)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "cancelled")
+	})
+
+	t.Run("connection errors reject", func(t *testing.T) {
+		_, err := await(t, vm, `fetch("http://127.0.0.1:1/")`)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "fetch failed")
+	})
+
+	t.Run("invalid url", func(t *testing.T) {
+		_, err := vm.RunString(`fetch("ftp://example.com")`)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid URL")
+	})
+
+	t.Run("sandboxed", func(t *testing.T) {
+		sandboxed := NewRuntime()
+		SetPermissions(sandboxed, &Permissions{Net: []string{"api.github.com"}})
+		defer SetPermissions(sandboxed, nil)
+		require.NoError(t, sandboxed.Set("base", server.URL))
+
+		_, err := sandboxed.RunString(`fetch(base + "/json")`)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "fetch needs net access to \"127.0.0.1\"")
+	})
+
+	t.Run("sandboxed redirects", func(t *testing.T) {
+		other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = fmt.Fprint(w, "other")
+		}))
+		defer other.Close()
+		redirecting := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, other.URL+r.URL.Path, http.StatusFound)
+		}))
+		defer redirecting.Close()
+
+		sandboxed := NewRuntime()
+		SetPermissions(sandboxed, &Permissions{Net: []string{redirecting.Listener.Addr().String()}})
+		defer SetPermissions(sandboxed, nil)
+		require.NoError(t, sandboxed.Set("base", redirecting.URL))
+
+		_, err := await(t, sandboxed, `fetch(base + "/json")`)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "permission denied: fetch needs net access to \"127.0.0.1\"")
+
+		SetPermissions(sandboxed, &Permissions{Net: []string{redirecting.Listener.Addr().String(), other.Listener.Addr().String()}})
+		val, err := await(t, sandboxed, `fetch(base + "/json").then((res) => res.text())`)
+		require.NoError(t, err)
+		assert.Equal(t, "other", val.String())
+	})
+}
+
+func TestResponse(t *testing.T) {
+	vm := NewRuntime()
+	val, err := await(t, vm, `(async () => {
+		const res = new Response('{"a":1}', { status: 500, headers: [["X-A", "1"], ["x-a", "2"]] });
+		const bytes = await new Response(new Uint8Array([104, 105])).text();
+		return [res.ok, res.headers.get("x-a"), (await res.json()).a, bytes];
+	})()`)
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{false, "1, 2", int64(1), "hi"}, val.Export())
+}
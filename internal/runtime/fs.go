@@ -14,7 +14,7 @@ import (
 	"github.com/fsnotify/fsnotify"
 )
 
-func FS(vm *goja.Runtime, module *goja.Object) {
+func FS(vm *Runtime, module *goja.Object) {
 	_fs := module.Get("exports").(*goja.Object)
 	var (
 		fileWatchers   = make(map[string]*fsnotify.Watcher)
@@ -43,7 +43,7 @@ func FS(vm *goja.Runtime, module *goja.Object) {
 	}
 
 	// Then set it in the module
-	statsCtor := vm.Get("Stats").ToObject(vm)
+	statsCtor := vm.Get("Stats").ToObject(vm.Runtime)
 	if err := _fs.Set("Stats", statsCtor); err != nil {
 		fmt.Printf("Error setting Stats on _fs: %v\n", err)
 	}
@@ -54,6 +54,7 @@ func FS(vm *goja.Runtime, module *goja.Object) {
 			return vm.ToValue("fs.readFileSync: missing path")
 		}
 		path := call.Arguments[0].String()
+		checkFS(vm, "fs.readFileSync", path)
 		data, err := os.ReadFile(path)
 		if err != nil {
 			return vm.ToValue(fmt.Sprintf("%v", err))
@@ -67,6 +68,7 @@ func FS(vm *goja.Runtime, module *goja.Object) {
 			return vm.ToValue("fs.writeFileSync: missing path or content")
 		}
 		path := call.Arguments[0].String()
+		checkFS(vm, "fs.writeFileSync", path)
 		content := call.Arguments[1].String()
 
 		err := os.WriteFile(path, []byte(content), 0644)
@@ -82,6 +84,7 @@ func FS(vm *goja.Runtime, module *goja.Object) {
 			return vm.ToValue("fs.appendFileSync: missing path or content")
 		}
 		path := call.Arguments[0].String()
+		checkFS(vm, "fs.appendFileSync", path)
 		content := call.Arguments[1].String()
 
 		f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
@@ -106,6 +109,7 @@ func FS(vm *goja.Runtime, module *goja.Object) {
 			return vm.ToValue("fs.existsSync: missing path")
 		}
 		path := call.Arguments[0].String()
+		checkFS(vm, "fs.existsSync", path)
 		_, err := os.Stat(path)
 		exists := !os.IsNotExist(err)
 		return vm.ToValue(exists)
@@ -117,6 +121,7 @@ func FS(vm *goja.Runtime, module *goja.Object) {
 			return vm.ToValue("fs.readdirSync: missing path")
 		}
 		path := call.Arguments[0].String()
+		checkFS(vm, "fs.readdirSync", path)
 		files, err := os.ReadDir(path)
 		if err != nil {
 			return vm.ToValue(fmt.Sprintf("%v", err))
@@ -139,6 +144,7 @@ func FS(vm *goja.Runtime, module *goja.Object) {
 			return vm.ToValue("fs.mkdirSync: missing path")
 		}
 		path := call.Arguments[0].String()
+		checkFS(vm, "fs.mkdirSync", path)
 		err := os.Mkdir(path, 0755)
 		if err != nil {
 			return vm.ToValue(fmt.Sprintf("%v", err))
@@ -151,9 +157,10 @@ func FS(vm *goja.Runtime, module *goja.Object) {
 			return vm.ToValue("fs.rmSync: missing path")
 		}
 		path := call.Arguments[0].String()
+		checkFS(vm, "fs.rmSync", path)
 		recursive := false
 		if len(call.Arguments) > 1 {
-			options := call.Arguments[1].ToObject(vm)
+			options := call.Arguments[1].ToObject(vm.Runtime)
 
 			recursiveProp := options.Get("recursive")
 			if recursiveProp != nil {
@@ -184,6 +191,7 @@ func FS(vm *goja.Runtime, module *goja.Object) {
 		}
 		src := call.Arguments[0].String()
 		dest := call.Arguments[1].String()
+		checkFS(vm, "fs.copyFileSync", src, dest)
 
 		// Open source file
 		srcFile, err := os.Open(src)
@@ -223,6 +231,7 @@ func FS(vm *goja.Runtime, module *goja.Object) {
 		}
 		oldPath := call.Arguments[0].String()
 		newPath := call.Arguments[1].String()
+		checkFS(vm, "fs.renameSync", oldPath, newPath)
 		err := os.Rename(oldPath, newPath)
 		if err != nil {
 			return vm.ToValue(fmt.Sprintf("%v", err))
@@ -236,6 +245,7 @@ func FS(vm *goja.Runtime, module *goja.Object) {
 			return vm.ToValue("fs.unlinkSync: missing path")
 		}
 		path := call.Arguments[0].String()
+		checkFS(vm, "fs.unlinkSync", path)
 		err := os.Remove(path)
 		if err != nil {
 			return vm.ToValue(fmt.Sprintf("%v", err))
@@ -249,6 +259,7 @@ func FS(vm *goja.Runtime, module *goja.Object) {
 			return vm.ToValue("fs.realpathSync: missing path")
 		}
 		path := call.Arguments[0].String()
+		checkFS(vm, "fs.realpathSync", path)
 		resolvedPath, err := filepath.EvalSymlinks(path)
 		if err != nil {
 			return vm.ToValue(fmt.Sprintf("%v", err))
@@ -266,6 +277,7 @@ func FS(vm *goja.Runtime, module *goja.Object) {
 			return vm.ToValue("fs.readlinkSync: missing path")
 		}
 		path := call.Arguments[0].String()
+		checkFS(vm, "fs.readlinkSync", path)
 		link, err := os.Readlink(path)
 		if err != nil {
 			return vm.ToValue(fmt.Sprintf("%v", err))
@@ -280,6 +292,7 @@ func FS(vm *goja.Runtime, module *goja.Object) {
 		}
 		src := call.Arguments[0].String()
 		dest := call.Arguments[1].String()
+		checkFS(vm, "fs.cpSync", src, dest)
 
 		// Check if source exists and is a directory
 		srcInfo, err := os.Stat(src)
@@ -317,6 +330,9 @@ func FS(vm *goja.Runtime, module *goja.Object) {
 				return err
 			}
 			destPath := filepath.Join(dest, relPath)
+			// Symlinks inside src, or inside an existing dest, must not lead
+			// out of the fs roots either
+			checkFS(vm, "fs.cpSync", path, destPath)
 
 			// If it's a directory, create it
 			if d.IsDir() {
@@ -344,7 +360,15 @@ func FS(vm *goja.Runtime, module *goja.Object) {
 		if err != nil {
 			return vm.ToValue(fmt.Sprintf("glob error: %v", err))
 		}
-		return vm.ToValue(matches)
+		// Only report the files the script is allowed to see
+		perms := GetPermissions(vm)
+		allowed := make([]string, 0, len(matches))
+		for _, match := range matches {
+			if perms.AllowsPath(match) {
+				allowed = append(allowed, match)
+			}
+		}
+		return vm.ToValue(allowed)
 	})
 
 	// mkdtempSync(prefix: string) => string
@@ -353,6 +377,7 @@ func FS(vm *goja.Runtime, module *goja.Object) {
 			return vm.ToValue("fs.mkdtempSync: missing prefix")
 		}
 		prefix := call.Arguments[0].String()
+		checkFS(vm, "fs.mkdtempSync", os.TempDir())
 		dir, err := os.MkdirTemp("", prefix)
 		if err != nil {
 			return vm.ToValue(fmt.Sprintf("%v", err))
@@ -379,6 +404,11 @@ func FS(vm *goja.Runtime, module *goja.Object) {
 		if target == "" || link == "" {
 			panic(vm.ToValue("fs.symlinkSync: target or link path cannot be empty"))
 		}
+		resolvedTarget := target
+		if !filepath.IsAbs(target) {
+			resolvedTarget = filepath.Join(filepath.Dir(link), target)
+		}
+		checkFS(vm, "fs.symlinkSync", link, resolvedTarget)
 
 		err := os.Symlink(target, link)
 		if err != nil {
@@ -394,6 +424,7 @@ func FS(vm *goja.Runtime, module *goja.Object) {
 			panic(vm.ToValue("fs.statSync: missing path"))
 		}
 		path := call.Arguments[0].String()
+		checkFS(vm, "fs.statSync", path)
 
 		info, err := os.Stat(path)
 		if err != nil {
@@ -406,9 +437,60 @@ func FS(vm *goja.Runtime, module *goja.Object) {
 	// Stats Class
 	_ = _fs.Set("Stats", statsCtor)
 
+	// createReadStream(path[, options]) => Readable, where options is an
+	// encoding or { encoding, start, end, highWaterMark }. end is inclusive.
+	_ = _fs.Set("createReadStream", func(call goja.FunctionCall) goja.Value {
+		path := stringArg(vm, call, 0, "path")
+		checkFS(vm, "fs.createReadStream", path)
+		opts := streamOptionsOf(call.Argument(1))
+
+		file, err := os.Open(path)
+		var src io.Reader = file
+		if err == nil && opts.start > 0 {
+			_, err = file.Seek(opts.start, io.SeekStart)
+		}
+		if err == nil && opts.end >= 0 {
+			src = io.LimitReader(file, opts.end-max(opts.start, 0)+1)
+		}
+		stream := newReadStream(vm, src, file, opts.highWaterMark)
+		if opts.encoding != "" {
+			stream.setEncoding(opts.encoding)
+		}
+		stream.opened(path, file, err)
+		return stream.obj
+	})
+
+	// createWriteStream(path[, options]) => Writable, where options is an
+	// encoding or { flags, encoding, mode }. flags is "w" by default, "a" appends.
+	_ = _fs.Set("createWriteStream", func(call goja.FunctionCall) goja.Value {
+		path := stringArg(vm, call, 0, "path")
+		checkFS(vm, "fs.createWriteStream", path)
+		opts := streamOptionsOf(call.Argument(1))
+
+		flag := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+		switch opts.flags {
+		case "a":
+			flag = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+		case "wx":
+			flag |= os.O_EXCL
+		case "ax":
+			flag = os.O_WRONLY | os.O_CREATE | os.O_APPEND | os.O_EXCL
+		case "r+":
+			flag = os.O_RDWR
+		}
+		file, err := os.OpenFile(path, flag, opts.mode)
+		stream := newWriteStream(vm, file, file)
+		if err != nil {
+			stream.dst, stream.closer = io.Discard, nil
+		}
+		stream.opened(path, file, err)
+		return stream.obj
+	})
+
 	// watch
 	_ = _fs.Set("watch", func(call goja.FunctionCall) goja.Value {
 		path := call.Argument(0).String()
+		checkFS(vm, "fs.watch", path)
 		var listener goja.Callable
 		var options map[string]interface{}
 
@@ -457,16 +539,13 @@ func FS(vm *goja.Runtime, module *goja.Object) {
 			}
 		}
 
-		// Background event loop
+		// A persistent watcher keeps the event loop running until it's closed
+		release := func() {}
 		if options == nil || options["persistent"] == nil || options["persistent"] == true {
-			EventLoop.Add(1)
+			release = hold(vm)
 		}
 		go func() {
-			defer func() {
-				if options == nil || options["persistent"] == nil || options["persistent"] == true {
-					EventLoop.Done()
-				}
-			}()
+			defer release()
 			debounceTimers := make(map[string]*time.Timer)
 			debounceDuration := 100 * time.Millisecond
 
@@ -486,11 +565,13 @@ func FS(vm *goja.Runtime, module *goja.Object) {
 						if ev.Op&fsnotify.Rename == fsnotify.Rename {
 							eventType = "rename"
 						}
-						for _, cb := range watchCallbacks[path] {
-							if _, err := cb(goja.Undefined(), vm.ToValue(eventType), vm.ToValue(ev.Name)); err != nil {
-								fmt.Printf("Error in watch callback: %v\n", err)
+						post(vm, func() {
+							for _, cb := range watchCallbacks[path] {
+								if _, err := cb(goja.Undefined(), vm.ToValue(eventType), vm.ToValue(ev.Name)); err != nil {
+									fmt.Printf("Error in watch callback: %v\n", err)
+								}
 							}
-						}
+						})
 					})
 
 				case err, ok := <-watcher.Errors:
@@ -510,7 +591,7 @@ func FS(vm *goja.Runtime, module *goja.Object) {
 			}
 			delete(fileWatchers, path)
 			delete(watchCallbacks, path)
-			EventLoop.Done()
+			release()
 			return goja.Undefined()
 		}); err != nil {
 			fmt.Printf("Error setting close on watcherObj: %v\n", err)
@@ -522,6 +603,7 @@ func FS(vm *goja.Runtime, module *goja.Object) {
 	// watchFile
 	_ = _fs.Set("watchFile", func(call goja.FunctionCall) goja.Value {
 		path := call.Argument(0).String()
+		checkFS(vm, "fs.watchFile", path)
 		var interval = time.Second
 		var listener goja.Callable
 
@@ -544,7 +626,7 @@ func FS(vm *goja.Runtime, module *goja.Object) {
 		}
 
 		// function object as a key
-		listenerObj := call.Argument(len(call.Arguments) - 1).ToObject(vm)
+		listenerObj := call.Argument(len(call.Arguments) - 1).ToObject(vm.Runtime)
 		if pollCallbacks[path] == nil {
 			pollCallbacks[path] = make(map[*goja.Object]goja.Callable)
 		}
@@ -555,9 +637,9 @@ func FS(vm *goja.Runtime, module *goja.Object) {
 			stop := make(chan struct{})
 			filePollers[path] = stop
 
-			EventLoop.Add(1)
+			release := hold(vm)
 			go func() {
-				defer EventLoop.Done()
+				defer release()
 				var prev os.FileInfo
 				ticker := time.NewTicker(interval)
 				defer ticker.Stop()
@@ -572,13 +654,16 @@ func FS(vm *goja.Runtime, module *goja.Object) {
 							continue
 						}
 						if prev != nil && curr.ModTime() != prev.ModTime() {
-							jsPrev := toJsStats(prev, vm, statsCtor)
-							jsCurr := toJsStats(curr, vm, statsCtor)
-							for _, cb := range pollCallbacks[path] {
-								if _, err := cb(goja.Undefined(), jsCurr, jsPrev); err != nil {
-									fmt.Printf("Error in watchFile callback: %v\n", err)
+							prev := prev
+							post(vm, func() {
+								jsPrev := toJsStats(prev, vm, statsCtor)
+								jsCurr := toJsStats(curr, vm, statsCtor)
+								for _, cb := range pollCallbacks[path] {
+									if _, err := cb(goja.Undefined(), jsCurr, jsPrev); err != nil {
+										fmt.Printf("Error in watchFile callback: %v\n", err)
+									}
 								}
-							}
+							})
 						}
 						prev = curr
 					}
@@ -602,7 +687,7 @@ func FS(vm *goja.Runtime, module *goja.Object) {
 
 		if listener != nil {
 			// Remove specific listener
-			listenerObj := call.Argument(1).ToObject(vm)
+			listenerObj := call.Argument(1).ToObject(vm.Runtime)
 			delete(pollCallbacks[path], listenerObj)
 		} else {
 			// Remove all listeners
@@ -636,7 +721,7 @@ func FS(vm *goja.Runtime, module *goja.Object) {
 	asyncNotImplList := []string{"glob", "link", "cp", "copyFile", "appendFile", "writeFile", "mkdtemp",
 		"readdir", "mkdir", "exists", "readFile", "symlink", "readlink", "unlink", "realpath", "rename", "rm", "rmdir", "stat"}
 
-	notImplList := []string{"access", "accessSync", "chown", "chownSync", "chmod", "chmodSync", "close", "closeSync", "createReadStream", "createWriteStream", "fchown", "fchownSync", "fchmod", "fchmodSync", "fdatasync", "fdatasyncSync", "fstat", "fstatSync", "fsync", "fsyncSync", "ftruncate", "ftruncateSync", "futimes", "futimesSync", "lchown", "lchownSync", "lstat", "lstatSync", "lutimes", "lutimesSync", "open", "openSync", "openAsBlob", "read", "readSync", "readv", "readvSync", "statfs", "statfsSync", "truncate", "truncateSync", "utimes", "utimesSync", "write", "writeSync", "writev", "writevSync", "Dirent", "ReadStream", "WriteStream", "FileReadStream", "FileWriteStream", "Dir", "opendir", "opendirSync"}
+	notImplList := []string{"access", "accessSync", "chown", "chownSync", "chmod", "chmodSync", "close", "closeSync", "fchown", "fchownSync", "fchmod", "fchmodSync", "fdatasync", "fdatasyncSync", "fstat", "fstatSync", "fsync", "fsyncSync", "ftruncate", "ftruncateSync", "futimes", "futimesSync", "lchown", "lchownSync", "lstat", "lstatSync", "lutimes", "lutimesSync", "open", "openSync", "openAsBlob", "read", "readSync", "readv", "readvSync", "statfs", "statfsSync", "truncate", "truncateSync", "utimes", "utimesSync", "write", "writeSync", "writev", "writevSync", "Dirent", "ReadStream", "WriteStream", "FileReadStream", "FileWriteStream", "Dir", "opendir", "opendirSync"}
 
 	for _, name := range asyncNotImplList {
 		_ = _fs.Set(name, asyncNotImplemented(name))
@@ -684,7 +769,7 @@ func SetObjProperty(obj *goja.Object, key string, value interface{}) {
 	}
 }
 
-func toJsStats(info os.FileInfo, vm *goja.Runtime, statsCtor *goja.Object) goja.Value {
+func toJsStats(info os.FileInfo, vm *Runtime, statsCtor *goja.Object) goja.Value {
 	stat, ok := info.Sys().(*syscall.Stat_t)
 	if !ok {
 		panic(vm.ToValue("fs.statSync: failed to read raw stat"))
@@ -744,3 +829,49 @@ func toJsStats(info os.FileInfo, vm *goja.Runtime, statsCtor *goja.Object) goja.
 	}
 	return vm.ToValue(instance)
 }
+
+// streamOptions are the options of fs.createReadStream and createWriteStream.
+type streamOptions struct {
+	encoding      string
+	flags         string
+	mode          os.FileMode
+	start, end    int64
+	highWaterMark int
+}
+
+func streamOptionsOf(val goja.Value) streamOptions {
+	opts := streamOptions{flags: "w", mode: 0o666, end: -1}
+	if val == nil || goja.IsUndefined(val) || goja.IsNull(val) {
+		return opts
+	}
+	obj, ok := val.(*goja.Object)
+	if !ok {
+		opts.encoding = val.String()
+		return opts
+	}
+	get := func(name string) goja.Value {
+		if v := obj.Get(name); v != nil && !goja.IsUndefined(v) && !goja.IsNull(v) {
+			return v
+		}
+		return nil
+	}
+	if v := get("encoding"); v != nil {
+		opts.encoding = v.String()
+	}
+	if v := get("flags"); v != nil {
+		opts.flags = v.String()
+	}
+	if v := get("mode"); v != nil {
+		opts.mode = os.FileMode(v.ToInteger())
+	}
+	if v := get("start"); v != nil {
+		opts.start = v.ToInteger()
+	}
+	if v := get("end"); v != nil {
+		opts.end = v.ToInteger()
+	}
+	if v := get("highWaterMark"); v != nil {
+		opts.highWaterMark = int(v.ToInteger())
+	}
+	return opts
+}
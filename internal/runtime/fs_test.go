@@ -9,11 +9,10 @@ import (
 	"sort"
 	"strings"
 	"testing"
-
-	"github.com/dop251/goja"
+	"time"
 )
 
-func setupTestVM(t *testing.T) (*goja.Runtime, func()) {
+func setupTestVM(t *testing.T) (*Runtime, func()) {
 	vm := NewRuntime()
 
 	tmpDir, err := os.MkdirTemp("", "runtime-test-")
@@ -569,3 +568,31 @@ func TestFSModule(t *testing.T) {
 		}
 	})
 }
+
+func TestFSWatchRunsOnTheEventLoop(t *testing.T) {
+	vm, cleanup := setupTestVM(t)
+	defer cleanup()
+
+	_, err := vm.RunString(`
+		globalThis.changed = [];
+		const watcher = fs.watch('.', (event, name) => {
+			changed.push(name);
+			watcher.close();
+		});
+	`)
+	if err != nil {
+		t.Fatalf("vm.RunString() failed: %v", err)
+	}
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		_ = os.WriteFile("test.txt", []byte("hello"), 0644)
+	}()
+	// The watcher keeps the loop running until its listener closes it
+	RunEventLoop(vm)
+
+	changed := vm.Get("changed").Export().([]interface{})
+	if len(changed) != 1 || !strings.HasSuffix(changed[0].(string), "test.txt") {
+		t.Errorf("expected a change of test.txt, got %v", changed)
+	}
+}
@@ -0,0 +1,326 @@
+package runtime
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/dop251/goja"
+)
+
+// HTTP is a minimal node:http module. It provides createServer, whose request
+// handlers run on the event loop; use fetch() for outgoing requests.
+func HTTP(vm *Runtime, module *goja.Object) {
+	_http := module.Get("exports").(*goja.Object)
+
+	statusCodes := vm.NewObject()
+	for code := 100; code < 600; code++ {
+		if text := http.StatusText(code); text != "" {
+			_ = statusCodes.Set(strconv.Itoa(code), text)
+		}
+	}
+	_ = _http.Set("STATUS_CODES", statusCodes)
+
+	// createServer([requestListener]) => Server
+	_ = _http.Set("createServer", func(call goja.FunctionCall) goja.Value {
+		srv := &jsServer{vm: vm, listeners: map[string][]goja.Callable{}}
+		for _, arg := range call.Arguments {
+			if handler, ok := goja.AssertFunction(arg); ok {
+				srv.listeners["request"] = append(srv.listeners["request"], handler)
+			}
+		}
+		return srv.object()
+	})
+}
+
+// jsServer backs the Server objects returned by http.createServer. All fields
+// are only touched on the runtime's goroutine.
+type jsServer struct {
+	vm        *Runtime
+	obj       *goja.Object
+	listeners map[string][]goja.Callable
+	server    *http.Server
+	addr      *net.TCPAddr
+	release   func(func())
+}
+
+func (s *jsServer) emit(event string, args ...goja.Value) error {
+	for _, listener := range s.listeners[event] {
+		if _, err := listener(s.obj, args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *jsServer) object() *goja.Object {
+	vm := s.vm
+	s.obj = vm.NewObject()
+	_ = s.obj.Set("listening", false)
+
+	// on(event, listener): "request", "listening" and "close"
+	_ = s.obj.Set("on", func(call goja.FunctionCall) goja.Value {
+		event := call.Argument(0).String()
+		listener, ok := goja.AssertFunction(call.Argument(1))
+		if !ok {
+			panic(vm.NewTypeError("server.on: listener must be a function"))
+		}
+		s.listeners[event] = append(s.listeners[event], listener)
+		return s.obj
+	})
+
+	// listen([port], [host], [callback]); the host defaults to localhost and port 0 picks a free port
+	_ = s.obj.Set("listen", func(call goja.FunctionCall) goja.Value {
+		if s.server != nil {
+			panic(vm.NewGoError(fmt.Errorf("server.listen: server is already listening")))
+		}
+		port, host := "0", "localhost"
+		for _, arg := range call.Arguments {
+			if fn, ok := goja.AssertFunction(arg); ok {
+				s.listeners["listening"] = append(s.listeners["listening"], fn)
+				continue
+			}
+			switch v := arg.Export().(type) {
+			case int64, float64:
+				port = arg.String()
+			case string:
+				if _, err := strconv.Atoi(v); err == nil && port == "0" {
+					port = v
+				} else {
+					host = v
+				}
+			}
+		}
+		checkNet(vm, "http.listen", host, port)
+
+		ln, err := net.Listen("tcp", net.JoinHostPort(host, port))
+		if err != nil {
+			panic(vm.NewGoError(err))
+		}
+		server := &http.Server{Handler: http.HandlerFunc(s.handle)}
+		s.server, s.addr = server, ln.Addr().(*net.TCPAddr)
+		s.release = Schedule(vm)
+		_ = s.obj.Set("listening", true)
+
+		go func() {
+			_ = server.Serve(ln)
+		}()
+
+		// Like node, the listening event fires asynchronously.
+		Schedule(vm)(func() {
+			if err := s.emit("listening"); err != nil {
+				fmt.Printf("Error in listening callback: %v\n", err)
+			}
+		})
+		return s.obj
+	})
+
+	// address() => { address, family, port }
+	_ = s.obj.Set("address", func(goja.FunctionCall) goja.Value {
+		if s.addr == nil {
+			return goja.Null()
+		}
+		family := "IPv4"
+		if s.addr.IP.To4() == nil {
+			family = "IPv6"
+		}
+		return vm.ToValue(map[string]interface{}{
+			"address": s.addr.IP.String(),
+			"family":  family,
+			"port":    s.addr.Port,
+		})
+	})
+
+	// close([callback]) stops accepting connections and waits for pending responses
+	_ = s.obj.Set("close", func(call goja.FunctionCall) goja.Value {
+		if callback, ok := goja.AssertFunction(call.Argument(0)); ok {
+			s.listeners["close"] = append(s.listeners["close"], callback)
+		}
+		if s.server == nil {
+			return s.obj
+		}
+		server, release := s.server, s.release
+		s.server, s.release = nil, nil
+		_ = s.obj.Set("listening", false)
+
+		go func() {
+			_ = server.Shutdown(context.Background())
+			release(func() {
+				if err := s.emit("close"); err != nil {
+					fmt.Printf("Error in close callback: %v\n", err)
+				}
+			})
+		}()
+		return s.obj
+	})
+
+	return s.obj
+}
+
+// handle runs on the HTTP server's goroutine. It hands the request over to the
+// event loop and waits until the script ends the response.
+func (s *jsServer) handle(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	res := &serverResponse{status: http.StatusOK, header: http.Header{}, finished: make(chan struct{})}
+
+	Schedule(s.vm)(func() {
+		reqObj, emitBody := newIncomingMessage(s.vm, r, body)
+		resObj := res.object(s.vm)
+		if err := s.emit("request", reqObj, resObj); err != nil {
+			fmt.Printf("Error in request handler: %v\n", err)
+			res.fail()
+			return
+		}
+		emitBody()
+	})
+
+	select {
+	case <-res.finished:
+	case <-r.Context().Done():
+		return
+	}
+
+	for name, values := range res.header {
+		w.Header()[name] = values
+	}
+	w.WriteHeader(res.status)
+	_, _ = w.Write(res.body.Bytes())
+}
+
+// newIncomingMessage builds the request object passed to handlers. The body is
+// delivered through the "data" and "end" events by the returned function, which
+// is called once the handler returns.
+func newIncomingMessage(vm *Runtime, r *http.Request, body []byte) (*goja.Object, func()) {
+	req := vm.NewObject()
+	headers := vm.NewObject()
+	for name, values := range r.Header {
+		_ = headers.Set(strings.ToLower(name), strings.Join(values, ", "))
+	}
+	_ = req.Set("method", r.Method)
+	_ = req.Set("url", r.URL.RequestURI())
+	_ = req.Set("headers", headers)
+	_ = req.Set("httpVersion", fmt.Sprintf("%d.%d", r.ProtoMajor, r.ProtoMinor))
+
+	listeners := map[string][]goja.Callable{}
+	_ = req.Set("on", func(call goja.FunctionCall) goja.Value {
+		if listener, ok := goja.AssertFunction(call.Argument(1)); ok {
+			event := call.Argument(0).String()
+			listeners[event] = append(listeners[event], listener)
+		}
+		return req
+	})
+
+	emitBody := func() {
+		Schedule(vm)(func() {
+			if len(body) > 0 {
+				for _, listener := range listeners["data"] {
+					if _, err := listener(req, vm.ToValue(string(body))); err != nil {
+						fmt.Printf("Error in request data listener: %v\n", err)
+					}
+				}
+			}
+			for _, listener := range listeners["end"] {
+				if _, err := listener(req); err != nil {
+					fmt.Printf("Error in request end listener: %v\n", err)
+				}
+			}
+		})
+	}
+	return req, emitBody
+}
+
+// serverResponse collects what the script writes; the HTTP goroutine sends it
+// once finished is closed.
+type serverResponse struct {
+	status   int
+	header   http.Header
+	body     bytes.Buffer
+	finished chan struct{}
+	once     sync.Once
+}
+
+func (res *serverResponse) fail() {
+	res.once.Do(func() {
+		res.status = http.StatusInternalServerError
+		res.header = http.Header{}
+		res.body.Reset()
+		close(res.finished)
+	})
+}
+
+func (res *serverResponse) object(vm *Runtime) *goja.Object {
+	obj := vm.NewObject()
+	_ = obj.Set("statusCode", res.status)
+	_ = obj.Set("headersSent", false)
+
+	setHeader := func(name string, value goja.Value) {
+		res.header.Del(name)
+		if values, ok := value.Export().([]interface{}); ok {
+			for _, v := range values {
+				res.header.Add(name, fmt.Sprint(v))
+			}
+			return
+		}
+		res.header.Set(name, value.String())
+	}
+
+	_ = obj.Set("setHeader", func(call goja.FunctionCall) goja.Value {
+		setHeader(call.Argument(0).String(), call.Argument(1))
+		return obj
+	})
+	_ = obj.Set("getHeader", func(call goja.FunctionCall) goja.Value {
+		if values := res.header.Values(call.Argument(0).String()); len(values) > 0 {
+			return vm.ToValue(strings.Join(values, ", "))
+		}
+		return goja.Undefined()
+	})
+	_ = obj.Set("removeHeader", func(call goja.FunctionCall) goja.Value {
+		res.header.Del(call.Argument(0).String())
+		return goja.Undefined()
+	})
+
+	// writeHead(statusCode, [statusMessage], [headers])
+	_ = obj.Set("writeHead", func(call goja.FunctionCall) goja.Value {
+		_ = obj.Set("statusCode", call.Argument(0))
+		headers := call.Argument(1)
+		if _, isString := headers.Export().(string); isString {
+			headers = call.Argument(2)
+		}
+		if h, ok := headers.(*goja.Object); ok {
+			for _, name := range h.Keys() {
+				setHeader(name, h.Get(name))
+			}
+		}
+		return obj
+	})
+
+	_ = obj.Set("write", func(call goja.FunctionCall) goja.Value {
+		if !goja.IsUndefined(call.Argument(0)) {
+			res.body.Write(toBytes(call.Argument(0)))
+		}
+		return vm.ToValue(true)
+	})
+
+	// end([chunk]) sends the response
+	_ = obj.Set("end", func(call goja.FunctionCall) goja.Value {
+		res.once.Do(func() {
+			if chunk := call.Argument(0); !goja.IsUndefined(chunk) && !goja.IsNull(chunk) {
+				if _, isFunc := goja.AssertFunction(chunk); !isFunc {
+					res.body.Write(toBytes(chunk))
+				}
+			}
+			res.status = int(obj.Get("statusCode").ToInteger())
+			_ = obj.Set("headersSent", true)
+			close(res.finished)
+		})
+		return obj
+	})
+
+	return obj
+}
@@ -0,0 +1,105 @@
+package runtime
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPServer(t *testing.T) {
+	t.Run("serves requests until closed", func(t *testing.T) {
+		vm := NewRuntime()
+		val, err := await(t, vm, `new Promise((resolve, reject) => {
+			const server = http.createServer((req, res) => {
+				let body = "";
+				req.on("data", (chunk) => body += chunk);
+				req.on("end", () => {
+					res.statusCode = 202;
+					res.setHeader("Content-Type", "text/plain");
+					res.end(req.method + " " + req.url + " " + req.headers["x-test"] + " " + body);
+				});
+			});
+			server.listen(0, "127.0.0.1", async () => {
+				try {
+					const { port } = server.address();
+					const res = await fetch("http://127.0.0.1:" + port + "/hello?x=1", {
+						method: "PUT",
+						headers: { "X-Test": "yes" },
+						body: "payload",
+					});
+					const text = await res.text();
+					server.close(() => resolve([res.status, res.headers.get("content-type"), text, server.listening]));
+				} catch (err) {
+					reject(err);
+				}
+			});
+		})`)
+		require.NoError(t, err)
+		assert.Equal(t, []interface{}{int64(202), "text/plain", "PUT /hello?x=1 yes payload", false}, val.Export())
+	})
+
+	t.Run("writeHead, write and thrown handlers", func(t *testing.T) {
+		vm := NewRuntime()
+		_, err := vm.RunString(`
+			var server = http.createServer();
+			server.on("request", (req, res) => {
+				if (req.url === "/boom") throw new Error("boom");
+				res.writeHead(418, "I'm a teapot", { "X-Kind": "teapot" });
+				res.write("short ");
+				res.end("and stout");
+			});
+			server.listen(0, "127.0.0.1");
+		`)
+		require.NoError(t, err)
+		port, err := vm.RunString(`server.address().port`)
+		require.NoError(t, err)
+
+		stopped := make(chan struct{})
+		go func() {
+			RunEventLoop(vm)
+			close(stopped)
+		}()
+		base := fmt.Sprintf("http://127.0.0.1:%d", port.ToInteger())
+
+		res, err := http.Get(base + "/tea")
+		require.NoError(t, err)
+		body, _ := io.ReadAll(res.Body)
+		_ = res.Body.Close()
+		assert.Equal(t, 418, res.StatusCode)
+		assert.Equal(t, "teapot", res.Header.Get("X-Kind"))
+		assert.Equal(t, "short and stout", string(body))
+
+		res, err = http.Get(base + "/boom")
+		require.NoError(t, err)
+		_ = res.Body.Close()
+		assert.Equal(t, http.StatusInternalServerError, res.StatusCode)
+
+		// Callbacks must run on the loop's goroutine, so close the server through it.
+		Schedule(vm)(func() {
+			_, err := vm.RunString(`server.close()`)
+			assert.NoError(t, err)
+		})
+		<-stopped
+	})
+
+	t.Run("sandboxed", func(t *testing.T) {
+		vm := NewRuntime()
+		SetPermissions(vm, &Permissions{Net: []string{"localhost:8080"}})
+		defer SetPermissions(vm, nil)
+
+		_, err := vm.RunString(`http.createServer().listen(9090)`)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "http.listen needs net access to \"localhost\"")
+	})
+
+	t.Run("status codes", func(t *testing.T) {
+		vm := NewRuntime()
+		val, err := vm.RunString(`require("http").STATUS_CODES[404]`)
+		require.NoError(t, err)
+		assert.Equal(t, "Not Found", val.String())
+	})
+}
@@ -6,24 +6,75 @@ import (
 	"github.com/dop251/goja_nodejs/url"
 )
 
-func NewRuntime() *goja.Runtime {
-	vm := goja.New()
+// Runtime is a goja runtime with the state prasmoid's modules keep for it.
+// NewRuntime creates it and hands it to the modules, so every runtime, like
+// each test file's or the repl's, has its own, which goes away with it.
+type Runtime struct {
+	*goja.Runtime
 
-	registry := new(require.Registry)
-	registry.Enable(vm)
-	url.Enable(vm)
+	registry *require.Registry
+	// permissions restrict the scripts, or don't if nil (see SetPermissions).
+	permissions *Permissions
+	// moduleRoots are the directories of the scripts run with RunFile, which
+	// sandboxed scripts may require modules from.
+	moduleRoots []string
+	// modules are the files loaded through require(), see Modules.
+	modules    []string
+	loop       *loop
+	rejections *rejectionTracker
+	// commands are the commands registered through prasmoid.Command, in order.
+	commands []*CommandConfig
+	// exitEmitted is set once process has emitted "exit", which happens once.
+	exitEmitted bool
+	// answers are the prompt answers given up front, by prompt name.
+	answers map[string]string
+	// tests are the tests declared by a test file, in order.
+	tests []*TestCase
+}
+
+func NewRuntime() *Runtime {
+	vm := &Runtime{Runtime: goja.New(), loop: newLoop()}
+	trackRejections(vm)
+	defineAsyncIterator(vm)
+
+	vm.registry = require.NewRegistry(require.WithLoader(vm.loadSource))
+	vm.registry.Enable(vm.Runtime)
+	url.Enable(vm.Runtime)
+	EnableFetch(vm)
 	Register(vm, "process", Process)
 	Register(vm, "os", OS)
 	Register(vm, "fs", FS)
 	Register(vm, "path", Path)
 	Register(vm, "child_process", ChildProcess)
+	Register(vm, "http", HTTP)
+	Register(vm, "buffer", Buffer)
+	Register(vm, "crypto", Crypto)
+	Register(vm, "zlib", Zlib)
+	Register(vm, "readline", Readline)
+	Register(vm, "prompt", Prompt)
 	Register(vm, "prasmoid", Prasmoid)
 	Register(vm, "console", Console)
 
 	return vm
 }
 
-func Register(vm *goja.Runtime, name string, module func(vm *goja.Runtime, module *goja.Object)) {
-	require.RegisterCoreModule(name, module)
-	_ = vm.Set(name, require.Require(vm, name))
+// Register makes module requirable as name, and "node:" name, in vm, and
+// sets the global of the same name.
+func Register(vm *Runtime, name string, module func(vm *Runtime, module *goja.Object)) {
+	registerModuleName(name)
+	vm.registry.RegisterNativeModule(name, func(_ *goja.Runtime, m *goja.Object) { module(vm, m) })
+	vm.registry.RegisterNativeModule("node:"+name, func(_ *goja.Runtime, m *goja.Object) {
+		_ = m.Set("exports", require.Require(vm.Runtime, name))
+	})
+	_ = vm.Set(name, require.Require(vm.Runtime, name))
+}
+
+// defineAsyncIterator adds the Symbol.asyncIterator goja lacks, as the symbol
+// esbuild's for await helper falls back to, so that transpiled loops and
+// async iterables agree on it.
+func defineAsyncIterator(vm *Runtime) {
+	_, err := vm.RunString(`Object.defineProperty(Symbol, "asyncIterator", { value: Symbol.for("Symbol.asyncIterator") })`)
+	if err != nil {
+		panic(err)
+	}
 }
@@ -0,0 +1,330 @@
+package runtime
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/dop251/goja"
+)
+
+// registerMocks adds the mock object. Every test runs in its own runtime, so
+// mocks never need restoring.
+//
+//   - mock.fn([impl]) creates a function recording its calls.
+//   - mock.module(name, overrides) replaces members of a built-in module.
+//   - mock.fs(files) backs fs with an in-memory { path: content } object. The
+//     fs functions it can't fake, like the streams and watchers, throw.
+//   - mock.exec(outputs) answers child_process.execSync from { command: output }
+//     or a function of the command.
+//   - mock.prasmoid({ metadata, config }) fakes metadata.json and the config,
+//     and replaces the project operations with mock functions.
+func registerMocks(vm *Runtime, global *goja.Object, mocks map[*goja.Object]*mockState) {
+	mock := vm.NewObject()
+
+	newMock := func(impl goja.Callable) *goja.Object {
+		state := &mockState{impl: impl}
+		fn := vm.ToValue(func(call goja.FunctionCall) goja.Value {
+			state.calls = append(state.calls, slices.Clone(call.Arguments))
+			if state.impl != nil {
+				val, err := state.impl(call.This, call.Arguments...)
+				if err != nil {
+					throw(vm, err)
+				}
+				return val
+			}
+			if state.returns != nil {
+				return state.returns
+			}
+			return goja.Undefined()
+		}).(*goja.Object)
+
+		// fn.calls lists the arguments of every call
+		_ = fn.DefineAccessorProperty("calls", vm.ToValue(func(goja.FunctionCall) goja.Value {
+			calls := make([]interface{}, len(state.calls))
+			for i, args := range state.calls {
+				values := make([]interface{}, len(args))
+				for j, arg := range args {
+					values[j] = arg
+				}
+				calls[i] = vm.NewArray(values...)
+			}
+			return vm.NewArray(calls...)
+		}), nil, goja.FLAG_FALSE, goja.FLAG_TRUE)
+		_ = fn.Set("mockReturnValue", func(call goja.FunctionCall) goja.Value {
+			state.returns, state.impl = call.Argument(0), nil
+			return fn
+		})
+		_ = fn.Set("mockImplementation", func(call goja.FunctionCall) goja.Value {
+			impl, ok := goja.AssertFunction(call.Argument(0))
+			if !ok {
+				panic(vm.NewTypeError("mockImplementation needs a function"))
+			}
+			state.impl = impl
+			return fn
+		})
+		_ = fn.Set("mockClear", func(goja.FunctionCall) goja.Value {
+			state.calls = nil
+			return fn
+		})
+		mocks[fn] = state
+		return fn
+	}
+	// spy wraps a Go implementation in a mock function.
+	spy := func(impl func(goja.FunctionCall) goja.Value) *goja.Object {
+		fn, _ := goja.AssertFunction(vm.ToValue(impl))
+		return newMock(fn)
+	}
+	module := func(name string) *goja.Object {
+		require, _ := goja.AssertFunction(vm.Get("require"))
+		exports, err := require(goja.Undefined(), vm.ToValue(name))
+		if err != nil {
+			throw(vm, err)
+		}
+		return exports.ToObject(vm.Runtime)
+	}
+
+	_ = mock.Set("fn", func(call goja.FunctionCall) goja.Value {
+		var impl goja.Callable
+		if arg := call.Argument(0); !goja.IsUndefined(arg) {
+			fn, ok := goja.AssertFunction(arg)
+			if !ok {
+				panic(vm.NewTypeError("mock.fn: the implementation must be a function"))
+			}
+			impl = fn
+		}
+		return newMock(impl)
+	})
+
+	_ = mock.Set("module", func(call goja.FunctionCall) goja.Value {
+		exports := module(call.Argument(0).String())
+		overrides, ok := call.Argument(1).(*goja.Object)
+		if !ok {
+			panic(vm.NewTypeError("mock.module: overrides must be an object"))
+		}
+		for _, key := range overrides.Keys() {
+			_ = exports.Set(key, overrides.Get(key))
+		}
+		return exports
+	})
+
+	_ = mock.Set("fs", func(call goja.FunctionCall) goja.Value {
+		fs := module("fs")
+		files := vm.NewObject()
+		if initial, ok := call.Argument(0).(*goja.Object); ok {
+			for _, key := range initial.Keys() {
+				_ = files.Set(filepath.Clean(key), initial.Get(key).String())
+			}
+		}
+		pathArg := func(call goja.FunctionCall) string {
+			return filepath.Clean(call.Argument(0).String())
+		}
+		// under lists the files in dir and its subdirectories.
+		under := func(dir string) []string {
+			var paths []string
+			for _, key := range files.Keys() {
+				if dir == "." && !filepath.IsAbs(key) || strings.HasPrefix(key, dir+string(filepath.Separator)) {
+					paths = append(paths, key)
+				}
+			}
+			return paths
+		}
+
+		// The fakes fail like the real functions, returning the error as a string.
+		_ = fs.Set("readFileSync", spy(func(call goja.FunctionCall) goja.Value {
+			path := pathArg(call)
+			if content := files.Get(path); content != nil {
+				return content
+			}
+			return vm.ToValue(fmt.Sprintf("open %s: no such file or directory", path))
+		}))
+		_ = fs.Set("writeFileSync", spy(func(call goja.FunctionCall) goja.Value {
+			_ = files.Set(pathArg(call), call.Argument(1).String())
+			return goja.Undefined()
+		}))
+		_ = fs.Set("appendFileSync", spy(func(call goja.FunctionCall) goja.Value {
+			path, content := pathArg(call), ""
+			if existing := files.Get(path); existing != nil {
+				content = existing.String()
+			}
+			_ = files.Set(path, content+call.Argument(1).String())
+			return goja.Undefined()
+		}))
+		_ = fs.Set("existsSync", spy(func(call goja.FunctionCall) goja.Value {
+			path := pathArg(call)
+			return vm.ToValue(files.Get(path) != nil || len(under(path)) > 0)
+		}))
+		_ = fs.Set("readdirSync", spy(func(call goja.FunctionCall) goja.Value {
+			dir := pathArg(call)
+			seen := map[string]bool{}
+			for _, path := range under(dir) {
+				rel, _ := filepath.Rel(dir, path)
+				seen[strings.Split(rel, string(filepath.Separator))[0]] = true
+			}
+			names := make([]string, 0, len(seen))
+			for name := range seen {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			return vm.ToValue(names)
+		}))
+		_ = fs.Set("mkdirSync", spy(func(goja.FunctionCall) goja.Value {
+			return goja.Undefined()
+		}))
+		remove := spy(func(call goja.FunctionCall) goja.Value {
+			path := pathArg(call)
+			for _, file := range append(under(path), path) {
+				_ = files.Delete(file)
+			}
+			return goja.Undefined()
+		})
+		_ = fs.Set("unlinkSync", remove)
+		_ = fs.Set("rmSync", remove)
+		_ = fs.Set("rmdirSync", remove)
+		_ = fs.Set("statSync", spy(func(call goja.FunctionCall) goja.Value {
+			path := pathArg(call)
+			stats := vm.NewObject()
+			switch content := files.Get(path); {
+			case content != nil:
+				_ = stats.Set("mode", 0o100644)
+				_ = stats.Set("size", len(content.String()))
+			case len(under(path)) > 0:
+				_ = stats.Set("mode", 0o040755)
+				_ = stats.Set("size", 4096)
+			default:
+				panic(vm.ToValue(fmt.Sprintf("fs.statSync error: stat %s: no such file or directory", path)))
+			}
+			instance, err := vm.New(fs.Get("Stats"), stats)
+			if err != nil {
+				throw(vm, err)
+			}
+			return instance
+		}))
+		// transfer copies the file or directory src to dest, and removes src when moving.
+		transfer := func(src, dest string, move bool) goja.Value {
+			moved := map[string]goja.Value{}
+			if content := files.Get(src); content != nil {
+				moved[dest] = content
+			}
+			for _, path := range under(src) {
+				rel, _ := filepath.Rel(src, path)
+				moved[filepath.Join(dest, rel)] = files.Get(path)
+			}
+			if len(moved) == 0 {
+				return vm.ToValue(fmt.Sprintf("open %s: no such file or directory", src))
+			}
+			if move {
+				for _, file := range append(under(src), src) {
+					_ = files.Delete(file)
+				}
+			}
+			for path, content := range moved {
+				_ = files.Set(path, content)
+			}
+			return goja.Undefined()
+		}
+		_ = fs.Set("copyFileSync", spy(func(call goja.FunctionCall) goja.Value {
+			src := pathArg(call)
+			if files.Get(src) == nil {
+				return vm.ToValue(fmt.Sprintf("open %s: no such file or directory", src))
+			}
+			return transfer(src, filepath.Clean(call.Argument(1).String()), false)
+		}))
+		_ = fs.Set("cpSync", spy(func(call goja.FunctionCall) goja.Value {
+			return transfer(pathArg(call), filepath.Clean(call.Argument(1).String()), false)
+		}))
+		_ = fs.Set("renameSync", spy(func(call goja.FunctionCall) goja.Value {
+			return transfer(pathArg(call), filepath.Clean(call.Argument(1).String()), true)
+		}))
+		// The rest would reach the real disk behind the fake one's back.
+		for _, name := range []string{"createReadStream", "createWriteStream", "realpathSync", "readlinkSync", "symlinkSync", "mkdtempSync", "globSync", "watch", "watchFile", "unwatchFile"} {
+			_ = fs.Set(name, func(goja.FunctionCall) goja.Value {
+				panic(vm.NewGoError(fmt.Errorf("mock.fs: fs.%s is not faked, replace it with mock.module", name)))
+			})
+		}
+		return files
+	})
+
+	_ = mock.Set("exec", func(call goja.FunctionCall) goja.Value {
+		outputs := call.Argument(0)
+		respond, isFunc := goja.AssertFunction(outputs)
+		table, isTable := outputs.(*goja.Object)
+		if !isFunc && !isTable {
+			panic(vm.NewTypeError("mock.exec: outputs must be an object or a function"))
+		}
+		execSync := spy(func(call goja.FunctionCall) goja.Value {
+			command := call.Argument(0).String()
+			if isFunc {
+				val, err := respond(goja.Undefined(), call.Argument(0))
+				if err != nil {
+					throw(vm, err)
+				}
+				return val
+			}
+			if output := table.Get(command); output != nil {
+				return output
+			}
+			panic(assertionError(vm, fmt.Sprintf("mock.exec: unexpected command %q", command)))
+		})
+		_ = module("child_process").Set("execSync", execSync)
+		return execSync
+	})
+
+	_ = mock.Set("prasmoid", func(call goja.FunctionCall) goja.Value {
+		prasmoid := module("prasmoid")
+		opts, ok := call.Argument(0).(*goja.Object)
+		if !ok {
+			opts = vm.NewObject()
+		}
+		metadata, ok := opts.Get("metadata").(*goja.Object)
+		if !ok {
+			metadata = vm.NewObject()
+		}
+		section := func(name string) *goja.Object {
+			if name == "." {
+				return metadata
+			}
+			obj, ok := metadata.Get(name).(*goja.Object)
+			if !ok {
+				obj = vm.NewObject()
+				_ = metadata.Set(name, obj)
+			}
+			return obj
+		}
+
+		_ = prasmoid.Set("getMetadata", spy(func(call goja.FunctionCall) goja.Value {
+			key := call.Argument(0).String()
+			if val := section("KPlugin").Get(key); val != nil && !goja.IsUndefined(val) && !goja.IsNull(val) {
+				return val
+			}
+			return vm.ToValue("prasmoid.getMetadata: " + key + " not found in metadata.json")
+		}))
+		_ = prasmoid.Set("setMetadata", spy(func(call goja.FunctionCall) goja.Value {
+			name := "KPlugin"
+			if s := call.Argument(2); !goja.IsUndefined(s) {
+				name = s.String()
+			}
+			_ = section(name).Set(call.Argument(0).String(), call.Argument(1))
+			return goja.Undefined()
+		}))
+		if config := opts.Get("config"); config != nil && !goja.IsUndefined(config) {
+			_ = prasmoid.DefineDataProperty("config", config, goja.FLAG_TRUE, goja.FLAG_TRUE, goja.FLAG_TRUE)
+		}
+		for _, name := range []string{"build", "link", "install", "format"} {
+			_ = prasmoid.Set(name, newMock(nil))
+		}
+		i18n := vm.NewObject()
+		_ = i18n.Set("extract", newMock(nil))
+		_ = i18n.Set("compile", newMock(nil))
+		_ = prasmoid.Set("i18n", i18n)
+		changeset := vm.NewObject()
+		_ = changeset.Set("add", newMock(nil))
+		_ = changeset.Set("apply", newMock(nil))
+		_ = prasmoid.Set("changeset", changeset)
+		return prasmoid
+	})
+
+	_ = global.Set("mock", mock)
+}
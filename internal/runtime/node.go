@@ -0,0 +1,92 @@
+package runtime
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"syscall"
+
+	"github.com/dop251/goja"
+	"golang.org/x/sys/unix"
+)
+
+// invalidArgType is node's ERR_INVALID_ARG_TYPE error, thrown by functions
+// given an argument of the wrong type.
+func invalidArgType(vm *Runtime, name, expected string, val goja.Value) *goja.Object {
+	var received string
+	switch {
+	case val == nil || goja.IsUndefined(val):
+		received = "undefined"
+	case goja.IsNull(val):
+		received = "null"
+	default:
+		if obj, ok := val.(*goja.Object); ok {
+			if _, isFunc := goja.AssertFunction(obj); isFunc {
+				received = fmt.Sprintf("function %s", obj.Get("name"))
+			} else {
+				received = "an instance of " + obj.Get("constructor").ToObject(vm.Runtime).Get("name").String()
+			}
+		} else {
+			inspected := Inspect(val)
+			if typeOf(val) == "string" {
+				inspected = quoteString(val.String())
+			}
+			received = fmt.Sprintf("type %s (%s)", typeOf(val), inspected)
+		}
+	}
+	err := vm.NewTypeError(fmt.Sprintf("The %q argument must be of type %s. Received %s", name, expected, received))
+	_ = err.Set("code", "ERR_INVALID_ARG_TYPE")
+	return err
+}
+
+// quoteString quotes s like util.inspect does in node's error messages,
+// shortening long strings.
+func quoteString(s string) string {
+	if len(s) > 28 {
+		s = s[:25] + "..."
+	}
+	switch {
+	case !strings.Contains(s, "'"):
+		return "'" + s + "'"
+	case !strings.Contains(s, `"`):
+		return `"` + s + `"`
+	}
+	return "'" + strings.ReplaceAll(s, "'", `\'`) + "'"
+}
+
+// systemError is err as a JS error, with node's code for system errors,
+// like ENOENT.
+func systemError(vm *Runtime, err error) *goja.Object {
+	obj := vm.NewGoError(err)
+	var errno syscall.Errno
+	if errors.As(err, &errno) {
+		_ = obj.Set("code", unix.ErrnoName(errno))
+		_ = obj.Set("errno", -int(errno))
+	}
+	return obj
+}
+
+// typeOf is the typeof of a primitive value.
+func typeOf(val goja.Value) string {
+	switch val.ExportType().Kind().String() {
+	case "bool":
+		return "boolean"
+	case "string":
+		return "string"
+	case "int64", "float64":
+		return "number"
+	}
+	if _, ok := val.Export().(interface{ Sign() int }); ok {
+		return "bigint"
+	}
+	return "symbol"
+}
+
+// stringArg returns the i-th argument, which node requires to be a string.
+func stringArg(vm *Runtime, call goja.FunctionCall, i int, name string) string {
+	arg := call.Argument(i)
+	if s, ok := arg.Export().(string); ok {
+		return s
+	}
+	panic(invalidArgType(vm, name, "string", arg))
+}
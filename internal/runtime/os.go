@@ -2,6 +2,7 @@ package runtime
 
 import (
 	"fmt"
+	"net"
 	"os"
 	"os/user"
 	"runtime"
@@ -14,12 +15,12 @@ import (
 	"golang.org/x/sys/unix"
 )
 
-func OS(vm *goja.Runtime, module *goja.Object) {
+func OS(vm *Runtime, module *goja.Object) {
 	_os := module.Get("exports").(*goja.Object)
 
 	// arch()
 	_ = _os.Set("arch", func(call goja.FunctionCall) goja.Value {
-		return vm.ToValue(runtime.GOARCH)
+		return vm.ToValue(nodeArch())
 	})
 
 	// platform()
@@ -44,9 +45,22 @@ func OS(vm *goja.Runtime, module *goja.Object) {
 		return vm.ToValue(release)
 	})
 
-	// type()
+	// type() — the kernel name, "Linux"
 	_ = _os.Set("type", func(call goja.FunctionCall) goja.Value {
-		return vm.ToValue("Linux") // Or adjust based on runtime.GOOS
+		var uname unix.Utsname
+		if err := unix.Uname(&uname); err != nil {
+			return vm.ToValue("unknown")
+		}
+		return vm.ToValue(unix.ByteSliceToString(uname.Sysname[:]))
+	})
+
+	// version() — the kernel build, e.g. "#1 SMP PREEMPT_DYNAMIC"
+	_ = _os.Set("version", func(call goja.FunctionCall) goja.Value {
+		var uname unix.Utsname
+		if err := unix.Uname(&uname); err != nil {
+			return vm.ToValue("unknown")
+		}
+		return vm.ToValue(unix.ByteSliceToString(uname.Version[:]))
 	})
 
 	// homedir()
@@ -80,8 +94,12 @@ func OS(vm *goja.Runtime, module *goja.Object) {
 		}
 	})
 
-	// freemem() and totalmem()
+	// freemem() and totalmem(). Like node, free memory counts what the kernel
+	// can reclaim, MemAvailable in /proc/meminfo.
 	_ = _os.Set("freemem", func(call goja.FunctionCall) goja.Value {
+		if available, ok := memAvailable(); ok {
+			return vm.ToValue(available)
+		}
 		var mem syscall.Sysinfo_t
 		if err := syscall.Sysinfo(&mem); err != nil {
 			return vm.ToValue("Error: freemem not supported on " + runtime.GOOS)
@@ -144,37 +162,282 @@ func OS(vm *goja.Runtime, module *goja.Object) {
 	// userInfo()
 	_ = _os.Set("userInfo", func(call goja.FunctionCall) goja.Value {
 		user, err := user.Current()
-		shellPath := os.Getenv("SHELL")
-		if shellPath == "" {
-			shellPath = "unknown"
-		}
 		if err != nil {
-			return vm.ToValue("unknown")
+			panic(vm.NewGoError(err))
 		}
+		uid, _ := strconv.Atoi(user.Uid)
+		gid, _ := strconv.Atoi(user.Gid)
 		obj := vm.NewObject()
-		SetObjProperty(obj, "uid", user.Uid)
-		SetObjProperty(obj, "gid", user.Gid)
-		SetObjProperty(obj, "name", user.Name)
+		SetObjProperty(obj, "uid", uid)
+		SetObjProperty(obj, "gid", gid)
 		SetObjProperty(obj, "username", user.Username)
 		SetObjProperty(obj, "homedir", user.HomeDir)
-		SetObjProperty(obj, "shell", shellPath)
+		if shell := loginShell(user.Username); shell != "" {
+			SetObjProperty(obj, "shell", shell)
+		} else {
+			SetObjProperty(obj, "shell", nil)
+		}
 		return obj
 	})
 
-	// === NOT IMPLEMENTED FUNCTIONS ===
+	// cpus()
+	_ = _os.Set("cpus", func(call goja.FunctionCall) goja.Value {
+		list := vm.NewArray()
+		for i, cpu := range cpus() {
+			times := vm.NewObject()
+			SetObjProperty(times, "user", cpu.Times.User)
+			SetObjProperty(times, "nice", cpu.Times.Nice)
+			SetObjProperty(times, "sys", cpu.Times.Sys)
+			SetObjProperty(times, "idle", cpu.Times.Idle)
+			SetObjProperty(times, "irq", cpu.Times.IRQ)
+			obj := vm.NewObject()
+			SetObjProperty(obj, "model", cpu.Model)
+			SetObjProperty(obj, "speed", cpu.Speed)
+			SetObjProperty(obj, "times", times)
+			SetObjProperty(list, strconv.Itoa(i), obj)
+		}
+		return list
+	})
+
+	// networkInterfaces()
+	_ = _os.Set("networkInterfaces", func(call goja.FunctionCall) goja.Value {
+		names, ifaces, err := networkInterfaces()
+		if err != nil {
+			panic(vm.NewGoError(err))
+		}
+		result := vm.NewObject()
+		for _, name := range names {
+			list := vm.NewArray()
+			for i, addr := range ifaces[name] {
+				obj := vm.NewObject()
+				SetObjProperty(obj, "address", addr.Address)
+				SetObjProperty(obj, "netmask", addr.Netmask)
+				SetObjProperty(obj, "family", addr.Family)
+				SetObjProperty(obj, "mac", addr.MAC)
+				SetObjProperty(obj, "internal", addr.Internal)
+				SetObjProperty(obj, "cidr", addr.CIDR)
+				if addr.ScopeID != nil {
+					SetObjProperty(obj, "scopeid", *addr.ScopeID)
+				}
+				SetObjProperty(list, strconv.Itoa(i), obj)
+			}
+			SetObjProperty(result, name, list)
+		}
+		return result
+	})
+
+	// getPriority([pid])
+	_ = _os.Set("getPriority", func(call goja.FunctionCall) goja.Value {
+		pid := int(call.Argument(0).ToInteger())
+		prio, err := unix.Getpriority(unix.PRIO_PROCESS, pid)
+		if err != nil {
+			panic(vm.NewGoError(fmt.Errorf("getPriority: %w", err)))
+		}
+		// The raw syscall returns 20 - nice, so that it's never negative
+		return vm.ToValue(20 - prio)
+	})
+
+	// setPriority([pid, ]priority)
+	_ = _os.Set("setPriority", func(call goja.FunctionCall) goja.Value {
+		pid, prio := 0, call.Argument(0)
+		if len(call.Arguments) > 1 {
+			pid, prio = int(call.Argument(0).ToInteger()), call.Argument(1)
+		}
+		if !GetPermissions(vm).AllowsProcess("setPriority") {
+			throwPermissionError(vm, "os.setPriority", "process", "setPriority")
+		}
+		if err := unix.Setpriority(unix.PRIO_PROCESS, pid, int(prio.ToInteger())); err != nil {
+			panic(vm.NewGoError(fmt.Errorf("setPriority: %w", err)))
+		}
+		return goja.Undefined()
+	})
+
+	_ = _os.Set("EOL", "\n")
+	_ = _os.Set("devNull", "/dev/null")
+	_ = _os.Set("constants", osConstants(vm))
+}
+
+// memAvailable reads the memory available to new processes from /proc/meminfo.
+func memAvailable() (uint64, bool) {
+	data, err := os.ReadFile("/proc/meminfo")
+	if err != nil {
+		return 0, false
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if rest, ok := strings.CutPrefix(line, "MemAvailable:"); ok {
+			kb, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimSpace(rest), " kB"), 10, 64)
+			return kb * 1024, err == nil
+		}
+	}
+	return 0, false
+}
+
+// loginShell is the shell of a user in /etc/passwd, which is where node reads it from.
+func loginShell(username string) string {
+	data, err := os.ReadFile("/etc/passwd")
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Split(line, ":")
+		if len(fields) == 7 && fields[0] == username {
+			return fields[6]
+		}
+	}
+	return ""
+}
+
+type cpuTimes struct {
+	User uint64
+	Nice uint64
+	Sys  uint64
+	Idle uint64
+	IRQ  uint64
+}
+
+type cpuInfo struct {
+	Model string
+	Speed int
+	Times cpuTimes
+}
 
-	notImplemented := func(name string) func(goja.FunctionCall) goja.Value {
-		return func(call goja.FunctionCall) goja.Value {
-			return vm.ToValue(fmt.Sprintf("os.%s is not implemented in this runtime", name))
+// cpus describes each logical CPU from /proc/cpuinfo and /proc/stat, with
+// times in milliseconds like node.
+func cpus() []cpuInfo {
+	var list []cpuInfo
+	if data, err := os.ReadFile("/proc/stat"); err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) < 8 || !strings.HasPrefix(fields[0], "cpu") || fields[0] == "cpu" {
+				continue
+			}
+			ms := func(i int) uint64 {
+				ticks, _ := strconv.ParseUint(fields[i], 10, 64)
+				return ticks * 1000 / clockTicks
+			}
+			list = append(list, cpuInfo{Times: cpuTimes{User: ms(1), Nice: ms(2), Sys: ms(3), Idle: ms(4), IRQ: ms(6)}})
+		}
+	}
+
+	var models []string
+	var speeds []int
+	if data, err := os.ReadFile("/proc/cpuinfo"); err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			key, value, ok := strings.Cut(line, ":")
+			if !ok {
+				continue
+			}
+			switch strings.TrimSpace(key) {
+			case "model name":
+				models = append(models, strings.TrimSpace(value))
+			case "cpu MHz":
+				mhz, _ := strconv.ParseFloat(strings.TrimSpace(value), 64)
+				speeds = append(speeds, int(mhz))
+			}
+		}
+	}
+	for i := range list {
+		list[i].Model = "unknown"
+		if i < len(models) {
+			list[i].Model = models[i]
+		}
+		if i < len(speeds) {
+			list[i].Speed = speeds[i]
 		}
 	}
+	return list
+}
+
+// clockTicks is USER_HZ, the unit of the times in /proc/stat. It's 100 on
+// every architecture Linux runs on.
+const clockTicks = 100
+
+type interfaceAddress struct {
+	Address  string
+	Netmask  string
+	Family   string
+	MAC      string
+	Internal bool
+	CIDR     string
+	ScopeID  *int
+}
 
-	notImplList := []string{
-		"cpus", "networkInterfaces", "setPriority", "getPriority", "version",
+// networkInterfaces lists the addresses of the network interfaces that are up,
+// and their names in the order of the system.
+func networkInterfaces() ([]string, map[string][]interfaceAddress, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, nil, err
 	}
+	var names []string
+	result := map[string][]interfaceAddress{}
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagRunning == 0 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		mac := iface.HardwareAddr.String()
+		if mac == "" {
+			mac = "00:00:00:00:00:00"
+		}
+		for _, addr := range addrs {
+			ipNet, ok := addr.(*net.IPNet)
+			if !ok {
+				continue
+			}
+			ones, _ := ipNet.Mask.Size()
+			entry := interfaceAddress{
+				Address:  ipNet.IP.String(),
+				Netmask:  net.IP(ipNet.Mask).String(),
+				Family:   "IPv4",
+				MAC:      mac,
+				Internal: iface.Flags&net.FlagLoopback != 0,
+				CIDR:     fmt.Sprintf("%s/%d", ipNet.IP, ones),
+			}
+			if ipNet.IP.To4() == nil {
+				scope := 0
+				if ipNet.IP.IsLinkLocalUnicast() {
+					scope = iface.Index
+				}
+				entry.Family, entry.ScopeID = "IPv6", &scope
+			}
+			if len(result[iface.Name]) == 0 {
+				names = append(names, iface.Name)
+			}
+			result[iface.Name] = append(result[iface.Name], entry)
+		}
+	}
+	return names, result, nil
+}
 
-	for _, name := range notImplList {
-		_ = _os.Set(name, notImplemented(name))
+// osConstants is os.constants: signal numbers, errno values and priorities.
+func osConstants(vm *Runtime) *goja.Object {
+	signals := vm.NewObject()
+	for sig := unix.Signal(1); sig < 32; sig++ {
+		if name := unix.SignalName(sig); name != "" {
+			_ = signals.Set(name, int(sig))
+		}
+	}
+	errno := vm.NewObject()
+	for e := syscall.Errno(1); e < 134; e++ {
+		if name := unix.ErrnoName(e); name != "" {
+			_ = errno.Set(name, int(e))
+		}
 	}
+	priority := vm.NewObject()
+	_ = priority.Set("PRIORITY_LOW", 19)
+	_ = priority.Set("PRIORITY_BELOW_NORMAL", 10)
+	_ = priority.Set("PRIORITY_NORMAL", 0)
+	_ = priority.Set("PRIORITY_ABOVE_NORMAL", -7)
+	_ = priority.Set("PRIORITY_HIGH", -14)
+	_ = priority.Set("PRIORITY_HIGHEST", -20)
 
+	constants := vm.NewObject()
+	_ = constants.Set("signals", signals)
+	_ = constants.Set("errno", errno)
+	_ = constants.Set("priority", priority)
+	return constants
 }
@@ -2,149 +2,386 @@ package runtime
 
 import (
 	"fmt"
+	"os"
 	"path/filepath"
 	"strings"
 
+	"github.com/bmatcuk/doublestar/v4"
 	"github.com/dop251/goja"
 )
 
-func Path(vm *goja.Runtime, module *goja.Object) {
+// Path is node's path module, for POSIX paths. The functions follow node's
+// lib/path.js to the letter, so that edge cases like trailing slashes and
+// dotfiles come out the same.
+func Path(vm *Runtime, module *goja.Object) {
 	_path := module.Get("exports").(*goja.Object)
 
+	_ = _path.Set("sep", "/")
+	_ = _path.Set("delimiter", ":")
+	_ = _path.Set("posix", _path)
+
 	// path.resolve(...paths)
 	_ = _path.Set("resolve", func(call goja.FunctionCall) goja.Value {
-		parts := []string{}
-		for _, arg := range call.Arguments {
-			parts = append(parts, arg.String())
+		paths := make([]string, len(call.Arguments))
+		for i := range call.Arguments {
+			paths[i] = stringArg(vm, call, i, fmt.Sprintf("paths[%d]", i))
 		}
-		return vm.ToValue(filepath.Join(parts...))
+		return vm.ToValue(resolvePath(paths...))
 	})
 
 	// path.normalize(path)
 	_ = _path.Set("normalize", func(call goja.FunctionCall) goja.Value {
-		if len(call.Arguments) == 0 {
-			return vm.ToValue("")
-		}
-		return vm.ToValue(filepath.Clean(call.Arguments[0].String()))
+		return vm.ToValue(normalizePath(stringArg(vm, call, 0, "path")))
 	})
 
 	// path.isAbsolute(path)
 	_ = _path.Set("isAbsolute", func(call goja.FunctionCall) goja.Value {
-		if len(call.Arguments) == 0 {
-			return vm.ToValue(false)
-		}
-		return vm.ToValue(filepath.IsAbs(call.Arguments[0].String()))
+		return vm.ToValue(strings.HasPrefix(stringArg(vm, call, 0, "path"), "/"))
 	})
 
 	// path.join(...paths)
 	_ = _path.Set("join", func(call goja.FunctionCall) goja.Value {
-		parts := []string{}
-		for _, arg := range call.Arguments {
-			parts = append(parts, arg.String())
+		var parts []string
+		for i := range call.Arguments {
+			if part := stringArg(vm, call, i, "path"); part != "" {
+				parts = append(parts, part)
+			}
+		}
+		if len(parts) == 0 {
+			return vm.ToValue(".")
 		}
-		return vm.ToValue(filepath.Join(parts...))
+		return vm.ToValue(normalizePath(strings.Join(parts, "/")))
 	})
 
 	// path.relative(from, to)
 	_ = _path.Set("relative", func(call goja.FunctionCall) goja.Value {
-		if len(call.Arguments) < 2 {
-			return vm.ToValue("path.relative: needs from and to")
-		}
-		from := call.Arguments[0].String()
-		to := call.Arguments[1].String()
-
-		// Convert both paths to absolute paths
-		absFrom, err1 := filepath.Abs(from)
-		absTo, err2 := filepath.Abs(to)
-		if err1 != nil || err2 != nil {
-			return vm.ToValue(fmt.Sprintf("path.relative error: %v", err1))
-		}
-
-		rel, err := filepath.Rel(absFrom, absTo)
-		if err != nil {
-			return vm.ToValue(fmt.Sprintf("path.relative error: %v", err))
+		from, to := stringArg(vm, call, 0, "from"), stringArg(vm, call, 1, "to")
+		from, to = resolvePath(from), resolvePath(to)
+		if from == to {
+			return vm.ToValue("")
 		}
+		// Both are absolute and clean, which is all filepath.Rel needs to agree with node.
+		rel, _ := filepath.Rel(from, to)
 		return vm.ToValue(rel)
 	})
 
-	// path.toNamespacedPath(path)
+	// path.toNamespacedPath(path) is only meaningful on Windows
 	_ = _path.Set("toNamespacedPath", func(call goja.FunctionCall) goja.Value {
-		if len(call.Arguments) == 0 {
-			return vm.ToValue("")
-		}
-		p := call.Arguments[0].String()
-		return vm.ToValue(filepath.Clean(p))
+		return call.Argument(0)
 	})
 
 	// path.dirname(path)
 	_ = _path.Set("dirname", func(call goja.FunctionCall) goja.Value {
-		if len(call.Arguments) == 0 {
-			return vm.ToValue("")
-		}
-		return vm.ToValue(filepath.Dir(call.Arguments[0].String()))
+		return vm.ToValue(dirnamePath(stringArg(vm, call, 0, "path")))
 	})
 
-	// path.basename(path)
+	// path.basename(path[, suffix])
 	_ = _path.Set("basename", func(call goja.FunctionCall) goja.Value {
-		if len(call.Arguments) == 0 {
-			return vm.ToValue("")
+		p := stringArg(vm, call, 0, "path")
+		suffix := ""
+		if !goja.IsUndefined(call.Argument(1)) {
+			suffix = stringArg(vm, call, 1, "suffix")
 		}
-		return vm.ToValue(filepath.Base(call.Arguments[0].String()))
+		return vm.ToValue(basenamePath(p, suffix))
 	})
 
 	// path.extname(path)
 	_ = _path.Set("extname", func(call goja.FunctionCall) goja.Value {
-		if len(call.Arguments) == 0 {
-			return vm.ToValue("")
-		}
-		return vm.ToValue(filepath.Ext(call.Arguments[0].String()))
+		p := stringArg(vm, call, 0, "path")
+		parts := splitPath(p, 0)
+		return vm.ToValue(parts.ext(p))
 	})
 
 	// path.parse(path)
 	_ = _path.Set("parse", func(call goja.FunctionCall) goja.Value {
-		if len(call.Arguments) == 0 {
-			return vm.ToValue(nil)
-		}
-		p := call.Arguments[0].String()
-		root := "/"
-		p = filepath.Clean(p)
-		if !strings.HasPrefix(p, "/") {
-			root = ""
-		}
-		dir := filepath.Dir(p)
-		base := filepath.Base(p)
-		ext := filepath.Ext(p)
-		name := strings.TrimSuffix(base, ext)
-
+		p := stringArg(vm, call, 0, "path")
 		result := vm.NewObject()
+		root, dir, base, ext, name := parsePath(p)
 		SetObjProperty(result, "root", root)
 		SetObjProperty(result, "dir", dir)
 		SetObjProperty(result, "base", base)
-		if ext == "." {
-			SetObjProperty(result, "ext", "")
-		} else {
-			SetObjProperty(result, "ext", ext)
-		}
+		SetObjProperty(result, "ext", ext)
 		SetObjProperty(result, "name", name)
 		return result
 	})
 
+	// path.format(pathObject)
+	_ = _path.Set("format", func(call goja.FunctionCall) goja.Value {
+		obj, ok := call.Argument(0).(*goja.Object)
+		if !ok {
+			panic(invalidArgType(vm, "pathObject", "object", call.Argument(0)))
+		}
+		field := func(name string) string {
+			if v := obj.Get(name); v != nil && v.ToBoolean() {
+				return v.String()
+			}
+			return ""
+		}
+		root, dir, base := field("root"), field("dir"), field("base")
+		if dir == "" {
+			dir = root
+		}
+		if base == "" {
+			base = field("name")
+			if ext := field("ext"); ext != "" && !strings.HasPrefix(ext, ".") {
+				base += "." + ext
+			} else {
+				base += ext
+			}
+		}
+		switch {
+		case dir == "":
+			return vm.ToValue(base)
+		case dir == root:
+			return vm.ToValue(dir + base)
+		default:
+			return vm.ToValue(dir + "/" + base)
+		}
+	})
+
 	// path.matchesGlob(path, pattern)
 	_ = _path.Set("matchesGlob", func(call goja.FunctionCall) goja.Value {
-		if len(call.Arguments) < 2 {
-			return vm.ToValue("path.matchesGlob: missing path or pattern")
-		}
-		p := call.Arguments[0].String()
-		pattern := call.Arguments[1].String()
-		match, err := filepath.Match(pattern, filepath.Base(p))
+		p, pattern := stringArg(vm, call, 0, "path"), stringArg(vm, call, 1, "pattern")
+		match, err := doublestar.Match(pattern, p)
 		if err != nil {
-			return vm.ToValue(fmt.Sprintf("glob error: %v", err))
+			panic(vm.NewTypeError(fmt.Sprintf("invalid glob %q: %v", pattern, err)))
 		}
 		return vm.ToValue(match)
 	})
+}
 
-	// path.format(pathObject)
-	_ = _path.Set("format", func(call goja.FunctionCall) goja.Value {
-		return vm.ToValue("Error: path.format not implemented")
-	})
+// normalizeString resolves the "." and ".." segments of a path and drops
+// empty ones. allowAboveRoot keeps the ".." that go above the start.
+func normalizeString(path string, allowAboveRoot bool) string {
+	res := ""
+	lastSegmentLength, lastSlash, dots := 0, -1, 0
+	var code byte
+	for i := 0; i <= len(path); i++ {
+		if i < len(path) {
+			code = path[i]
+		} else if code == '/' {
+			break
+		} else {
+			code = '/'
+		}
+
+		switch {
+		case code == '/':
+			switch {
+			case lastSlash == i-1 || dots == 1:
+				// Empty and "." segments
+			case dots == 2:
+				if len(res) < 2 || lastSegmentLength != 2 || !strings.HasSuffix(res, "..") {
+					if len(res) > 2 {
+						if idx := strings.LastIndexByte(res, '/'); idx == -1 {
+							res, lastSegmentLength = "", 0
+						} else {
+							res = res[:idx]
+							lastSegmentLength = len(res) - 1 - strings.LastIndexByte(res, '/')
+						}
+						lastSlash, dots = i, 0
+						continue
+					} else if len(res) != 0 {
+						res, lastSegmentLength = "", 0
+						lastSlash, dots = i, 0
+						continue
+					}
+				}
+				if allowAboveRoot {
+					if len(res) > 0 {
+						res += "/.."
+					} else {
+						res = ".."
+					}
+					lastSegmentLength = 2
+				}
+			default:
+				if len(res) > 0 {
+					res += "/" + path[lastSlash+1:i]
+				} else {
+					res = path[lastSlash+1 : i]
+				}
+				lastSegmentLength = i - lastSlash - 1
+			}
+			lastSlash, dots = i, 0
+		case code == '.' && dots != -1:
+			dots++
+		default:
+			dots = -1
+		}
+	}
+	return res
+}
+
+// resolvePath resolves paths right to left into an absolute path, starting
+// from the working directory.
+func resolvePath(paths ...string) string {
+	resolved, absolute := "", false
+	for i := len(paths) - 1; i >= -1 && !absolute; i-- {
+		var p string
+		if i >= 0 {
+			p = paths[i]
+		} else {
+			p, _ = os.Getwd()
+		}
+		if p == "" {
+			continue
+		}
+		resolved = p + "/" + resolved
+		absolute = p[0] == '/'
+	}
+	resolved = normalizeString(resolved, !absolute)
+	if absolute {
+		return "/" + resolved
+	}
+	if resolved == "" {
+		return "."
+	}
+	return resolved
+}
+
+func normalizePath(p string) string {
+	if p == "" {
+		return "."
+	}
+	absolute := p[0] == '/'
+	trailingSeparator := p[len(p)-1] == '/'
+	p = normalizeString(p, !absolute)
+	if p == "" {
+		if absolute {
+			return "/"
+		}
+		if trailingSeparator {
+			return "./"
+		}
+		return "."
+	}
+	if trailingSeparator {
+		p += "/"
+	}
+	if absolute {
+		return "/" + p
+	}
+	return p
+}
+
+func dirnamePath(p string) string {
+	if p == "" {
+		return "."
+	}
+	hasRoot := p[0] == '/'
+	end, matchedSlash := -1, true
+	for i := len(p) - 1; i >= 1; i-- {
+		if p[i] == '/' {
+			if !matchedSlash {
+				end = i
+				break
+			}
+		} else {
+			matchedSlash = false
+		}
+	}
+	if end == -1 {
+		if hasRoot {
+			return "/"
+		}
+		return "."
+	}
+	if hasRoot && end == 1 {
+		return "//"
+	}
+	return p[:end]
+}
+
+// basenamePath returns the last segment of p, without suffix unless the
+// segment is nothing but the suffix.
+func basenamePath(p, suffix string) string {
+	if suffix != "" && suffix == p {
+		return ""
+	}
+	trimmed := strings.TrimRight(p, "/")
+	base := trimmed[strings.LastIndexByte(trimmed, '/')+1:]
+	if suffix != "" && base != suffix {
+		base = strings.TrimSuffix(base, suffix)
+	}
+	return base
+}
+
+// pathParts locates the last segment of a path and its extension.
+type pathParts struct {
+	startPart, startDot, end, preDotState int
+}
+
+// splitPath scans p backwards from its end down to start, like node's
+// extname and parse do.
+func splitPath(p string, start int) pathParts {
+	parts := pathParts{startDot: -1, end: -1}
+	matchedSlash := true
+	for i := len(p) - 1; i >= start; i-- {
+		code := p[i]
+		if code == '/' {
+			if !matchedSlash {
+				parts.startPart = i + 1
+				break
+			}
+			continue
+		}
+		if parts.end == -1 {
+			matchedSlash = false
+			parts.end = i + 1
+		}
+		if code == '.' {
+			if parts.startDot == -1 {
+				parts.startDot = i
+			} else if parts.preDotState != 1 {
+				parts.preDotState = 1
+			}
+		} else if parts.startDot != -1 {
+			parts.preDotState = -1
+		}
+	}
+	return parts
+}
+
+// hasExt is false for segments without a dot, dotfiles and "..".
+func (parts pathParts) hasExt() bool {
+	return parts.startDot != -1 && parts.end != -1 && parts.preDotState != 0 &&
+		!(parts.preDotState == 1 && parts.startDot == parts.end-1 && parts.startDot == parts.startPart+1)
+}
+
+func (parts pathParts) ext(p string) string {
+	if !parts.hasExt() {
+		return ""
+	}
+	return p[parts.startDot:parts.end]
+}
+
+func parsePath(p string) (root, dir, base, ext, name string) {
+	if p == "" {
+		return
+	}
+	absolute := p[0] == '/'
+	start := 0
+	if absolute {
+		root, start = "/", 1
+	}
+	parts := splitPath(p, start)
+	if parts.end != -1 {
+		from := parts.startPart
+		if from == 0 && absolute {
+			from = 1
+		}
+		base = p[from:parts.end]
+		if parts.hasExt() {
+			name, ext = p[from:parts.startDot], p[parts.startDot:parts.end]
+		} else {
+			name = base
+		}
+	}
+	if parts.startPart > 0 {
+		dir = p[:parts.startPart-1]
+	} else if absolute {
+		dir = "/"
+	}
+	return
 }
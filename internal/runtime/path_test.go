@@ -1,6 +1,7 @@
 package runtime
 
 import (
+	"os"
 	"testing"
 
 	"github.com/dop251/goja"
@@ -24,21 +25,23 @@ func TestPathModule(t *testing.T) {
 			script := `path.resolve();`
 			val, err := vm.RunString(script)
 			require.NoError(t, err)
-			require.Equal(t, "", val.String()) // Corrected expectation
+			wd, _ := os.Getwd()
+			require.Equal(t, wd, val.String())
 		})
 
 		t.Run("empty arguments", func(t *testing.T) {
 			script := `path.resolve('', '', '');`
 			val, err := vm.RunString(script)
 			require.NoError(t, err)
-			require.Equal(t, "", val.String()) // Corrected expectation
+			wd, _ := os.Getwd()
+			require.Equal(t, wd, val.String())
 		})
 
 		t.Run("absolute path in middle", func(t *testing.T) {
 			script := `path.resolve('/foo', 'bar', '/baz', 'qux');`
 			val, err := vm.RunString(script)
 			require.NoError(t, err)
-			require.Equal(t, "/foo/bar/baz/qux", val.String()) // Corrected expectation
+			require.Equal(t, "/baz/qux", val.String())
 		})
 
 		t.Run("with dots", func(t *testing.T) {
@@ -114,21 +117,21 @@ func TestPathModule(t *testing.T) {
 			script := `path.join();`
 			val, err := vm.RunString(script)
 			require.NoError(t, err)
-			require.Equal(t, "", val.String()) // Corrected expectation
+			require.Equal(t, ".", val.String())
 		})
 
 		t.Run("empty arguments", func(t *testing.T) {
 			script := `path.join('', '', '');`
 			val, err := vm.RunString(script)
 			require.NoError(t, err)
-			require.Equal(t, "", val.String()) // Corrected expectation
+			require.Equal(t, ".", val.String())
 		})
 
 		t.Run("mixed absolute and relative", func(t *testing.T) {
 			script := `path.join('/foo', 'bar', '/baz', 'qux');`
 			val, err := vm.RunString(script)
 			require.NoError(t, err)
-			require.Equal(t, "/foo/bar/baz/qux", val.String()) // Corrected expectation
+			require.Equal(t, "/foo/bar/baz/qux", val.String())
 		})
 	})
 
@@ -144,7 +147,7 @@ func TestPathModule(t *testing.T) {
 			script := `path.relative('/a/b', '/a/b');`
 			val, err := vm.RunString(script)
 			require.NoError(t, err)
-			require.Equal(t, ".", val.String())
+			require.Equal(t, "", val.String())
 		})
 
 		t.Run("from parent to child", func(t *testing.T) {
@@ -163,9 +166,8 @@ func TestPathModule(t *testing.T) {
 
 		t.Run("missing arguments", func(t *testing.T) {
 			script := `path.relative('/a');`
-			val, err := vm.RunString(script)
-			require.NoError(t, err)
-			require.Equal(t, "path.relative: needs from and to", val.String())
+			_, err := vm.RunString(script)
+			require.ErrorContains(t, err, `The "to" argument must be of type string. Received undefined`)
 		})
 	})
 
@@ -174,14 +176,14 @@ func TestPathModule(t *testing.T) {
 			script := `path.toNamespacedPath();`
 			val, err := vm.RunString(script)
 			require.NoError(t, err)
-			require.Equal(t, "", val.String())
+			require.True(t, goja.IsUndefined(val))
 		})
 
 		t.Run("empty string", func(t *testing.T) {
 			script := `path.toNamespacedPath('');`
 			val, err := vm.RunString(script)
 			require.NoError(t, err)
-			require.Equal(t, ".", val.String())
+			require.Equal(t, "", val.String())
 		})
 
 		t.Run("regular path", func(t *testing.T) {
@@ -195,7 +197,7 @@ func TestPathModule(t *testing.T) {
 			script := `path.toNamespacedPath('/foo/./bar/../baz');`
 			val, err := vm.RunString(script)
 			require.NoError(t, err)
-			require.Equal(t, "/foo/baz", val.String())
+			require.Equal(t, "/foo/./bar/../baz", val.String())
 		})
 	})
 
@@ -209,9 +211,8 @@ func TestPathModule(t *testing.T) {
 
 		t.Run("no arguments", func(t *testing.T) {
 			script := `path.dirname();`
-			val, err := vm.RunString(script)
-			require.NoError(t, err)
-			require.Equal(t, "", val.String())
+			_, err := vm.RunString(script)
+			require.ErrorContains(t, err, `The "path" argument must be of type string. Received undefined`)
 		})
 
 		t.Run("empty string", func(t *testing.T) {
@@ -253,23 +254,22 @@ func TestPathModule(t *testing.T) {
 
 		t.Run("no arguments", func(t *testing.T) {
 			script := `path.basename();`
-			val, err := vm.RunString(script)
-			require.NoError(t, err)
-			require.Equal(t, "", val.String())
+			_, err := vm.RunString(script)
+			require.ErrorContains(t, err, `The "path" argument must be of type string. Received undefined`)
 		})
 
 		t.Run("empty string", func(t *testing.T) {
 			script := `path.basename('');`
 			val, err := vm.RunString(script)
 			require.NoError(t, err)
-			require.Equal(t, ".", val.String())
+			require.Equal(t, "", val.String())
 		})
 
 		t.Run("root path", func(t *testing.T) {
 			script := `path.basename('/');`
 			val, err := vm.RunString(script)
 			require.NoError(t, err)
-			require.Equal(t, "/", val.String())
+			require.Equal(t, "", val.String())
 		})
 
 		t.Run("path ending with slash", func(t *testing.T) {
@@ -297,9 +297,8 @@ func TestPathModule(t *testing.T) {
 
 		t.Run("no arguments", func(t *testing.T) {
 			script := `path.extname();`
-			val, err := vm.RunString(script)
-			require.NoError(t, err)
-			require.Equal(t, "", val.String())
+			_, err := vm.RunString(script)
+			require.ErrorContains(t, err, `The "path" argument must be of type string. Received undefined`)
 		})
 
 		t.Run("empty string", func(t *testing.T) {
@@ -327,7 +326,7 @@ func TestPathModule(t *testing.T) {
 			script := `path.extname('.bashrc');`
 			val, err := vm.RunString(script)
 			require.NoError(t, err)
-			require.Equal(t, ".bashrc", val.String()) // Corrected expectation
+			require.Equal(t, "", val.String())
 		})
 
 		t.Run("path with directory", func(t *testing.T) {
@@ -343,7 +342,7 @@ func TestPathModule(t *testing.T) {
 			script := `path.parse('/home/user/dir/file.txt');`
 			val, err := vm.RunString(script)
 			require.NoError(t, err)
-			obj := val.ToObject(vm)
+			obj := val.ToObject(vm.Runtime)
 			require.Equal(t, "/", obj.Get("root").String())
 			require.Equal(t, "/home/user/dir", obj.Get("dir").String())
 			require.Equal(t, "file.txt", obj.Get("base").String())
@@ -353,19 +352,18 @@ func TestPathModule(t *testing.T) {
 
 		t.Run("no arguments", func(t *testing.T) {
 			script := `path.parse();`
-			val, err := vm.RunString(script)
-			require.NoError(t, err)
-			require.True(t, goja.IsNull(val)) // Corrected expectation: vm.ToValue(nil) is null, not undefined
+			_, err := vm.RunString(script)
+			require.ErrorContains(t, err, `The "path" argument must be of type string. Received undefined`)
 		})
 
 		t.Run("empty string", func(t *testing.T) {
 			script := `path.parse('');`
 			val, err := vm.RunString(script)
 			require.NoError(t, err)
-			obj := val.ToObject(vm)
+			obj := val.ToObject(vm.Runtime)
 			require.Equal(t, "", obj.Get("root").String())
-			require.Equal(t, ".", obj.Get("dir").String()) // Corrected expectation
-			require.Equal(t, ".", obj.Get("base").String())
+			require.Equal(t, "", obj.Get("dir").String())
+			require.Equal(t, "", obj.Get("base").String())
 			require.Equal(t, "", obj.Get("ext").String())
 			require.Equal(t, "", obj.Get("name").String())
 		})
@@ -374,21 +372,21 @@ func TestPathModule(t *testing.T) {
 			script := `path.parse('/');`
 			val, err := vm.RunString(script)
 			require.NoError(t, err)
-			obj := val.ToObject(vm)
+			obj := val.ToObject(vm.Runtime)
 			require.Equal(t, "/", obj.Get("root").String())
 			require.Equal(t, "/", obj.Get("dir").String())
-			require.Equal(t, "/", obj.Get("base").String())
+			require.Equal(t, "", obj.Get("base").String())
 			require.Equal(t, "", obj.Get("ext").String())
-			require.Equal(t, "/", obj.Get("name").String())
+			require.Equal(t, "", obj.Get("name").String())
 		})
 
 		t.Run("relative path", func(t *testing.T) {
 			script := `path.parse('file.txt');`
 			val, err := vm.RunString(script)
 			require.NoError(t, err)
-			obj := val.ToObject(vm)
+			obj := val.ToObject(vm.Runtime)
 			require.Equal(t, "", obj.Get("root").String())
-			require.Equal(t, ".", obj.Get("dir").String())
+			require.Equal(t, "", obj.Get("dir").String())
 			require.Equal(t, "file.txt", obj.Get("base").String())
 			require.Equal(t, ".txt", obj.Get("ext").String())
 			require.Equal(t, "file", obj.Get("name").String())
@@ -398,7 +396,7 @@ func TestPathModule(t *testing.T) {
 			script := `path.parse('/path/to/file');`
 			val, err := vm.RunString(script)
 			require.NoError(t, err)
-			obj := val.ToObject(vm)
+			obj := val.ToObject(vm.Runtime)
 			require.Equal(t, "/", obj.Get("root").String())
 			require.Equal(t, "/path/to", obj.Get("dir").String())
 			require.Equal(t, "file", obj.Get("base").String())
@@ -410,7 +408,7 @@ func TestPathModule(t *testing.T) {
 			script := `path.parse('/path/to/file.tar.gz');`
 			val, err := vm.RunString(script)
 			require.NoError(t, err)
-			obj := val.ToObject(vm)
+			obj := val.ToObject(vm.Runtime)
 			require.Equal(t, "/", obj.Get("root").String())
 			require.Equal(t, "/path/to", obj.Get("dir").String())
 			require.Equal(t, "file.tar.gz", obj.Get("base").String())
@@ -448,25 +446,44 @@ func TestPathModule(t *testing.T) {
 			require.True(t, val.ToBoolean())
 		})
 
-		t.Run("path is directory", func(t *testing.T) {
-			script := `path.matchesGlob('/foo/bar', 'bar');`
+		t.Run("pattern must match the whole path", func(t *testing.T) {
+			script := `[path.matchesGlob('/foo/bar', 'bar'), path.matchesGlob('/foo/bar', '**/bar')];`
 			val, err := vm.RunString(script)
 			require.NoError(t, err)
-			require.True(t, val.ToBoolean())
+			require.Equal(t, []interface{}{false, true}, val.Export())
 		})
 
 		t.Run("missing arguments", func(t *testing.T) {
 			script := `path.matchesGlob('foo.txt');`
-			val, err := vm.RunString(script)
-			require.NoError(t, err)
-			require.Equal(t, "path.matchesGlob: missing path or pattern", val.String())
+			_, err := vm.RunString(script)
+			require.ErrorContains(t, err, `The "pattern" argument must be of type string. Received undefined`)
 		})
 	})
 
 	t.Run("format", func(t *testing.T) {
-		script := `path.format({});`
-		val, err := vm.RunString(script)
-		require.NoError(t, err)
-		require.Equal(t, "Error: path.format not implemented", val.String())
+		t.Run("dir and base", func(t *testing.T) {
+			script := `path.format({ root: '/ignored', dir: '/home/user', base: 'file.txt' });`
+			val, err := vm.RunString(script)
+			require.NoError(t, err)
+			require.Equal(t, "/home/user/file.txt", val.String())
+		})
+
+		t.Run("root, name and ext", func(t *testing.T) {
+			script := `[path.format({ root: '/', name: 'file', ext: '.txt' }), path.format({ name: 'a', ext: 'js' })];`
+			val, err := vm.RunString(script)
+			require.NoError(t, err)
+			require.Equal(t, []interface{}{"/file.txt", "a.js"}, val.Export())
+		})
+
+		t.Run("empty object", func(t *testing.T) {
+			val, err := vm.RunString(`path.format({});`)
+			require.NoError(t, err)
+			require.Equal(t, "", val.String())
+		})
+
+		t.Run("not an object", func(t *testing.T) {
+			_, err := vm.RunString(`path.format('/a/b');`)
+			require.ErrorContains(t, err, `The "pathObject" argument must be of type object. Received type string ('/a/b')`)
+		})
 	})
 }
@@ -4,31 +4,122 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/dop251/goja"
 )
 
 // CommandConfig represents the configuration for a command
 type CommandConfig struct {
-	Run   goja.Callable `json:"-"`
-	Short string        `json:"short"`
-	Long  string        `json:"long"`
-	Alias []string      `json:"alias"`
-	Flags []CommandFlag `json:"flags"`
+	Run         goja.Callable    `json:"-"`
+	Name        string           `json:"name"`
+	Short       string           `json:"short"`
+	Long        string           `json:"long"`
+	Alias       []string         `json:"alias"`
+	Flags       []CommandFlag    `json:"flags"`
+	Permissions *Permissions     `json:"permissions"`
+	Subcommands []*CommandConfig `json:"subcommands"`
+	Args        *CommandArgs     `json:"args"`
 }
 
 type CommandFlag struct {
-	Name        string      `json:"name"`
-	Type        string      `json:"type"`
-	Value       interface{} `json:"value"`
-	Shorthand   string      `json:"shorthand"`
-	Description string      `json:"description"`
+	Name        string        `json:"name"`
+	Type        string        `json:"type"`
+	Value       interface{}   `json:"value"`
+	Shorthand   string        `json:"shorthand"`
+	Description string        `json:"description"`
+	Choices     []string      `json:"choices"`
+	Required    bool          `json:"required"`
+	Completions []string      `json:"completions"`
+	Complete    goja.Callable `json:"-"`
 }
 
-// CommandStorage stores the command configuration globally
-var CommandStorage CommandConfig
+// DefaultValue converts the declared default of the flag to its Go type: a
+// string for string and enum flags, bool, int64, float64, a non-nil []string
+// or a time.Duration, which is given as a Go duration string ("1m30s") or in
+// milliseconds.
+func (f CommandFlag) DefaultValue() (interface{}, error) {
+	invalid := fmt.Errorf("flag %q: invalid default value %v for type %s", f.Name, f.Value, f.Type)
 
-func Prasmoid(vm *goja.Runtime, module *goja.Object) {
+	switch f.Type {
+	case "string", "enum":
+		if f.Value == nil {
+			return "", nil
+		}
+		return fmt.Sprintf("%v", f.Value), nil
+	case "bool":
+		def, _ := f.Value.(bool)
+		return def, nil
+	case "int":
+		switch v := f.Value.(type) {
+		case nil:
+			return int64(0), nil
+		case int64:
+			return v, nil
+		case float64:
+			if v != float64(int64(v)) {
+				return nil, invalid
+			}
+			return int64(v), nil
+		}
+	case "float":
+		switch v := f.Value.(type) {
+		case nil:
+			return float64(0), nil
+		case int64:
+			return float64(v), nil
+		case float64:
+			return v, nil
+		}
+	case "stringArray":
+		switch v := f.Value.(type) {
+		case nil:
+			return []string{}, nil
+		case []interface{}:
+			def := make([]string, len(v))
+			for i, item := range v {
+				def[i] = fmt.Sprintf("%v", item)
+			}
+			return def, nil
+		case string:
+			return []string{v}, nil
+		}
+	case "duration":
+		switch v := f.Value.(type) {
+		case nil:
+			return time.Duration(0), nil
+		case string:
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return nil, invalid
+			}
+			return d, nil
+		case int64:
+			return time.Duration(v) * time.Millisecond, nil
+		case float64:
+			return time.Duration(v * float64(time.Millisecond)), nil
+		}
+	default:
+		return nil, fmt.Errorf("unsupported flag type: %s", f.Type)
+	}
+	return nil, invalid
+}
+
+// CommandArgs describes the positional arguments of a command. Max is -1 when unbounded.
+type CommandArgs struct {
+	Min         int           `json:"min"`
+	Max         int           `json:"max"`
+	Names       []string      `json:"names"`
+	Completions []string      `json:"completions"`
+	Complete    goja.Callable `json:"-"`
+}
+
+// Commands returns the commands registered by the scripts that ran in vm.
+func Commands(vm *Runtime) []*CommandConfig {
+	return vm.commands
+}
+
+func Prasmoid(vm *Runtime, module *goja.Object) {
 	exports := module.Get("exports").(*goja.Object)
 
 	_ = exports.Set("getMetadata", func(call goja.FunctionCall) goja.Value {
@@ -45,100 +136,187 @@ func Prasmoid(vm *goja.Runtime, module *goja.Object) {
 		return vm.ToValue(data)
 	})
 
+	registerProject(vm, exports)
+
 	_ = exports.Set("Command", func(call goja.FunctionCall) goja.Value {
 		if len(call.Arguments) != 1 {
 			panic("prasmoid.Command: exactly 1 argument required")
 		}
 
-		// Check if it's an object
-		arg := call.Argument(0)
-		if arg == nil || goja.IsUndefined(arg) || goja.IsNull(arg) {
-			panic("prasmoid.Command: argument must be a JS object")
-		}
+		config := parseCommand(vm, call.Argument(0), "prasmoid.Command")
 
-		_, ok := goja.AssertFunction(arg)
-		if ok {
-			panic("prasmoid.Command: expected object, got function")
+		// A sandboxed script gets the permissions of every command it declares
+		// from here on; each command is narrowed down to its own when it runs.
+		if perms := GetPermissions(vm); perms != nil && !perms.AllowAll {
+			SetPermissions(vm, perms.Merge(config.declaredPermissions()))
 		}
 
-		cmdObj := arg.ToObject(vm)
-		if cmdObj == nil {
-			panic("prasmoid.Command: failed to convert argument to object")
-		}
+		vm.commands = append(vm.commands, config)
+		return nil
+	})
+}
+
+// parseCommand reads a command object, including its subcommands. prefix names
+// the offending command in error messages.
+func parseCommand(vm *Runtime, arg goja.Value, prefix string) *CommandConfig {
+	// Check if it's an object
+	if arg == nil || goja.IsUndefined(arg) || goja.IsNull(arg) {
+		panic(prefix + ": argument must be a JS object")
+	}
+
+	_, ok := goja.AssertFunction(arg)
+	if ok {
+		panic(prefix + ": expected object, got function")
+	}
+
+	cmdObj := arg.ToObject(vm.Runtime)
+	if cmdObj == nil {
+		panic(prefix + ": failed to convert argument to object")
+	}
+
+	config := &CommandConfig{}
 
-		runVal := cmdObj.Get("run")
-		if goja.IsUndefined(runVal) || runVal == nil {
-			panic("prasmoid.Command: missing 'run' function")
+	if nameVal := cmdObj.Get("name"); !goja.IsUndefined(nameVal) && nameVal != nil {
+		config.Name = nameVal.String()
+	}
+
+	// Subcommands are declared inline and need a name to be addressable.
+	if subVal := cmdObj.Get("subcommands"); !goja.IsUndefined(subVal) && subVal != nil {
+		var subs []goja.Value
+		if err := vm.ExportTo(subVal, &subs); err != nil {
+			panic(prefix + ": 'subcommands' must be an array of command objects")
 		}
+		for i, sub := range subs {
+			subConfig := parseCommand(vm, sub, fmt.Sprintf("%s: subcommands[%d]", prefix, i))
+			if subConfig.Name == "" {
+				panic(fmt.Sprintf("%s: subcommands[%d] is missing a 'name'", prefix, i))
+			}
+			config.Subcommands = append(config.Subcommands, subConfig)
+		}
+	}
 
+	// A command that only groups subcommands doesn't need to run anything.
+	runVal := cmdObj.Get("run")
+	if goja.IsUndefined(runVal) || runVal == nil {
+		if len(config.Subcommands) == 0 {
+			panic(prefix + ": missing 'run' function")
+		}
+	} else {
 		runFunc, ok := goja.AssertFunction(runVal)
 		if !ok {
-			panic("prasmoid.Command: 'run' must be a function")
+			panic(prefix + ": 'run' must be a function")
 		}
+		config.Run = runFunc
+	}
 
-		config := CommandConfig{Run: runFunc}
+	// Optional fields
+	if shortVal := cmdObj.Get("short"); !goja.IsUndefined(shortVal) && shortVal != nil {
+		config.Short = shortVal.String()
+	}
 
-		// Optional fields
-		if shortVal := cmdObj.Get("short"); !goja.IsUndefined(shortVal) && shortVal != nil {
-			config.Short = shortVal.String()
-		}
+	if longVal := cmdObj.Get("long"); !goja.IsUndefined(longVal) && longVal != nil {
+		config.Long = longVal.String()
+	}
 
-		if longVal := cmdObj.Get("long"); !goja.IsUndefined(longVal) && longVal != nil {
-			config.Long = longVal.String()
+	if aliasVal := cmdObj.Get("alias"); !goja.IsUndefined(aliasVal) && aliasVal != nil {
+		if aliasArr, ok := aliasVal.Export().([]interface{}); ok {
+			for _, a := range aliasArr {
+				if str, ok := a.(string); ok {
+					config.Alias = append(config.Alias, str)
+				}
+			}
 		}
+	}
+
+	if flagsVal := cmdObj.Get("flags"); !goja.IsUndefined(flagsVal) && flagsVal != nil {
+		var flagArr []goja.Value
+		if err := vm.ExportTo(flagsVal, &flagArr); err == nil {
+			for _, f := range flagArr {
+				flagObj, ok := f.(*goja.Object)
+				if !ok || flagObj.ClassName() != "Object" {
+					continue
+				}
+				flagMap := flagObj.Export().(map[string]interface{})
+
+				typ := asString(flagMap["type"])
+				if typ == "boolean" {
+					typ = "bool"
+				}
+
+				// The default may be given as `default` or `value`
+				val := flagMap["default"]
+				if val == nil {
+					val = flagMap["value"]
+				}
 
-		if aliasVal := cmdObj.Get("alias"); !goja.IsUndefined(aliasVal) && aliasVal != nil {
-			if aliasArr, ok := aliasVal.Export().([]interface{}); ok {
-				for _, a := range aliasArr {
-					if str, ok := a.(string); ok {
-						config.Alias = append(config.Alias, str)
+				// Handle default values if value is not provided
+				if val == nil {
+					switch typ {
+					case "bool":
+						val = false
+					case "string", "enum":
+						val = ""
 					}
 				}
-			}
-		}
 
-		if flagsVal := cmdObj.Get("flags"); !goja.IsUndefined(flagsVal) && flagsVal != nil {
-			if flagArr, ok := flagsVal.Export().([]interface{}); ok {
-				for _, f := range flagArr {
-					if flagMap, ok := f.(map[string]interface{}); ok {
-						typ := asString(flagMap["type"])
-						val := flagMap["value"] // Get the value once
-
-						// Handle default values if value is not provided
-						if val == nil {
-							switch typ {
-							case "bool":
-								val = false
-							case "string":
-								val = ""
-							}
-						}
-
-						// Type check for boolean flags
-						if typ == "bool" {
-							switch val.(type) { // Use the potentially defaulted 'val'
-							case bool:
-								// OK
-							default:
-								panic("non-bool value not allowed in boolean flag")
-							}
-						}
-
-						config.Flags = append(config.Flags, CommandFlag{
-							Name:        asString(flagMap["name"]),
-							Type:        typ,
-							Value:       val,
-							Shorthand:   asString(flagMap["shorthand"]),
-							Description: asString(flagMap["description"]),
-						})
+				// Type check for boolean flags
+				if typ == "bool" {
+					switch val.(type) { // Use the potentially defaulted 'val'
+					case bool:
+						// OK
+					default:
+						panic("non-bool value not allowed in boolean flag")
 					}
 				}
+
+				flag := CommandFlag{
+					Name:        asString(flagMap["name"]),
+					Type:        typ,
+					Value:       val,
+					Shorthand:   asString(flagMap["shorthand"]),
+					Description: asString(flagMap["description"]),
+					Choices:     asStrings(flagMap["choices"]),
+					Required:    flagMap["required"] == true,
+				}
+				flag.Completions, flag.Complete = parseCompletions(flagObj.Get("completions"))
+				config.Flags = append(config.Flags, flag)
 			}
 		}
+	}
 
-		CommandStorage = config
-		return nil
-	})
+	if argsVal := cmdObj.Get("args"); !goja.IsUndefined(argsVal) && argsVal != nil {
+		argsObj, ok := argsVal.(*goja.Object)
+		if !ok {
+			panic(prefix + ": 'args' must be an object like { min, max, names }")
+		}
+		args := &CommandArgs{Min: 0, Max: -1}
+		if min := argsObj.Get("min"); min != nil && !goja.IsUndefined(min) {
+			args.Min = int(min.ToInteger())
+		}
+		if max := argsObj.Get("max"); max != nil && !goja.IsUndefined(max) {
+			args.Max = int(max.ToInteger())
+		}
+		if args.Min < 0 || (args.Max >= 0 && args.Max < args.Min) {
+			panic(prefix + ": 'args.max' must not be less than 'args.min'")
+		}
+		if names := argsObj.Get("names"); names != nil && !goja.IsUndefined(names) {
+			args.Names = asStrings(names.Export())
+		}
+		args.Completions, args.Complete = parseCompletions(argsObj.Get("completions"))
+		config.Args = args
+	}
+
+	config.Permissions = parsePermissions(cmdObj.Get("permissions"))
+	return config
+}
+
+// declaredPermissions is the union of the permissions of the command and all its subcommands.
+func (c *CommandConfig) declaredPermissions() *Permissions {
+	perms := (&Permissions{}).Merge(c.Permissions)
+	for _, sub := range c.Subcommands {
+		perms = perms.Merge(sub.declaredPermissions())
+	}
+	return perms
 }
 
 func asString(val interface{}) string {
@@ -148,21 +326,45 @@ func asString(val interface{}) string {
 	return ""
 }
 
-// Get metadata from metadata.json
-func GetDataFromMetadata(key string) (string, error) {
+func asStrings(val interface{}) []string {
+	var out []string
+	if arr, ok := val.([]interface{}); ok {
+		for _, item := range arr {
+			if str, ok := item.(string); ok {
+				out = append(out, str)
+			}
+		}
+	}
+	return out
+}
+
+// parseCompletions reads shell completion values, declared either as a list
+// or as a function `(toComplete, args) => string[]`.
+func parseCompletions(val goja.Value) ([]string, goja.Callable) {
+	if val == nil || goja.IsUndefined(val) || goja.IsNull(val) {
+		return nil, nil
+	}
+	if fn, ok := goja.AssertFunction(val); ok {
+		return nil, fn
+	}
+	return asStrings(val.Export()), nil
+}
+
+// Get metadata from metadata.json. Values keep their JSON type, so e.g. Authors is a list.
+func GetDataFromMetadata(key string) (interface{}, error) {
 	data, err := os.ReadFile("metadata.json")
 	if err != nil {
-		return "", fmt.Errorf("metadata.json not found")
+		return nil, fmt.Errorf("metadata.json not found")
 	}
 	var meta map[string]interface{}
 	err = json.Unmarshal(data, &meta)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	if plugin, ok := meta["KPlugin"].(map[string]interface{}); ok {
-		if id, ok := plugin[key].(string); ok {
-			return id, nil
+		if value, ok := plugin[key]; ok && value != nil {
+			return value, nil
 		}
 	}
-	return "", fmt.Errorf("%s not found in metadata.json", key)
+	return nil, fmt.Errorf("%s not found in metadata.json", key)
 }
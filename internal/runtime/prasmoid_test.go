@@ -135,13 +135,16 @@ func TestPrasmoidModule(t *testing.T) {
 			require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "metadata.json"), []byte(metadataContent), 0644))
 		})
 
-		t.Run("key is not a string within KPlugin", func(t *testing.T) {
-			require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "metadata.json"), []byte(`{"KPlugin": {"Id": 123}}`), 0644))
+		t.Run("non-string values keep their type", func(t *testing.T) {
+			require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "metadata.json"), []byte(`{"KPlugin": {"Id": 123, "Authors": [{"Name": "PRAS"}]}}`), 0644))
 
-			script := `prasmoid.getMetadata('Id');`
-			val, err := vm.RunString(script)
+			val, err := vm.RunString(`prasmoid.getMetadata('Id') + 1`)
 			require.NoError(t, err)
-			require.Contains(t, val.String(), "prasmoid.getMetadata: Id not found in metadata.json")
+			require.Equal(t, int64(124), val.ToInteger())
+
+			val, err = vm.RunString(`prasmoid.getMetadata('Authors')[0].Name`)
+			require.NoError(t, err)
+			require.Equal(t, "PRAS", val.String())
 
 			// Recreate metadata.json
 			require.NoError(t, os.WriteFile(filepath.Join(tmpDir, "metadata.json"), []byte(metadataContent), 0644))
@@ -149,6 +152,13 @@ func TestPrasmoidModule(t *testing.T) {
 	})
 
 	t.Run("Command", func(t *testing.T) {
+		// lastCommand returns the most recent registration of the shared runtime.
+		lastCommand := func() *CommandConfig {
+			registered := Commands(vm)
+			require.NotEmpty(t, registered)
+			return registered[len(registered)-1]
+		}
+
 		// Test panic conditions
 		t.Run("no arguments panics", func(t *testing.T) {
 			require.PanicsWithValue(t, "prasmoid.Command: exactly 1 argument required", func() {
@@ -207,9 +217,9 @@ func TestPrasmoidModule(t *testing.T) {
 			`
 			_, err := vm.RunString(script)
 			require.NoError(t, err)
-			require.NotNil(t, CommandStorage.Run)
+			require.NotNil(t, lastCommand().Run)
 			// Verify the run function can be called
-			val, err := CommandStorage.Run(goja.Undefined())
+			val, err := lastCommand().Run(goja.Undefined())
 			require.NoError(t, err)
 			require.Equal(t, "command executed", val.String())
 		})
@@ -224,8 +234,8 @@ func TestPrasmoidModule(t *testing.T) {
 			`
 			_, err := vm.RunString(script)
 			require.NoError(t, err)
-			require.Equal(t, "Short description", CommandStorage.Short)
-			require.Equal(t, "Long description", CommandStorage.Long)
+			require.Equal(t, "Short description", lastCommand().Short)
+			require.Equal(t, "Long description", lastCommand().Long)
 		})
 
 		t.Run("command with alias", func(t *testing.T) {
@@ -237,9 +247,9 @@ func TestPrasmoidModule(t *testing.T) {
 			`
 			_, err := vm.RunString(script)
 			require.NoError(t, err)
-			require.Contains(t, CommandStorage.Alias, "cmd1")
-			require.Contains(t, CommandStorage.Alias, "c1")
-			require.Len(t, CommandStorage.Alias, 2)
+			require.Contains(t, lastCommand().Alias, "cmd1")
+			require.Contains(t, lastCommand().Alias, "c1")
+			require.Len(t, lastCommand().Alias, 2)
 		})
 
 		t.Run("command with empty alias", func(t *testing.T) {
@@ -251,7 +261,7 @@ func TestPrasmoidModule(t *testing.T) {
 			`
 			_, err := vm.RunString(script)
 			require.NoError(t, err)
-			require.Empty(t, CommandStorage.Alias)
+			require.Empty(t, lastCommand().Alias)
 		})
 
 		t.Run("command with flags", func(t *testing.T) {
@@ -268,24 +278,24 @@ func TestPrasmoidModule(t *testing.T) {
 			`
 			_, err := vm.RunString(script)
 			require.NoError(t, err)
-			require.Len(t, CommandStorage.Flags, 4)
+			require.Len(t, lastCommand().Flags, 4)
 
-			flag1 := CommandStorage.Flags[0]
+			flag1 := lastCommand().Flags[0]
 			require.Equal(t, "myString", flag1.Name)
 			require.Equal(t, "string", flag1.Type)
 			require.Equal(t, "default", flag1.Value)
 
-			flag2 := CommandStorage.Flags[1]
+			flag2 := lastCommand().Flags[1]
 			require.Equal(t, "myBool", flag2.Name)
 			require.Equal(t, "bool", flag2.Type)
 			require.Equal(t, true, flag2.Value)
 
-			flag3 := CommandStorage.Flags[2]
+			flag3 := lastCommand().Flags[2]
 			require.Equal(t, "myStringNoValue", flag3.Name)
 			require.Equal(t, "string", flag3.Type)
 			require.Equal(t, "", flag3.Value)
 
-			flag4 := CommandStorage.Flags[3]
+			flag4 := lastCommand().Flags[3]
 			require.Equal(t, "myBoolNoValue", flag4.Name)
 			require.Equal(t, "bool", flag4.Type)
 			require.Equal(t, false, flag4.Value)
@@ -302,8 +312,8 @@ func TestPrasmoidModule(t *testing.T) {
 			`
 			_, err := vm.RunString(script)
 			require.NoError(t, err)
-			require.Len(t, CommandStorage.Flags, 1)
-			flag := CommandStorage.Flags[0]
+			require.Len(t, lastCommand().Flags, 1)
+			flag := lastCommand().Flags[0]
 			require.Equal(t, "verbose", flag.Name)
 			require.Equal(t, "bool", flag.Type)
 			require.Equal(t, false, flag.Value)
@@ -324,8 +334,60 @@ func TestPrasmoidModule(t *testing.T) {
 			`
 			_, err := vm.RunString(script)
 			require.NoError(t, err)
-			require.Len(t, CommandStorage.Flags, 1)
-			require.Equal(t, "validFlag", CommandStorage.Flags[0].Name)
+			require.Len(t, lastCommand().Flags, 1)
+			require.Equal(t, "validFlag", lastCommand().Flags[0].Name)
+		})
+
+		t.Run("commands accumulate per runtime", func(t *testing.T) {
+			other := NewRuntime()
+			_, err := other.RunString(`
+				prasmoid.Command({ name: "one", run: function(){} });
+				prasmoid.Command({ name: "two", run: function(){} });
+			`)
+			require.NoError(t, err)
+			registered := Commands(other)
+			require.Len(t, registered, 2)
+			require.Equal(t, "one", registered[0].Name)
+			require.Equal(t, "two", registered[1].Name)
+			require.Empty(t, Commands(NewRuntime()))
+		})
+
+		t.Run("command with subcommands", func(t *testing.T) {
+			script := `
+				prasmoid.Command({
+					name: "release",
+					short: "Release tasks",
+					permissions: { exec: ["git"] },
+					subcommands: [
+						{ name: "notes", run: function(){} },
+						{ name: "publish", run: function(){}, permissions: { net: ["api.github.com"] },
+						  subcommands: [{ name: "dry", run: function(){} }] },
+					],
+				});
+			`
+			_, err := vm.RunString(script)
+			require.NoError(t, err)
+			cmd := lastCommand()
+			require.Equal(t, "release", cmd.Name)
+			require.Nil(t, cmd.Run)
+			require.Len(t, cmd.Subcommands, 2)
+			require.Equal(t, "notes", cmd.Subcommands[0].Name)
+			require.Equal(t, "publish", cmd.Subcommands[1].Name)
+			require.Equal(t, "dry", cmd.Subcommands[1].Subcommands[0].Name)
+			require.Equal(t, []string{"git"}, cmd.declaredPermissions().Exec)
+			require.Equal(t, []string{"api.github.com"}, cmd.declaredPermissions().Net)
+		})
+
+		t.Run("subcommand without name panics", func(t *testing.T) {
+			require.PanicsWithValue(t, "prasmoid.Command: subcommands[0] is missing a 'name'", func() {
+				_, _ = vm.RunString(`prasmoid.Command({ subcommands: [{ run: function(){} }] });`)
+			})
+		})
+
+		t.Run("invalid subcommand panics", func(t *testing.T) {
+			require.PanicsWithValue(t, "prasmoid.Command: subcommands[1]: missing 'run' function", func() {
+				_, _ = vm.RunString(`prasmoid.Command({ subcommands: [{ name: "a", run: function(){} }, { name: "b" }] });`)
+			})
 		})
 
 		t.Run("command with alias containing non-string elements", func(t *testing.T) {
@@ -337,9 +399,9 @@ func TestPrasmoidModule(t *testing.T) {
 			`
 			_, err := vm.RunString(script)
 			require.NoError(t, err)
-			require.Len(t, CommandStorage.Alias, 2)
-			require.Contains(t, CommandStorage.Alias, "alias1")
-			require.Contains(t, CommandStorage.Alias, "alias2")
+			require.Len(t, lastCommand().Alias, 2)
+			require.Contains(t, lastCommand().Alias, "alias1")
+			require.Contains(t, lastCommand().Alias, "alias2")
 		})
 	})
 }
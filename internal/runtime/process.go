@@ -2,28 +2,42 @@ package runtime
 
 import (
 	"fmt"
+	"io"
+	"math/big"
 	"os"
 	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
 
 	"github.com/dop251/goja"
+	"golang.org/x/sys/unix"
+	"golang.org/x/term"
+
+	"github.com/PRASSamin/prasmoid/internal"
 )
 
-func Process(vm *goja.Runtime, module *goja.Object) {
+// stdin is what process.stdin reads, a variable for tests.
+var stdin io.Reader = os.Stdin
+
+func Process(vm *Runtime, module *goja.Object) {
 	_process := module.Get("exports").(*goja.Object)
 
-	// process.exit(code)
+	// process.on, once, off, emit and friends, for the "exit" and "warning" events
+	events := newEmitter(vm, _process)
+
+	// process.exit([code]) runs the "exit" listeners first, like node
 	_ = _process.Set("exit", func(call goja.FunctionCall) goja.Value {
-		code := 0
-		if len(call.Arguments) > 0 {
-			code = int(call.Arguments[0].ToInteger())
+		checkProcess(vm, "exit")
+		if code := call.Argument(0); !goja.IsUndefined(code) {
+			_ = _process.Set("exitCode", code.ToInteger())
 		}
-		os.Exit(code)
+		os.Exit(EmitExit(vm, 0))
 		return goja.Undefined()
 	})
+	_ = _process.Set("exitCode", goja.Undefined())
 
 	// process.cwd()
 	_ = _process.Set("cwd", func(call goja.FunctionCall) goja.Value {
@@ -40,6 +54,7 @@ func Process(vm *goja.Runtime, module *goja.Object) {
 			return vm.ToValue("chdir: path is required")
 		}
 		path := call.Arguments[0].String()
+		checkProcess(vm, "chdir")
 		err := os.Chdir(path)
 		if err != nil {
 			return vm.ToValue(fmt.Sprintf("chdir error: %v", err))
@@ -57,25 +72,42 @@ func Process(vm *goja.Runtime, module *goja.Object) {
 		var m runtime.MemStats
 		runtime.ReadMemStats(&m)
 
+		// Like node, rss is the memory resident in RAM, which Go's stats don't know.
+		rss, ok := residentSetSize()
+		if !ok {
+			rss = m.Sys
+		}
+
 		obj := vm.NewObject()
-		SetObjProperty(obj, "rss", m.Sys)
+		SetObjProperty(obj, "rss", rss)
 		SetObjProperty(obj, "heapTotal", m.HeapSys)
 		SetObjProperty(obj, "heapUsed", m.HeapAlloc)
 		SetObjProperty(obj, "external", m.StackSys)
 		return obj
 	})
 
-	// process.kill(pid)
+	// process.kill(pid[, signal]), where signal is a name like "SIGINT" or a number
 	_ = _process.Set("kill", func(call goja.FunctionCall) goja.Value {
 		if len(call.Arguments) < 1 {
 			return vm.ToValue("kill: pid required")
 		}
+		checkProcess(vm, "kill")
 		pid := call.Arguments[0].ToInteger()
 		if pid <= 0 {
 			// Prevent calling syscall.Kill with dangerous PIDs like -1 or 0
 			return vm.ToValue(fmt.Sprintf("kill error: invalid pid %d", pid))
 		}
-		err := syscall.Kill(int(pid), syscall.SIGTERM) // Or whatever signal is passed
+		sig := syscall.SIGTERM
+		switch arg := call.Argument(1); {
+		case goja.IsUndefined(arg):
+		case typeOf(arg) == "number":
+			sig = syscall.Signal(arg.ToInteger())
+		default:
+			if sig = unix.SignalNum(arg.String()); sig == 0 {
+				return vm.ToValue(fmt.Sprintf("kill error: unknown signal %s", arg))
+			}
+		}
+		err := syscall.Kill(int(pid), sig)
 		if err != nil {
 			return vm.ToValue(fmt.Sprintf("kill error: %v", err))
 		}
@@ -102,6 +134,9 @@ func Process(vm *goja.Runtime, module *goja.Object) {
 		return vm.ToValue(os.Getegid())
 	})
 
+	// process.argv: the CLI's arguments, unless the runner sets them with SetArgv
+	_ = _process.Set("argv", vm.ToValue(append([]string(nil), os.Args...)))
+
 	// process.env
 	type Process struct {
 		env map[string]string
@@ -116,7 +151,156 @@ func Process(vm *goja.Runtime, module *goja.Object) {
 	envs := LoadEnvWithPrefix(wd)
 	p := &Process{env: envs}
 
-	_ = _process.Set("env", p.env)
+	// Reads go through the sandbox, which hides variables a command did not ask for
+	_ = _process.Set("env", vm.NewDynamicObject(&envObject{vm: vm, env: p.env}))
+
+	_ = _process.Set("platform", runtime.GOOS)
+	_ = _process.Set("arch", nodeArch())
+	_ = _process.Set("pid", os.Getpid())
+	_ = _process.Set("ppid", os.Getppid())
+	_ = _process.Set("title", "prasmoid")
+	_ = _process.Set("version", "v"+internal.Version)
+	_ = _process.Set("versions", map[string]interface{}{
+		"prasmoid": internal.Version,
+		"go":       strings.TrimPrefix(runtime.Version(), "go"),
+	})
+	_ = _process.Set("release", map[string]interface{}{"name": "prasmoid"})
+	_ = _process.Set("execArgv", vm.NewArray())
+	if exe, err := os.Executable(); err == nil {
+		_ = _process.Set("execPath", exe)
+	}
+	_ = _process.Set("argv0", filepath.Base(os.Args[0]))
+
+	_ = _process.Set("stdin", newStdin(vm))
+	_ = _process.Set("stdout", newStdout(vm, 1, func() *os.File { return os.Stdout }))
+	_ = _process.Set("stderr", newStdout(vm, 2, func() *os.File { return os.Stderr }))
+
+	// process.hrtime([previous]) and process.hrtime.bigint()
+	hrtime := vm.ToValue(func(call goja.FunctionCall) goja.Value {
+		now := time.Since(hrtimeBase)
+		if prev, ok := call.Argument(0).Export().([]interface{}); ok && len(prev) == 2 {
+			now -= time.Duration(toInt64(prev[0]))*time.Second + time.Duration(toInt64(prev[1]))
+		}
+		return vm.ToValue([]int64{int64(now / time.Second), int64(now % time.Second)})
+	}).ToObject(vm.Runtime)
+	_ = hrtime.Set("bigint", func(call goja.FunctionCall) goja.Value {
+		return vm.ToValue(big.NewInt(int64(time.Since(hrtimeBase))))
+	})
+	_ = _process.Set("hrtime", hrtime)
+
+	// process.nextTick(callback[, ...args]) runs callback once the current
+	// operation completes, before timers and I/O.
+	_ = _process.Set("nextTick", func(call goja.FunctionCall) goja.Value {
+		callback, ok := goja.AssertFunction(call.Argument(0))
+		if !ok {
+			panic(invalidArgType(vm, "callback", "function", call.Argument(0)))
+		}
+		args := append([]goja.Value(nil), call.Arguments[1:]...)
+		promise, resolve, _ := vm.NewPromise()
+		_ = resolve(goja.Undefined())
+		then, _ := goja.AssertFunction(vm.ToValue(promise).ToObject(vm.Runtime).Get("then"))
+		_, _ = then(vm.ToValue(promise), vm.ToValue(func(goja.FunctionCall) goja.Value {
+			if _, err := callback(goja.Undefined(), args...); err != nil {
+				panic(err)
+			}
+			return goja.Undefined()
+		}))
+		return goja.Undefined()
+	})
+
+	// process.emitWarning(warning[, type]) prints the warning to stderr and
+	// emits a "warning" event.
+	_ = _process.Set("emitWarning", func(call goja.FunctionCall) goja.Value {
+		warning := call.Argument(0)
+		if obj, ok := warning.(*goja.Object); !ok || obj.Get("message") == nil {
+			kind := "Warning"
+			if t := call.Argument(1); typeOf(t) == "string" {
+				kind = t.String()
+			} else if opts, ok := t.(*goja.Object); ok && opts.Get("type") != nil {
+				kind = opts.Get("type").String()
+			}
+			err := vm.NewGoError(fmt.Errorf("%s", warning))
+			_ = err.Set("name", kind)
+			warning = err
+		}
+		obj := warning.ToObject(vm.Runtime)
+		fmt.Fprintf(os.Stderr, "(prasmoid:%d) %s: %s\n", os.Getpid(), obj.Get("name"), obj.Get("message"))
+		events.emit("warning", warning)
+		return goja.Undefined()
+	})
+
+	// process.cpuUsage([previous]) in microseconds
+	_ = _process.Set("cpuUsage", func(call goja.FunctionCall) goja.Value {
+		var usage unix.Rusage
+		_ = unix.Getrusage(unix.RUSAGE_SELF, &usage)
+		user, system := usage.Utime.Nano()/1000, usage.Stime.Nano()/1000
+		if prev, ok := call.Argument(0).(*goja.Object); ok {
+			user -= prev.Get("user").ToInteger()
+			system -= prev.Get("system").ToInteger()
+		}
+		obj := vm.NewObject()
+		SetObjProperty(obj, "user", user)
+		SetObjProperty(obj, "system", system)
+		return obj
+	})
+
+	// process.resourceUsage()
+	_ = _process.Set("resourceUsage", func(call goja.FunctionCall) goja.Value {
+		var usage unix.Rusage
+		_ = unix.Getrusage(unix.RUSAGE_SELF, &usage)
+		obj := vm.NewObject()
+		SetObjProperty(obj, "userCPUTime", usage.Utime.Nano()/1000)
+		SetObjProperty(obj, "systemCPUTime", usage.Stime.Nano()/1000)
+		SetObjProperty(obj, "maxRSS", usage.Maxrss)
+		SetObjProperty(obj, "sharedMemorySize", usage.Ixrss)
+		SetObjProperty(obj, "unsharedDataSize", usage.Idrss)
+		SetObjProperty(obj, "unsharedStackSize", usage.Isrss)
+		SetObjProperty(obj, "minorPageFault", usage.Minflt)
+		SetObjProperty(obj, "majorPageFault", usage.Majflt)
+		SetObjProperty(obj, "swappedOut", usage.Nswap)
+		SetObjProperty(obj, "fsRead", usage.Inblock)
+		SetObjProperty(obj, "fsWrite", usage.Oublock)
+		SetObjProperty(obj, "ipcSent", usage.Msgsnd)
+		SetObjProperty(obj, "ipcReceived", usage.Msgrcv)
+		SetObjProperty(obj, "signalsCount", usage.Nsignals)
+		SetObjProperty(obj, "voluntaryContextSwitches", usage.Nvcsw)
+		SetObjProperty(obj, "involuntaryContextSwitches", usage.Nivcsw)
+		return obj
+	})
+
+	// process.availableMemory() and process.constrainedMemory()
+	_ = _process.Set("availableMemory", func(call goja.FunctionCall) goja.Value {
+		available, _ := memAvailable()
+		return vm.ToValue(available)
+	})
+	_ = _process.Set("constrainedMemory", func(call goja.FunctionCall) goja.Value {
+		return vm.ToValue(cgroupMemoryLimit())
+	})
+
+	// process.getgroups()
+	_ = _process.Set("getgroups", func(call goja.FunctionCall) goja.Value {
+		groups, err := os.Getgroups()
+		if err != nil {
+			panic(vm.NewGoError(err))
+		}
+		return vm.ToValue(groups)
+	})
+
+	// process.umask([mask]) returns the previous mask, setting the new one if given
+	_ = _process.Set("umask", func(call goja.FunctionCall) goja.Value {
+		mask := call.Argument(0)
+		if goja.IsUndefined(mask) {
+			old := unix.Umask(0)
+			unix.Umask(old)
+			return vm.ToValue(old)
+		}
+		checkProcess(vm, "umask")
+		value := mask.ToInteger()
+		if typeOf(mask) == "string" {
+			value, _ = strconv.ParseInt(mask.String(), 8, 32)
+		}
+		return vm.ToValue(unix.Umask(int(value)))
+	})
 
 	// === NOT IMPLEMENTED FUNCTIONS ===
 
@@ -127,11 +311,10 @@ func Process(vm *goja.Runtime, module *goja.Object) {
 	}
 
 	notImplList := []string{
-		"nextTick", "binding", "dlopen", "getActiveResourcesInfo", "reallyExit", "loadEnvFile",
-		"cpuUsage", "resourceUsage", "constrainedMemory", "availableMemory", "execve",
-		"ref", "unref", "hrtime", "openStdin", "getgroups", "assert",
+		"binding", "dlopen", "getActiveResourcesInfo", "reallyExit", "loadEnvFile", "execve",
+		"ref", "unref", "openStdin", "assert",
 		"setUncaughtExceptionCaptureCallback", "hasUncaughtExceptionCaptureCallback",
-		"emitWarning", "setSourceMapsEnabled", "getBuiltinModule", "abort",
+		"setSourceMapsEnabled", "getBuiltinModule", "abort",
 		"initgroups", "setgroups", "setegid", "seteuid", "setgid", "setuid",
 	}
 
@@ -140,8 +323,132 @@ func Process(vm *goja.Runtime, module *goja.Object) {
 	}
 }
 
+// hrtimeBase is the arbitrary point process.hrtime counts from.
+var hrtimeBase = time.Now()
+
+func toInt64(v interface{}) int64 {
+	switch n := v.(type) {
+	case int64:
+		return n
+	case float64:
+		return int64(n)
+	}
+	return 0
+}
+
+// nodeArch is process.arch, which names some architectures differently than Go.
+func nodeArch() string {
+	switch runtime.GOARCH {
+	case "amd64":
+		return "x64"
+	case "386":
+		return "ia32"
+	}
+	return runtime.GOARCH
+}
+
+// cgroupMemoryLimit is the memory limit of the process's cgroup, or 0 if it has none.
+func cgroupMemoryLimit() uint64 {
+	data, err := os.ReadFile("/sys/fs/cgroup/memory.max")
+	if err != nil {
+		return 0
+	}
+	limit, _ := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	return limit
+}
+
+// newStdin is process.stdin, a Readable that starts reading when something
+// listens to "data", pipes it or iterates it.
+func newStdin(vm *Runtime) *goja.Object {
+	stream := newReadStream(vm, stdin, nil, 0).obj
+	_ = stream.Set("fd", 0)
+	// Like node, isTTY is only set on terminals
+	if term.IsTerminal(0) {
+		_ = stream.Set("isTTY", true)
+	}
+	return stream
+}
+
+// newStdout is process.stdout or stderr. Writes aren't buffered, so that they
+// keep their order with console's, and write always returns true. end doesn't
+// close the file, like node.
+func newStdout(vm *Runtime, fd int, file func() *os.File) *goja.Object {
+	stream := vm.NewObject()
+	events := newEmitter(vm, stream)
+	_ = stream.Set("fd", fd)
+	_ = stream.Set("writable", true)
+	if term.IsTerminal(fd) {
+		_ = stream.Set("isTTY", true)
+		if columns, rows, err := term.GetSize(fd); err == nil {
+			_ = stream.Set("columns", columns)
+			_ = stream.Set("rows", rows)
+		}
+	}
+	write := func(data []byte, callback goja.Callable) {
+		_, err := file().Write(data)
+		if callback != nil {
+			errVal := goja.Null()
+			if err != nil {
+				errVal = systemError(vm, err)
+			}
+			_, _ = callback(goja.Undefined(), errVal)
+		}
+	}
+	// write(chunk[, encoding][, callback])
+	_ = stream.Set("write", func(call goja.FunctionCall) goja.Value {
+		data, callback := writeArgs(vm, call)
+		write(data, callback)
+		return vm.ToValue(true)
+	})
+	// end([chunk][, encoding][, callback])
+	_ = stream.Set("end", func(call goja.FunctionCall) goja.Value {
+		data, callback := writeArgs(vm, call)
+		if data != nil {
+			write(data, nil)
+		}
+		if callback != nil {
+			_, _ = callback(goja.Undefined())
+		}
+		events.emit("finish")
+		return stream
+	})
+	return stream
+}
+
+// EmitExit emits process's "exit" event and returns the exit code: code if it
+// isn't 0, process.exitCode otherwise. Runners call it once the event loop is
+// done.
+func EmitExit(vm *Runtime, code int) int {
+	process := vm.Get("process").ToObject(vm.Runtime)
+	if code != 0 {
+		_ = process.Set("exitCode", code)
+	}
+	if exitCode := process.Get("exitCode"); exitCode != nil && !goja.IsUndefined(exitCode) {
+		code = int(exitCode.ToInteger())
+	}
+	if vm.exitEmitted {
+		return code
+	}
+	vm.exitEmitted = true
+	emit, _ := goja.AssertFunction(process.Get("emit"))
+	if _, err := emit(process, vm.ToValue("exit"), vm.ToValue(code)); err != nil {
+		fmt.Fprintln(os.Stderr, FormatError(err))
+		return 1
+	}
+	// Listeners may change process.exitCode
+	if exitCode := process.Get("exitCode"); exitCode != nil && !goja.IsUndefined(exitCode) {
+		code = int(exitCode.ToInteger())
+	}
+	return code
+}
+
 var startTime = time.Now()
 
+// SetArgv sets process.argv, which like node's starts with the executable and the script.
+func SetArgv(vm *Runtime, argv []string) {
+	_ = vm.Get("process").ToObject(vm.Runtime).Set("argv", vm.ToValue(argv))
+}
+
 func LoadEnvWithPrefix(baseDir string) map[string]string {
 	envs := make(map[string]string)
 	envFiles := []string{
@@ -186,3 +493,18 @@ func loadEnvFile(envMap map[string]string, filePath string) {
 		}
 	}
 }
+
+// residentSetSize is the resident set size of the process, VmRSS in /proc/self/status.
+func residentSetSize() (uint64, bool) {
+	data, err := os.ReadFile("/proc/self/status")
+	if err != nil {
+		return 0, false
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if rest, ok := strings.CutPrefix(line, "VmRSS:"); ok {
+			kb, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimSpace(rest), " kB"), 10, 64)
+			return kb * 1024, err == nil
+		}
+	}
+	return 0, false
+}
@@ -2,13 +2,18 @@ package runtime
 
 import (
 	"fmt"
+	"io"
 	"os"
+	"strings"
 	"testing"
 
 	"path/filepath"
 
 	"github.com/dop251/goja"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+
+	"github.com/PRASSamin/prasmoid/internal"
 )
 
 func TestProcessModule(t *testing.T) {
@@ -63,8 +68,18 @@ func TestProcessModule(t *testing.T) {
 		script := `process.memoryUsage();`
 		val, err := vm.RunString(script)
 		require.NoError(t, err)
-		obj := val.ToObject(vm)
+		obj := val.ToObject(vm.Runtime)
 		require.Greater(t, obj.Get("rss").ToInteger(), int64(0))
+		if status, err := os.ReadFile("/proc/self/status"); err == nil {
+			// The resident set moves a little between the two reads
+			var rss int64
+			for _, line := range strings.Split(string(status), "\n") {
+				if _, err := fmt.Sscanf(line, "VmRSS: %d kB", &rss); err == nil {
+					break
+				}
+			}
+			require.InDelta(t, rss*1024, obj.Get("rss").ToInteger(), 16<<20)
+		}
 		require.Greater(t, obj.Get("heapTotal").ToInteger(), int64(0))
 		require.Greater(t, obj.Get("heapUsed").ToInteger(), int64(0))
 		require.Greater(t, obj.Get("external").ToInteger(), int64(0))
@@ -214,11 +229,10 @@ ANOTHER_VAR=test2
 
 	t.Run("not implemented functions", func(t *testing.T) {
 		notImplementedFuncs := []string{
-			"nextTick", "binding", "dlopen", "getActiveResourcesInfo", "reallyExit", "loadEnvFile",
-			"cpuUsage", "resourceUsage", "constrainedMemory", "availableMemory", "execve",
-			"ref", "unref", "hrtime", "openStdin", "getgroups", "assert",
+			"binding", "dlopen", "getActiveResourcesInfo", "reallyExit", "loadEnvFile", "execve",
+			"ref", "unref", "openStdin", "assert",
 			"setUncaughtExceptionCaptureCallback", "hasUncaughtExceptionCaptureCallback",
-			"emitWarning", "setSourceMapsEnabled", "getBuiltinModule", "abort",
+			"setSourceMapsEnabled", "getBuiltinModule", "abort",
 			"initgroups", "setgroups", "setegid", "seteuid", "setgid", "setuid",
 		}
 
@@ -233,3 +247,140 @@ ANOTHER_VAR=test2
 		}
 	})
 }
+
+func TestProcessNodeSurface(t *testing.T) {
+	t.Run("properties", func(t *testing.T) {
+		vm := NewRuntime()
+		val, err := vm.RunString(`[process.platform, process.pid, process.ppid, process.title, process.version, process.exitCode]`)
+		require.NoError(t, err)
+		require.Equal(t, []interface{}{"linux", int64(os.Getpid()), int64(os.Getppid()), "prasmoid", "v" + internal.Version, nil}, val.Export())
+	})
+
+	t.Run("hrtime", func(t *testing.T) {
+		vm := NewRuntime()
+		val, err := vm.RunString(`
+			const start = process.hrtime();
+			const diff = process.hrtime(start);
+			[typeof process.hrtime.bigint(), start.length, diff[0] <= start[0], diff[1] >= 0]
+		`)
+		require.NoError(t, err)
+		require.Equal(t, []interface{}{"bigint", int64(2), true, true}, val.Export())
+	})
+
+	t.Run("nextTick runs after the current script", func(t *testing.T) {
+		vm := NewRuntime()
+		_, err := vm.RunString(`
+			var order = [];
+			process.nextTick((a, b) => order.push(a + b), "tick", "ed");
+			order.push("sync");
+		`)
+		require.NoError(t, err)
+		RunEventLoop(vm)
+		require.Equal(t, []interface{}{"sync", "ticked"}, vm.Get("order").Export())
+
+		_, err = vm.RunString(`process.nextTick("nope")`)
+		require.ErrorContains(t, err, `The "callback" argument must be of type function. Received type string ('nope')`)
+	})
+
+	t.Run("stdout and stderr", func(t *testing.T) {
+		vm := NewRuntime()
+		r, w, err := os.Pipe()
+		require.NoError(t, err)
+		stdout := os.Stdout
+		os.Stdout = w
+		val, err := vm.RunString(`process.stdout.write("no newline") && process.stdout.write(Buffer.from("!"))`)
+		os.Stdout = stdout
+		require.NoError(t, w.Close())
+		require.NoError(t, err)
+		require.True(t, val.ToBoolean())
+		out, _ := io.ReadAll(r)
+		require.Equal(t, "no newline!", string(out))
+
+		val, err = vm.RunString(`[process.stdin.fd, process.stdout.fd, process.stderr.fd]`)
+		require.NoError(t, err)
+		require.Equal(t, []interface{}{int64(0), int64(1), int64(2)}, val.Export())
+	})
+
+	t.Run("events", func(t *testing.T) {
+		vm := NewRuntime()
+		val, err := vm.RunString(`
+			const seen = [];
+			const listener = (v) => seen.push("on " + v);
+			process.on("custom", listener);
+			process.once("custom", (v) => seen.push("once " + v));
+			process.emit("custom", 1);
+			process.emit("custom", 2);
+			process.off("custom", listener);
+			[seen, process.emit("custom", 3), process.listenerCount("custom")]
+		`)
+		require.NoError(t, err)
+		require.Equal(t, []interface{}{[]interface{}{"on 1", "once 1", "on 2"}, false, int64(0)}, val.Export())
+	})
+
+	t.Run("exit event and exitCode", func(t *testing.T) {
+		vm := NewRuntime()
+		_, err := vm.RunString(`
+			var exited;
+			process.on("exit", (code) => { exited = code; process.exitCode = code + 1; });
+			process.exitCode = 3;
+		`)
+		require.NoError(t, err)
+		require.Equal(t, 4, EmitExit(vm, 0))
+		require.Equal(t, int64(3), vm.Get("exited").Export())
+		// The event is only emitted once
+		require.Equal(t, 4, EmitExit(vm, 0))
+
+		vm = NewRuntime()
+		_, err = vm.RunString(`var exited; process.on("exit", (code) => { exited = code; });`)
+		require.NoError(t, err)
+		require.Equal(t, 1, EmitExit(vm, 1))
+		require.Equal(t, int64(1), vm.Get("exited").Export())
+	})
+
+	t.Run("emitWarning", func(t *testing.T) {
+		vm := NewRuntime()
+		r, w, err := os.Pipe()
+		require.NoError(t, err)
+		stderr := os.Stderr
+		os.Stderr = w
+		val, err := vm.RunString(`
+			let warned;
+			process.on("warning", (w) => { warned = w.name + ": " + w.message; });
+			process.emitWarning("careful", "DeprecationWarning");
+			warned
+		`)
+		os.Stderr = stderr
+		require.NoError(t, w.Close())
+		require.NoError(t, err)
+		out, _ := io.ReadAll(r)
+		require.Equal(t, "DeprecationWarning: careful", val.String())
+		require.Equal(t, fmt.Sprintf("(prasmoid:%d) DeprecationWarning: careful\n", os.Getpid()), string(out))
+	})
+
+	t.Run("resource usage", func(t *testing.T) {
+		vm := NewRuntime()
+		val, err := vm.RunString(`
+			const usage = process.cpuUsage();
+			const diff = process.cpuUsage(usage);
+			[usage.user >= 0, diff.user <= usage.user, process.resourceUsage().maxRSS > 0, Array.isArray(process.getgroups())]
+		`)
+		require.NoError(t, err)
+		require.Equal(t, []interface{}{true, true, true, true}, val.Export())
+	})
+
+	t.Run("umask", func(t *testing.T) {
+		vm := NewRuntime()
+		old := unix.Umask(0o22)
+		t.Cleanup(func() { unix.Umask(old) })
+
+		val, err := vm.RunString(`[process.umask(), process.umask("077"), process.umask()]`)
+		require.NoError(t, err)
+		require.Equal(t, []interface{}{int64(0o22), int64(0o22), int64(0o77)}, val.Export())
+
+		SetPermissions(vm, &Permissions{})
+		_, err = vm.RunString(`process.umask(0)`)
+		require.ErrorContains(t, err, "permission denied: process.umask")
+		_, err = vm.RunString(`process.umask()`)
+		require.NoError(t, err)
+	})
+}
@@ -0,0 +1,185 @@
+package runtime
+
+import (
+	"encoding/json"
+
+	"github.com/dop251/goja"
+
+	"github.com/PRASSamin/prasmoid/types"
+)
+
+// ProjectAPI is the CLI functionality the prasmoid module exposes to scripts.
+// The packages implementing the commands depend on this one, so they fill
+// it in from their init(). Unset operations throw when called.
+type ProjectAPI struct {
+	Config         func() types.Config
+	SetMetadata    func(key string, value interface{}, section ...string) error
+	Build          func(output string) (string, error)
+	Link           func() (string, error)
+	Install        func() error
+	I18nExtract    func(noPo bool) error
+	I18nCompile    func(silent bool) error
+	ChangesetAdd   func(bump, summary string, apply bool) (string, error)
+	ChangesetApply func() error
+	Format         func(files []string) error
+}
+
+var Project ProjectAPI
+
+// registerProject adds the project API to the exports of the prasmoid module.
+func registerProject(vm *Runtime, exports *goja.Object) {
+	unavailable := func(name string) {
+		panic(vm.NewTypeError("prasmoid.%s is not available", name))
+	}
+	check := func(err error) {
+		if err != nil {
+			panic(vm.NewGoError(err))
+		}
+	}
+	options := func(val goja.Value) *goja.Object {
+		if obj, ok := val.(*goja.Object); ok {
+			return obj
+		}
+		return vm.NewObject()
+	}
+	optString := func(opts *goja.Object, key string) string {
+		if val := opts.Get(key); val != nil && !goja.IsUndefined(val) && !goja.IsNull(val) {
+			return val.String()
+		}
+		return ""
+	}
+	optBool := func(opts *goja.Object, key string) bool {
+		val := opts.Get(key)
+		return val != nil && val.ToBoolean()
+	}
+
+	// prasmoid.config: the loaded prasmoid.config.js, with the keys used in the file
+	_ = exports.DefineAccessorProperty("config", vm.ToValue(func(call goja.FunctionCall) goja.Value {
+		if Project.Config == nil {
+			return goja.Undefined()
+		}
+		data, err := json.Marshal(Project.Config())
+		check(err)
+		var config map[string]interface{}
+		check(json.Unmarshal(data, &config))
+		return vm.ToValue(config)
+	}), nil, goja.FLAG_TRUE, goja.FLAG_TRUE)
+
+	// prasmoid.setMetadata(key, value, [section]): section defaults to "KPlugin", "." is the root
+	_ = exports.Set("setMetadata", func(call goja.FunctionCall) goja.Value {
+		checkProject(vm, "prasmoid.setMetadata", "metadata")
+		if Project.SetMetadata == nil {
+			unavailable("setMetadata")
+		}
+		if len(call.Arguments) < 2 {
+			panic(vm.NewTypeError("prasmoid.setMetadata: key and value required"))
+		}
+		var section []string
+		if s := call.Argument(2); !goja.IsUndefined(s) {
+			section = append(section, s.String())
+		}
+		check(Project.SetMetadata(call.Argument(0).String(), call.Argument(1).Export(), section...))
+		return goja.Undefined()
+	})
+
+	// prasmoid.build({ output }) => path of the .plasmoid archive. The output
+	// directory is removed first, so it needs fs access too.
+	_ = exports.Set("build", func(call goja.FunctionCall) goja.Value {
+		checkProject(vm, "prasmoid.build", "build")
+		if Project.Build == nil {
+			unavailable("build")
+		}
+		output := optString(options(call.Argument(0)), "output")
+		if output == "" {
+			output = "./build"
+		}
+		checkFS(vm, "prasmoid.build", output)
+		archive, err := Project.Build(output)
+		check(err)
+		return vm.ToValue(archive)
+	})
+
+	// prasmoid.link() => directory the project is linked to
+	_ = exports.Set("link", func(call goja.FunctionCall) goja.Value {
+		checkProject(vm, "prasmoid.link", "link")
+		if Project.Link == nil {
+			unavailable("link")
+		}
+		dest, err := Project.Link()
+		check(err)
+		return vm.ToValue(dest)
+	})
+
+	_ = exports.Set("install", func(call goja.FunctionCall) goja.Value {
+		checkProject(vm, "prasmoid.install", "install")
+		if Project.Install == nil {
+			unavailable("install")
+		}
+		check(Project.Install())
+		return goja.Undefined()
+	})
+
+	i18n := vm.NewObject()
+	// prasmoid.i18n.extract({ noPo })
+	_ = i18n.Set("extract", func(call goja.FunctionCall) goja.Value {
+		checkProject(vm, "prasmoid.i18n.extract", "i18n")
+		if Project.I18nExtract == nil {
+			unavailable("i18n.extract")
+		}
+		check(Project.I18nExtract(optBool(options(call.Argument(0)), "noPo")))
+		return goja.Undefined()
+	})
+	// prasmoid.i18n.compile({ silent })
+	_ = i18n.Set("compile", func(call goja.FunctionCall) goja.Value {
+		checkProject(vm, "prasmoid.i18n.compile", "i18n")
+		if Project.I18nCompile == nil {
+			unavailable("i18n.compile")
+		}
+		check(Project.I18nCompile(optBool(options(call.Argument(0)), "silent")))
+		return goja.Undefined()
+	})
+	_ = exports.Set("i18n", i18n)
+
+	changeset := vm.NewObject()
+	// prasmoid.changeset.add({ bump, summary, apply }) => path of the changeset file
+	_ = changeset.Set("add", func(call goja.FunctionCall) goja.Value {
+		checkProject(vm, "prasmoid.changeset.add", "changeset")
+		if Project.ChangesetAdd == nil {
+			unavailable("changeset.add")
+		}
+		opts := options(call.Argument(0))
+		file, err := Project.ChangesetAdd(optString(opts, "bump"), optString(opts, "summary"), optBool(opts, "apply"))
+		check(err)
+		return vm.ToValue(file)
+	})
+	_ = changeset.Set("apply", func(call goja.FunctionCall) goja.Value {
+		checkProject(vm, "prasmoid.changeset.apply", "changeset")
+		if Project.ChangesetApply == nil {
+			unavailable("changeset.apply")
+		}
+		check(Project.ChangesetApply())
+		return goja.Undefined()
+	})
+	_ = exports.Set("changeset", changeset)
+
+	// prasmoid.format([files]): formats the given QML files, or all of them
+	// under contents/, in place
+	_ = exports.Set("format", func(call goja.FunctionCall) goja.Value {
+		checkProject(vm, "prasmoid.format", "format")
+		if Project.Format == nil {
+			unavailable("format")
+		}
+		var files []string
+		if arg := call.Argument(0); !goja.IsUndefined(arg) {
+			if err := vm.ExportTo(arg, &files); err != nil {
+				panic(vm.NewTypeError("prasmoid.format: files must be an array of paths"))
+			}
+		}
+		if len(files) == 0 {
+			checkFS(vm, "prasmoid.format", "./contents")
+		}
+		checkFS(vm, "prasmoid.format", files...)
+		check(Project.Format(files))
+		return goja.Undefined()
+	})
+}
@@ -0,0 +1,118 @@
+package runtime
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/PRASSamin/prasmoid/types"
+)
+
+func TestProjectAPI(t *testing.T) {
+	original := Project
+	t.Cleanup(func() { Project = original })
+
+	var calls []interface{}
+	Project = ProjectAPI{
+		Config: func() types.Config {
+			return types.Config{I18n: types.ConfigI18n{Dir: "translations", Locales: []string{"de"}}}
+		},
+		SetMetadata: func(key string, value interface{}, section ...string) error {
+			calls = append(calls, key, value, section)
+			return nil
+		},
+		Build: func(output string) (string, error) {
+			return output + "/org.example.test-1.0.plasmoid", nil
+		},
+		ChangesetAdd: func(bump, summary string, apply bool) (string, error) {
+			calls = append(calls, bump, summary, apply)
+			return ".changes/patch.mdx", nil
+		},
+		Format: func(files []string) error {
+			return errors.New("qmlformat failed")
+		},
+	}
+
+	t.Run("config", func(t *testing.T) {
+		vm := NewRuntime()
+		val, err := vm.RunString(`prasmoid.config.i18n.dir + ":" + prasmoid.config.i18n.locales.join(",")`)
+		require.NoError(t, err)
+		assert.Equal(t, "translations:de", val.String())
+	})
+
+	t.Run("operations", func(t *testing.T) {
+		calls = nil
+		vm := NewRuntime()
+		val, err := vm.RunString(`
+			prasmoid.setMetadata("Version", "1.1.0");
+			prasmoid.setMetadata("X-Plasma-API", 6, ".");
+			prasmoid.changeset.add({ bump: "patch", summary: "Fixes" });
+			prasmoid.build({ output: "dist" });
+		`)
+		require.NoError(t, err)
+		assert.Equal(t, "dist/org.example.test-1.0.plasmoid", val.String())
+		assert.Equal(t, []interface{}{
+			"Version", "1.1.0", []string(nil),
+			"X-Plasma-API", int64(6), []string{"."},
+			"patch", "Fixes", false,
+		}, calls)
+	})
+
+	t.Run("errors are thrown", func(t *testing.T) {
+		vm := NewRuntime()
+		_, err := vm.RunString(`prasmoid.format(["contents/ui/main.qml"])`)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "qmlformat failed")
+
+		_, err = vm.RunString(`prasmoid.install()`)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "prasmoid.install is not available")
+	})
+
+	t.Run("sandboxed scripts need the project permission", func(t *testing.T) {
+		vm := NewRuntime()
+		SetPermissions(vm, &Permissions{Project: []string{"metadata"}})
+		_, err := vm.RunString(`prasmoid.setMetadata("Version", "2.0.0")`)
+		require.NoError(t, err)
+
+		_, err = vm.RunString(`prasmoid.build()`)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `permission denied: prasmoid.build needs project access to "build"`)
+	})
+
+	t.Run("sandboxed scripts need fs access to what build and format write", func(t *testing.T) {
+		originalWd, _ := os.Getwd()
+		require.NoError(t, os.Chdir(t.TempDir()))
+		defer func() { _ = os.Chdir(originalWd) }()
+
+		var formatted []string
+		Project.Format = func(files []string) error {
+			formatted = files
+			return nil
+		}
+		vm := NewRuntime()
+		SetPermissions(vm, &Permissions{Project: []string{"build", "format"}, FS: []string{"./build", "./contents"}})
+
+		val, err := vm.RunString(`prasmoid.build()`)
+		require.NoError(t, err)
+		assert.Equal(t, "./build/org.example.test-1.0.plasmoid", val.String())
+		_, err = vm.RunString(`prasmoid.format(["contents/ui/main.qml"]); prasmoid.format()`)
+		require.NoError(t, err)
+		assert.Nil(t, formatted)
+
+		_, err = vm.RunString(`prasmoid.build({ output: "/home/me" })`)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `permission denied: prasmoid.build needs fs access to "/home/me"`)
+		_, err = vm.RunString(`prasmoid.format(["contents/ui/main.qml", "../other/main.qml"])`)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `permission denied: prasmoid.format needs fs access to "../other/main.qml"`)
+
+		SetPermissions(vm, &Permissions{Project: []string{"format"}, FS: []string{"./contents/ui"}})
+		_, err = vm.RunString(`prasmoid.format()`)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `permission denied: prasmoid.format needs fs access to "./contents"`)
+	})
+}
@@ -0,0 +1,287 @@
+package runtime
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/AlecAivazis/survey/v2/terminal"
+	"github.com/dop251/goja"
+	"golang.org/x/term"
+)
+
+var (
+	surveyAskOne    = survey.AskOne
+	stdinIsTerminal = func() bool { return term.IsTerminal(int(os.Stdin.Fd())) }
+)
+
+// SetAnswers gives the prompts of vm answers up front (e.g. from --answer name=value),
+// so that they don't ask.
+func SetAnswers(vm *Runtime, values map[string]string) {
+	vm.answers = values
+}
+
+var nonAlnum = regexp.MustCompile(`[^A-Za-z0-9]+`)
+
+// AnswerEnv returns the environment variable answering the prompt with the given name.
+func AnswerEnv(name string) string {
+	return "PRASMOID_ANSWER_" + strings.ToUpper(nonAlnum.ReplaceAllString(name, "_"))
+}
+
+// promptOptions are the options shared by every prompt.
+type promptOptions struct {
+	Name     string
+	Default  goja.Value
+	Help     string
+	Required bool
+	Validate goja.Callable
+}
+
+// Prompt asks the user questions with survey. Each prompt takes a message and
+// options { name, default, help, required, validate }. A prompt with a name is
+// answered without asking by --answer name=value or PRASMOID_ANSWER_<NAME>, and
+// when stdin isn't a terminal it falls back to those, then to its default.
+func Prompt(vm *Runtime, module *goja.Object) {
+	_prompt := module.Get("exports").(*goja.Object)
+
+	parseOptions := func(val goja.Value) promptOptions {
+		var opts promptOptions
+		obj, ok := val.(*goja.Object)
+		if !ok {
+			return opts
+		}
+		get := func(key string) goja.Value {
+			if v := obj.Get(key); v != nil && !goja.IsUndefined(v) && !goja.IsNull(v) {
+				return v
+			}
+			return nil
+		}
+		if v := get("name"); v != nil {
+			opts.Name = v.String()
+		}
+		opts.Default = get("default")
+		if v := get("help"); v != nil {
+			opts.Help = v.String()
+		}
+		if v := get("required"); v != nil {
+			opts.Required = v.ToBoolean()
+		}
+		if v := get("validate"); v != nil {
+			fn, ok := goja.AssertFunction(v)
+			if !ok {
+				panic(vm.NewTypeError("prompt: validate must be a function"))
+			}
+			opts.Validate = fn
+		}
+		return opts
+	}
+
+	// validator runs the required check and the script's validate function,
+	// which returns true or an error message.
+	validator := func(opts promptOptions) func(ans interface{}) error {
+		return func(ans interface{}) error {
+			if opts.Required {
+				if err := survey.Required(ans); err != nil {
+					return err
+				}
+			}
+			if opts.Validate == nil {
+				return nil
+			}
+			if option, ok := ans.(survey.OptionAnswer); ok {
+				ans = option.Value
+			}
+			if options, ok := ans.([]survey.OptionAnswer); ok {
+				values := make([]string, len(options))
+				for i, o := range options {
+					values[i] = o.Value
+				}
+				ans = values
+			}
+			res, err := opts.Validate(goja.Undefined(), vm.ToValue(ans))
+			if err != nil {
+				return err
+			}
+			if msg, ok := res.Export().(string); ok {
+				return errors.New(msg)
+			}
+			if !res.ToBoolean() {
+				return errors.New("invalid answer")
+			}
+			return nil
+		}
+	}
+
+	// preset returns the answer given up front for the prompt, if any.
+	preset := func(opts promptOptions) (string, bool) {
+		if opts.Name == "" {
+			return "", false
+		}
+		if val, ok := vm.answers[opts.Name]; ok {
+			return val, true
+		}
+		return os.LookupEnv(AnswerEnv(opts.Name))
+	}
+
+	// ask shows the survey prompt, or answers it without asking. parse turns a
+	// preset answer into the prompt's value; answer is where survey stores it.
+	ask := func(kind, message string, opts promptOptions, p survey.Prompt, answer interface{}, parse func(string) (interface{}, error), fallback func() interface{}) goja.Value {
+		validate := validator(opts)
+		if val, ok := preset(opts); ok {
+			parsed, err := parse(val)
+			if err == nil {
+				err = validate(parsed)
+			}
+			if err != nil {
+				panic(vm.NewGoError(fmt.Errorf("prompt.%s: invalid answer %q for %q: %v", kind, val, opts.Name, err)))
+			}
+			return vm.ToValue(parsed)
+		}
+
+		if !stdinIsTerminal() {
+			if opts.Default != nil {
+				return vm.ToValue(fallback())
+			}
+			if opts.Name == "" {
+				panic(vm.NewGoError(fmt.Errorf("prompt.%s: %q needs an answer but stdin is not a terminal; give the prompt a name to answer it with --answer", kind, message)))
+			}
+			panic(vm.NewGoError(fmt.Errorf("prompt.%s: %q needs an answer but stdin is not a terminal; pass --answer %s=<value> or set %s", kind, message, opts.Name, AnswerEnv(opts.Name))))
+		}
+
+		if err := surveyAskOne(p, answer, survey.WithValidator(validate)); err != nil {
+			if errors.Is(err, terminal.InterruptErr) {
+				panic(vm.NewGoError(fmt.Errorf("prompt.%s: cancelled", kind)))
+			}
+			panic(vm.NewGoError(fmt.Errorf("prompt.%s: %v", kind, err)))
+		}
+		return nil
+	}
+
+	asString := func(val string) (interface{}, error) { return val, nil }
+	defaultString := func(opts promptOptions) string {
+		if opts.Default == nil {
+			return ""
+		}
+		return opts.Default.String()
+	}
+	choicesOf := func(kind string, val goja.Value) []string {
+		var choices []string
+		if err := vm.ExportTo(val, &choices); err != nil || len(choices) == 0 {
+			panic(vm.NewTypeError("prompt.%s: choices must be a non-empty array of strings", kind))
+		}
+		return choices
+	}
+
+	// input(message, [options]) => string
+	_ = _prompt.Set("input", func(call goja.FunctionCall) goja.Value {
+		message, opts := call.Argument(0).String(), parseOptions(call.Argument(1))
+		var answer string
+		p := &survey.Input{Message: message, Default: defaultString(opts), Help: opts.Help}
+		if val := ask("input", message, opts, p, &answer, asString, func() interface{} { return defaultString(opts) }); val != nil {
+			return val
+		}
+		return vm.ToValue(answer)
+	})
+
+	// password(message, [options]) => string
+	_ = _prompt.Set("password", func(call goja.FunctionCall) goja.Value {
+		message, opts := call.Argument(0).String(), parseOptions(call.Argument(1))
+		var answer string
+		p := &survey.Password{Message: message, Help: opts.Help}
+		if val := ask("password", message, opts, p, &answer, asString, func() interface{} { return defaultString(opts) }); val != nil {
+			return val
+		}
+		return vm.ToValue(answer)
+	})
+
+	// editor(message, [options]) => string, written in $VISUAL or $EDITOR
+	_ = _prompt.Set("editor", func(call goja.FunctionCall) goja.Value {
+		message, opts := call.Argument(0).String(), parseOptions(call.Argument(1))
+		var answer string
+		p := &survey.Editor{Message: message, Default: defaultString(opts), Help: opts.Help, AppendDefault: true, HideDefault: true}
+		if val := ask("editor", message, opts, p, &answer, asString, func() interface{} { return defaultString(opts) }); val != nil {
+			return val
+		}
+		return vm.ToValue(answer)
+	})
+
+	// confirm(message, [options]) => boolean
+	_ = _prompt.Set("confirm", func(call goja.FunctionCall) goja.Value {
+		message, opts := call.Argument(0).String(), parseOptions(call.Argument(1))
+		def := opts.Default != nil && opts.Default.ToBoolean()
+		var answer bool
+		p := &survey.Confirm{Message: message, Default: def, Help: opts.Help}
+		parse := func(val string) (interface{}, error) {
+			switch strings.ToLower(val) {
+			case "y", "yes":
+				return true, nil
+			case "n", "no":
+				return false, nil
+			}
+			return strconv.ParseBool(val)
+		}
+		if val := ask("confirm", message, opts, p, &answer, parse, func() interface{} { return def }); val != nil {
+			return val
+		}
+		return vm.ToValue(answer)
+	})
+
+	// select(message, choices, [options]) => string
+	_ = _prompt.Set("select", func(call goja.FunctionCall) goja.Value {
+		message, choices, opts := call.Argument(0).String(), choicesOf("select", call.Argument(1)), parseOptions(call.Argument(2))
+		p := &survey.Select{Message: message, Options: choices, Help: opts.Help}
+		if opts.Default != nil {
+			p.Default = opts.Default.String()
+		}
+		parse := func(val string) (interface{}, error) {
+			if !slices.Contains(choices, val) {
+				return nil, fmt.Errorf("must be one of %s", strings.Join(choices, ", "))
+			}
+			return val, nil
+		}
+		var answer string
+		if val := ask("select", message, opts, p, &answer, parse, func() interface{} { return defaultString(opts) }); val != nil {
+			return val
+		}
+		return vm.ToValue(answer)
+	})
+
+	// multiselect(message, choices, [options]) => string[]; preset answers are comma separated
+	_ = _prompt.Set("multiselect", func(call goja.FunctionCall) goja.Value {
+		message, choices, opts := call.Argument(0).String(), choicesOf("multiselect", call.Argument(1)), parseOptions(call.Argument(2))
+		var defaults []string
+		if opts.Default != nil {
+			if err := vm.ExportTo(opts.Default, &defaults); err != nil {
+				panic(vm.NewTypeError("prompt.multiselect: default must be an array of strings"))
+			}
+		}
+		p := &survey.MultiSelect{Message: message, Options: choices, Help: opts.Help}
+		if len(defaults) > 0 {
+			p.Default = defaults
+		}
+		parse := func(val string) (interface{}, error) {
+			selected := []string{}
+			for _, item := range strings.Split(val, ",") {
+				item = strings.TrimSpace(item)
+				if item == "" {
+					continue
+				}
+				if !slices.Contains(choices, item) {
+					return nil, fmt.Errorf("%q is not one of %s", item, strings.Join(choices, ", "))
+				}
+				selected = append(selected, item)
+			}
+			return selected, nil
+		}
+		answer := []string{}
+		if val := ask("multiselect", message, opts, p, &answer, parse, func() interface{} { return defaults }); val != nil {
+			return val
+		}
+		return vm.ToValue(answer)
+	})
+}
@@ -0,0 +1,101 @@
+package runtime
+
+import (
+	"testing"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPromptModule(t *testing.T) {
+	originalAsk, originalIsTerminal := surveyAskOne, stdinIsTerminal
+	t.Cleanup(func() { surveyAskOne, stdinIsTerminal = originalAsk, originalIsTerminal })
+
+	t.Run("asks through survey on a terminal", func(t *testing.T) {
+		stdinIsTerminal = func() bool { return true }
+		var asked survey.Prompt
+		surveyAskOne = func(p survey.Prompt, response interface{}, opts ...survey.AskOpt) error {
+			asked = p
+			switch r := response.(type) {
+			case *string:
+				*r = "staging"
+			case *bool:
+				*r = true
+			case *[]string:
+				*r = []string{"a", "c"}
+			}
+			return nil
+		}
+		vm := NewRuntime()
+
+		val, err := vm.RunString(`prompt.select("Target?", ["staging", "production"], { default: "production" })`)
+		require.NoError(t, err)
+		assert.Equal(t, "staging", val.String())
+		require.IsType(t, &survey.Select{}, asked)
+		assert.Equal(t, "production", asked.(*survey.Select).Default)
+
+		val, err = vm.RunString(`prompt.confirm("Sure?")`)
+		require.NoError(t, err)
+		assert.True(t, val.ToBoolean())
+
+		val, err = vm.RunString(`require("prompt").multiselect("Pick", ["a", "b", "c"]).join(",")`)
+		require.NoError(t, err)
+		assert.Equal(t, "a,c", val.String())
+
+		_, err = vm.RunString(`prompt.password("Token?")`)
+		require.NoError(t, err)
+		assert.IsType(t, &survey.Password{}, asked)
+	})
+
+	t.Run("preset answers skip the prompt", func(t *testing.T) {
+		stdinIsTerminal = func() bool { return true }
+		surveyAskOne = func(p survey.Prompt, response interface{}, opts ...survey.AskOpt) error {
+			t.Fatal("prompt should not be shown")
+			return nil
+		}
+		vm := NewRuntime()
+		SetAnswers(vm, map[string]string{"name": "Plasmoid", "ok": "yes", "tags": "a, b"})
+		t.Setenv(AnswerEnv("target-env"), "production")
+
+		val, err := vm.RunString(`[
+			prompt.input("Name?", { name: "name" }),
+			prompt.confirm("OK?", { name: "ok" }),
+			prompt.multiselect("Tags?", ["a", "b", "c"], { name: "tags" }).join("+"),
+			prompt.select("Target?", ["staging", "production"], { name: "target-env" }),
+		].join(" ")`)
+		require.NoError(t, err)
+		assert.Equal(t, "Plasmoid true a+b production", val.String())
+		assert.Equal(t, "PRASMOID_ANSWER_TARGET_ENV", AnswerEnv("target-env"))
+	})
+
+	t.Run("preset answers are validated", func(t *testing.T) {
+		vm := NewRuntime()
+		SetAnswers(vm, map[string]string{"target": "moon", "name": "x"})
+
+		_, err := vm.RunString(`prompt.select("Target?", ["staging", "production"], { name: "target" })`)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `invalid answer "moon" for "target": must be one of staging, production`)
+
+		_, err = vm.RunString(`prompt.input("Name?", { name: "name", validate: (v) => v.length > 2 || "too short" })`)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "too short")
+	})
+
+	t.Run("without a terminal", func(t *testing.T) {
+		stdinIsTerminal = func() bool { return false }
+		vm := NewRuntime()
+
+		val, err := vm.RunString(`prompt.input("Name?", { name: "who", default: "World" })`)
+		require.NoError(t, err)
+		assert.Equal(t, "World", val.String())
+
+		_, err = vm.RunString(`prompt.input("Name?", { name: "who" })`)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "pass --answer who=<value> or set PRASMOID_ANSWER_WHO")
+
+		_, err = vm.RunString(`prompt.confirm("Sure?")`)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "give the prompt a name")
+	})
+}
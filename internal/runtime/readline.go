@@ -0,0 +1,227 @@
+package runtime
+
+import (
+	"strings"
+
+	"github.com/dop251/goja"
+	"github.com/dop251/goja_nodejs/buffer"
+)
+
+// Readline is node's readline module: createInterface splits a readable
+// stream into "line" events, and is an async iterator of the lines.
+func Readline(vm *Runtime, module *goja.Object) {
+	_readline := module.Get("exports").(*goja.Object)
+
+	// createInterface(input) or createInterface({ input, output, prompt, crlfDelay })
+	_ = _readline.Set("createInterface", func(call goja.FunctionCall) goja.Value {
+		opts, ok := call.Argument(0).(*goja.Object)
+		if !ok {
+			panic(invalidArgType(vm, "input", "object", call.Argument(0)))
+		}
+		input, output := opts, call.Argument(1)
+		if v := opts.Get("input"); v != nil && !goja.IsUndefined(v) {
+			input, output = v.ToObject(vm.Runtime), opts.Get("output")
+		}
+		prompt := "> "
+		if v := opts.Get("prompt"); v != nil && !goja.IsUndefined(v) {
+			prompt = v.String()
+		}
+		var out *goja.Object
+		if o, ok := output.(*goja.Object); ok {
+			out = o
+		}
+		return newInterface(vm, input, out, prompt)
+	})
+}
+
+// lineReader is a readline Interface.
+type lineReader struct {
+	*emitter
+	input   *goja.Object
+	output  *goja.Object
+	prompt  string
+	partial strings.Builder
+	// sawCR is set when a chunk ended with \r, whose \n may start the next one.
+	sawCR  bool
+	closed bool
+	paused bool
+	// questions are the callbacks of question() waiting for a line.
+	questions []goja.Callable
+}
+
+func newInterface(vm *Runtime, input, output *goja.Object, prompt string) *goja.Object {
+	r := &lineReader{input: input, output: output, prompt: prompt}
+	obj := vm.NewObject()
+	r.emitter = newEmitter(vm, obj)
+
+	onInput := func(event string, fn func(args ...goja.Value)) {
+		on, ok := goja.AssertFunction(input.Get("on"))
+		if !ok {
+			panic(invalidArgType(vm, "input", "stream", input))
+		}
+		if _, err := on(input, vm.ToValue(event), vm.ToValue(func(call goja.FunctionCall) goja.Value {
+			fn(call.Arguments...)
+			return goja.Undefined()
+		})); err != nil {
+			panic(err)
+		}
+	}
+	onInput("data", func(args ...goja.Value) {
+		if len(args) > 0 {
+			r.feed(vm, args[0])
+		}
+	})
+	onInput("end", func(...goja.Value) {
+		if r.partial.Len() > 0 {
+			line := r.partial.String()
+			r.partial.Reset()
+			r.line(line)
+		}
+		r.close()
+	})
+
+	_ = obj.Set("close", func(goja.FunctionCall) goja.Value {
+		r.close()
+		return goja.Undefined()
+	})
+	_ = obj.Set("pause", func(goja.FunctionCall) goja.Value {
+		r.pause()
+		return obj
+	})
+	_ = obj.Set("resume", func(goja.FunctionCall) goja.Value {
+		r.resume()
+		return obj
+	})
+	_ = obj.Set("setPrompt", func(call goja.FunctionCall) goja.Value {
+		r.prompt = call.Argument(0).String()
+		return goja.Undefined()
+	})
+	_ = obj.Set("getPrompt", func(goja.FunctionCall) goja.Value {
+		return vm.ToValue(r.prompt)
+	})
+	_ = obj.Set("prompt", func(goja.FunctionCall) goja.Value {
+		r.write(r.prompt)
+		r.resume()
+		return goja.Undefined()
+	})
+	// question(query[, options], callback) answers callback with the next line
+	_ = obj.Set("question", func(call goja.FunctionCall) goja.Value {
+		callback, ok := goja.AssertFunction(call.Arguments[len(call.Arguments)-1])
+		if len(call.Arguments) < 2 || !ok {
+			panic(invalidArgType(vm, "callback", "function", call.Argument(len(call.Arguments)-1)))
+		}
+		r.write(call.Argument(0).String())
+		r.questions = append(r.questions, callback)
+		r.resume()
+		return goja.Undefined()
+	})
+	// write(data) writes to the output
+	_ = obj.Set("write", func(call goja.FunctionCall) goja.Value {
+		r.write(call.Argument(0).String())
+		return goja.Undefined()
+	})
+	defineGetter(vm, obj, "closed", func() interface{} { return r.closed })
+	defineGetter(vm, obj, "line", func() interface{} { return r.partial.String() })
+	defineGetter(vm, obj, "terminal", func() interface{} { return false })
+	defineGetter(vm, obj, "input", func() interface{} { return input })
+	defineGetter(vm, obj, "output", func() interface{} { return output })
+	setAsyncIterator(vm, obj, func() *goja.Object {
+		return newAsyncIterator(r.emitter, "line", "close", r.pause, r.resume, r.close)
+	})
+
+	r.resume()
+	return obj
+}
+
+// feed splits a chunk of the input into lines. Lines end with \n, \r\n or \r.
+func (r *lineReader) feed(vm *Runtime, chunk goja.Value) {
+	var text string
+	if s, ok := chunk.Export().(string); ok {
+		text = s
+	} else {
+		text = string(buffer.Bytes(vm.Runtime, chunk))
+	}
+	if r.sawCR {
+		text = strings.TrimPrefix(text, "\n")
+		r.sawCR = false
+	}
+	for !r.closed {
+		i := strings.IndexAny(text, "\r\n")
+		if i < 0 {
+			r.partial.WriteString(text)
+			return
+		}
+		r.partial.WriteString(text[:i])
+		line := r.partial.String()
+		r.partial.Reset()
+		if text[i] == '\r' {
+			if i+1 == len(text) {
+				r.sawCR = true
+			} else if text[i+1] == '\n' {
+				i++
+			}
+		}
+		text = text[i+1:]
+		r.line(line)
+	}
+}
+
+// line hands a line to the oldest question, or emits it.
+func (r *lineReader) line(line string) {
+	if len(r.questions) > 0 {
+		callback := r.questions[0]
+		r.questions = r.questions[1:]
+		if _, err := callback(goja.Undefined(), r.vm.ToValue(line)); err != nil {
+			panic(err)
+		}
+		return
+	}
+	r.emit("line", r.vm.ToValue(line))
+}
+
+func (r *lineReader) write(text string) {
+	if r.output == nil || text == "" {
+		return
+	}
+	if write, ok := goja.AssertFunction(r.output.Get("write")); ok {
+		if _, err := write(r.output, r.vm.ToValue(text)); err != nil {
+			panic(err)
+		}
+	}
+}
+
+func (r *lineReader) callInput(method string) {
+	if fn, ok := goja.AssertFunction(r.input.Get(method)); ok {
+		if _, err := fn(r.input); err != nil {
+			panic(err)
+		}
+	}
+}
+
+func (r *lineReader) pause() {
+	if r.paused || r.closed {
+		return
+	}
+	r.paused = true
+	r.callInput("pause")
+	r.emit("pause")
+}
+
+func (r *lineReader) resume() {
+	if !r.paused || r.closed {
+		return
+	}
+	r.paused = false
+	r.callInput("resume")
+	r.emit("resume")
+}
+
+// close stops reading the input and emits "close", once.
+func (r *lineReader) close() {
+	if r.closed {
+		return
+	}
+	r.pause()
+	r.closed = true
+	r.emit("close")
+}
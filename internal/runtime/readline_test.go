@@ -0,0 +1,65 @@
+package runtime
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadline(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "lines.txt"), []byte("one\r\ntwo\n\nthree\rfour"), 0644))
+	originalWd, _ := os.Getwd()
+	require.NoError(t, os.Chdir(dir))
+	t.Cleanup(func() { _ = os.Chdir(originalWd) })
+
+	t.Run("line events", func(t *testing.T) {
+		result := runScript(t, NewRuntime(), dir, "lines.js", `
+			var result = [];
+			const rl = require("readline").createInterface({ input: require("fs").createReadStream("lines.txt", { highWaterMark: 4 }) });
+			rl.on("line", (line) => result.push(line));
+			rl.on("close", () => result.push("closed"));
+		`)
+		require.Equal(t, []interface{}{"one", "two", "", "three", "four", "closed"}, result)
+	})
+
+	t.Run("for await over the lines", func(t *testing.T) {
+		result := runScript(t, NewRuntime(), dir, "iterate.js", `
+			var result = [];
+			(async () => {
+				const rl = require("readline").createInterface({ input: require("fs").createReadStream("lines.txt") });
+				for await (const line of rl) {
+					if (line === "three") break;
+					result.push(line);
+				}
+				result.push(rl.closed);
+			})();
+		`)
+		require.Equal(t, []interface{}{"one", "two", "", true}, result)
+	})
+
+	t.Run("question answers with the next line of stdin", func(t *testing.T) {
+		original := stdin
+		t.Cleanup(func() { stdin = original })
+		stdin = strings.NewReader("Ada\nignored\n")
+
+		result := runScript(t, NewRuntime(), dir, "question.js", `
+			var result = [];
+			const output = { write: (text) => result.push(text) };
+			const rl = require("readline").createInterface({ input: process.stdin, output });
+			rl.question("Name? ", (name) => {
+				result.push("hello " + name);
+				rl.close();
+			});
+		`)
+		require.Equal(t, []interface{}{"Name? ", "hello Ada"}, result)
+	})
+
+	t.Run("input must be a stream", func(t *testing.T) {
+		_, err := NewRuntime().RunString(`require("readline").createInterface({ input: 42 })`)
+		require.ErrorContains(t, err, `The "input" argument must be of type stream`)
+	})
+}
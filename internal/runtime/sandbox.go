@@ -0,0 +1,339 @@
+package runtime
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/dop251/goja"
+)
+
+// Permissions is the capability set a custom command declares with
+// `permissions: { fs: ["./contents"], exec: ["git"], env: ["PRASMOID_*"], process: ["exit"], net: ["api.github.com"], project: ["build"] }`.
+//
+//   - FS lists directories (or files) the fs module may touch, relative to the project root.
+//   - Exec lists executables child_process may run, by name.
+//   - Env lists environment variable names or glob patterns visible in process.env.
+//   - Process lists the process operations allowed: "exit", "kill", "chdir", "umask" and
+//     "setPriority".
+//   - Net lists hosts fetch may connect to and http servers may listen on, as "host" or "host:port".
+//   - Project lists the project operations of the prasmoid module allowed: "metadata", "build",
+//     "link", "install", "i18n", "changeset" and "format".
+//
+// A "*" entry allows everything in its category.
+type Permissions struct {
+	AllowAll bool     `json:"-"`
+	FS       []string `json:"fs"`
+	Exec     []string `json:"exec"`
+	Env      []string `json:"env"`
+	Process  []string `json:"process"`
+	Net      []string `json:"net"`
+	Project  []string `json:"project"`
+}
+
+// PermissionError is thrown into the script when an operation is not permitted.
+type PermissionError struct {
+	Op       string
+	Kind     string
+	Resource string
+}
+
+func (e *PermissionError) Error() string {
+	return fmt.Sprintf("permission denied: %s needs %s access to %q. Declare it with `permissions: { %s: [%q] }` or run the command with --allow-all",
+		e.Op, e.Kind, e.Resource, e.Kind, e.Resource)
+}
+
+// SetPermissions restricts what scripts running in vm may do. Passing nil
+// lifts the restrictions; a runtime without permissions is what the CLI itself
+// uses (e.g. for the config file).
+func SetPermissions(vm *Runtime, perms *Permissions) {
+	vm.permissions = perms
+}
+
+// GetPermissions returns the permissions of vm, or nil if it is unrestricted.
+func GetPermissions(vm *Runtime) *Permissions {
+	return vm.permissions
+}
+
+func (p *Permissions) restricted() bool {
+	return p != nil && !p.AllowAll
+}
+
+// Merge returns the union of both permission sets, where nil contributes nothing.
+// It never returns nil, so the result can be passed to SetPermissions without
+// lifting the sandbox.
+func (p *Permissions) Merge(other *Permissions) *Permissions {
+	merged := &Permissions{}
+	for _, set := range []*Permissions{p, other} {
+		if set == nil {
+			continue
+		}
+		merged.AllowAll = merged.AllowAll || set.AllowAll
+		merged.FS = append(merged.FS, set.FS...)
+		merged.Exec = append(merged.Exec, set.Exec...)
+		merged.Env = append(merged.Env, set.Env...)
+		merged.Process = append(merged.Process, set.Process...)
+		merged.Net = append(merged.Net, set.Net...)
+		merged.Project = append(merged.Project, set.Project...)
+	}
+	return merged
+}
+
+// AllowsPath reports whether the path lies inside one of the permitted fs roots.
+func (p *Permissions) AllowsPath(target string) bool {
+	if !p.restricted() {
+		return true
+	}
+	abs, err := filepath.Abs(target)
+	if err != nil {
+		return false
+	}
+	candidates := []string{abs}
+	resolved, ok := realPath(abs)
+	if !ok {
+		return false
+	}
+	if resolved != abs {
+		candidates = append(candidates, resolved)
+	}
+
+	for _, candidate := range candidates {
+		allowed := false
+		for _, root := range p.FS {
+			if root == "*" {
+				return true
+			}
+			rootAbs, err := filepath.Abs(root)
+			if err != nil {
+				continue
+			}
+			if candidate == rootAbs || strings.HasPrefix(candidate, rootAbs+string(filepath.Separator)) {
+				allowed = true
+				break
+			}
+		}
+		// Every form of the path must be permitted, so symlinks can't escape a root.
+		if !allowed {
+			return false
+		}
+	}
+	return true
+}
+
+// realPath resolves the symlinks of abs. A path that doesn't exist yet, like
+// a file about to be written, resolves through its nearest existing ancestor,
+// so a symlinked directory above it can't lead out of a root. It isn't ok when
+// part of the path exists but can't be resolved, like a dangling symlink.
+func realPath(abs string) (string, bool) {
+	dir, rest := abs, ""
+	for {
+		if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+			return filepath.Join(resolved, rest), true
+		}
+		if _, err := os.Lstat(dir); err == nil {
+			return "", false
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return abs, true
+		}
+		rest = filepath.Join(filepath.Base(dir), rest)
+		dir = parent
+	}
+}
+
+// AllowsExec reports whether the executable may be run.
+func (p *Permissions) AllowsExec(name string) bool {
+	if !p.restricted() {
+		return true
+	}
+	for _, allowed := range p.Exec {
+		if allowed == "*" || allowed == name {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsEnv reports whether the environment variable is visible.
+func (p *Permissions) AllowsEnv(name string) bool {
+	if !p.restricted() {
+		return true
+	}
+	for _, pattern := range p.Env {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsProcess reports whether the process operation ("exit", "kill", "chdir", ...) is allowed.
+func (p *Permissions) AllowsProcess(op string) bool {
+	if !p.restricted() {
+		return true
+	}
+	for _, allowed := range p.Process {
+		if allowed == "*" || allowed == op {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsNet reports whether the host may be connected to (or listened on) at the given port.
+func (p *Permissions) AllowsNet(host, port string) bool {
+	if !p.restricted() {
+		return true
+	}
+	for _, allowed := range p.Net {
+		if allowed == "*" || allowed == host || allowed == net.JoinHostPort(host, port) {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsProject reports whether the project operation (e.g. "build") is allowed.
+func (p *Permissions) AllowsProject(op string) bool {
+	if !p.restricted() {
+		return true
+	}
+	for _, allowed := range p.Project {
+		if allowed == "*" || allowed == op {
+			return true
+		}
+	}
+	return false
+}
+
+// throwPermissionError raises a JS error for a denied operation.
+func throwPermissionError(vm *Runtime, op, kind, resource string) {
+	panic(vm.NewGoError(&PermissionError{Op: op, Kind: kind, Resource: resource}))
+}
+
+// checkFS throws unless every path is accessible to the script.
+func checkFS(vm *Runtime, op string, paths ...string) {
+	perms := GetPermissions(vm)
+	for _, p := range paths {
+		if !perms.AllowsPath(p) {
+			throwPermissionError(vm, op, "fs", p)
+		}
+	}
+}
+
+// checkExec throws unless the executable may be run.
+func checkExec(vm *Runtime, op, name string) {
+	if !GetPermissions(vm).AllowsExec(name) {
+		throwPermissionError(vm, op, "exec", name)
+	}
+}
+
+// checkProcess throws unless the process operation is allowed.
+func checkProcess(vm *Runtime, op string) {
+	if !GetPermissions(vm).AllowsProcess(op) {
+		throwPermissionError(vm, "process."+op, "process", op)
+	}
+}
+
+// checkNet throws unless the host is reachable by the script.
+func checkNet(vm *Runtime, op, host, port string) {
+	if !GetPermissions(vm).AllowsNet(host, port) {
+		throwPermissionError(vm, op, "net", host)
+	}
+}
+
+// checkProject throws unless the project operation is allowed.
+func checkProject(vm *Runtime, op, kind string) {
+	if !GetPermissions(vm).AllowsProject(kind) {
+		throwPermissionError(vm, op, "project", kind)
+	}
+}
+
+// parsePermissions reads a `permissions` object declared by a script.
+func parsePermissions(val goja.Value) *Permissions {
+	perms := &Permissions{}
+	if val == nil || goja.IsUndefined(val) || goja.IsNull(val) {
+		return perms
+	}
+	obj, ok := val.Export().(map[string]interface{})
+	if !ok {
+		panic("prasmoid.Command: 'permissions' must be an object")
+	}
+	list := func(key string) []string {
+		var out []string
+		raw, exists := obj[key]
+		if !exists || raw == nil {
+			return out
+		}
+		items, ok := raw.([]interface{})
+		if !ok {
+			panic(fmt.Sprintf("prasmoid.Command: 'permissions.%s' must be an array of strings", key))
+		}
+		for _, item := range items {
+			str, ok := item.(string)
+			if !ok {
+				panic(fmt.Sprintf("prasmoid.Command: 'permissions.%s' must be an array of strings", key))
+			}
+			out = append(out, str)
+		}
+		return out
+	}
+	perms.FS = list("fs")
+	perms.Exec = list("exec")
+	perms.Env = list("env")
+	perms.Process = list("process")
+	perms.Net = list("net")
+	perms.Project = list("project")
+	return perms
+}
+
+// envObject exposes the environment through a dynamic object, so that
+// variables hidden by the sandbox can't be read or enumerated.
+type envObject struct {
+	vm  *Runtime
+	env map[string]string
+}
+
+func (e *envObject) Get(key string) goja.Value {
+	val, ok := e.env[key]
+	if !ok || !GetPermissions(e.vm).AllowsEnv(key) {
+		return goja.Undefined()
+	}
+	return e.vm.ToValue(val)
+}
+
+func (e *envObject) Set(key string, val goja.Value) bool {
+	if !GetPermissions(e.vm).AllowsEnv(key) {
+		throwPermissionError(e.vm, "process.env", "env", key)
+	}
+	e.env[key] = val.String()
+	return true
+}
+
+func (e *envObject) Has(key string) bool {
+	_, ok := e.env[key]
+	return ok && GetPermissions(e.vm).AllowsEnv(key)
+}
+
+func (e *envObject) Delete(key string) bool {
+	if !GetPermissions(e.vm).AllowsEnv(key) {
+		throwPermissionError(e.vm, "process.env", "env", key)
+	}
+	delete(e.env, key)
+	return true
+}
+
+func (e *envObject) Keys() []string {
+	perms := GetPermissions(e.vm)
+	keys := make([]string, 0, len(e.env))
+	for k := range e.env {
+		if perms.AllowsEnv(k) {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
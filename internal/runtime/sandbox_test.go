@@ -0,0 +1,241 @@
+package runtime
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPermissions(t *testing.T) {
+	t.Run("nil permissions allow everything", func(t *testing.T) {
+		var perms *Permissions
+		assert.True(t, perms.AllowsPath("/etc/passwd"))
+		assert.True(t, perms.AllowsExec("rm"))
+		assert.True(t, perms.AllowsEnv("HOME"))
+		assert.True(t, perms.AllowsProcess("exit"))
+	})
+
+	t.Run("allow all", func(t *testing.T) {
+		perms := &Permissions{AllowAll: true}
+		assert.True(t, perms.AllowsPath("/etc/passwd"))
+		assert.True(t, perms.AllowsExec("rm"))
+	})
+
+	t.Run("paths are limited to the declared roots", func(t *testing.T) {
+		originalWd, _ := os.Getwd()
+		require.NoError(t, os.Chdir(t.TempDir()))
+		defer func() { _ = os.Chdir(originalWd) }()
+
+		perms := &Permissions{FS: []string{"./contents"}}
+		assert.True(t, perms.AllowsPath("contents"))
+		assert.True(t, perms.AllowsPath("./contents/ui/main.qml"))
+		assert.False(t, perms.AllowsPath("./contents-backup"))
+		assert.False(t, perms.AllowsPath("contents/../metadata.json"))
+		assert.False(t, perms.AllowsPath("/etc/passwd"))
+	})
+
+	t.Run("symlinks cannot escape a root", func(t *testing.T) {
+		dir := t.TempDir()
+		outside := t.TempDir()
+		require.NoError(t, os.Symlink(outside, filepath.Join(dir, "escape")))
+
+		require.NoError(t, os.Symlink(filepath.Join(outside, "missing"), filepath.Join(dir, "dangling")))
+
+		perms := &Permissions{FS: []string{dir}}
+		assert.True(t, perms.AllowsPath(filepath.Join(dir, "file")))
+		assert.True(t, perms.AllowsPath(filepath.Join(dir, "new", "file")))
+		assert.False(t, perms.AllowsPath(filepath.Join(dir, "escape")))
+		assert.False(t, perms.AllowsPath(filepath.Join(dir, "escape", "new", "file")), "paths that don't exist yet resolve through their ancestors")
+		assert.False(t, perms.AllowsPath(filepath.Join(dir, "dangling")))
+		assert.False(t, perms.AllowsPath(filepath.Join(dir, "dangling", "file")))
+	})
+
+	t.Run("exec, env and process", func(t *testing.T) {
+		perms := &Permissions{Exec: []string{"git"}, Env: []string{"PRASMOID_*"}, Process: []string{"exit"}}
+		assert.True(t, perms.AllowsExec("git"))
+		assert.False(t, perms.AllowsExec("/tmp/git"))
+		assert.False(t, perms.AllowsExec("rm"))
+		assert.True(t, perms.AllowsEnv("PRASMOID_TOKEN"))
+		assert.False(t, perms.AllowsEnv("HOME"))
+		assert.True(t, perms.AllowsProcess("exit"))
+		assert.False(t, perms.AllowsProcess("kill"))
+	})
+
+	t.Run("net", func(t *testing.T) {
+		perms := &Permissions{Net: []string{"api.github.com", "localhost:8080"}}
+		assert.True(t, perms.AllowsNet("api.github.com", "443"))
+		assert.True(t, perms.AllowsNet("localhost", "8080"))
+		assert.False(t, perms.AllowsNet("localhost", "9090"))
+		assert.False(t, perms.AllowsNet("example.com", "80"))
+	})
+}
+
+func TestSandboxedModules(t *testing.T) {
+	dir := t.TempDir()
+	originalWd, _ := os.Getwd()
+	require.NoError(t, os.Chdir(dir))
+	defer func() { _ = os.Chdir(originalWd) }()
+
+	require.NoError(t, os.MkdirAll("contents", 0755))
+	require.NoError(t, os.WriteFile("contents/main.qml", []byte("Item {}"), 0644))
+	require.NoError(t, os.WriteFile("secret.txt", []byte("secret"), 0644))
+	t.Setenv("PRASMOID_TOKEN", "token")
+	t.Setenv("AWS_SECRET", "hidden")
+
+	vm := NewRuntime()
+	SetPermissions(vm, &Permissions{})
+	defer SetPermissions(vm, nil)
+
+	_, err := vm.RunString(`prasmoid.Command({
+		run: () => {},
+		permissions: { fs: ["./contents"], exec: ["echo"], env: ["PRASMOID_*"] },
+	});`)
+	require.NoError(t, err)
+
+	t.Run("fs inside the declared root", func(t *testing.T) {
+		val, err := vm.RunString(`fs.readFileSync("contents/main.qml")`)
+		require.NoError(t, err)
+		assert.Equal(t, "Item {}", val.String())
+	})
+
+	t.Run("fs outside the declared root", func(t *testing.T) {
+		_, err := vm.RunString(`fs.readFileSync("secret.txt")`)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `permission denied: fs.readFileSync needs fs access to "secret.txt"`)
+
+		_, err = vm.RunString(`fs.rmSync(".", { recursive: true })`)
+		require.Error(t, err)
+		assert.DirExists(t, "contents")
+	})
+
+	t.Run("glob only lists permitted files", func(t *testing.T) {
+		val, err := vm.RunString(`fs.globSync("**/*.{qml,txt}")`)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"contents/main.qml"}, val.Export())
+	})
+
+	t.Run("exec", func(t *testing.T) {
+		val, err := vm.RunString(`child_process.execSync("echo hi")`)
+		require.NoError(t, err)
+		assert.Equal(t, "hi\n", val.String())
+
+		_, err = vm.RunString(`child_process.execSync("rm -rf /tmp/nothing")`)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "needs exec access")
+	})
+
+	t.Run("env", func(t *testing.T) {
+		val, err := vm.RunString(`process.env.PRASMOID_TOKEN`)
+		require.NoError(t, err)
+		assert.Equal(t, "token", val.String())
+
+		val, err = vm.RunString(`[process.env.AWS_SECRET, "AWS_SECRET" in process.env, Object.keys(process.env).includes("AWS_SECRET")]`)
+		require.NoError(t, err)
+		assert.Equal(t, []interface{}{nil, false, false}, val.Export())
+	})
+
+	t.Run("process", func(t *testing.T) {
+		_, err := vm.RunString(`process.exit(3)`)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "process.exit needs process access")
+
+		_, err = vm.RunString(`process.kill(1)`)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "process.kill needs process access")
+	})
+
+	t.Run("top-level code runs without permissions until declared", func(t *testing.T) {
+		vm := NewRuntime()
+		SetPermissions(vm, &Permissions{})
+		defer SetPermissions(vm, nil)
+
+		_, err := vm.RunString(`fs.readFileSync("contents/main.qml")`)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "permission denied")
+	})
+
+	t.Run("require", func(t *testing.T) {
+		commands := filepath.Join(dir, ".prasmoid", "commands")
+		require.NoError(t, os.MkdirAll(commands, 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(commands, "lib.js"), []byte(`module.exports = "lib";`), 0644))
+		require.NoError(t, os.WriteFile("contents/data.json", []byte(`{"name": "data"}`), 0644))
+		require.NoError(t, os.WriteFile("secret.json", []byte(`{"token": "secret"}`), 0644))
+		script := filepath.Join(commands, "deploy.js")
+		require.NoError(t, os.WriteFile(script, []byte(`
+			prasmoid.Command({ run: () => {}, permissions: { fs: ["./contents"] } });
+			globalThis.lib = require("./lib");
+			globalThis.data = require("../../contents/data.json").name;
+		`), 0644))
+
+		vm := NewRuntime()
+		SetPermissions(vm, &Permissions{})
+		src, _ := os.ReadFile(script)
+		_, err := RunFile(vm, script, src)
+		require.NoError(t, err)
+		assert.Equal(t, "lib", vm.Get("lib").String())
+		assert.Equal(t, "data", vm.Get("data").String())
+
+		_, err = vm.RunString(`require(` + strconv.Quote(filepath.Join(dir, "secret.json")) + `).token`)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "permission denied: require needs fs access")
+	})
+
+	t.Run("committed symlinks cannot lead out of a root", func(t *testing.T) {
+		// A contributor's branch can commit links like these into the project
+		outside := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(outside, "secret.txt"), []byte("secret"), 0644))
+		require.NoError(t, os.Symlink(outside, "contents/evil"))
+		require.NoError(t, os.MkdirAll("contents/lib", 0755))
+		require.NoError(t, os.WriteFile("contents/lib/util.js", []byte("// util"), 0644))
+		require.NoError(t, os.Symlink(filepath.Join(outside, "secret.txt"), "contents/lib/leak"))
+
+		vm := NewRuntime()
+		SetPermissions(vm, &Permissions{})
+		_, err := vm.RunString(`prasmoid.Command({ run: () => {}, permissions: { fs: ["./contents"] } });`)
+		require.NoError(t, err)
+
+		for _, script := range []string{
+			`fs.writeFileSync("contents/evil/pwned", "x")`,
+			`fs.appendFileSync("contents/evil/pwned", "x")`,
+			`fs.mkdirSync("contents/evil/pwned")`,
+			`fs.copyFileSync("contents/main.qml", "contents/evil/pwned")`,
+			`fs.renameSync("contents/lib/util.js", "contents/evil/pwned")`,
+			`fs.createWriteStream("contents/evil/pwned")`,
+			`fs.cpSync("contents/lib", "contents/copy")`,
+		} {
+			_, err := vm.RunString(script)
+			require.Error(t, err, script)
+			assert.Contains(t, err.Error(), "permission denied", script)
+		}
+		assert.NoFileExists(t, filepath.Join(outside, "pwned"))
+		assert.NoFileExists(t, "contents/copy/leak")
+		assert.FileExists(t, "contents/lib/util.js")
+	})
+
+	t.Run("invalid permissions", func(t *testing.T) {
+		require.PanicsWithValue(t, "prasmoid.Command: 'permissions.fs' must be an array of strings", func() {
+			_, _ = vm.RunString(`prasmoid.Command({ run: () => {}, permissions: { fs: "./contents" } })`)
+		})
+	})
+}
+
+func TestRuntimesKeepTheirOwnState(t *testing.T) {
+	sandboxed, free := NewRuntime(), NewRuntime()
+	SetPermissions(sandboxed, &Permissions{})
+	assert.NotNil(t, GetPermissions(sandboxed))
+	assert.Nil(t, GetPermissions(free))
+
+	_, err := sandboxed.RunString(`prasmoid.Command({ name: "deploy", run() {} })`)
+	require.NoError(t, err)
+	assert.Len(t, Commands(sandboxed), 1)
+	assert.Empty(t, Commands(free))
+
+	// node: prefixed modules are the same as the others
+	same, err := free.RunString(`require("node:fs") === require("fs")`)
+	require.NoError(t, err)
+	assert.True(t, same.ToBoolean())
+}
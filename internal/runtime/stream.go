@@ -0,0 +1,673 @@
+package runtime
+
+import (
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/dop251/goja"
+	"github.com/dop251/goja_nodejs/buffer"
+)
+
+// Streams are node's Readable and Writable for files, stdio and child
+// processes. The reading and writing happens on goroutines, and the events
+// are emitted on the event loop. Only the flowing mode of readables is
+// supported: "data" events, pipe() and for await.
+
+// defaultHighWaterMark is the chunk size of readables, and how much a
+// writable buffers before write() asks to wait for "drain".
+const defaultHighWaterMark = 64 * 1024
+
+// readStream is a Readable over src.
+type readStream struct {
+	*emitter
+	src    io.Reader
+	closer io.Closer
+	size   int
+	// decoder turns chunks into strings once an encoding is set.
+	decoder *chunkDecoder
+
+	// flowing is nil until the stream starts or is paused explicitly, like
+	// node's readableFlowing.
+	flowing *bool
+	reading bool
+	// opening holds off reading until "open" is emitted.
+	opening   bool
+	ended     bool
+	destroyed bool
+	closed    bool
+	bytesRead int64
+
+	// mu guards hold, the event loop slot a flowing stream keeps while it
+	// waits for data. Pausing gives it up, so that a paused stream doesn't
+	// keep a script from finishing, even with a read blocked on stdin.
+	mu   sync.Mutex
+	hold func(func())
+	// stash is a chunk that arrived while the stream was paused.
+	stash *readResult
+}
+
+type readResult struct {
+	data []byte
+	err  error
+}
+
+// newReadStream creates a Readable reading src in chunks of size bytes. closer,
+// if any, is closed once the stream ends or is destroyed.
+func newReadStream(vm *Runtime, src io.Reader, closer io.Closer, size int) *readStream {
+	if size <= 0 {
+		size = defaultHighWaterMark
+	}
+	s := &readStream{src: src, closer: closer, size: size}
+	obj := vm.NewObject()
+	s.emitter = newEmitter(vm, obj)
+	s.onListen = func(event string) {
+		// Listening to "data" starts a stream that wasn't paused explicitly
+		if event == "data" && s.flowing == nil {
+			s.resume()
+		}
+	}
+
+	_ = obj.Set("pause", func(call goja.FunctionCall) goja.Value {
+		s.pause()
+		return obj
+	})
+	_ = obj.Set("resume", func(call goja.FunctionCall) goja.Value {
+		s.resume()
+		return obj
+	})
+	_ = obj.Set("isPaused", func(call goja.FunctionCall) goja.Value {
+		return vm.ToValue(s.flowing != nil && !*s.flowing)
+	})
+	_ = obj.Set("setEncoding", func(call goja.FunctionCall) goja.Value {
+		s.setEncoding(call.Argument(0).String())
+		return obj
+	})
+
+	// pipe(destination[, { end }]) writes the chunks to destination, waiting
+	// for "drain" when it asks to, and ends it after the last one.
+	_ = obj.Set("pipe", func(call goja.FunctionCall) goja.Value {
+		dest, ok := call.Argument(0).(*goja.Object)
+		if !ok {
+			panic(invalidArgType(vm, "destination", "object", call.Argument(0)))
+		}
+		end := true
+		if opts, ok := call.Argument(1).(*goja.Object); ok {
+			if v := opts.Get("end"); v != nil && !goja.IsUndefined(v) {
+				end = v.ToBoolean()
+			}
+		}
+		write, _ := goja.AssertFunction(dest.Get("write"))
+		s.on("data", func(args ...goja.Value) {
+			ok, err := write(dest, args[0])
+			if err != nil {
+				panic(err)
+			}
+			if !ok.ToBoolean() {
+				s.pause()
+				once, _ := goja.AssertFunction(dest.Get("once"))
+				_, _ = once(dest, vm.ToValue("drain"), vm.ToValue(func(goja.FunctionCall) goja.Value {
+					s.resume()
+					return goja.Undefined()
+				}))
+			}
+		})
+		if end {
+			s.on("end", func(...goja.Value) {
+				if endFn, ok := goja.AssertFunction(dest.Get("end")); ok {
+					if _, err := endFn(dest); err != nil {
+						panic(err)
+					}
+				}
+			})
+		}
+		return dest
+	})
+
+	// destroy([error])
+	_ = obj.Set("destroy", func(call goja.FunctionCall) goja.Value {
+		s.destroy(call.Argument(0))
+		return obj
+	})
+
+	defineGetter(vm, obj, "readable", func() interface{} { return !s.ended && !s.destroyed })
+	defineGetter(vm, obj, "readableEnded", func() interface{} { return s.ended })
+	defineGetter(vm, obj, "destroyed", func() interface{} { return s.destroyed })
+	defineGetter(vm, obj, "bytesRead", func() interface{} { return s.bytesRead })
+	setAsyncIterator(vm, obj, func() *goja.Object {
+		return newAsyncIterator(s.emitter, "data", "end", s.pause, s.resume, func() { s.destroy(goja.Undefined()) })
+	})
+	return s
+}
+
+func (s *readStream) setEncoding(encoding string) {
+	s.decoder = newChunkDecoder(encoding)
+}
+
+func (s *readStream) isFlowing() bool {
+	return s.flowing != nil && *s.flowing
+}
+
+func (s *readStream) pause() {
+	if s.flowing == nil || *s.flowing {
+		paused := false
+		s.flowing = &paused
+		s.release()
+		s.emit("pause")
+	}
+}
+
+func (s *readStream) resume() {
+	if !s.isFlowing() {
+		flowing := true
+		s.flowing = &flowing
+		s.emit("resume")
+	}
+	if stash := s.stash; stash != nil {
+		s.stash = nil
+		done := Schedule(s.vm)
+		go done(func() { s.handle(*stash) })
+		return
+	}
+	s.read()
+}
+
+// release gives up the event loop slot of the stream.
+func (s *readStream) release() {
+	s.mu.Lock()
+	hold := s.hold
+	s.hold = nil
+	s.mu.Unlock()
+	if hold != nil {
+		go hold(func() {})
+	}
+}
+
+// read reads the next chunk on a goroutine, unless the stream is paused.
+func (s *readStream) read() {
+	if s.opening || s.ended || s.destroyed || !s.isFlowing() {
+		return
+	}
+	s.mu.Lock()
+	if s.hold == nil {
+		s.hold = Schedule(s.vm)
+	}
+	s.mu.Unlock()
+	if s.reading {
+		return
+	}
+	s.reading = true
+	go func() {
+		buf := make([]byte, s.size)
+		n, err := s.src.Read(buf)
+		s.mu.Lock()
+		done := s.hold
+		s.hold = nil
+		s.mu.Unlock()
+		if done == nil {
+			// Paused meanwhile, the chunk waits for resume()
+			done = Schedule(s.vm)
+		}
+		done(func() {
+			s.reading = false
+			result := readResult{buf[:n], err}
+			if !s.isFlowing() && !s.destroyed {
+				s.stash = &result
+				return
+			}
+			s.handle(result)
+		})
+	}()
+}
+
+// handle emits the outcome of a read, then reads on.
+func (s *readStream) handle(result readResult) {
+	if s.destroyed {
+		return
+	}
+	if len(result.data) > 0 {
+		s.bytesRead += int64(len(result.data))
+		s.emitFromLoop("data", s.chunk(result.data))
+	}
+	switch err := result.err; {
+	case errors.Is(err, io.EOF) || errors.Is(err, os.ErrClosed):
+		s.end()
+	case err != nil:
+		s.fail(err)
+	default:
+		s.read()
+	}
+}
+
+// chunk is what "data" passes for data: a Buffer, or a string with an encoding.
+func (s *readStream) chunk(data []byte) goja.Value {
+	if s.decoder != nil {
+		return s.vm.ToValue(s.decoder.write(data))
+	}
+	return buffer.WrapBytes(s.vm.Runtime, append([]byte(nil), data...))
+}
+
+func (s *readStream) end() {
+	if rest := s.decoder.flush(); rest != "" {
+		s.emitFromLoop("data", s.vm.ToValue(rest))
+	}
+	s.ended = true
+	s.emitFromLoop("end")
+	s.close()
+}
+
+func (s *readStream) fail(err error) {
+	s.destroyed = true
+	s.emitFromLoop("error", systemError(s.vm, err))
+	s.close()
+}
+
+func (s *readStream) destroy(reason goja.Value) {
+	if s.destroyed {
+		return
+	}
+	s.destroyed = true
+	if reason != nil && !goja.IsUndefined(reason) && !goja.IsNull(reason) {
+		s.emit("error", reason)
+	}
+	s.close()
+}
+
+func (s *readStream) close() {
+	if s.closed {
+		return
+	}
+	s.closed = true
+	s.release()
+	if s.closer != nil {
+		_ = s.closer.Close()
+		s.closer = nil
+	}
+	s.emitFromLoop("close")
+}
+
+// writeStream is a Writable over dst. Writes happen in order on goroutines.
+type writeStream struct {
+	*emitter
+	dst    io.Writer
+	closer io.Closer
+	// last is closed when the latest write is done, the next one waits for it.
+	last chan struct{}
+
+	buffered     int
+	needDrain    bool
+	ending       bool
+	finished     bool
+	destroyed    bool
+	bytesWritten int64
+}
+
+// newWriteStream creates a Writable writing to dst. closer, if any, is closed
+// once the stream ends.
+func newWriteStream(vm *Runtime, dst io.Writer, closer io.Closer) *writeStream {
+	s := &writeStream{dst: dst, closer: closer}
+	obj := vm.NewObject()
+	s.emitter = newEmitter(vm, obj)
+
+	// write(chunk[, encoding][, callback]) => whether to keep writing before "drain"
+	_ = obj.Set("write", func(call goja.FunctionCall) goja.Value {
+		data, callback := writeArgs(vm, call)
+		if s.ending {
+			err := vm.NewTypeError("write after end")
+			_ = err.Set("code", "ERR_STREAM_WRITE_AFTER_END")
+			s.later(func() {
+				if callback != nil {
+					_, _ = callback(goja.Undefined(), err)
+				}
+				s.emitFromLoop("error", err)
+			})
+			return vm.ToValue(false)
+		}
+		return vm.ToValue(s.write(data, callback))
+	})
+
+	// end([chunk][, encoding][, callback])
+	_ = obj.Set("end", func(call goja.FunctionCall) goja.Value {
+		data, callback := writeArgs(vm, call)
+		if data != nil {
+			s.write(data, nil)
+		}
+		s.end(callback)
+		return obj
+	})
+
+	_ = obj.Set("destroy", func(call goja.FunctionCall) goja.Value {
+		if !s.destroyed {
+			s.destroyed = true
+			if reason := call.Argument(0); !goja.IsUndefined(reason) {
+				s.emit("error", reason)
+			}
+			s.end(nil)
+		}
+		return obj
+	})
+
+	defineGetter(vm, obj, "writable", func() interface{} { return !s.ending && !s.destroyed })
+	defineGetter(vm, obj, "writableEnded", func() interface{} { return s.ending })
+	defineGetter(vm, obj, "writableFinished", func() interface{} { return s.finished })
+	defineGetter(vm, obj, "writableLength", func() interface{} { return s.buffered })
+	defineGetter(vm, obj, "writableNeedDrain", func() interface{} { return s.needDrain })
+	defineGetter(vm, obj, "destroyed", func() interface{} { return s.destroyed })
+	defineGetter(vm, obj, "bytesWritten", func() interface{} { return s.bytesWritten })
+	return s
+}
+
+// writeArgs parses the (chunk, encoding, callback) arguments of write and
+// end, where chunk is nil if it's missing.
+func writeArgs(vm *Runtime, call goja.FunctionCall) ([]byte, goja.Callable) {
+	var callback goja.Callable
+	args := call.Arguments
+	if len(args) > 0 {
+		if fn, ok := goja.AssertFunction(args[len(args)-1]); ok {
+			callback, args = fn, args[:len(args)-1]
+		}
+	}
+	if len(args) == 0 || goja.IsUndefined(args[0]) || goja.IsNull(args[0]) {
+		return nil, callback
+	}
+	encoding := goja.Undefined()
+	if len(args) > 1 {
+		encoding = args[1]
+	}
+	return buffer.DecodeBytes(vm.Runtime, args[0], encoding), callback
+}
+
+// after runs job once the writes before it are done, and then callback on
+// the event loop.
+func (s *writeStream) after(job func() error, callback func(err error)) {
+	prev, next := s.last, make(chan struct{})
+	s.last = next
+	done := Schedule(s.vm)
+	go func() {
+		if prev != nil {
+			<-prev
+		}
+		err := job()
+		close(next)
+		done(func() { callback(err) })
+	}()
+}
+
+// later runs callback on the event loop, after the current operation.
+func (s *writeStream) later(callback func()) {
+	s.after(func() error { return nil }, func(error) { callback() })
+}
+
+func (s *writeStream) write(data []byte, callback goja.Callable) bool {
+	s.buffered += len(data)
+	s.after(func() error {
+		_, err := s.dst.Write(data)
+		return err
+	}, func(err error) {
+		s.buffered -= len(data)
+		if err == nil {
+			s.bytesWritten += int64(len(data))
+		}
+		if callback != nil {
+			errVal := goja.Null()
+			if err != nil {
+				errVal = systemError(s.vm, err)
+			}
+			if _, cbErr := callback(goja.Undefined(), errVal); cbErr != nil {
+				reportUncaught(s.vm, cbErr)
+			}
+		}
+		if err != nil {
+			s.emitFromLoop("error", systemError(s.vm, err))
+			return
+		}
+		if s.needDrain && s.buffered == 0 {
+			s.needDrain = false
+			s.emitFromLoop("drain")
+		}
+	})
+	if s.buffered >= defaultHighWaterMark {
+		s.needDrain = true
+	}
+	return !s.needDrain
+}
+
+// end closes the stream after the pending writes, then emits "finish" and "close".
+func (s *writeStream) end(callback goja.Callable) {
+	if s.ending {
+		return
+	}
+	s.ending = true
+	s.after(func() error {
+		if s.closer != nil {
+			return s.closer.Close()
+		}
+		return nil
+	}, func(err error) {
+		if err != nil {
+			s.emitFromLoop("error", systemError(s.vm, err))
+		}
+		s.finished = true
+		if callback != nil {
+			if _, cbErr := callback(goja.Undefined()); cbErr != nil {
+				reportUncaught(s.vm, cbErr)
+			}
+		}
+		s.emitFromLoop("finish")
+		s.emitFromLoop("close")
+	})
+}
+
+// chunkDecoder turns chunks into strings without splitting the characters
+// that straddle two chunks, like node's StringDecoder.
+type chunkDecoder struct {
+	codec    buffer.StringCodec
+	utf8     bool
+	base64   bool
+	leftover []byte
+}
+
+func newChunkDecoder(encoding string) *chunkDecoder {
+	codec := buffer.StringCodecByName(encoding)
+	if codec == nil {
+		codec = buffer.StringCodecByName("utf8")
+	}
+	d := &chunkDecoder{codec: codec}
+	switch encoding {
+	case "base64", "base64url":
+		d.base64 = true
+	case "hex", "latin1", "binary", "ascii":
+	default:
+		d.utf8 = true
+	}
+	return d
+}
+
+func (d *chunkDecoder) write(data []byte) string {
+	data = append(d.leftover, data...)
+	d.leftover = nil
+	keep := 0
+	switch {
+	case d.utf8:
+		// Keep an incomplete character at the end for the next chunk
+		for i := len(data) - 1; i >= 0 && i >= len(data)-utf8.UTFMax; i-- {
+			if utf8.RuneStart(data[i]) {
+				if !utf8.FullRune(data[i:]) {
+					keep = len(data) - i
+				}
+				break
+			}
+		}
+	case d.base64:
+		keep = len(data) % 3
+	}
+	d.leftover = append([]byte(nil), data[len(data)-keep:]...)
+	return d.codec.Encode(data[:len(data)-keep])
+}
+
+// flush returns what's left at the end of the stream. It's fine to call on nil.
+func (d *chunkDecoder) flush() string {
+	if d == nil || len(d.leftover) == 0 {
+		return ""
+	}
+	rest := d.codec.Encode(d.leftover)
+	d.leftover = nil
+	return rest
+}
+
+// defineGetter defines a read-only property computed on access.
+func defineGetter(vm *Runtime, obj *goja.Object, name string, get func() interface{}) {
+	_ = obj.DefineAccessorProperty(name, vm.ToValue(func(goja.FunctionCall) goja.Value {
+		return vm.ToValue(get())
+	}), nil, goja.FLAG_FALSE, goja.FLAG_TRUE)
+}
+
+// setAsyncIterator makes obj usable with for await.
+func setAsyncIterator(vm *Runtime, obj *goja.Object, iterator func() *goja.Object) {
+	symbol, ok := vm.Get("Symbol").ToObject(vm.Runtime).Get("asyncIterator").(*goja.Symbol)
+	if !ok {
+		return
+	}
+	_ = obj.SetSymbol(symbol, func(goja.FunctionCall) goja.Value {
+		return iterator()
+	})
+}
+
+// asyncIteratorBuffer is how many items an async iterator buffers before it
+// pauses its source.
+const asyncIteratorBuffer = 16
+
+// newAsyncIterator iterates over the item events of e until the end event,
+// rejecting on "error". pause and resume apply backpressure, stop is called
+// when a loop breaks early.
+func newAsyncIterator(e *emitter, item, end string, pause, resume, stop func()) *goja.Object {
+	vm := e.vm
+	type waiter struct{ resolve, reject func(interface{}) error }
+	var (
+		queue   []goja.Value
+		waiting []waiter
+		done    bool
+		failure goja.Value
+	)
+	result := func(value goja.Value, done bool) *goja.Object {
+		obj := vm.NewObject()
+		_ = obj.Set("value", value)
+		_ = obj.Set("done", done)
+		return obj
+	}
+
+	e.on(item, func(args ...goja.Value) {
+		value := goja.Undefined()
+		if len(args) > 0 {
+			value = args[0]
+		}
+		if len(waiting) > 0 {
+			w := waiting[0]
+			waiting = waiting[1:]
+			_ = w.resolve(result(value, false))
+			return
+		}
+		queue = append(queue, value)
+		if len(queue) >= asyncIteratorBuffer {
+			pause()
+		}
+	})
+	e.on(end, func(...goja.Value) {
+		done = true
+		for _, w := range waiting {
+			_ = w.resolve(result(goja.Undefined(), true))
+		}
+		waiting = nil
+	})
+	e.on("error", func(args ...goja.Value) {
+		failure = goja.Undefined()
+		if len(args) > 0 {
+			failure = args[0]
+		}
+		for _, w := range waiting {
+			_ = w.reject(failure)
+		}
+		waiting = nil
+	})
+
+	iterator := vm.NewObject()
+	_ = iterator.Set("next", func(goja.FunctionCall) goja.Value {
+		promise, resolve, reject := vm.NewPromise()
+		switch {
+		case len(queue) > 0:
+			value := queue[0]
+			queue = queue[1:]
+			_ = resolve(result(value, false))
+			if len(queue) == 0 && !done {
+				resume()
+			}
+		case failure != nil:
+			_ = reject(failure)
+		case done:
+			_ = resolve(result(goja.Undefined(), true))
+		default:
+			waiting = append(waiting, waiter{resolve, reject})
+			resume()
+		}
+		return vm.ToValue(promise)
+	})
+	_ = iterator.Set("return", func(goja.FunctionCall) goja.Value {
+		if !done {
+			done = true
+			queue = nil
+			stop()
+		}
+		promise, resolve, _ := vm.NewPromise()
+		_ = resolve(result(goja.Undefined(), true))
+		return vm.ToValue(promise)
+	})
+	setAsyncIterator(vm, iterator, func() *goja.Object { return iterator })
+	return iterator
+}
+
+// opened reports the outcome of opening the file of a stream: "open" and
+// "ready", or "error" and "close", on the event loop like node.
+func (s *readStream) opened(path string, file *os.File, err error) {
+	_ = s.obj.Set("path", path)
+	fd := -1
+	if err != nil {
+		s.destroyed = true
+		s.closer = nil
+	} else {
+		fd = int(file.Fd())
+	}
+	s.opening = true
+	done := Schedule(s.vm)
+	go done(func() {
+		s.opening = false
+		if err != nil {
+			s.emitFromLoop("error", systemError(s.vm, err))
+			s.emitFromLoop("close")
+			return
+		}
+		s.emitFromLoop("open", s.vm.ToValue(fd))
+		s.emitFromLoop("ready")
+		s.read()
+	})
+}
+
+func (s *writeStream) opened(path string, file *os.File, err error) {
+	_ = s.obj.Set("path", path)
+	fd := -1
+	if err != nil {
+		s.ending, s.destroyed = true, true
+	} else {
+		fd = int(file.Fd())
+	}
+	s.later(func() {
+		if err != nil {
+			s.emitFromLoop("error", systemError(s.vm, err))
+			s.emitFromLoop("close")
+			return
+		}
+		s.emitFromLoop("open", s.vm.ToValue(fd))
+		s.emitFromLoop("ready")
+	})
+}
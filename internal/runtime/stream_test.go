@@ -0,0 +1,196 @@
+package runtime
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// runScript runs src as the file name in dir, drives the event loop and
+// returns the global "result".
+func runScript(t *testing.T, vm *Runtime, dir, name, src string) interface{} {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	_, err := RunFile(vm, path, []byte(src))
+	require.NoError(t, err)
+	RunEventLoop(vm)
+	require.Empty(t, UnhandledRejections(vm))
+	return vm.Get("result").Export()
+}
+
+func TestStreams(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "input.txt")
+	require.NoError(t, os.WriteFile(input, []byte("héllo wörld"), 0644))
+	originalWd, _ := os.Getwd()
+	require.NoError(t, os.Chdir(dir))
+	t.Cleanup(func() { _ = os.Chdir(originalWd) })
+
+	t.Run("read chunks as strings across characters", func(t *testing.T) {
+		result := runScript(t, NewRuntime(), dir, "read.js", `
+			var result = [];
+			const stream = require("fs").createReadStream("input.txt", { encoding: "utf8", highWaterMark: 2 });
+			stream.on("open", (fd) => result.push(typeof fd));
+			stream.on("data", (chunk) => result.push(chunk));
+			stream.on("end", () => result.push("end " + stream.bytesRead));
+			stream.on("close", () => result.push("close"));
+		`)
+		require.Equal(t, []interface{}{"number", "h", "él", "lo", " w", "ö", "rl", "d", "end 13", "close"}, result)
+	})
+
+	t.Run("ranges and missing files", func(t *testing.T) {
+		result := runScript(t, NewRuntime(), dir, "range.js", `
+			var result = [];
+			const fs = require("fs");
+			fs.createReadStream("input.txt", { start: 7, end: 9 }).on("data", (chunk) => result.push(chunk.toString()));
+			fs.createReadStream("missing.txt").on("error", (err) => result.push(err.code));
+		`)
+		assert.ElementsMatch(t, []interface{}{"wö", "ENOENT"}, result)
+	})
+
+	t.Run("for await reads the chunks", func(t *testing.T) {
+		result := runScript(t, NewRuntime(), dir, "iterate.js", `
+			var result = 0;
+			(async () => {
+				for await (const chunk of require("fs").createReadStream("input.txt", { highWaterMark: 4 })) result += chunk.length;
+			})();
+		`)
+		require.EqualValues(t, 13, result)
+	})
+
+	t.Run("pipe into a write stream", func(t *testing.T) {
+		result := runScript(t, NewRuntime(), dir, "pipe.js", `
+			var result;
+			const fs = require("fs");
+			const out = fs.createWriteStream("copy.txt");
+			out.on("finish", () => { result = fs.readFileSync("copy.txt", "utf8") + " " + out.bytesWritten; });
+			fs.createReadStream("input.txt", { highWaterMark: 3 }).pipe(out);
+		`)
+		require.Equal(t, "héllo wörld 13", result)
+	})
+
+	t.Run("writes keep their order and append", func(t *testing.T) {
+		result := runScript(t, NewRuntime(), dir, "write.js", `
+			var result = [];
+			const fs = require("fs");
+			const out = fs.createWriteStream("log.txt", { flags: "a" });
+			out.write("one ", () => result.push("written"));
+			out.write(Buffer.from("two "));
+			out.end("three", () => result.push(fs.readFileSync("log.txt", "utf8")));
+			out.write("late").valueOf();
+			out.on("error", (err) => result.push(err.code));
+		`)
+		require.Equal(t, []interface{}{"written", "one two three", "ERR_STREAM_WRITE_AFTER_END"}, result)
+	})
+
+	t.Run("process.stdin", func(t *testing.T) {
+		original := stdin
+		t.Cleanup(func() { stdin = original })
+		stdin = strings.NewReader("from stdin")
+
+		result := runScript(t, NewRuntime(), dir, "stdin.js", `
+			var result = "";
+			process.stdin.setEncoding("utf8");
+			process.stdin.on("data", (chunk) => result += chunk);
+		`)
+		require.Equal(t, "from stdin", result)
+	})
+
+	t.Run("an unread stdin doesn't keep the script alive", func(t *testing.T) {
+		r, w, err := os.Pipe()
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = w.Close(); _ = r.Close() })
+		original := stdin
+		t.Cleanup(func() { stdin = original })
+		stdin = r
+
+		result := runScript(t, NewRuntime(), dir, "paused.js", `
+			var result = "started";
+			process.stdin.on("data", () => {});
+			process.stdin.pause();
+		`)
+		require.Equal(t, "started", result)
+	})
+
+	t.Run("listener errors are uncaught", func(t *testing.T) {
+		vm := NewRuntime()
+		_, err := vm.RunString(`require("fs").createReadStream("input.txt").on("data", () => { throw new Error("in listener"); })`)
+		require.NoError(t, err)
+		RunEventLoop(vm)
+		errs := UnhandledRejections(vm)
+		require.Len(t, errs, 1)
+		assert.Contains(t, errs[0].Error(), "Uncaught Error: in listener")
+	})
+}
+
+func TestSpawn(t *testing.T) {
+	dir := t.TempDir()
+
+	t.Run("pipes between processes", func(t *testing.T) {
+		vm := NewRuntime()
+		result := runScript(t, vm, dir, "pipe.js", `
+			var result = [], exited;
+			const { spawn } = require("child_process");
+			const sort = spawn("sort");
+			spawn("printf", ["b\\na\\n"]).stdout.pipe(sort.stdin);
+			sort.stdout.setEncoding("utf8");
+			sort.stdout.on("data", (out) => result.push(out));
+			sort.on("exit", (code, signal) => exited = [code, signal]);
+			sort.on("close", (code) => result.push("close " + code));
+		`)
+		require.Equal(t, []interface{}{"a\nb\n", "close 0"}, result)
+		require.Equal(t, []interface{}{int64(0), nil}, vm.Get("exited").Export())
+	})
+
+	t.Run("shell, env and cwd", func(t *testing.T) {
+		result := runScript(t, NewRuntime(), dir, "shell.js", `
+			var result, out = "";
+			const child = require("child_process").spawn("echo $GREETING $(pwd); exit 3", { shell: true, cwd: "/", env: { GREETING: "hi" } });
+			child.stdout.on("data", (chunk) => out += chunk);
+			child.on("close", (code) => { result = [out, code, child.exitCode]; });
+		`)
+		require.Equal(t, []interface{}{"hi /\n", int64(3), int64(3)}, result)
+	})
+
+	t.Run("sandboxed env and cwd", func(t *testing.T) {
+		t.Setenv("PRASMOID_GREETING", "hi")
+		t.Setenv("AWS_SECRET", "hidden")
+		vm := NewRuntime()
+		SetPermissions(vm, &Permissions{Exec: []string{"sh"}, Env: []string{"PRASMOID_*"}})
+		result := runScript(t, vm, dir, "sandboxed.js", `
+			var result, out = "";
+			const child = require("child_process").spawn("sh", ["-c", "echo $PRASMOID_GREETING-$AWS_SECRET"]);
+			child.stdout.on("data", (chunk) => out += chunk);
+			child.on("close", () => { result = out; });
+		`)
+		require.Equal(t, "hi-\n", result)
+
+		_, err := vm.RunString(`require("child_process").spawn("sh", ["-c", "true"], { cwd: "/" })`)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `permission denied: child_process.spawn needs fs access to "/"`)
+	})
+
+	t.Run("kill", func(t *testing.T) {
+		result := runScript(t, NewRuntime(), dir, "kill.js", `
+			var result;
+			const child = require("child_process").spawn("sleep", ["10"]);
+			child.on("exit", (code, signal) => { result = [code, signal, child.killed]; });
+			child.kill("SIGKILL");
+		`)
+		require.Equal(t, []interface{}{nil, "SIGKILL", true}, result)
+	})
+
+	t.Run("missing commands emit error", func(t *testing.T) {
+		result := runScript(t, NewRuntime(), dir, "missing.js", `
+			var result = [];
+			const child = require("child_process").spawn("prasmoid-no-such-command");
+			child.on("error", (err) => result.push(err.code));
+			child.on("close", (code) => result.push(code));
+		`)
+		require.Equal(t, []interface{}{"ENOENT", int64(-2)}, result)
+	})
+}
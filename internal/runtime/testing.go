@@ -0,0 +1,326 @@
+package runtime
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/dop251/goja"
+)
+
+// suite is a describe() block. The root suite of a file has no name.
+type suite struct {
+	name       string
+	parent     *suite
+	skip, only bool
+	before     []goja.Callable
+	after      []goja.Callable
+}
+
+// TestCase is a test declared with it() in a test file.
+type TestCase struct {
+	Name string
+	// Suites are the describe() blocks around the test, outermost first.
+	Suites []string
+	Skip   bool
+	Only   bool
+	fn     goja.Callable
+	suite  *suite
+}
+
+// FullName is the test's name prefixed with its suites.
+func (t *TestCase) FullName() string {
+	return strings.Join(append(slices.Clone(t.Suites), t.Name), " > ")
+}
+
+// Tests returns the tests declared by the test file that ran in vm.
+func Tests(vm *Runtime) []*TestCase {
+	return vm.tests
+}
+
+// EnableTesting installs the test API in vm: describe, it (or test),
+// beforeEach, afterEach, expect, mock and runCommand. path is the test file,
+// which runCommand resolves scripts against.
+func EnableTesting(vm *Runtime, path string) {
+	global := vm.GlobalObject()
+	current := &suite{}
+
+	declareTest := func(skip, only bool) func(goja.FunctionCall) goja.Value {
+		return func(call goja.FunctionCall) goja.Value {
+			name := call.Argument(0).String()
+			fn, ok := goja.AssertFunction(call.Argument(1))
+			if !ok {
+				panic(vm.NewTypeError("it: %q needs a test function", name))
+			}
+			var suites []string
+			for s := current; s.parent != nil; s = s.parent {
+				suites = append([]string{s.name}, suites...)
+				skip, only = skip || s.skip, only || s.only
+			}
+			vm.tests = append(vm.tests, &TestCase{Name: name, Suites: suites, Skip: skip, Only: only, fn: fn, suite: current})
+			return goja.Undefined()
+		}
+	}
+	declareSuite := func(skip, only bool) func(goja.FunctionCall) goja.Value {
+		return func(call goja.FunctionCall) goja.Value {
+			name := call.Argument(0).String()
+			fn, ok := goja.AssertFunction(call.Argument(1))
+			if !ok {
+				panic(vm.NewTypeError("describe: %q needs a function declaring its tests", name))
+			}
+			parent := current
+			current = &suite{name: name, parent: parent, skip: skip, only: only}
+			defer func() { current = parent }()
+			val, err := fn(goja.Undefined())
+			if err != nil {
+				throw(vm, err)
+			}
+			if _, ok := val.Export().(*goja.Promise); ok {
+				panic(vm.NewTypeError("describe: %q must declare its tests synchronously", name))
+			}
+			return goja.Undefined()
+		}
+	}
+	withVariants := func(declare func(skip, only bool) func(goja.FunctionCall) goja.Value) *goja.Object {
+		fn := vm.ToValue(declare(false, false)).(*goja.Object)
+		_ = fn.Set("skip", declare(true, false))
+		_ = fn.Set("only", declare(false, true))
+		return fn
+	}
+	hook := func(name string, add func(fn goja.Callable)) func(goja.FunctionCall) goja.Value {
+		return func(call goja.FunctionCall) goja.Value {
+			fn, ok := goja.AssertFunction(call.Argument(0))
+			if !ok {
+				panic(vm.NewTypeError("%s needs a function", name))
+			}
+			add(fn)
+			return goja.Undefined()
+		}
+	}
+
+	it := withVariants(declareTest)
+	_ = global.Set("it", it)
+	_ = global.Set("test", it)
+	_ = global.Set("describe", withVariants(declareSuite))
+	_ = global.Set("beforeEach", hook("beforeEach", func(fn goja.Callable) { current.before = append(current.before, fn) }))
+	_ = global.Set("afterEach", hook("afterEach", func(fn goja.Callable) { current.after = append(current.after, fn) }))
+
+	mocks := registerExpect(vm, global)
+	registerMocks(vm, global, mocks)
+	registerRunCommand(vm, global, path)
+
+	// A command calling process.exit would end the whole test run.
+	_ = vm.Get("process").ToObject(vm.Runtime).Set("exit", func(call goja.FunctionCall) goja.Value {
+		panic(assertionError(vm, fmt.Sprintf("process.exit(%d) was called", call.Argument(0).ToInteger())))
+	})
+}
+
+// RunTest runs a test declared in vm with the beforeEach and afterEach hooks
+// of its suites, waiting for the tests that return a Promise.
+func RunTest(vm *Runtime, test *TestCase) error {
+	var suites []*suite
+	for s := test.suite; s != nil; s = s.parent {
+		suites = append([]*suite{s}, suites...)
+	}
+
+	for _, s := range suites {
+		for _, fn := range s.before {
+			if err := callAndWait(vm, fn); err != nil {
+				return err
+			}
+		}
+	}
+	err := callAndWait(vm, test.fn)
+	// afterEach hooks run innermost first, even after a failure.
+	for i := len(suites) - 1; i >= 0; i-- {
+		for _, fn := range suites[i].after {
+			if afterErr := callAndWait(vm, fn); err == nil {
+				err = afterErr
+			}
+		}
+	}
+	// A promise the test forgot to await fails it too.
+	if unhandled := UnhandledRejections(vm); err == nil && len(unhandled) > 0 {
+		err = unhandled[0]
+	}
+	return testFailure(err)
+}
+
+// testFailure describes a failed test with the stack of the JS error, leaving
+// out the frames of the Go functions behind expect and the mocks.
+func testFailure(err error) error {
+	if scriptErr, ok := AsScriptError(err); ok {
+		return scriptErr
+	}
+	return err
+}
+
+// callAndWait calls fn and, when it returns a Promise, runs the event loop until it settles.
+func callAndWait(vm *Runtime, fn goja.Callable, args ...goja.Value) error {
+	_, err := callAndResolve(vm, fn, args...)
+	return err
+}
+
+func callAndResolve(vm *Runtime, fn goja.Callable, args ...goja.Value) (goja.Value, error) {
+	val, err := fn(goja.Undefined(), args...)
+	if err != nil {
+		return nil, err
+	}
+	return Await(vm, val)
+}
+
+// throw rethrows err in JS, keeping the value of JS exceptions.
+func throw(vm *Runtime, err error) {
+	if exception, ok := err.(*goja.Exception); ok {
+		panic(exception)
+	}
+	panic(vm.NewGoError(err))
+}
+
+// assertionError is the error thrown by failed expectations.
+func assertionError(vm *Runtime, message string) *goja.Object {
+	err, _ := vm.New(vm.Get("Error"), vm.ToValue(message))
+	_ = err.Set("name", "AssertionError")
+	return err
+}
+
+// registerRunCommand adds runCommand(script, { name, args, flags, answers }),
+// which loads a command script like the CLI does and runs one of its
+// commands. name is a path such as "release publish"; it defaults to the
+// first command of the script.
+func registerRunCommand(vm *Runtime, global *goja.Object, testPath string) {
+	loaded := map[string][]*CommandConfig{}
+
+	load := func(script string) []*CommandConfig {
+		if !filepath.IsAbs(script) {
+			script = filepath.Join(filepath.Dir(testPath), script)
+		}
+		if commands, ok := loaded[script]; ok {
+			return commands
+		}
+		src, err := os.ReadFile(script)
+		if err != nil {
+			panic(vm.NewGoError(fmt.Errorf("runCommand: %v", err)))
+		}
+		before := len(Commands(vm))
+		if _, err := RunFile(vm, script, src); err != nil {
+			panic(vm.NewGoError(fmt.Errorf("runCommand: error running %s: %v", script, err)))
+		}
+		commands := Commands(vm)[before:]
+		if len(commands) == 0 {
+			panic(vm.NewGoError(fmt.Errorf("runCommand: no command registered in %s", script)))
+		}
+		loaded[script] = commands
+		return commands
+	}
+
+	// find returns the command called name and the permissions it runs with,
+	// those of the command and its parents, as the CLI grants them.
+	find := func(script string, commands []*CommandConfig, name string) (*CommandConfig, *Permissions) {
+		if name == "" {
+			return commands[0], (&Permissions{}).Merge(commands[0].Permissions)
+		}
+		base := strings.TrimSuffix(filepath.Base(script), filepath.Ext(script))
+		var found *CommandConfig
+		perms := &Permissions{}
+		for i, part := range strings.Fields(name) {
+			var next *CommandConfig
+			for _, command := range commands {
+				if command.Name == part || (i == 0 && command.Name == "" && base == part) {
+					next = command
+					break
+				}
+			}
+			if next == nil {
+				panic(vm.NewGoError(fmt.Errorf("runCommand: %s does not register %q", script, name)))
+			}
+			found, commands = next, next.Subcommands
+			perms = perms.Merge(found.Permissions)
+		}
+		return found, perms
+	}
+
+	_ = global.Set("runCommand", func(call goja.FunctionCall) goja.Value {
+		script := call.Argument(0).String()
+		opts, ok := call.Argument(1).(*goja.Object)
+		if !ok {
+			opts = vm.NewObject()
+		}
+		option := func(key string) goja.Value {
+			if val := opts.Get(key); val != nil && !goja.IsUndefined(val) && !goja.IsNull(val) {
+				return val
+			}
+			return nil
+		}
+
+		var name string
+		if val := option("name"); val != nil {
+			name = val.String()
+		}
+		command, perms := find(script, load(script), name)
+		if command.Run == nil {
+			panic(vm.NewTypeError("runCommand: %q has no run function", name))
+		}
+
+		args := vm.NewArray()
+		if val := option("args"); val != nil {
+			args = val.ToObject(vm.Runtime)
+		}
+
+		// Flags start from their defaults, as the CLI would parse them.
+		values := map[string]goja.Value{}
+		for _, flag := range command.Flags {
+			value, err := flag.DefaultValue()
+			if err != nil {
+				panic(vm.NewGoError(fmt.Errorf("runCommand: %v", err)))
+			}
+			if d, ok := value.(time.Duration); ok {
+				value = d.Milliseconds()
+			}
+			values[flag.Name] = vm.ToValue(value)
+		}
+		if val := option("flags"); val != nil {
+			given := val.ToObject(vm.Runtime)
+			for _, key := range given.Keys() {
+				values[key] = given.Get(key)
+			}
+		}
+		flags := vm.NewObject()
+		for key, value := range values {
+			_ = flags.Set(key, value)
+		}
+		_ = flags.Set("get", func(call goja.FunctionCall) goja.Value {
+			if value, ok := values[call.Argument(0).String()]; ok {
+				return value
+			}
+			return goja.Undefined()
+		})
+
+		answers := map[string]string{}
+		if val := option("answers"); val != nil {
+			given := val.ToObject(vm.Runtime)
+			for _, key := range given.Keys() {
+				answers[key] = given.Get(key).String()
+			}
+		}
+		SetAnswers(vm, answers)
+
+		ctx := vm.NewObject()
+		_ = ctx.Set("Args", func(goja.FunctionCall) goja.Value { return args })
+		_ = ctx.Set("Flags", func(goja.FunctionCall) goja.Value { return flags })
+
+		// A sandboxed command runs with its own permissions only, like in the CLI.
+		if previous := GetPermissions(vm); previous.restricted() {
+			SetPermissions(vm, perms)
+			defer SetPermissions(vm, previous)
+		}
+		result, err := callAndResolve(vm, command.Run, ctx)
+		if err != nil {
+			throw(vm, err)
+		}
+		return result
+	})
+}
@@ -0,0 +1,163 @@
+package runtime
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestingRuntime(t *testing.T) *Runtime {
+	t.Helper()
+	vm := NewRuntime()
+	EnableTesting(vm, filepath.Join(t.TempDir(), "cmd.test.js"))
+	return vm
+}
+
+func TestExpect(t *testing.T) {
+	passing := []string{
+		`expect(1).toBe(1)`,
+		`expect({ a: [1, { b: "c" }] }).toEqual({ a: [1, { b: "c" }] })`,
+		`expect([1, 2]).not.toEqual([1, 2, 3])`,
+		`expect("yes").toBeTruthy(); expect(0).toBeFalsy()`,
+		`expect(null).toBeNull(); expect(undefined).toBeUndefined(); expect(0).toBeDefined()`,
+		`expect("prasmoid").toContain("moid"); expect([1, 2]).toContain(2)`,
+		`expect("v1.2.3").toMatch(/^v\d/); expect("abc").toMatch("b")`,
+		`expect([1, 2]).toHaveLength(2)`,
+		`expect(2).toBeGreaterThan(1); expect(2).toBeLessThanOrEqual(2)`,
+		`expect(() => { throw new TypeError("bad input") }).toThrow("bad"); expect(() => {}).not.toThrow()`,
+	}
+	for _, code := range passing {
+		t.Run(code, func(t *testing.T) {
+			_, err := newTestingRuntime(t).RunString(code)
+			assert.NoError(t, err)
+		})
+	}
+
+	failing := map[string]string{
+		`expect(1).toBe(2)`:                                   "AssertionError: expected 1 to be 2",
+		`expect({ a: 1 }).toEqual({ a: 1, b: 2 })`:            `expected { a: 1 } to equal { a: 1, b: 2 }`,
+		`expect("x").not.toBe("x")`:                           `expected "x" not to be "x"`,
+		`expect(() => {}).toThrow()`:                          "expected function to throw",
+		`expect(() => { throw new Error("a") }).toThrow("b")`: `expected function to throw "b", got "a"`,
+		`expect(1).toHaveBeenCalled()`:                        "TypeError: expect(...).toHaveBeenCalled: 1 is not a mock function",
+	}
+	for code, message := range failing {
+		t.Run(code, func(t *testing.T) {
+			_, err := newTestingRuntime(t).RunString(code)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), message)
+		})
+	}
+}
+
+func TestMocks(t *testing.T) {
+	t.Run("mock.fn", func(t *testing.T) {
+		vm := newTestingRuntime(t)
+		_, err := vm.RunString(`
+			const add = mock.fn((a, b) => a + b);
+			expect(add(1, 2)).toBe(3);
+			expect(add).toHaveBeenCalledTimes(1);
+			expect(add).toHaveBeenCalledWith(1, 2);
+			add.mockReturnValue("fixed");
+			expect(add(5, 5)).toBe("fixed");
+			expect(add.calls).toEqual([[1, 2], [5, 5]]);
+			add.mockClear();
+			expect(add).not.toHaveBeenCalled();
+		`)
+		assert.NoError(t, err)
+	})
+
+	t.Run("mock.fs", func(t *testing.T) {
+		vm := newTestingRuntime(t)
+		_, err := vm.RunString(`
+			const files = mock.fs({ "contents/ui/main.qml": "Item {}", "metadata.json": "{}" });
+			expect(fs.readFileSync("./metadata.json")).toBe("{}");
+			expect(fs.readFileSync("missing.txt")).toContain("no such file");
+			expect(fs.existsSync("contents/ui")).toBe(true);
+			expect(fs.readdirSync(".")).toEqual(["contents", "metadata.json"]);
+			fs.writeFileSync("out.txt", "a");
+			fs.appendFileSync("out.txt", "b");
+			expect(files["out.txt"]).toBe("ab");
+			fs.rmSync("contents");
+			expect(fs.existsSync("contents/ui/main.qml")).toBe(false);
+			expect(fs.writeFileSync).toHaveBeenCalledWith("out.txt", "a");
+			expect(fs.statSync("out.txt").isFile()).toBe(true);
+			expect(fs.statSync("out.txt").size).toBe(2);
+			fs.mkdirSync("dist");
+			fs.copyFileSync("out.txt", "dist/a.txt");
+			fs.cpSync("dist", "backup");
+			expect(fs.statSync("backup").isDirectory()).toBe(true);
+			fs.renameSync("backup/a.txt", "backup/b.txt");
+			expect(files["backup/b.txt"]).toBe("ab");
+			expect(files["backup/a.txt"]).toBeUndefined();
+			expect(fs.renameSync("missing.txt", "x.txt")).toContain("no such file");
+			expect(() => fs.statSync("missing.txt")).toThrow("no such file");
+			expect(() => fs.createReadStream("out.txt")).toThrow("mock.fs: fs.createReadStream is not faked");
+		`)
+		assert.NoError(t, err)
+	})
+
+	t.Run("mock.exec", func(t *testing.T) {
+		vm := newTestingRuntime(t)
+		_, err := vm.RunString(`
+			const exec = mock.exec({ "git status": "clean" });
+			expect(require("child_process").execSync("git status")).toBe("clean");
+			expect(() => child_process.execSync("rm -rf /")).toThrow('unexpected command "rm -rf /"');
+			mock.exec((command) => command.toUpperCase());
+			expect(child_process.execSync("echo")).toBe("ECHO");
+		`)
+		assert.NoError(t, err)
+	})
+
+	t.Run("mock.prasmoid", func(t *testing.T) {
+		vm := newTestingRuntime(t)
+		_, err := vm.RunString(`
+			const meta = { KPlugin: { Version: "1.0.0" } };
+			mock.prasmoid({ metadata: meta, config: { commands: { dir: "cmds" } } });
+			expect(prasmoid.getMetadata("Version")).toBe("1.0.0");
+			prasmoid.setMetadata("X-Plasma-API", "6.0", ".");
+			expect(meta["X-Plasma-API"]).toBe("6.0");
+			expect(prasmoid.config.commands.dir).toBe("cmds");
+			prasmoid.i18n.compile({ silent: true });
+			expect(prasmoid.i18n.compile).toHaveBeenCalledWith({ silent: true });
+			expect(prasmoid.build).not.toHaveBeenCalled();
+		`)
+		assert.NoError(t, err)
+	})
+}
+
+func TestRunTest(t *testing.T) {
+	vm := newTestingRuntime(t)
+	_, err := vm.RunString(`
+		const order = [];
+		beforeEach(() => order.push("outer before"));
+		afterEach(() => order.push("outer after"));
+		describe("suite", () => {
+			beforeEach(() => order.push("inner before"));
+			afterEach(() => order.push("inner after"));
+			it("runs hooks around the test", () => order.push("test"));
+			it.skip("skipped", () => {});
+			it("waits for promises", async () => {
+				await Promise.resolve();
+				throw new Error("late failure");
+			});
+		});
+	`)
+	require.NoError(t, err)
+
+	tests := Tests(vm)
+	require.Len(t, tests, 3)
+	assert.Equal(t, "suite > runs hooks around the test", tests[0].FullName())
+	assert.True(t, tests[1].Skip)
+
+	require.NoError(t, RunTest(vm, tests[0]))
+	order, _ := vm.RunString(`order.join(", ")`)
+	assert.Equal(t, "outer before, inner before, test, inner after, outer after", order.String())
+
+	err = RunTest(vm, tests[2])
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "late failure")
+	assert.NotContains(t, err.Error(), "(native)")
+}
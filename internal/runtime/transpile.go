@@ -0,0 +1,291 @@
+package runtime
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/PRASSamin/prasmoid/internal/stack"
+	"github.com/dop251/goja"
+	"github.com/dop251/goja_nodejs/require"
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+// ScriptExtensions lists the file extensions that can be executed as scripts.
+var ScriptExtensions = []string{".js", ".mjs", ".cjs", ".ts", ".mts", ".cts"}
+
+// IsScriptFile reports whether the file has one of the supported script extensions.
+// TypeScript declaration files are not scripts.
+func IsScriptFile(filename string) bool {
+	if strings.HasSuffix(filename, ".d.ts") {
+		return false
+	}
+	ext := filepath.Ext(filename)
+	for _, e := range ScriptExtensions {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+// IsTestFile reports whether the file is a script holding tests, like deploy.test.js.
+func IsTestFile(filename string) bool {
+	if !IsScriptFile(filename) {
+		return false
+	}
+	base := strings.TrimSuffix(filename, filepath.Ext(filename))
+	return filepath.Ext(base) == ".test"
+}
+
+// needsTranspile reports whether the file must go through esbuild before goja can run it.
+// Plain .js/.cjs files are only transpiled when they use ES module syntax or
+// for await, which goja doesn't support.
+func needsTranspile(filename string, src string) bool {
+	switch filepath.Ext(filename) {
+	case ".ts", ".mts", ".cts", ".mjs":
+		return true
+	case ".js", ".cjs":
+		return hasModuleSyntax(src) || strings.Contains(src, "for await")
+	}
+	return false
+}
+
+// hasModuleSyntax is a cheap check for top-level import/export statements.
+func hasModuleSyntax(src string) bool {
+	for _, line := range strings.Split(src, "\n") {
+		if strings.HasPrefix(line, "import ") || strings.HasPrefix(line, "import{") ||
+			strings.HasPrefix(line, "export ") || strings.HasPrefix(line, "export{") {
+			return true
+		}
+	}
+	return false
+}
+
+// Transpile converts TypeScript and ES module sources into CommonJS that goja can run.
+// The returned code carries an inline source map, so runtime errors point at the
+// original file, line and column.
+//
+// Top-level await is supported by wrapping the module body in an async function;
+// the completion value of such a script is the Promise of that function.
+func Transpile(filename string, src string) (string, error) {
+	if !needsTranspile(filename, src) {
+		return src, nil
+	}
+
+	loader := api.LoaderJS
+	if strings.HasSuffix(filepath.Ext(filename), "ts") {
+		loader = api.LoaderTS
+	}
+
+	result := transform(src, filename, loader, api.FormatCommonJS)
+	if len(result.Errors) == 0 {
+		return string(result.Code), nil
+	}
+	if !isTopLevelAwaitError(result.Errors) {
+		return "", transpileError(filename, result.Errors)
+	}
+
+	// Strip the types first and keep the module syntax, then move the body into an
+	// async function and let esbuild turn the remaining imports into require calls.
+	esm := transform(src, filename, loader, api.FormatESModule)
+	if len(esm.Errors) > 0 {
+		return "", transpileError(filename, esm.Errors)
+	}
+
+	cjs := transform(wrapTopLevelAwait(string(esm.Code)), filename, api.LoaderJS, api.FormatCommonJS)
+	if len(cjs.Errors) > 0 {
+		return "", transpileError(filename, cjs.Errors)
+	}
+	return string(cjs.Code), nil
+}
+
+func transform(src, filename string, loader api.Loader, format api.Format) api.TransformResult {
+	return api.Transform(src, api.TransformOptions{
+		Loader:         loader,
+		Format:         format,
+		Target:         api.ES2017,
+		Sourcefile:     filename,
+		Sourcemap:      api.SourceMapInline,
+		SourcesContent: api.SourcesContentExclude,
+		Supported: map[string]bool{
+			"top-level-await": true,
+		},
+	})
+}
+
+func isTopLevelAwaitError(messages []api.Message) bool {
+	for _, msg := range messages {
+		if strings.Contains(msg.Text, "Top-level await") {
+			return true
+		}
+	}
+	return false
+}
+
+// transpileError reports esbuild's messages as a syntax error located at the first of them.
+func transpileError(filename string, messages []api.Message) error {
+	err := &ScriptError{}
+	var lines []string
+	for _, msg := range messages {
+		lines = append(lines, msg.Text)
+		if msg.Location != nil && len(err.Frames) == 0 {
+			err.Frames = []stack.Frame{{File: filename, Line: msg.Location.Line, Column: msg.Location.Column + 1}}
+		}
+	}
+	err.Message = "SyntaxError: " + strings.Join(lines, "\n")
+	if len(err.Frames) == 0 {
+		err.Frames = []stack.Frame{{File: filename}}
+	}
+	return err
+}
+
+// wrapTopLevelAwait moves everything except import declarations into an async
+// function. It relies on esbuild's printer, which emits every top-level statement
+// starting at column zero and nested code indented. Exported declarations lose
+// their export keyword, since nothing can import from a command script.
+//
+// Removed lines are replaced by empty ones so the body keeps its line numbers,
+// which is what the inline source map of the esm input refers to.
+func wrapTopLevelAwait(esm string) string {
+	var imports, body []string
+	var sourceMap string
+	inImport, inExportList := false, false
+
+	for _, line := range strings.Split(esm, "\n") {
+		switch {
+		case strings.HasPrefix(line, "//# sourceMappingURL="):
+			sourceMap = line
+			continue
+		case inImport || strings.HasPrefix(line, "import ") || strings.HasPrefix(line, "import{"):
+			imports = append(imports, line)
+			inImport = !strings.HasSuffix(line, ";")
+			line = ""
+		case inExportList || strings.HasPrefix(line, "export {") || strings.HasPrefix(line, "export{"):
+			inExportList = !strings.HasSuffix(line, ";")
+			line = ""
+		case strings.HasPrefix(line, "export default "):
+			line = "const __default = " + strings.TrimPrefix(line, "export default ")
+		case strings.HasPrefix(line, "export "):
+			line = strings.TrimPrefix(line, "export ")
+		}
+		body = append(body, line)
+	}
+
+	// The imports and the function head share the first line, so every body
+	// line stays where it was.
+	var out strings.Builder
+	out.WriteString(strings.Join(imports, " "))
+	out.WriteString("(async () => {")
+	out.WriteString(strings.Join(body, "\n"))
+	out.WriteString("\n})();\n")
+	if sourceMap != "" {
+		out.WriteString(sourceMap + "\n")
+	}
+	return out.String()
+}
+
+// RunFile transpiles (when needed) and runs a script file in the given runtime.
+// The script is registered under its absolute path, so relative require() calls
+// and stack traces resolve against the file's location.
+func RunFile(vm *Runtime, path string, src []byte) (goja.Value, error) {
+	name := path
+	if abs, err := filepath.Abs(path); err == nil {
+		name = abs
+	}
+	dir := filepath.Dir(name)
+	vm.moduleRoots = append(vm.moduleRoots, dir)
+	if resolved, err := filepath.EvalSymlinks(dir); err == nil && resolved != dir {
+		vm.moduleRoots = append(vm.moduleRoots, resolved)
+	}
+
+	code, err := Transpile(name, string(src))
+	if err != nil {
+		return nil, err
+	}
+
+	val, err := vm.RunScript(name, code)
+	if err != nil {
+		return nil, err
+	}
+
+	// A script using top-level await completes with the Promise of its body,
+	// which may be waiting on the event loop (e.g. for a fetch).
+	if _, ok := val.Export().(*goja.Promise); ok {
+		if _, err := Await(vm, val); err != nil {
+			return nil, err
+		}
+	}
+	return val, nil
+}
+
+// rejectionError turns a rejected value into an error, with the stack of JS errors.
+func rejectionError(reason goja.Value) error {
+	return errorOf(reason, nil)
+}
+
+// loadSource is the SourceLoader of vm. A sandboxed script may only require
+// modules in the directory of a script run with RunFile, or in its fs roots.
+func (vm *Runtime) loadSource(path string) ([]byte, error) {
+	if perms := GetPermissions(vm); perms.restricted() && !vm.inModuleRoot(path) && !perms.AllowsPath(path) {
+		return nil, &PermissionError{Op: "require", Kind: "fs", Resource: path}
+	}
+	resolved, data, err := loadModule(path)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(vm.modules, resolved) {
+		vm.modules = append(vm.modules, resolved)
+	}
+	return data, nil
+}
+
+// Modules returns the files scripts loaded through require() in vm, like
+// helpers and JSON data, in the order they were first loaded.
+func Modules(vm *Runtime) []string {
+	return vm.modules
+}
+
+// inModuleRoot reports whether path lies in the directory of a script run in vm.
+func (vm *Runtime) inModuleRoot(path string) bool {
+	return (&Permissions{FS: vm.moduleRoots}).AllowsPath(path)
+}
+
+// SourceLoader loads modules for require(), transpiling TypeScript and ES module files.
+// A request for "./lib" or "./lib.js" also resolves to lib.ts or lib.mjs if present.
+func SourceLoader(path string) ([]byte, error) {
+	_, data, err := loadModule(path)
+	return data, err
+}
+
+// loadModule is SourceLoader, which also returns the file the module was read from.
+func loadModule(path string) (string, []byte, error) {
+	resolved := path
+	data, err := require.DefaultSourceLoader(path)
+	if errors.Is(err, require.ModuleFileDoesNotExistError) && filepath.Ext(path) == ".js" {
+		base := strings.TrimSuffix(path, ".js")
+		for _, ext := range []string{".ts", ".mts", ".cts", ".mjs"} {
+			if _, statErr := os.Stat(base + ext); statErr == nil {
+				resolved = base + ext
+				data, err = require.DefaultSourceLoader(resolved)
+				break
+			}
+		}
+	}
+	if err != nil {
+		return "", nil, err
+	}
+
+	if !needsTranspile(resolved, string(data)) {
+		return resolved, data, nil
+	}
+
+	code, err := Transpile(resolved, string(data))
+	if err != nil {
+		return "", nil, &fs.PathError{Op: "transpile", Path: resolved, Err: err}
+	}
+	return resolved, []byte(code), nil
+}
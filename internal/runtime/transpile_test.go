@@ -0,0 +1,131 @@
+package runtime
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dop251/goja"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsScriptFile(t *testing.T) {
+	assert.True(t, IsScriptFile("cmd.js"))
+	assert.True(t, IsScriptFile("cmd.ts"))
+	assert.True(t, IsScriptFile("cmd.mjs"))
+	assert.False(t, IsScriptFile("README.md"))
+	assert.False(t, IsScriptFile("prasmoid.d.ts"))
+}
+
+func TestIsTestFile(t *testing.T) {
+	assert.True(t, IsTestFile("deploy.test.js"))
+	assert.True(t, IsTestFile("deploy.test.ts"))
+	assert.False(t, IsTestFile("deploy.js"))
+	assert.False(t, IsTestFile("test.js"))
+	assert.False(t, IsTestFile("deploy.test.md"))
+}
+
+func TestTranspile(t *testing.T) {
+	t.Run("plain js is left untouched", func(t *testing.T) {
+		src := `const prasmoid = require("prasmoid");`
+		code, err := Transpile("cmd.js", src)
+		require.NoError(t, err)
+		assert.Equal(t, src, code)
+	})
+
+	t.Run("typescript is stripped and imports become require", func(t *testing.T) {
+		src := "import { Command } from \"prasmoid\";\nconst n: number = 1;\nCommand({ run: (ctx: any) => n });\n"
+		code, err := Transpile("cmd.ts", src)
+		require.NoError(t, err)
+		assert.Contains(t, code, `require("prasmoid")`)
+		assert.NotContains(t, code, ": number")
+		assert.Contains(t, code, "//# sourceMappingURL=data:application/json;base64,")
+	})
+
+	t.Run("syntax errors report the original location", func(t *testing.T) {
+		_, err := Transpile("broken.ts", "const a: number =;\n")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "broken.ts:1:")
+	})
+}
+
+func TestRunFile(t *testing.T) {
+	dir := t.TempDir()
+
+	t.Run("typescript command with top-level await", func(t *testing.T) {
+		path := filepath.Join(dir, "release.ts")
+		src := `import { Command } from "prasmoid";
+
+interface Release { tag: string }
+
+const release: Release = await Promise.resolve({ tag: "v1.0.0" });
+
+Command({
+  short: release.tag,
+  run: async (ctx: unknown): Promise<void> => {},
+});
+`
+		require.NoError(t, os.WriteFile(path, []byte(src), 0644))
+		vm := NewRuntime()
+		_, err := RunFile(vm, path, []byte(src))
+		require.NoError(t, err)
+		require.Len(t, Commands(vm), 1)
+		assert.Equal(t, "v1.0.0", Commands(vm)[0].Short)
+	})
+
+	t.Run("runtime errors map back to the typescript source", func(t *testing.T) {
+		path := filepath.Join(dir, "throws.ts")
+		src := `type Answer = number;
+
+const answer: Answer = 42;
+
+
+throw new Error("boom " + answer);
+`
+		vm := NewRuntime()
+		_, err := RunFile(vm, path, []byte(src))
+		require.Error(t, err)
+		exc, ok := err.(*goja.Exception)
+		require.True(t, ok)
+		assert.Contains(t, exc.String(), "throws.ts:6:")
+	})
+
+	t.Run("rejections after top-level await are reported", func(t *testing.T) {
+		path := filepath.Join(dir, "rejects.mjs")
+		src := "await Promise.resolve();\nthrow new Error(\"late failure\");\n"
+		vm := NewRuntime()
+		_, err := RunFile(vm, path, []byte(src))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "late failure")
+		assert.Contains(t, err.Error(), "rejects.mjs:2:")
+	})
+
+	t.Run("top-level await waits for the event loop", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(`{"tag":"v2.0.0"}`))
+		}))
+		defer server.Close()
+
+		path := filepath.Join(dir, "latest.mjs")
+		src := "const res = await fetch(\"" + server.URL + "\");\nglobalThis.tag = (await res.json()).tag;\n"
+		vm := NewRuntime()
+		_, err := RunFile(vm, path, []byte(src))
+		require.NoError(t, err)
+		assert.Equal(t, "v2.0.0", vm.Get("tag").String())
+	})
+
+	t.Run("requires typescript helpers relative to the script", func(t *testing.T) {
+		helper := "export function greet(name: string): string { return `hi ${name}`; }\n"
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "helper.ts"), []byte(helper), 0644))
+
+		path := filepath.Join(dir, "main.ts")
+		src := "import { greet } from \"./helper\";\nglobalThis.result = greet(\"there\");\n"
+		vm := NewRuntime()
+		_, err := RunFile(vm, path, []byte(src))
+		require.NoError(t, err)
+		assert.Equal(t, "hi there", vm.Get("result").String())
+	})
+}
@@ -0,0 +1,141 @@
+package runtime
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+
+	"github.com/dop251/goja"
+	"github.com/dop251/goja_nodejs/buffer"
+)
+
+// zlibCodec compresses or decompresses a whole input at once.
+type zlibCodec func(data []byte, level int) ([]byte, error)
+
+func compressWith(newWriter func(w io.Writer, level int) (io.WriteCloser, error)) zlibCodec {
+	return func(data []byte, level int) ([]byte, error) {
+		var out bytes.Buffer
+		w, err := newWriter(&out, level)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(data); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		return out.Bytes(), nil
+	}
+}
+
+func decompressWith(newReader func(r io.Reader) (io.ReadCloser, error)) zlibCodec {
+	return func(data []byte, _ int) ([]byte, error) {
+		r, err := newReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer func() { _ = r.Close() }()
+		return io.ReadAll(r)
+	}
+}
+
+var (
+	gunzip = decompressWith(func(r io.Reader) (io.ReadCloser, error) {
+		return gzip.NewReader(r)
+	})
+	inflate = decompressWith(zlib.NewReader)
+)
+
+var zlibCodecs = map[string]zlibCodec{
+	"gzip": compressWith(func(w io.Writer, level int) (io.WriteCloser, error) {
+		return gzip.NewWriterLevel(w, level)
+	}),
+	"gunzip": gunzip,
+	"deflate": compressWith(func(w io.Writer, level int) (io.WriteCloser, error) {
+		return zlib.NewWriterLevel(w, level)
+	}),
+	"inflate": inflate,
+	"deflateRaw": compressWith(func(w io.Writer, level int) (io.WriteCloser, error) {
+		return flate.NewWriter(w, level)
+	}),
+	"inflateRaw": decompressWith(func(r io.Reader) (io.ReadCloser, error) {
+		return flate.NewReader(r), nil
+	}),
+	// unzip detects gzip or zlib headers, like node's.
+	"unzip": func(data []byte, level int) ([]byte, error) {
+		if len(data) >= 2 && data[0] == 0x1f && data[1] == 0x8b {
+			return gunzip(data, level)
+		}
+		return inflate(data, level)
+	},
+}
+
+// Zlib provides node's one-shot zlib API: gzip/gunzip, deflate/inflate,
+// deflateRaw/inflateRaw and unzip, each as a Sync function returning a Buffer
+// and as a callback function that runs on the event loop.
+func Zlib(vm *Runtime, module *goja.Object) {
+	_zlib := module.Get("exports").(*goja.Object)
+
+	constants := map[string]int{
+		"Z_NO_COMPRESSION":      flate.NoCompression,
+		"Z_BEST_SPEED":          flate.BestSpeed,
+		"Z_BEST_COMPRESSION":    flate.BestCompression,
+		"Z_DEFAULT_COMPRESSION": flate.DefaultCompression,
+	}
+	_ = _zlib.Set("constants", constants)
+
+	level := func(opts goja.Value) int {
+		if obj, ok := opts.(*goja.Object); ok {
+			if l := obj.Get("level"); l != nil && !goja.IsUndefined(l) {
+				return int(l.ToInteger())
+			}
+		}
+		return flate.DefaultCompression
+	}
+
+	for name, codec := range zlibCodecs {
+		// <name>Sync(data, [options]) => Buffer
+		_ = _zlib.Set(name+"Sync", func(call goja.FunctionCall) goja.Value {
+			out, err := codec(buffer.DecodeBytes(vm.Runtime, call.Argument(0), goja.Undefined()), level(call.Argument(1)))
+			if err != nil {
+				panic(vm.NewGoError(err))
+			}
+			return buffer.WrapBytes(vm.Runtime, out)
+		})
+
+		// <name>(data, [options], callback(err, result))
+		_ = _zlib.Set(name, func(call goja.FunctionCall) goja.Value {
+			callback, ok := goja.AssertFunction(call.Argument(len(call.Arguments) - 1))
+			if !ok {
+				panic(vm.NewTypeError("zlib.%s: callback must be a function", name))
+			}
+			data := buffer.DecodeBytes(vm.Runtime, call.Argument(0), goja.Undefined())
+			var opts goja.Value = goja.Undefined()
+			if len(call.Arguments) > 2 {
+				opts = call.Argument(1)
+			}
+			lvl := level(opts)
+
+			done := Schedule(vm)
+			go func() {
+				out, err := codec(data, lvl)
+				done(func() {
+					var cbErr error
+					if err != nil {
+						_, cbErr = callback(goja.Undefined(), vm.NewGoError(err))
+					} else {
+						_, cbErr = callback(goja.Undefined(), goja.Null(), buffer.WrapBytes(vm.Runtime, out))
+					}
+					if cbErr != nil {
+						fmt.Printf("Error in zlib.%s callback: %v\n", name, cbErr)
+					}
+				})
+			}()
+			return goja.Undefined()
+		})
+	}
+}
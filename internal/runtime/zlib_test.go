@@ -0,0 +1,44 @@
+package runtime
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestZlibModule(t *testing.T) {
+	vm := NewRuntime()
+
+	t.Run("sync round trips", func(t *testing.T) {
+		val, err := vm.RunString(`[
+			zlib.gunzipSync(zlib.gzipSync("a")).toString(),
+			zlib.inflateSync(zlib.deflateSync("b")).toString(),
+			zlib.inflateRawSync(zlib.deflateRawSync("c")).toString(),
+			zlib.unzipSync(zlib.gzipSync("d")).toString(),
+			zlib.unzipSync(zlib.deflateSync("e")).toString(),
+			zlib.gzipSync("prasmoid ".repeat(50), { level: 9 }).length < 450,
+		]`)
+		require.NoError(t, err)
+		assert.Equal(t, []interface{}{"a", "b", "c", "d", "e", true}, val.Export())
+	})
+
+	t.Run("invalid input", func(t *testing.T) {
+		_, err := vm.RunString(`zlib.gunzipSync("not gzip")`)
+		require.Error(t, err)
+	})
+
+	t.Run("callbacks run on the event loop", func(t *testing.T) {
+		val, err := await(t, vm, `new Promise((resolve, reject) => {
+			zlib.gzip("async", (err, packed) => {
+				if (err) return reject(err);
+				zlib.gunzip(packed, (err, data) => err ? reject(err) : resolve(data.toString()));
+			});
+		})`)
+		require.NoError(t, err)
+		assert.Equal(t, "async", val.String())
+
+		_, err = await(t, vm, `new Promise((resolve, reject) => zlib.inflate("bad", (err) => err ? reject(err) : resolve()))`)
+		require.Error(t, err)
+	})
+}
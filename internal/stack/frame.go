@@ -0,0 +1,64 @@
+// Package stack describes locations in the stack traces of scripts and shows
+// the code around them. It has no dependencies, so commands that only print
+// locations, like the preview's logs, don't need the script runtime.
+package stack
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Frame is a location in the stack trace of a script error. Scripts that are
+// transpiled carry a source map, so frames point into the original file.
+type Frame struct {
+	Func   string
+	File   string
+	Line   int
+	Column int
+}
+
+func (f Frame) String() string {
+	location := fmt.Sprintf("%s:%d:%d", f.File, f.Line, f.Column)
+	if f.Func == "" {
+		return location
+	}
+	return fmt.Sprintf("%s (%s)", f.Func, location)
+}
+
+// CodeFrame shows the lines around a frame, pointing at its column. It's
+// empty when the file can't be read, e.g. for code typed into the REPL.
+func CodeFrame(frame Frame) string {
+	src, err := os.ReadFile(frame.File)
+	if err != nil {
+		return ""
+	}
+	lines := strings.Split(strings.ReplaceAll(string(src), "\r\n", "\n"), "\n")
+	if frame.Line < 1 || frame.Line > len(lines) {
+		return ""
+	}
+
+	first, last := max(frame.Line-2, 1), min(frame.Line+2, len(lines))
+	width := len(strconv.Itoa(last))
+	var out []string
+	for n := first; n <= last; n++ {
+		marker := "  "
+		if n == frame.Line {
+			marker = "> "
+		}
+		out = append(out, strings.TrimRight(fmt.Sprintf("%s%*d | %s", marker, width, n, lines[n-1]), " "))
+		if n == frame.Line && frame.Column > 0 {
+			// Keep the tabs of the line, so the caret lines up with the code.
+			prefix := []rune(lines[n-1])
+			prefix = prefix[:min(frame.Column-1, len(prefix))]
+			for i, r := range prefix {
+				if r != '\t' {
+					prefix[i] = ' '
+				}
+			}
+			out = append(out, fmt.Sprintf("  %*s | %s^", width, "", string(prefix)))
+		}
+	}
+	return strings.Join(out, "\n")
+}
@@ -0,0 +1,43 @@
+package stack
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFrameString(t *testing.T) {
+	assert.Equal(t, "main.js:3:9", Frame{File: "main.js", Line: 3, Column: 9}.String())
+	assert.Equal(t, "check (main.js:3:9)", Frame{Func: "check", File: "main.js", Line: 3, Column: 9}.String())
+}
+
+func TestCodeFrame(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "main.qml")
+	require.NoError(t, os.WriteFile(path, []byte("Item {\r\n\tText {\r\n\t\ttext: missing\r\n\t}\r\n}\r\n"), 0o644))
+
+	t.Run("points at the column", func(t *testing.T) {
+		assert.Equal(t, ""+
+			"  1 | Item {\n"+
+			"  2 | \tText {\n"+
+			"> 3 | \t\ttext: missing\n"+
+			"    | \t\t      ^\n"+
+			"  4 | \t}\n"+
+			"  5 | }", CodeFrame(Frame{File: path, Line: 3, Column: 9}))
+	})
+
+	t.Run("without a column", func(t *testing.T) {
+		assert.Equal(t, "> 1 | Item {\n  2 | \tText {\n  3 | \t\ttext: missing", CodeFrame(Frame{File: path, Line: 1}))
+	})
+
+	t.Run("lines out of the file", func(t *testing.T) {
+		assert.Empty(t, CodeFrame(Frame{File: path, Line: 0}))
+		assert.Empty(t, CodeFrame(Frame{File: path, Line: 7}))
+	})
+
+	t.Run("files that can't be read", func(t *testing.T) {
+		assert.Empty(t, CodeFrame(Frame{File: filepath.Join(t.TempDir(), "missing.js"), Line: 1}))
+	})
+}
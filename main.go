@@ -16,6 +16,9 @@ import (
 	_ "github.com/PRASSamin/prasmoid/cmd/link"
 	_ "github.com/PRASSamin/prasmoid/cmd/preview"
 	_ "github.com/PRASSamin/prasmoid/cmd/regen"
+	_ "github.com/PRASSamin/prasmoid/cmd/repl"
+	_ "github.com/PRASSamin/prasmoid/cmd/run"
+	_ "github.com/PRASSamin/prasmoid/cmd/screenshot"
 	_ "github.com/PRASSamin/prasmoid/cmd/uninstall"
 	_ "github.com/PRASSamin/prasmoid/cmd/unlink"
 	_ "github.com/PRASSamin/prasmoid/cmd/upgrade"
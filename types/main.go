@@ -3,6 +3,8 @@ package types
 type ConfigCommands struct {
 	Dir    string   `json:"dir"`
 	Ignore []string `json:"ignore"`
+	// Sources are more directories or git repositories to load commands from.
+	Sources []string `json:"sources,omitempty"`
 }
 
 type ConfigI18n struct {
@@ -10,7 +12,37 @@ type ConfigI18n struct {
 	Locales []string `json:"locales"`
 }
 
+// ConfigPreviewProfile is a layout to preview the plasmoid with, e.g. in a
+// panel or on the desktop.
+type ConfigPreviewProfile struct {
+	Containment string `json:"containment,omitempty"`
+	FormFactor  string `json:"formfactor,omitempty"`
+	Location    string `json:"location,omitempty"`
+	Size        string `json:"size,omitempty"`
+}
+
+type ConfigPreview struct {
+	// Watch are the globs, relative to the project, of the files whose changes
+	// restart `prasmoid preview --watch`.
+	Watch []string `json:"watch,omitempty"`
+	// Profiles are layouts picked with `prasmoid preview --profile <name>`.
+	Profiles map[string]ConfigPreviewProfile `json:"profiles,omitempty"`
+}
+
+type ConfigScreenshot struct {
+	// Profiles are the preview profiles `prasmoid screenshot` takes
+	// screenshots with, by default all of them.
+	Profiles []string `json:"profiles,omitempty"`
+	// Locales are the languages to take screenshots in, by default the
+	// current one.
+	Locales []string `json:"locales,omitempty"`
+	// LightDark takes every screenshot with a light and a dark color scheme.
+	LightDark bool `json:"lightDark,omitempty"`
+}
+
 type Config struct {
-	Commands ConfigCommands `json:"commands"`
-	I18n     ConfigI18n     `json:"i18n"`
+	Commands   ConfigCommands   `json:"commands"`
+	I18n       ConfigI18n       `json:"i18n"`
+	Preview    ConfigPreview    `json:"preview,omitempty"`
+	Screenshot ConfigScreenshot `json:"screenshot,omitempty"`
 }